@@ -15,8 +15,9 @@ import (
 )
 
 var (
-	ErrInvalidAdminKey = errors.New("invalid admin key")
-	ErrInvalidToken    = errors.New("invalid token format")
+	ErrInvalidAdminKey  = errors.New("invalid admin key")
+	ErrInvalidServerKey = errors.New("invalid server admin key")
+	ErrInvalidToken     = errors.New("invalid token format")
 )
 
 // GenerateID creates a random hex ID of the specified byte length
@@ -48,6 +49,32 @@ func ValidateAdminKey(pollID, adminKey, salt string) error {
 	return nil
 }
 
+// GenerateWebhookSecret derives the secret a poll's webhooks are signed
+// with. Like the admin key it is never stored, and each poll gets its own
+// so one integrator can't forge another poll's notifications.
+func GenerateWebhookSecret(pollID, salt string) string {
+	h := hmac.New(sha256.New, []byte(salt))
+	h.Write([]byte("webhook:" + pollID))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// SignWebhook returns the hex HMAC-SHA256 of body keyed by secret, which
+// receivers recompute to verify a delivery
+func SignWebhook(secret string, body []byte) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// ValidateServerKey checks the operator key guarding server-wide admin
+// endpoints. When no key is configured every request is rejected.
+func ValidateServerKey(serverKey, configured string) error {
+	if configured == "" || !hmac.Equal([]byte(serverKey), []byte(configured)) {
+		return ErrInvalidServerKey
+	}
+	return nil
+}
+
 // GenerateVoterToken creates a random secure token for a voter
 // This is used to identify voters and allow ballot updates
 func GenerateVoterToken() (string, error) {
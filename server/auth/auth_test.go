@@ -119,6 +119,55 @@ func TestValidateAdminKey(t *testing.T) {
 	}
 }
 
+func TestValidateServerKey(t *testing.T) {
+	tests := []struct {
+		name       string
+		serverKey  string
+		configured string
+		wantErr    bool
+	}{
+		{"matching key", "ops-key", "ops-key", false},
+		{"wrong key", "other-key", "ops-key", true},
+		{"empty key", "", "ops-key", true},
+		{"not configured", "", "", true},
+		{"not configured with key", "ops-key", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateServerKey(tt.serverKey, tt.configured)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateServerKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr && err != ErrInvalidServerKey {
+				t.Errorf("ValidateServerKey() error = %v, want %v", err, ErrInvalidServerKey)
+			}
+		})
+	}
+}
+
+func TestWebhookSignatures(t *testing.T) {
+	secret := GenerateWebhookSecret("poll-1", "webhook-salt")
+	if secret != GenerateWebhookSecret("poll-1", "webhook-salt") {
+		t.Error("GenerateWebhookSecret() should be deterministic")
+	}
+	if secret == GenerateWebhookSecret("poll-2", "webhook-salt") {
+		t.Error("GenerateWebhookSecret() should differ between polls")
+	}
+	if secret == GenerateAdminKey("poll-1", "webhook-salt") {
+		t.Error("GenerateWebhookSecret() should not match the admin key for the same salt")
+	}
+
+	body := []byte(`{"event":"poll.closed"}`)
+	signature := SignWebhook(secret, body)
+	if len(signature) != 64 {
+		t.Errorf("SignWebhook() length = %d, want 64 hex characters", len(signature))
+	}
+	if signature == SignWebhook(secret, []byte(`{"event":"poll.opened"}`)) {
+		t.Error("SignWebhook() should differ for different bodies")
+	}
+}
+
 func TestGenerateVoterToken(t *testing.T) {
 	// Test basic generation
 	token, err := GenerateVoterToken()
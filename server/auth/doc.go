@@ -15,6 +15,21 @@ The key is URL-safe base64 encoded without padding. Since it's deterministic,
 the same poll ID and salt always produce the same key. This allows validation
 without storing the key in the database.
 
+# Server Keys
+
+Server-wide admin endpoints are guarded by a single operator-configured key,
+compared in constant time. An unconfigured key rejects every request:
+
+	err := auth.ValidateServerKey(r.Header.Get("X-Server-Key"), cfg.ServerAdminKey)
+
+# Webhook Signatures
+
+Each poll's webhooks are signed with a secret derived from its ID, so the
+secret is never stored and one poll's receiver can't forge another's:
+
+	secret := auth.GenerateWebhookSecret(pollID, cfg.WebhookSalt)
+	signature := auth.SignWebhook(secret, body) // hex HMAC-SHA256
+
 # Voter Tokens
 
 Voter tokens are random 24-byte (192-bit) secrets:
@@ -4,17 +4,120 @@
 package cliparse
 
 import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"flag"
+	"fmt"
+	"net/url"
 	"os"
+	"regexp"
 	"strconv"
+	"strings"
 )
 
+// DefaultPort is the port the server listens on when none is configured
+const DefaultPort = 3318
+
+// DefaultCORSMaxAge is how long (seconds) browsers may cache a preflight
+const DefaultCORSMaxAge = 600
+
+// DefaultMaxOptions is the most options a poll may offer
+const DefaultMaxOptions = 50
+
+// DefaultDBStatementTimeout is how long (milliseconds) Postgres may run a
+// single statement before cancelling it
+const DefaultDBStatementTimeout = 5000
+
+// DefaultMaxHeaderBytes caps the size of a request's headers, matching
+// net/http's own default
+const DefaultMaxHeaderBytes = 1 << 20
+
+// DefaultResultDigits is how many decimal places GetResults rounds
+// statistics to
+const DefaultResultDigits = 4
+
+// DefaultPollIDBytes and DefaultOptionIDBytes are the random bytes behind
+// poll and option IDs, which are hex-encoded at twice that length
+const (
+	DefaultPollIDBytes   = 16
+	DefaultOptionIDBytes = 12
+)
+
+// MinIDBytes is the shortest configurable poll or option ID. Poll IDs
+// stand in for credentials on some routes, so they must stay unguessable.
+const MinIDBytes = 8
+
+// MaxCloseGracePeriod caps the close grace period, in milliseconds. Every
+// close request holds its connection open that long.
+const MaxCloseGracePeriod = 10000
+
+// DefaultLowSampleThreshold is the fewest scores an option needs before
+// its statistics are no longer flagged low_sample
+const DefaultLowSampleThreshold = 3
+
+// DefaultMinUsernameLength and DefaultMaxUsernameLength bound the
+// usernames voters may claim, in bytes
+const (
+	DefaultMinUsernameLength = 2
+	DefaultMaxUsernameLength = 50
+)
+
+// TrailingSlashRedirect, TrailingSlashRewrite, and TrailingSlashStrict are
+// the ways the router can answer a path that only differs from a route by
+// a trailing slash: a 308 redirect to the route, serving the route
+// directly, or a 404
+const (
+	TrailingSlashRedirect = "redirect"
+	TrailingSlashRewrite  = "rewrite"
+	TrailingSlashStrict   = "strict"
+)
+
+// maxResultDigits is the most decimal places a float64 statistic on the
+// [-1, 1] axis meaningfully carries
+const maxResultDigits = 15
+
 type Config struct {
 	Port         int
 	DatabaseURL  string
 	AdminKeySalt string
 	PollSlugSalt string
+	CORSMaxAge   int    // seconds browsers may cache a preflight response
+	MaxOptions   int    // options per poll, and so scores per ballot; 0 means unlimited
+	BasePath     string // prefix for every route, e.g. "/api/v1"; empty serves at the root
+
+	DBStatementTimeout    int      // milliseconds per statement; 0 keeps the server default
+	ReadDatabaseURL       string   // optional read replica for results and previews
+	ServerAdminKey        string   // guards server-wide /admin endpoints; empty disables them
+	CloseGracePeriod      int      // milliseconds ClosePoll waits before sealing; 0 seals immediately
+	MinBallotInterval     int      // seconds a voter must wait between ballot updates; 0 means unlimited
+	MinPollCreateInterval int      // seconds one device or IP must wait between created polls; 0 means unlimited
+	CORSOrigins           []string // origins allowed cross-origin access; empty allows any
+	CORSCredentials       bool     // send Access-Control-Allow-Credentials; requires CORSOrigins
+	WebhookSalt           string   // signs close webhooks; empty disables them
+	DisableDevices        bool     // keep no device records and turn off the device routes
+	MaxHeaderBytes        int      // request line and headers, read before any handler runs
+	DisableKeepAlives     bool     // stop reusing connections once graceful shutdown begins
+	LogHeaders            bool     // log request headers, with credentials redacted
+	TrustProxyHeaders     bool     // take client addresses from X-Forwarded-For and X-Real-IP, as set by a proxy
+	ServerHeader          string   // Server response header value; empty sends none
+	TrailingSlash         string   // TrailingSlashRedirect, TrailingSlashRewrite, or TrailingSlashStrict; empty means redirect
+	AllowDuplicateOptions bool     // let a poll offer options whose labels differ only in case or spacing
+	ResultDigits          int      // decimal places of statistics in GetResults; 0 keeps full precision
+	MaxBallotsPerIP       int      // ballots per poll from one IP address; 0 means unlimited
+	MaxBallotsPerPoll     int      // ballots one poll may hold; 0 means unlimited
+	MinUsernameLength     int      // shortest username a voter may claim; 0 means DefaultMinUsernameLength
+	MaxUsernameLength     int      // longest username a voter may claim; 0 means DefaultMaxUsernameLength
+	TLSCertFile           string   // PEM certificate chain; with TLSKeyFile, serves HTTPS
+	TLSKeyFile            string   // PEM private key for TLSCertFile
+	PollIDBytes           int      // random bytes per poll ID; 0 means DefaultPollIDBytes
+	OptionIDBytes         int      // random bytes per option ID; 0 means DefaultOptionIDBytes
+	SafeDescriptions      bool     // add an HTML-safe description_safe beside each poll description
+	LowSampleThreshold    int      // scores below which an option's results are flagged low_sample; 0 flags none
+	PollMaxAge            int      // hours after creation an open poll is closed automatically; 0 means no limit
+	PollPurgeAge          int      // hours after closing a poll is deleted automatically; 0 keeps closed polls
 }
 
 // ParseFlags validates flags and sets configuration
@@ -23,13 +126,151 @@ func ParseFlags(args []string) (Config, error) {
 
 	fs := flag.NewFlagSet("quickly-pick", flag.ContinueOnError)
 
+	// Defaults for optional settings come from the environment so CLI
+	// flags still take precedence
+	corsMaxAge, err := envInt("CORS_MAX_AGE", DefaultCORSMaxAge)
+	if err != nil {
+		return Config{}, err
+	}
+	maxOptions, err := envInt("MAX_OPTIONS", DefaultMaxOptions)
+	if err != nil {
+		return Config{}, err
+	}
+	dbStatementTimeout, err := envInt("DB_STATEMENT_TIMEOUT", DefaultDBStatementTimeout)
+	if err != nil {
+		return Config{}, err
+	}
+	corsCredentials, err := envBool("CORS_ALLOW_CREDENTIALS", false)
+	if err != nil {
+		return Config{}, err
+	}
+	maxHeaderBytes, err := envInt("MAX_HEADER_BYTES", DefaultMaxHeaderBytes)
+	if err != nil {
+		return Config{}, err
+	}
+	disableKeepAlives, err := envBool("DISABLE_KEEPALIVES", false)
+	if err != nil {
+		return Config{}, err
+	}
+	logHeaders, err := envBool("LOG_HEADERS", false)
+	if err != nil {
+		return Config{}, err
+	}
+	trustProxyHeaders, err := envBool("TRUST_PROXY_HEADERS", false)
+	if err != nil {
+		return Config{}, err
+	}
+	disableDevices, err := envBool("DISABLE_DEVICE_TRACKING", false)
+	if err != nil {
+		return Config{}, err
+	}
+	closeGracePeriod, err := envInt("CLOSE_GRACE_PERIOD", 0)
+	if err != nil {
+		return Config{}, err
+	}
+	minBallotInterval, err := envInt("MIN_BALLOT_INTERVAL", 0)
+	if err != nil {
+		return Config{}, err
+	}
+	minPollCreateInterval, err := envInt("MIN_POLL_CREATE_INTERVAL", 0)
+	if err != nil {
+		return Config{}, err
+	}
+	allowDuplicateOptions, err := envBool("ALLOW_DUPLICATE_OPTIONS", false)
+	if err != nil {
+		return Config{}, err
+	}
+	resultDigits, err := envInt("RESULT_DIGITS", DefaultResultDigits)
+	if err != nil {
+		return Config{}, err
+	}
+	maxBallotsPerIP, err := envInt("MAX_BALLOTS_PER_IP", 0)
+	if err != nil {
+		return Config{}, err
+	}
+	maxBallotsPerPoll, err := envInt("MAX_BALLOTS_PER_POLL", 0)
+	if err != nil {
+		return Config{}, err
+	}
+	minUsernameLength, err := envInt("MIN_USERNAME_LENGTH", DefaultMinUsernameLength)
+	if err != nil {
+		return Config{}, err
+	}
+	maxUsernameLength, err := envInt("MAX_USERNAME_LENGTH", DefaultMaxUsernameLength)
+	if err != nil {
+		return Config{}, err
+	}
+	pollIDBytes, err := envInt("POLL_ID_BYTES", DefaultPollIDBytes)
+	if err != nil {
+		return Config{}, err
+	}
+	optionIDBytes, err := envInt("OPTION_ID_BYTES", DefaultOptionIDBytes)
+	if err != nil {
+		return Config{}, err
+	}
+	safeDescriptions, err := envBool("SAFE_DESCRIPTIONS", false)
+	if err != nil {
+		return Config{}, err
+	}
+	lowSampleThreshold, err := envInt("LOW_SAMPLE_THRESHOLD", DefaultLowSampleThreshold)
+	if err != nil {
+		return Config{}, err
+	}
+	pollMaxAge, err := envInt("POLL_MAX_AGE", 0)
+	if err != nil {
+		return Config{}, err
+	}
+	pollPurgeAge, err := envInt("POLL_PURGE_AGE", 0)
+	if err != nil {
+		return Config{}, err
+	}
+
 	// Network config
 	fs.IntVar(&cfg.Port, "p", 0, "Server port")
 	fs.StringVar(&cfg.DatabaseURL, "d", "", "Database URL")
+	fs.StringVar(&cfg.ReadDatabaseURL, "read-database-url", os.Getenv("READ_DATABASE_URL"), "Read replica URL for results and previews (optional)")
+	fs.IntVar(&cfg.DBStatementTimeout, "db-statement-timeout", dbStatementTimeout, "Milliseconds before Postgres cancels a statement (0 = server default)")
+	fs.StringVar(&cfg.TLSCertFile, "tls-cert", os.Getenv("TLS_CERT_FILE"), "TLS certificate file; serves HTTPS with tls-key")
+	fs.StringVar(&cfg.TLSKeyFile, "tls-key", os.Getenv("TLS_KEY_FILE"), "TLS private key file; serves HTTPS with tls-cert")
 
 	// Secrets (prefer env variables)
 	fs.StringVar(&cfg.AdminKeySalt, "admin-salt", "", "Admin key salt")
 	fs.StringVar(&cfg.PollSlugSalt, "slug-salt", "", "Poll slug salt")
+	fs.StringVar(&cfg.ServerAdminKey, "server-admin-key", "", "Key for server-wide admin endpoints (optional)")
+	fs.StringVar(&cfg.WebhookSalt, "webhook-salt", "", "Webhook signing salt (optional)")
+
+	// HTTP behavior
+	fs.StringVar(&cfg.BasePath, "base-path", os.Getenv("BASE_PATH"), "Path prefix for all routes (e.g. /api/v1)")
+	fs.StringVar(&cfg.TrailingSlash, "trailing-slash", os.Getenv("TRAILING_SLASH"), "Paths with a trailing slash: redirect, rewrite, or strict (default: redirect)")
+	fs.IntVar(&cfg.ResultDigits, "result-digits", resultDigits, "Decimal places of result statistics (0 = full precision)")
+	fs.IntVar(&cfg.LowSampleThreshold, "low-sample-threshold", lowSampleThreshold, "Scores below which an option's results are flagged low_sample (0 = never)")
+	fs.IntVar(&cfg.CORSMaxAge, "cors-max-age", corsMaxAge, "Seconds browsers may cache CORS preflight responses")
+	fs.IntVar(&cfg.MaxHeaderBytes, "max-header-bytes", maxHeaderBytes, "Maximum bytes of request headers")
+	fs.BoolVar(&cfg.DisableKeepAlives, "disable-keepalives", disableKeepAlives, "Disable keep-alives during graceful shutdown so connections close as their requests finish")
+	fs.BoolVar(&cfg.LogHeaders, "log-headers", logHeaders, "Log request headers, with credentials redacted")
+	fs.BoolVar(&cfg.TrustProxyHeaders, "trust-proxy-headers", trustProxyHeaders, "Take client addresses from X-Forwarded-For and X-Real-IP; only behind a proxy that sets them")
+	fs.StringVar(&cfg.ServerHeader, "server-header", os.Getenv("SERVER_HEADER"), "Server header sent with every response (default: none)")
+	corsOrigins := fs.String("cors-allowed-origins", os.Getenv("CORS_ALLOWED_ORIGINS"), "Comma-separated origins allowed cross-origin access (default: any)")
+	fs.BoolVar(&cfg.CORSCredentials, "cors-allow-credentials", corsCredentials, "Allow credentialed cross-origin requests from cors-allowed-origins")
+	fs.BoolVar(&cfg.SafeDescriptions, "safe-descriptions", safeDescriptions, "Return description_safe, each poll description with HTML tags stripped and escaped")
+
+	// Privacy
+	fs.BoolVar(&cfg.DisableDevices, "disable-device-tracking", disableDevices, "Keep no device records and disable the device endpoints")
+
+	// Poll limits
+	fs.IntVar(&cfg.MaxOptions, "max-options", maxOptions, "Maximum options per poll and scores per ballot (0 = unlimited)")
+	fs.IntVar(&cfg.PollIDBytes, "poll-id-bytes", pollIDBytes, "Random bytes per poll ID")
+	fs.IntVar(&cfg.OptionIDBytes, "option-id-bytes", optionIDBytes, "Random bytes per option ID")
+	fs.BoolVar(&cfg.AllowDuplicateOptions, "allow-duplicate-options", allowDuplicateOptions, "Allow options whose labels differ only in case or spacing")
+	fs.IntVar(&cfg.MinBallotInterval, "min-ballot-interval", minBallotInterval, "Seconds a voter must wait between ballot updates (0 = unlimited)")
+	fs.IntVar(&cfg.MinPollCreateInterval, "min-poll-create-interval", minPollCreateInterval, "Seconds one device or IP must wait between creating polls (0 = unlimited)")
+	fs.IntVar(&cfg.MaxBallotsPerIP, "max-ballots-per-ip", maxBallotsPerIP, "Ballots per poll one IP address may cast (0 = unlimited)")
+	fs.IntVar(&cfg.MaxBallotsPerPoll, "max-ballots-per-poll", maxBallotsPerPoll, "Ballots one poll may hold (0 = unlimited)")
+	fs.IntVar(&cfg.MinUsernameLength, "min-username-length", minUsernameLength, "Shortest username a voter may claim, in bytes")
+	fs.IntVar(&cfg.MaxUsernameLength, "max-username-length", maxUsernameLength, "Longest username a voter may claim, in bytes")
+	fs.IntVar(&cfg.CloseGracePeriod, "close-grace-period", closeGracePeriod, "Milliseconds to keep accepting ballots after a close request before sealing results")
+	fs.IntVar(&cfg.PollMaxAge, "poll-max-age", pollMaxAge, "Hours after creation an open poll is closed automatically (0 = no limit)")
+	fs.IntVar(&cfg.PollPurgeAge, "poll-purge-age", pollPurgeAge, "Hours after closing a poll is deleted automatically (0 = keep)")
 
 	if err := fs.Parse(args); err != nil {
 		return Config{}, err
@@ -44,9 +285,12 @@ func ParseFlags(args []string) (Config, error) {
 			}
 			cfg.Port = port
 		} else {
-			cfg.Port = 3318 // default
+			cfg.Port = DefaultPort
 		}
 	}
+	if cfg.Port < 1 || cfg.Port > 65535 {
+		return Config{}, errors.New("port must be between 1 and 65535")
+	}
 
 	if cfg.DatabaseURL == "" {
 		cfg.DatabaseURL = os.Getenv("DATABASE_URL")
@@ -54,6 +298,19 @@ func ParseFlags(args []string) (Config, error) {
 	if cfg.DatabaseURL == "" {
 		return Config{}, errors.New("DATABASE_URL required")
 	}
+	// Catch a mistyped scheme here rather than deep inside sql.Open
+	databaseURL, err := normalizeDataSourceName("DATABASE_URL", cfg.DatabaseURL)
+	if err != nil {
+		return Config{}, err
+	}
+	cfg.DatabaseURL = databaseURL
+	if cfg.ReadDatabaseURL != "" {
+		readDatabaseURL, err := normalizeDataSourceName("READ_DATABASE_URL", cfg.ReadDatabaseURL)
+		if err != nil {
+			return Config{}, err
+		}
+		cfg.ReadDatabaseURL = readDatabaseURL
+	}
 
 	// Secrets - MUST be provided
 	if cfg.AdminKeySalt == "" {
@@ -70,5 +327,213 @@ func ParseFlags(args []string) (Config, error) {
 		return Config{}, errors.New("POLL_SLUG_SALT required")
 	}
 
+	// Optional; server-wide admin endpoints stay disabled without it
+	if cfg.ServerAdminKey == "" {
+		cfg.ServerAdminKey = os.Getenv("SERVER_ADMIN_KEY")
+	}
+
+	// Optional; polls can't register close webhooks without it
+	if cfg.WebhookSalt == "" {
+		cfg.WebhookSalt = os.Getenv("WEBHOOK_SALT")
+	}
+
+	// Serving half-configured TLS as plain HTTP would be a silent downgrade
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		return Config{}, errors.New("tls-cert and tls-key must be set together")
+	}
+
+	if cfg.MaxHeaderBytes <= 0 {
+		return Config{}, errors.New("max-header-bytes must be positive")
+	}
+	if cfg.CORSMaxAge < 0 {
+		return Config{}, errors.New("cors-max-age cannot be negative")
+	}
+	for _, origin := range strings.Split(*corsOrigins, ",") {
+		origin = strings.TrimSpace(origin)
+		if origin == "" {
+			continue
+		}
+		if origin == "*" {
+			return Config{}, errors.New("cors-allowed-origins cannot contain *; leave it empty to allow any origin")
+		}
+		cfg.CORSOrigins = append(cfg.CORSOrigins, origin)
+	}
+	// Credentials with a wildcard origin are forbidden by the CORS spec
+	if cfg.CORSCredentials && len(cfg.CORSOrigins) == 0 {
+		return Config{}, errors.New("cors-allow-credentials requires cors-allowed-origins")
+	}
+	if cfg.MaxOptions < 0 {
+		return Config{}, errors.New("max-options cannot be negative")
+	}
+	if cfg.DBStatementTimeout < 0 {
+		return Config{}, errors.New("db-statement-timeout cannot be negative")
+	}
+	if cfg.CloseGracePeriod < 0 {
+		return Config{}, errors.New("close-grace-period cannot be negative")
+	}
+	if cfg.CloseGracePeriod > MaxCloseGracePeriod {
+		return Config{}, errors.New("close-grace-period cannot exceed 10000 milliseconds")
+	}
+	if cfg.MinBallotInterval < 0 {
+		return Config{}, errors.New("min-ballot-interval cannot be negative")
+	}
+	if cfg.MinPollCreateInterval < 0 {
+		return Config{}, errors.New("min-poll-create-interval cannot be negative")
+	}
+	if cfg.PollIDBytes < MinIDBytes || cfg.OptionIDBytes < MinIDBytes {
+		return Config{}, errors.New("poll-id-bytes and option-id-bytes must be at least 8")
+	}
+	if cfg.MaxBallotsPerIP < 0 {
+		return Config{}, errors.New("max-ballots-per-ip cannot be negative")
+	}
+	if cfg.MaxBallotsPerPoll < 0 {
+		return Config{}, errors.New("max-ballots-per-poll cannot be negative")
+	}
+	if cfg.MinUsernameLength < 1 {
+		return Config{}, errors.New("min-username-length must be at least 1")
+	}
+	if cfg.MinUsernameLength >= cfg.MaxUsernameLength {
+		return Config{}, errors.New("min-username-length must be less than max-username-length")
+	}
+	if cfg.LowSampleThreshold < 0 {
+		return Config{}, errors.New("low-sample-threshold cannot be negative")
+	}
+	if cfg.PollMaxAge < 0 || cfg.PollPurgeAge < 0 {
+		return Config{}, errors.New("poll-max-age and poll-purge-age cannot be negative")
+	}
+	if cfg.ResultDigits < 0 || cfg.ResultDigits > maxResultDigits {
+		return Config{}, errors.New("result-digits must be between 0 and 15")
+	}
+
+	switch cfg.TrailingSlash {
+	case "":
+		cfg.TrailingSlash = TrailingSlashRedirect
+	case TrailingSlashRedirect, TrailingSlashRewrite, TrailingSlashStrict:
+	default:
+		return Config{}, errors.New("trailing-slash must be redirect, rewrite, or strict")
+	}
+
+	basePath, err := normalizeBasePath(cfg.BasePath)
+	if err != nil {
+		return Config{}, err
+	}
+	cfg.BasePath = basePath
+
 	return cfg, nil
 }
+
+// envInt reads an integer environment variable, returning def when unset
+func envInt(name string, def int) (int, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, errors.New("invalid " + name + " env variable")
+	}
+	return n, nil
+}
+
+// envBool reads a boolean environment variable, returning def when unset
+func envBool(name string, def bool) (bool, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, errors.New("invalid " + name + " env variable")
+	}
+	return b, nil
+}
+
+// normalizeBasePath returns path with a leading slash and no trailing slash,
+// or "" when it names the root
+func normalizeBasePath(path string) (string, error) {
+	path = strings.Trim(strings.TrimSpace(path), "/")
+	if path == "" {
+		return "", nil
+	}
+	if strings.ContainsAny(path, " {}?#") {
+		return "", errors.New("invalid base-path")
+	}
+	return "/" + path, nil
+}
+
+// normalizeDataSourceName checks that dsn, the value of the setting name, is
+// a connection string lib/pq accepts: a postgres:// or postgresql:// URL,
+// whose scheme is lowercased, or a key=value string, returned unchanged.
+// Errors never include dsn, since it may carry a password.
+func normalizeDataSourceName(name, dsn string) (string, error) {
+	if !strings.Contains(dsn, "://") && strings.Contains(dsn, "=") {
+		return dsn, nil
+	}
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return "", fmt.Errorf("%s is not a valid URL", name)
+	}
+	if u.Scheme != "postgres" && u.Scheme != "postgresql" {
+		return "", fmt.Errorf("%s must use the postgres:// or postgresql:// scheme", name)
+	}
+	// url.Parse lowercases the scheme; keep the rest exactly as given
+	return u.Scheme + dsn[len(u.Scheme):], nil
+}
+
+// Redacted returns a copy of cfg that is safe to log. Salts and keys become
+// fingerprints, so a log shows which secrets are set and which share a value
+// without revealing them, and database URLs lose their passwords.
+func (cfg Config) Redacted() Config {
+	cfg.AdminKeySalt = fingerprint(cfg.AdminKeySalt)
+	cfg.PollSlugSalt = fingerprint(cfg.PollSlugSalt)
+	cfg.ServerAdminKey = fingerprint(cfg.ServerAdminKey)
+	cfg.WebhookSalt = fingerprint(cfg.WebhookSalt)
+	cfg.DatabaseURL = redactDataSourceName(cfg.DatabaseURL)
+	cfg.ReadDatabaseURL = redactDataSourceName(cfg.ReadDatabaseURL)
+	return cfg
+}
+
+// fingerprintKey keys fingerprints. It is random per process and never
+// logged, so a fingerprint can't be used to check guesses at a secret
+// offline; the cost is that fingerprints only compare within one process.
+var fingerprintKey = func() []byte {
+	key := make([]byte, 32)
+	rand.Read(key)
+	return key
+}()
+
+// fingerprint identifies a secret by the first bytes of its HMAC-SHA256
+// under fingerprintKey, and leaves an unset one empty
+func fingerprint(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, fingerprintKey)
+	mac.Write([]byte(secret))
+	return "hmac-sha256:" + hex.EncodeToString(mac.Sum(nil)[:4])
+}
+
+// redactedPassword replaces database passwords in logged URLs
+const redactedPassword = "xxxxx"
+
+// dsnPassword matches the value of password in a key=value connection
+// string, quoted or not
+var dsnPassword = regexp.MustCompile(`(?i)(\bpassword\s*=\s*)('(?:[^'\\]|\\.)*'|\S+)`)
+
+// redactDataSourceName hides the password in a postgres:// URL, including a
+// password query parameter, or in a key=value connection string
+func redactDataSourceName(dsn string) string {
+	if strings.Contains(dsn, "://") {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			// No way to tell where the password is, so hide it all
+			return fingerprint(dsn)
+		}
+		if query := u.Query(); query.Has("password") {
+			query.Set("password", redactedPassword)
+			u.RawQuery = query.Encode()
+		}
+		return u.Redacted()
+	}
+	return dsnPassword.ReplaceAllString(dsn, "${1}"+redactedPassword)
+}
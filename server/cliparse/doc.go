@@ -12,17 +12,85 @@ ParseFlags returns a Config struct with all settings:
 
 # Config Fields
 
-  - Port: Server listen port (default: 3318)
+  - Port: Server listen port, 1-65535 (default: DefaultPort, 3318)
   - DatabaseURL: PostgreSQL connection string (required)
+  - ReadDatabaseURL: Read replica for results and previews (default: the primary)
+  - DBStatementTimeout: Milliseconds per SQL statement (default: 5000, 0 = server default)
   - AdminKeySalt: Secret for admin key HMAC (required)
   - PollSlugSalt: Secret for share slug generation (required)
+  - ServerAdminKey: Secret for server-wide /admin endpoints (optional, disabled when empty)
+  - WebhookSalt: Secret for signing close webhooks (optional, disabled when empty)
+  - TLSCertFile, TLSKeyFile: PEM certificate and key; serve HTTPS when both are set (default: plain HTTP)
+  - MaxHeaderBytes: Request header size limit (default: DefaultMaxHeaderBytes, 1 MiB)
+  - DisableKeepAlives: Disable keep-alives while draining on shutdown (default: false, idle connections are left to Shutdown)
+  - LogHeaders: Log request headers with credentials redacted (default: false)
+  - TrustProxyHeaders: Take client addresses from X-Forwarded-For and X-Real-IP; set only behind
+    a proxy that writes them (default: false, the connection's address is used)
+  - ServerHeader: Server header sent with every response (default: none)
+  - CORSMaxAge: Seconds browsers may cache CORS preflights (default: 600)
+  - CORSOrigins: Origins allowed cross-origin access (default: any)
+  - CORSCredentials: Send Access-Control-Allow-Credentials to CORSOrigins (default: false)
+  - MaxOptions: Options per poll and scores per ballot (default: 50, 0 = unlimited)
+  - PollIDBytes, OptionIDBytes: Random bytes per poll and option ID, at least MinIDBytes (8)
+    (default: DefaultPollIDBytes, 16, and DefaultOptionIDBytes, 12; 0 in a hand-built Config means the default)
+  - AllowDuplicateOptions: Accept option labels differing only in case or spacing (default: false)
+  - SafeDescriptions: Add description_safe, an HTML-safe copy of each poll description (default: false)
+  - BasePath: Prefix for all routes, e.g. /api/v1 (default: none)
+  - TrailingSlash: How a route requested with a trailing slash is answered: redirect (308),
+    rewrite (served as the route), or strict (404) (default: redirect; empty in a hand-built Config means redirect)
+  - ResultDigits: Decimal places of result statistics, 0-15 (default: DefaultResultDigits, 4; 0 = full precision)
+  - LowSampleThreshold: Scores below which an option is flagged low_sample (default: DefaultLowSampleThreshold, 3; 0 = never)
+  - DisableDevices: Keep no device records; device routes return 410 (default: false)
+  - CloseGracePeriod: Milliseconds ClosePoll waits before sealing results, at most 10000 (default: 0)
+  - MinBallotInterval: Seconds between one voter's ballot updates (default: 0 = unlimited)
+  - MinPollCreateInterval: Seconds between polls created from one IP, and by one
+    registered device (default: 0 = unlimited)
+  - MaxBallotsPerIP: Ballots per poll from one IP address (default: 0 = unlimited)
+  - MaxBallotsPerPoll: Ballots one poll may hold (default: 0 = unlimited)
+  - MinUsernameLength, MaxUsernameLength: Bytes in a claimed username; polls may narrow them
+    (default: DefaultMinUsernameLength, 2, and DefaultMaxUsernameLength, 50; 0 in a hand-built Config means the default)
+  - PollMaxAge: Hours after creation an open poll is closed automatically (default: 0 = no limit)
+  - PollPurgeAge: Hours after closing a poll and its ballots are deleted (default: 0 = keep forever)
 
 # CLI Flags
 
 	-p, --port        Server port
 	-d, --database-url Database URL
+	--db-statement-timeout Statement timeout in milliseconds
+	--read-database-url Read replica URL
+	--tls-cert        TLS certificate file
+	--tls-key         TLS private key file
 	--admin-salt      Admin key salt
 	--slug-salt       Poll slug salt
+	--server-admin-key Server admin key
+	--webhook-salt    Webhook signing salt
+	--cors-max-age    CORS preflight cache duration in seconds
+	--max-header-bytes Request header size limit
+	--disable-keepalives Disable keep-alives during shutdown
+	--log-headers     Log request headers (credentials redacted)
+	--trust-proxy-headers Trust X-Forwarded-For and X-Real-IP
+	--server-header   Server response header
+	--cors-allowed-origins Comma-separated origin allowlist
+	--cors-allow-credentials Allow credentialed requests
+	--safe-descriptions Return HTML-safe poll descriptions
+	--max-options     Maximum options per poll
+	--poll-id-bytes   Random bytes per poll ID
+	--option-id-bytes Random bytes per option ID
+	--allow-duplicate-options Allow duplicate option labels
+	--base-path       Route prefix
+	--trailing-slash  Trailing slash handling
+	--result-digits   Decimal places of result statistics
+	--low-sample-threshold Scores needed to clear low_sample
+	--close-grace-period Close delay in milliseconds
+	--disable-device-tracking Turn off device records and routes
+	--min-ballot-interval Seconds between ballot updates
+	--min-poll-create-interval Seconds between created polls
+	--max-ballots-per-ip Ballots per poll from one IP
+	--max-ballots-per-poll Ballots one poll may hold
+	--min-username-length Shortest username
+	--max-username-length Longest username
+	--poll-max-age    Hours before open polls expire
+	--poll-purge-age  Hours before closed polls are deleted
 
 # Environment Variables
 
@@ -30,8 +98,41 @@ Flags fall back to environment variables:
 
 	PORT          → -p
 	DATABASE_URL  → -d
+	DB_STATEMENT_TIMEOUT → --db-statement-timeout
+	READ_DATABASE_URL → --read-database-url
+	TLS_CERT_FILE → --tls-cert
+	TLS_KEY_FILE  → --tls-key
 	ADMIN_KEY_SALT → --admin-salt
 	POLL_SLUG_SALT → --slug-salt
+	SERVER_ADMIN_KEY → --server-admin-key
+	WEBHOOK_SALT → --webhook-salt
+	CORS_MAX_AGE  → --cors-max-age
+	MAX_HEADER_BYTES → --max-header-bytes
+	DISABLE_KEEPALIVES → --disable-keepalives
+	LOG_HEADERS   → --log-headers
+	TRUST_PROXY_HEADERS → --trust-proxy-headers
+	SERVER_HEADER → --server-header
+	CORS_ALLOWED_ORIGINS → --cors-allowed-origins
+	CORS_ALLOW_CREDENTIALS → --cors-allow-credentials
+	SAFE_DESCRIPTIONS → --safe-descriptions
+	MAX_OPTIONS   → --max-options
+	POLL_ID_BYTES → --poll-id-bytes
+	OPTION_ID_BYTES → --option-id-bytes
+	ALLOW_DUPLICATE_OPTIONS → --allow-duplicate-options
+	BASE_PATH     → --base-path
+	TRAILING_SLASH → --trailing-slash
+	RESULT_DIGITS → --result-digits
+	LOW_SAMPLE_THRESHOLD → --low-sample-threshold
+	CLOSE_GRACE_PERIOD → --close-grace-period
+	DISABLE_DEVICE_TRACKING → --disable-device-tracking
+	MIN_BALLOT_INTERVAL → --min-ballot-interval
+	MIN_POLL_CREATE_INTERVAL → --min-poll-create-interval
+	MAX_BALLOTS_PER_IP → --max-ballots-per-ip
+	MAX_BALLOTS_PER_POLL → --max-ballots-per-poll
+	MIN_USERNAME_LENGTH → --min-username-length
+	MAX_USERNAME_LENGTH → --max-username-length
+	POLL_MAX_AGE  → --poll-max-age
+	POLL_PURGE_AGE → --poll-purge-age
 
 CLI flags take precedence over environment variables.
 
@@ -39,9 +140,38 @@ CLI flags take precedence over environment variables.
 
 ParseFlags returns an error if required values are missing:
 
-  - DATABASE_URL must be provided
+  - PORT must be between 1 and 65535
+  - MAX_HEADER_BYTES must be positive
+  - RESULT_DIGITS must be between 0 and 15
+  - POLL_ID_BYTES and OPTION_ID_BYTES must be at least 8
+  - MIN_USERNAME_LENGTH must be at least 1 and less than MAX_USERNAME_LENGTH
+  - TRAILING_SLASH must be redirect, rewrite, or strict
+  - DATABASE_URL must be provided, and it and READ_DATABASE_URL must be
+    postgres:// or postgresql:// URLs or key=value connection strings
   - ADMIN_KEY_SALT must be provided
   - POLL_SLUG_SALT must be provided
+  - TLS_CERT_FILE and TLS_KEY_FILE must be set together
+  - CORS_ALLOWED_ORIGINS cannot contain *, and CORS_ALLOW_CREDENTIALS
+    requires it to be set
+  - CORS_MAX_AGE, MAX_OPTIONS, DB_STATEMENT_TIMEOUT, CLOSE_GRACE_PERIOD,
+    MIN_BALLOT_INTERVAL, MIN_POLL_CREATE_INTERVAL, MAX_BALLOTS_PER_IP,
+    MAX_BALLOTS_PER_POLL, LOW_SAMPLE_THRESHOLD, POLL_MAX_AGE, and
+    POLL_PURGE_AGE must not be negative
+
+# Logging
+
+Redacted returns a copy of the Config that is safe to log. AdminKeySalt,
+PollSlugSalt, ServerAdminKey, and WebhookSalt become "hmac-sha256:" and
+the first 8 hex digits of an HMAC keyed with a random per-process key, so
+fingerprints show which secrets match within one run but can't be used to
+guess a secret offline, and don't compare across restarts or instances;
+database URLs keep everything but the password. main.go logs it at startup:
+
+	slog.Info("Configuration loaded", "config", cfg.Redacted())
+
+With LogHeaders set, the router also logs every request's headers.
+Credential headers such as X-Admin-Key are masked by
+middleware.RedactHeaders before they reach the log.
 
 # Example
 
@@ -51,8 +181,8 @@ ParseFlags returns an error if required values are missing:
 		log.Fatal(err)
 	}
 
-	db, err := sql.Open("postgres", cfg.DatabaseURL)
+	conn, err := sql.Open("postgres", db.UTCDataSourceName(cfg.DatabaseURL))
 	// ...
-	mux := router.NewRouter(db, cfg)
+	mux := router.NewRouter(conn, cfg)
 */
 package cliparse
@@ -0,0 +1,59 @@
+// Copyright (c) 2025 Daniel Kuo.
+// Source-available; no permission granted to use, copy, modify, or distribute. See LICENSE.
+
+package db
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// UTCDataSourceName pins the session TimeZone of a lib/pq connection string
+// to UTC, so TIMESTAMPTZ columns scan into time.Time values in time.UTC no
+// matter how the database server is configured. Both URL and key=value
+// connection strings are supported; an explicit timezone is left alone.
+func UTCDataSourceName(dsn string) string {
+	return withDefaultParam(dsn, "timezone", "UTC")
+}
+
+// StatementTimeoutDataSourceName sets the session statement_timeout of a
+// lib/pq connection string, so Postgres cancels any query running longer
+// than timeoutMs even after the client that issued it has gone away.
+// A timeoutMs of zero leaves the server default; an explicit
+// statement_timeout in dsn is left alone.
+func StatementTimeoutDataSourceName(dsn string, timeoutMs int) string {
+	if timeoutMs <= 0 {
+		return dsn
+	}
+	return withDefaultParam(dsn, "statement_timeout", strconv.Itoa(timeoutMs))
+}
+
+// withDefaultParam adds key=value to a URL or key=value connection string
+// unless key is already set. lib/pq sends parameters it does not recognize
+// to the server as session settings.
+func withDefaultParam(dsn, key, value string) string {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			// Let sql.Open report the malformed URL
+			return dsn
+		}
+		q := u.Query()
+		if q.Get(key) == "" {
+			q.Set(key, value)
+			u.RawQuery = q.Encode()
+		}
+		return u.String()
+	}
+
+	for _, field := range strings.Fields(dsn) {
+		if strings.HasPrefix(field, key+"=") {
+			return dsn
+		}
+	}
+	if dsn == "" {
+		return key + "=" + value
+	}
+	return dsn + " " + key + "=" + value
+}
@@ -14,6 +14,43 @@ CreateSchema initializes all required tables:
 
 Safe to call multiple times - uses IF NOT EXISTS for all tables and indexes.
 
+Instances started together migrate one at a time. CreateSchema holds a
+Postgres advisory lock while it applies the schema, and the others wait
+for it before applying theirs. The wait counts against the connection's
+statement_timeout. main calls CreateSchema before it starts listening, so
+no instance serves requests against a schema that is still being applied.
+
+# Schema Version
+
+The schema is an ordered list of steps, step N bringing it to version N.
+CreateSchema runs each step not yet recorded in schema_migrations in its
+own transaction together with the row recording it, so a version is only
+recorded once its DDL has succeeded; if a step fails, the versions before
+it stay recorded and the ones after it are not applied.
+AppliedSchemaVersion reads back the highest one, 0 for databases that
+predate the table, so a running instance can tell whether the database
+has been brought up to the schema it expects:
+
+	applied, err := db.AppliedSchemaVersion(ctx, conn)
+	if applied < db.SchemaVersion {
+		// outdated schema
+	}
+
+# Timestamps
+
+All timestamp columns are TIMESTAMPTZ and handlers write time.Now().UTC().
+Open connections through UTCDataSourceName so the session TimeZone is UTC
+and scanned values come back in time.UTC:
+
+	conn, err := sql.Open("postgres", db.UTCDataSourceName(cfg.DatabaseURL))
+
+# Statement Timeout
+
+StatementTimeoutDataSourceName sets the session statement_timeout, so
+Postgres cancels runaway queries even after the HTTP client disconnects:
+
+	dsn := db.StatementTimeoutDataSourceName(db.UTCDataSourceName(cfg.DatabaseURL), cfg.DBStatementTimeout)
+
 # Tables
 
 The schema includes:
@@ -24,8 +61,12 @@ The schema includes:
   - ballot: One ballot per voter per poll
   - score: Individual option scores (0-1)
   - result_snapshot: Immutable BMJ results
+  - admin_action: Audit log of admin actions per poll
+  - idempotency_key: Idempotency-Key values that created polls, per device or IP
+  - poll_creation: When each device or IP last created a poll
   - device: Registered devices
   - device_poll: Links devices to polls
+  - schema_migrations: Schema versions applied to the database
 
 # Relationships
 
@@ -34,6 +75,8 @@ The schema includes:
 	poll 1──* ballot
 	ballot 1──* score
 	poll 1──* result_snapshot
+	poll 1──* admin_action
+	poll 1──* idempotency_key
 	device *──* poll (via device_poll)
 
 All foreign keys use ON DELETE CASCADE.
@@ -43,11 +86,15 @@ All foreign keys use ON DELETE CASCADE.
 Performance indexes on:
 
   - poll.share_slug (unique)
+  - poll.vanity_slug (unique)
   - poll.status
   - option.poll_id
   - ballot.poll_id
   - ballot.(poll_id, voter_token)
   - score.option_id
+  - admin_action.(poll_id, created_at)
+  - idempotency_key.(scope, key) (primary key)
+  - poll_creation.scope (primary key)
   - device.device_uuid (unique)
 */
 package db
@@ -4,22 +4,134 @@
 package db
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"fmt"
+
+	"github.com/lib/pq"
 )
 
+// SchemaVersion is the schema this build creates, one per step in
+// migrations. Bump it with every step added, so readiness checks can spot
+// instances running against a database that hasn't been brought up to date.
+const SchemaVersion = 14
+
+// schemaLockName keys the advisory lock CreateSchema holds while it
+// migrates
+const schemaLockName = "quickly-pick schema"
+
 // CreateSchema creates all tables needed for the application.
-// Safe to call multiple times - uses IF NOT EXISTS.
+// Safe to call multiple times - applies only the steps not yet recorded,
+// and every step is safe to rerun anyway. Instances starting
+// together take turns: each holds a Postgres advisory lock while it
+// migrates, so none sees another's schema half applied.
 func CreateSchema(db *sql.DB) error {
-	_, err := db.Exec(schema)
+	ctx := context.Background()
+
+	// Session advisory locks belong to one connection, so pin it
+	conn, err := db.Conn(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to create schema: %w", err)
+		return fmt.Errorf("failed to get schema connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock(hashtext($1))`, schemaLockName); err != nil {
+		return fmt.Errorf("failed to lock schema: %w", err)
+	}
+
+	err = createSchema(ctx, conn)
+
+	// The connection goes back to the pool, so the lock must not go with
+	// it; if unlocking fails, discard the connection to release the lock
+	if _, unlockErr := conn.ExecContext(ctx, `SELECT pg_advisory_unlock(hashtext($1))`, schemaLockName); unlockErr != nil {
+		conn.Raw(func(any) error { return driver.ErrBadConn })
+		if err == nil {
+			err = fmt.Errorf("failed to unlock schema: %w", unlockErr)
+		}
 	}
+	return err
+}
 
+// createSchema applies each migration not yet recorded on conn, in order
+func createSchema(ctx context.Context, conn *sql.Conn) error {
+	return applyMigrations(ctx, conn, migrations)
+}
+
+// applyMigrations runs steps in order, step i bringing the schema to version
+// i+1. Each runs in its own transaction with the row recording its version,
+// so a version is only recorded once its DDL has succeeded, and a failed
+// step leaves the earlier ones recorded and the later ones unapplied.
+func applyMigrations(ctx context.Context, conn *sql.Conn, steps []string) error {
+	_, err := conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	for i, ddl := range steps {
+		if err := applyMigration(ctx, conn, i+1, ddl); err != nil {
+			return fmt.Errorf("failed to apply schema version %d: %w", i+1, err)
+		}
+	}
 	return nil
 }
 
-const schema = `
+// applyMigration runs ddl and records version, unless version is already
+// recorded
+func applyMigration(ctx context.Context, conn *sql.Conn, version int, ddl string) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO schema_migrations (version) VALUES ($1)
+		ON CONFLICT (version) DO NOTHING
+	`, version)
+	if err != nil {
+		return err
+	}
+	if recorded, err := result.RowsAffected(); err != nil || recorded == 0 {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, ddl); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// AppliedSchemaVersion returns the highest schema version recorded in
+// schema_migrations, or 0 if the database has never recorded one
+func AppliedSchemaVersion(ctx context.Context, db *sql.DB) (int, error) {
+	var version int
+	err := db.QueryRowContext(ctx, `
+		SELECT COALESCE(MAX(version), 0) FROM schema_migrations
+	`).Scan(&version)
+
+	// Databases created before versions were recorded have no table
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "42P01" {
+		return 0, nil
+	}
+	return version, err
+}
+
+// migrations are the schema's steps, oldest first; migrations[i] brings the
+// schema to version i+1. Append a step to change the schema, never edit one
+// that has shipped, and keep every step safe to rerun, since databases that
+// predate version tracking run them all.
+var migrations = []string{
+	// 1: the schema as of the first recorded version. Databases that predate
+	// recording run this against tables they already have.
+	`
 -- Polls
 CREATE TABLE IF NOT EXISTS poll (
     id TEXT PRIMARY KEY,
@@ -29,10 +141,17 @@ CREATE TABLE IF NOT EXISTS poll (
     method TEXT NOT NULL DEFAULT 'bmj',
     status TEXT NOT NULL DEFAULT 'draft' CHECK (status IN ('draft', 'open', 'closed')),
     share_slug TEXT UNIQUE,
-    closes_at TIMESTAMP,
-    closed_at TIMESTAMP,
+    vanity_slug TEXT UNIQUE,
+    closes_at TIMESTAMPTZ,
+    closed_at TIMESTAMPTZ,
     final_snapshot_id TEXT,
-    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    opened_at TIMESTAMPTZ,
+    min_open_seconds INTEGER NOT NULL DEFAULT 0 CHECK (min_open_seconds >= 0),
+    min_scored_options INTEGER NOT NULL DEFAULT 1 CHECK (min_scored_options >= 1),
+    close_webhook_url TEXT,
+    live_results BOOLEAN NOT NULL DEFAULT FALSE,
+    creator_contact TEXT
 );
 
 CREATE INDEX IF NOT EXISTS idx_poll_share_slug ON poll(share_slug);
@@ -42,7 +161,9 @@ CREATE INDEX IF NOT EXISTS idx_poll_status ON poll(status);
 CREATE TABLE IF NOT EXISTS option (
     id TEXT PRIMARY KEY,
     poll_id TEXT NOT NULL REFERENCES poll(id) ON DELETE CASCADE,
-    label TEXT NOT NULL
+    label TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    position INTEGER NOT NULL DEFAULT 0
 );
 
 CREATE INDEX IF NOT EXISTS idx_option_poll_id ON option(poll_id);
@@ -52,7 +173,7 @@ CREATE TABLE IF NOT EXISTS username_claim (
     poll_id TEXT NOT NULL REFERENCES poll(id) ON DELETE CASCADE,
     username TEXT NOT NULL,
     voter_token TEXT NOT NULL,
-    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
     PRIMARY KEY (poll_id, voter_token),
     UNIQUE (poll_id, username)
 );
@@ -64,7 +185,7 @@ CREATE TABLE IF NOT EXISTS ballot (
     id TEXT PRIMARY KEY,
     poll_id TEXT NOT NULL REFERENCES poll(id) ON DELETE CASCADE,
     voter_token TEXT NOT NULL,
-    submitted_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    submitted_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
     ip_hash TEXT,
     user_agent TEXT,
     UNIQUE (poll_id, voter_token)
@@ -88,7 +209,7 @@ CREATE TABLE IF NOT EXISTS result_snapshot (
     id TEXT PRIMARY KEY,
     poll_id TEXT NOT NULL REFERENCES poll(id) ON DELETE CASCADE,
     method TEXT NOT NULL,
-    computed_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    computed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
     payload JSONB NOT NULL
 );
 
@@ -99,8 +220,8 @@ CREATE TABLE IF NOT EXISTS device (
     id TEXT PRIMARY KEY,
     device_uuid TEXT NOT NULL UNIQUE,
     platform TEXT NOT NULL,  -- 'ios', 'macos', 'android', 'web'
-    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
-    last_seen_at TIMESTAMP NOT NULL DEFAULT NOW()
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_seen_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
 );
 
 CREATE INDEX IF NOT EXISTS idx_device_uuid ON device(device_uuid);
@@ -111,9 +232,127 @@ CREATE TABLE IF NOT EXISTS device_poll (
     poll_id TEXT NOT NULL REFERENCES poll(id) ON DELETE CASCADE,
     voter_token TEXT,
     role TEXT NOT NULL DEFAULT 'voter',  -- 'voter' or 'admin'
-    linked_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    linked_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
     PRIMARY KEY (device_id, poll_id)
 );
 
 CREATE INDEX IF NOT EXISTS idx_device_poll_device ON device_poll(device_id);
-`
+
+-- Columns added after the initial release (for existing databases)
+ALTER TABLE poll ADD COLUMN IF NOT EXISTS opened_at TIMESTAMPTZ;
+ALTER TABLE poll ADD COLUMN IF NOT EXISTS min_open_seconds INTEGER NOT NULL DEFAULT 0 CHECK (min_open_seconds >= 0);
+ALTER TABLE poll ADD COLUMN IF NOT EXISTS vanity_slug TEXT UNIQUE;
+ALTER TABLE poll ADD COLUMN IF NOT EXISTS min_scored_options INTEGER NOT NULL DEFAULT 1 CHECK (min_scored_options >= 1);
+ALTER TABLE poll ADD COLUMN IF NOT EXISTS close_webhook_url TEXT;
+ALTER TABLE poll ADD COLUMN IF NOT EXISTS live_results BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE poll ADD COLUMN IF NOT EXISTS creator_contact TEXT;
+ALTER TABLE option ADD COLUMN IF NOT EXISTS position INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE option ADD COLUMN IF NOT EXISTS description TEXT NOT NULL DEFAULT '';
+
+-- Timestamps were once stored as zoneless TIMESTAMP. Convert any that remain,
+-- reading the old values as UTC.
+DO $$
+DECLARE
+    col RECORD;
+BEGIN
+    FOR col IN
+        SELECT table_name, column_name
+        FROM information_schema.columns
+        WHERE table_schema = current_schema()
+          AND data_type = 'timestamp without time zone'
+          AND table_name IN ('poll', 'option', 'username_claim', 'ballot', 'score',
+                             'result_snapshot', 'device', 'device_poll')
+    LOOP
+        EXECUTE format('ALTER TABLE %I ALTER COLUMN %I TYPE TIMESTAMPTZ USING %I AT TIME ZONE ''UTC''',
+                       col.table_name, col.column_name, col.column_name);
+    END LOOP;
+END $$;
+`,
+
+	// 2: pausing voting
+	`
+ALTER TABLE poll ADD COLUMN IF NOT EXISTS paused BOOLEAN NOT NULL DEFAULT FALSE;
+`,
+
+	// 3: embargoed results
+	`
+ALTER TABLE poll ADD COLUMN IF NOT EXISTS reveal_at TIMESTAMPTZ;
+`,
+
+	// 4: the admin action audit log
+	`
+-- Admin actions, recorded for accountability
+CREATE TABLE IF NOT EXISTS admin_action (
+    id TEXT PRIMARY KEY,
+    poll_id TEXT NOT NULL REFERENCES poll(id) ON DELETE CASCADE,
+    action TEXT NOT NULL,  -- 'publish', 'close', 'delete_option'
+    ip_hash TEXT,          -- NULL for actions the server takes itself
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_admin_action_poll_id ON admin_action(poll_id, created_at);
+`,
+
+	// 5: test ballots
+	`
+ALTER TABLE poll ADD COLUMN IF NOT EXISTS exclude_test_ballots BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE ballot ADD COLUMN IF NOT EXISTS is_test BOOLEAN NOT NULL DEFAULT FALSE;  -- cast by the poll's admin device
+`,
+
+	// 6: a default score for unscored options
+	`
+ALTER TABLE poll ADD COLUMN IF NOT EXISTS default_unscored REAL CHECK (default_unscored >= 0 AND default_unscored <= 1);  -- NULL leaves unscored options out
+`,
+
+	// 7: locking ballots after the first submission
+	`
+ALTER TABLE poll ADD COLUMN IF NOT EXISTS allow_ballot_updates BOOLEAN NOT NULL DEFAULT TRUE;
+`,
+
+	// 8: option metadata
+	`
+ALTER TABLE option ADD COLUMN IF NOT EXISTS metadata JSONB;  -- client-defined, e.g. a color or icon; never interpreted
+`,
+
+	// 9: idempotent poll creation
+	`
+-- Idempotency-Key values sent to CreatePoll, so retries return the same poll
+CREATE TABLE IF NOT EXISTS idempotency_key (
+    scope TEXT NOT NULL,  -- 'device:<uuid>' or 'ip:<hash>'
+    key TEXT NOT NULL,
+    poll_id TEXT NOT NULL REFERENCES poll(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (scope, key)
+);
+`,
+
+	// 10: per-poll username bounds
+	`
+ALTER TABLE poll ADD COLUMN IF NOT EXISTS min_username_length INTEGER CHECK (min_username_length >= 1);  -- NULL uses the server's bounds
+ALTER TABLE poll ADD COLUMN IF NOT EXISTS max_username_length INTEGER CHECK (max_username_length >= 1);
+`,
+
+	// 11: the option set hash
+	`
+ALTER TABLE poll ADD COLUMN IF NOT EXISTS options_hash TEXT;  -- SHA-256 of the option labels in display order, set at publish
+`,
+
+	// 12: the poll creation rate limit
+	`
+-- When each client last created a poll, for MinPollCreateInterval
+CREATE TABLE IF NOT EXISTS poll_creation (
+    scope TEXT PRIMARY KEY,  -- 'device:<uuid>' or 'ip:<hash>', as in idempotency_key
+    created_at TIMESTAMPTZ NOT NULL
+);
+`,
+
+	// 13: keeping the creator from voting
+	`
+ALTER TABLE poll ADD COLUMN IF NOT EXISTS creator_can_vote BOOLEAN NOT NULL DEFAULT TRUE;  -- FALSE refuses the admin device's claims and ballots
+`,
+
+	// 14: request hashes for idempotency keys
+	`
+ALTER TABLE idempotency_key ADD COLUMN IF NOT EXISTS request_hash TEXT;  -- SHA-256 of the CreatePollRequest, so a reused key with another body is refused
+`,
+}
@@ -27,7 +27,22 @@ Required settings:
 
 Optional settings:
 
-  - PORT (-p): Server port (default: 3318)
+  - PORT (-p): Server port, 1-65535 (default: cliparse.DefaultPort, 3318)
+
+See the cliparse package for the full list of optional settings.
+
+# Connections
+
+With TLSCertFile and TLSKeyFile set the server listens with
+ListenAndServeTLS, which negotiates HTTP/2 over ALPN automatically; plain
+HTTP serves HTTP/1.1 only. Keep-alives are on while the server runs.
+
+On SIGINT or SIGTERM the server shuts down gracefully: it stops accepting
+connections and waits up to 30 seconds for in-flight requests before
+closing what remains. With DisableKeepAlives (--disable-keepalives) it
+also turns keep-alives off for the drain, so each connection closes as
+its last response is written instead of lingering for requests it will
+never serve, which lets load balancers move clients away cleanly.
 
 # Architecture
 
@@ -0,0 +1,102 @@
+// Copyright (c) 2025 Daniel Kuo.
+// Source-available; no permission granted to use, copy, modify, or distribute. See LICENSE.
+
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/danielhkuo/quickly-pick/auth"
+	"github.com/danielhkuo/quickly-pick/middleware"
+	"github.com/danielhkuo/quickly-pick/models"
+)
+
+// auditAdminAction records an admin action on pollID within tx, so the
+// entry commits or rolls back with the change it describes. An empty
+// ipHash, for actions the server takes itself, is stored as NULL.
+func auditAdminAction(tx *sql.Tx, pollID, action, ipHash string) error {
+	actionID, err := auth.GenerateID(recordIDBytes)
+	if err != nil {
+		return fmt.Errorf("generate admin action ID: %w", err)
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO admin_action (id, poll_id, action, ip_hash, created_at)
+		VALUES ($1, $2, $3, NULLIF($4, ''), NOW())
+	`, actionID, pollID, action, ipHash)
+	if err != nil {
+		return fmt.Errorf("insert admin action: %w", err)
+	}
+	return nil
+}
+
+// actorIPHash returns the hashed client IP recorded with an admin action
+func (h *PollHandler) actorIPHash(r *http.Request) string {
+	return auth.HashIP(middleware.ClientIP(r, h.cfg.TrustProxyHeaders), h.cfg.AdminKeySalt)
+}
+
+// GetAdminActions handles GET /polls/:id/admin/actions
+// Lists the admin actions recorded for a poll, newest first.
+func (h *PollHandler) GetAdminActions(w http.ResponseWriter, r *http.Request) {
+	pollID := r.PathValue("id")
+	if pollID == "" {
+		middleware.ErrorResponse(w, http.StatusBadRequest, "poll_id is required")
+		return
+	}
+
+	// Validate admin key
+	adminKey := r.Header.Get("X-Admin-Key")
+	if err := auth.ValidateAdminKey(pollID, adminKey, h.cfg.AdminKeySalt); err != nil {
+		middleware.ErrorResponse(w, http.StatusUnauthorized, "Invalid admin key")
+		return
+	}
+
+	var exists bool
+	err := h.db.QueryRow("SELECT EXISTS(SELECT 1 FROM poll WHERE id = $1)", pollID).Scan(&exists)
+	if err != nil {
+		slog.Error("failed to query poll", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if !exists {
+		middleware.ErrorResponse(w, http.StatusNotFound, "Poll not found")
+		return
+	}
+
+	rows, err := h.db.Query(`
+		SELECT id, action, COALESCE(ip_hash, ''), created_at
+		FROM admin_action
+		WHERE poll_id = $1
+		ORDER BY created_at DESC, id
+	`, pollID)
+	if err != nil {
+		slog.Error("failed to query admin actions", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer rows.Close()
+
+	actions := []models.AdminAction{}
+	for rows.Next() {
+		var action models.AdminAction
+		if err := rows.Scan(&action.ID, &action.Action, &action.IPHash, &action.CreatedAt); err != nil {
+			slog.Error("failed to scan admin action", "error", err)
+			middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+		actions = append(actions, action)
+	}
+	if err := rows.Err(); err != nil {
+		slog.Error("failed to iterate admin actions", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	middleware.JSONResponse(w, http.StatusOK, models.AdminActionsResponse{
+		PollID:  pollID,
+		Actions: actions,
+	})
+}
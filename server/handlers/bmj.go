@@ -4,63 +4,142 @@
 package handlers
 
 import (
+	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"sort"
 
 	"github.com/danielhkuo/quickly-pick/models"
 )
 
+// BMJVetoThreshold is the share of negative scores at which an option with a
+// non-positive median is soft-vetoed
+const BMJVetoThreshold = 0.33
+
+// BMJAlgorithmVersion identifies the ranking rules recorded in result
+// snapshots. Bump it whenever a change could rank the same ballots differently.
+const BMJAlgorithmVersion = 1
+
+// BMJScoreScale describes the signed axis BMJ statistics are reported on,
+// so clients can plot results without hardcoding the transform
+var BMJScoreScale = models.ScoreScale{
+	Min:       -1,
+	Mid:       0,
+	Max:       1,
+	Transform: "2*value01-1",
+}
+
+// signedScore maps a stored value01 onto BMJScoreScale
+func signedScore(value01 float64) float64 {
+	return 2.0*value01 - 1.0
+}
+
+// signedOptionScores maps each option's value01 scores onto BMJScoreScale
+// and sorts them ascending, the arrays rankOptionScores takes its
+// statistics from
+func signedOptionScores(scores map[string][]float64) map[string][]float64 {
+	signed := make(map[string][]float64, len(scores))
+	for optionID, values := range scores {
+		sorted := make([]float64, len(values))
+		for i, v := range values {
+			sorted[i] = signedScore(v)
+		}
+		sort.Float64s(sorted)
+		signed[optionID] = sorted
+	}
+	return signed
+}
+
 // BMJStats represents the statistical aggregates for a single option
 type BMJStats struct {
-	OptionID string
-	Label    string
-	Median   float64
-	P10      float64
-	P90      float64
-	Mean     float64
-	NegShare float64
-	Veto     bool
+	OptionID  string
+	Label     string
+	Median    float64
+	P10       float64
+	P90       float64
+	Mean      float64
+	NegShare  float64
+	Veto      bool
+	VoteCount int // ballots that scored the option; filled-in defaults don't count
+}
+
+// TieBreakFunc reports whether a should rank ahead of b when every BMJ
+// statistic (veto, median, p10, p90, mean) is equal
+type TieBreakFunc func(a, b BMJStats) bool
+
+// TieBreakByOptionID is the default final tie-break: option ID ascending
+func TieBreakByOptionID(a, b BMJStats) bool {
+	return a.OptionID < b.OptionID
 }
 
-// ComputeBMJRankings calculates Balanced Majority Judgment rankings for a poll
-func ComputeBMJRankings(db *sql.DB, pollID string) ([]models.OptionStats, error) {
+// BMJOptions customizes how ComputeBMJRankingsWithOptions ranks a poll
+type BMJOptions struct {
+	// TieBreak orders options with identical statistics.
+	// Defaults to TieBreakByOptionID when nil.
+	TieBreak TieBreakFunc
+
+	// VetoThreshold is the negative share that soft-vetoes an option.
+	// Defaults to BMJVetoThreshold when zero.
+	VetoThreshold float64
+
+	// LowSampleThreshold flags options scored by fewer ballots as
+	// low_sample. Zero flags none.
+	LowSampleThreshold int
+}
+
+// vetoThreshold returns the veto threshold opts rank with
+func (opts BMJOptions) vetoThreshold() float64 {
+	if opts.VetoThreshold == 0 {
+		return BMJVetoThreshold
+	}
+	return opts.VetoThreshold
+}
+
+// ComputeBMJRankings calculates Balanced Majority Judgment rankings for a
+// poll. Its queries are abandoned with ctx's error once ctx is done.
+func ComputeBMJRankings(ctx context.Context, db *sql.DB, pollID string) ([]models.OptionStats, error) {
+	return ComputeBMJRankingsWithOptions(ctx, db, pollID, BMJOptions{})
+}
+
+// ComputeBMJRankingsWithOptions calculates BMJ rankings using opts
+func ComputeBMJRankingsWithOptions(ctx context.Context, db *sql.DB, pollID string, opts BMJOptions) ([]models.OptionStats, error) {
 	// Get all options for the poll
-	optionLabels, err := getOptionLabels(db, pollID)
+	optionLabels, err := getOptionLabels(ctx, db, pollID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get option labels: %w", err)
 	}
 
 	// Get all scores grouped by option
-	optionScores, err := getOptionScores(db, pollID)
+	optionScores, scoredCounts, err := getOptionScores(ctx, db, pollID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get option scores: %w", err)
 	}
 
+	return rankOptionScores(optionLabels, optionScores, scoredCounts, opts), nil
+}
+
+// rankOptionScores computes each option's BMJ statistics from its value01
+// scores and ranks them with opts. Options without scores rank on zeros.
+func rankOptionScores(optionLabels map[string]string, optionScores map[string][]float64, scoredCounts map[string]int, opts BMJOptions) []models.OptionStats {
 	// Compute statistics for each option
 	var stats []BMJStats
-	for optionID, rawScores := range optionScores {
-		// Convert to signed scores: s = 2*value01 - 1
-		signedScores := make([]float64, len(rawScores))
-		for i, v := range rawScores {
-			signedScores[i] = 2.0*v - 1.0
-		}
-
-		// Sort for percentile calculations
-		sort.Float64s(signedScores)
-
+	for optionID, signedScores := range signedOptionScores(optionScores) {
 		stat := BMJStats{
-			OptionID: optionID,
-			Label:    optionLabels[optionID],
-			Median:   percentile(signedScores, 0.5),
-			P10:      percentile(signedScores, 0.1),
-			P90:      percentile(signedScores, 0.9),
-			Mean:     mean(signedScores),
-			NegShare: negativeShare(signedScores),
+			OptionID:  optionID,
+			Label:     optionLabels[optionID],
+			Median:    percentile(signedScores, 0.5),
+			P10:       percentile(signedScores, 0.1),
+			P90:       percentile(signedScores, 0.9),
+			Mean:      mean(signedScores),
+			NegShare:  negativeShare(signedScores),
+			VoteCount: scoredCounts[optionID],
 		}
 
 		// Apply soft veto rule
-		stat.Veto = stat.NegShare >= 0.33 && stat.Median <= 0
+		stat.Veto = stat.NegShare >= opts.vetoThreshold() && stat.Median <= 0
 
 		stats = append(stats, stat)
 	}
@@ -81,6 +160,20 @@ func ComputeBMJRankings(db *sql.DB, pollID string) ([]models.OptionStats, error)
 		}
 	}
 
+	rankings := rankBMJStats(stats, opts.TieBreak)
+	for i := range rankings {
+		rankings[i].LowSample = rankings[i].VoteCount < opts.LowSampleThreshold
+	}
+	return rankings
+}
+
+// rankBMJStats sorts stats by the BMJ criteria and assigns 1-indexed ranks.
+// tieBreak decides between options whose statistics are identical.
+func rankBMJStats(stats []BMJStats, tieBreak TieBreakFunc) []models.OptionStats {
+	if tieBreak == nil {
+		tieBreak = TieBreakByOptionID
+	}
+
 	// Sort by BMJ ranking criteria (lexicographic order)
 	sort.Slice(stats, func(i, j int) bool {
 		a, b := stats[i], stats[j]
@@ -110,32 +203,69 @@ func ComputeBMJRankings(db *sql.DB, pollID string) ([]models.OptionStats, error)
 			return a.Mean > b.Mean
 		}
 
-		// 6. Stable tie-breaking by option ID (ascending)
-		return a.OptionID < b.OptionID
+		// 6. Deterministic final tie-break (option ID ascending by default)
+		return tieBreak(a, b)
 	})
 
 	// Convert to models.OptionStats with ranks
 	results := make([]models.OptionStats, len(stats))
 	for i, stat := range stats {
 		results[i] = models.OptionStats{
-			OptionID: stat.OptionID,
-			Label:    stat.Label,
-			Median:   stat.Median,
-			P10:      stat.P10,
-			P90:      stat.P90,
-			Mean:     stat.Mean,
-			NegShare: stat.NegShare,
-			Veto:     stat.Veto,
-			Rank:     i + 1, // 1-indexed ranking
+			OptionID:  stat.OptionID,
+			Label:     stat.Label,
+			Median:    stat.Median,
+			P10:       stat.P10,
+			P90:       stat.P90,
+			Mean:      stat.Mean,
+			NegShare:  stat.NegShare,
+			Veto:      stat.Veto,
+			Rank:      i + 1, // 1-indexed ranking
+			VoteCount: stat.VoteCount,
 		}
+		results[i].Status = optionStatus(results[i])
+	}
+
+	return results
+}
+
+// optionStatus derives a human-readable status from rank and veto
+// so result tables can be read without interpreting boolean columns
+func optionStatus(stat models.OptionStats) string {
+	if stat.Veto {
+		return models.OptionStatusVetoed
+	}
+	if stat.Rank == 1 {
+		return models.OptionStatusWinner
+	}
+	return models.OptionStatusRanked
+}
+
+// firstPlaceTie returns the options that match the top-ranked option on
+// every BMJ statistic, so only the final tie-break put one of them first.
+// It returns nil when the winner is clear.
+func firstPlaceTie(rankings []models.OptionStats) *models.ResultsTie {
+	if len(rankings) < 2 {
+		return nil
 	}
 
-	return results, nil
+	top := rankings[0]
+	var tied []string
+	for _, stat := range rankings {
+		if stat.Veto != top.Veto || stat.Median != top.Median || stat.P10 != top.P10 ||
+			stat.P90 != top.P90 || stat.Mean != top.Mean {
+			break
+		}
+		tied = append(tied, stat.OptionID)
+	}
+	if len(tied) < 2 {
+		return nil
+	}
+	return &models.ResultsTie{OptionIDs: tied}
 }
 
 // getOptionLabels retrieves option labels for a poll
-func getOptionLabels(db *sql.DB, pollID string) (map[string]string, error) {
-	rows, err := db.Query(`
+func getOptionLabels(ctx context.Context, db *sql.DB, pollID string) (map[string]string, error) {
+	rows, err := db.QueryContext(ctx, `
 		SELECT id, label FROM option WHERE poll_id = $1
 	`, pollID)
 	if err != nil {
@@ -155,31 +285,93 @@ func getOptionLabels(db *sql.DB, pollID string) (map[string]string, error) {
 	return labels, rows.Err()
 }
 
-// getOptionScores retrieves all scores grouped by option
-func getOptionScores(db *sql.DB, pollID string) (map[string][]float64, error) {
-	rows, err := db.Query(`
-		SELECT s.option_id, s.value01
-		FROM score s
-		JOIN ballot b ON s.ballot_id = b.id
+// getOptionScores retrieves all scores grouped by option, each option's in
+// ballot ID order so repeated computations see identical inputs. It leaves
+// out test ballots when the poll excludes them. With the poll's
+// default_unscored set, every ballot that scored anything also contributes
+// that value for each option it left unscored; scored counts only the
+// ballots' own scores per option.
+func getOptionScores(ctx context.Context, db *sql.DB, pollID string) (scores map[string][]float64, scored map[string]int, err error) {
+	ballots, err := getBallotScores(ctx, db, pollID)
+	if err != nil {
+		return nil, nil, err
+	}
+	scores, scored = groupBallotScores(ballots)
+	return scores, scored, nil
+}
+
+// ballotScore is one value a ranked ballot contributes to an option;
+// scored is false when the value is the poll's default_unscored
+type ballotScore struct {
+	optionID string
+	value    float64
+	scored   bool
+}
+
+// getBallotScores retrieves the values each counted ballot contributes, in
+// ballot ID order, under the same rules as getOptionScores
+func getBallotScores(ctx context.Context, db *sql.DB, pollID string) ([][]ballotScore, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT b.id, o.id, COALESCE(s.value01, p.default_unscored), s.value01 IS NOT NULL
+		FROM ballot b
+		JOIN poll p ON b.poll_id = p.id
+		JOIN option o ON o.poll_id = b.poll_id
+		LEFT JOIN score s ON s.ballot_id = b.id AND s.option_id = o.id
 		WHERE b.poll_id = $1
-		ORDER BY s.option_id
+		  AND NOT (b.is_test AND p.exclude_test_ballots)
+		  AND (s.value01 IS NOT NULL OR (p.default_unscored IS NOT NULL
+		       AND EXISTS (SELECT 1 FROM score WHERE ballot_id = b.id)))
+		ORDER BY b.id, o.id
 	`, pollID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	scores := make(map[string][]float64)
+	var ballots [][]ballotScore
+	lastBallotID := ""
 	for rows.Next() {
-		var optionID string
-		var value float64
-		if err := rows.Scan(&optionID, &value); err != nil {
+		var ballotID string
+		var score ballotScore
+		if err := rows.Scan(&ballotID, &score.optionID, &score.value, &score.scored); err != nil {
 			return nil, err
 		}
-		scores[optionID] = append(scores[optionID], value)
+		if len(ballots) == 0 || ballotID != lastBallotID {
+			ballots = append(ballots, nil)
+			lastBallotID = ballotID
+		}
+		ballots[len(ballots)-1] = append(ballots[len(ballots)-1], score)
 	}
 
-	return scores, rows.Err()
+	return ballots, rows.Err()
+}
+
+// groupBallotScores collects ballots' values by option, keeping ballot
+// order, and counts each option's own (not filled-in) scores
+func groupBallotScores(ballots [][]ballotScore) (scores map[string][]float64, scored map[string]int) {
+	scores = make(map[string][]float64)
+	scored = make(map[string]int)
+	for _, ballot := range ballots {
+		for _, score := range ballot {
+			scores[score.optionID] = append(scores[score.optionID], score.value)
+			if score.scored {
+				scored[score.optionID]++
+			}
+		}
+	}
+	return scores, scored
+}
+
+// countBallots returns the poll's ballots in total and the number its
+// rankings count, which leaves out test ballots when the poll excludes them
+func countBallots(ctx context.Context, db *sql.DB, pollID string) (total, counted int, err error) {
+	err = db.QueryRowContext(ctx, `
+		SELECT COUNT(*), COUNT(*) FILTER (WHERE NOT (b.is_test AND p.exclude_test_ballots))
+		FROM ballot b
+		JOIN poll p ON b.poll_id = p.id
+		WHERE b.poll_id = $1
+	`, pollID).Scan(&total, &counted)
+	return total, counted, err
 }
 
 // percentile calculates the p-th percentile of sorted data
@@ -234,9 +426,91 @@ func negativeShare(signedScores []float64) float64 {
 	return float64(negCount) / float64(len(signedScores))
 }
 
+// snapshotPayload is the JSON stored in result_snapshot.payload. It records
+// the parameters the rankings were computed with, so historical results stay
+// interpretable after those parameters change.
+type snapshotPayload struct {
+	Rankings         []models.OptionStats `json:"rankings"`
+	InputsHash       string               `json:"inputs_hash"`
+	OptionsHash      string               `json:"options_hash,omitempty"`
+	Method           string               `json:"method,omitempty"`
+	VetoThreshold    float64              `json:"veto_threshold,omitempty"`
+	AlgorithmVersion int                  `json:"algorithm_version,omitempty"`
+}
+
+// withLegacyParameters fills in the parameters that snapshots written before
+// they were recorded always used
+func (p *snapshotPayload) withLegacyParameters() {
+	if p.Method == "" {
+		p.Method = models.MethodBMJ
+	}
+	if p.AlgorithmVersion == 0 {
+		p.AlgorithmVersion = 1
+		p.VetoThreshold = 0.33
+	}
+}
+
+// computeSnapshotPayload ranks the poll's current ballots with opts and
+// records the parameters used
+func computeSnapshotPayload(ctx context.Context, db *sql.DB, pollID string, opts BMJOptions) (snapshotPayload, error) {
+	rankings, err := ComputeBMJRankingsWithOptions(ctx, db, pollID, opts)
+	if err != nil {
+		return snapshotPayload{}, err
+	}
+	optionsHash, err := queryOptionsHash(ctx, db, pollID)
+	if err != nil {
+		return snapshotPayload{}, err
+	}
+	return snapshotPayload{
+		Rankings:         rankings,
+		InputsHash:       computeInputsHash(ctx, db, pollID),
+		OptionsHash:      optionsHash,
+		Method:           models.MethodBMJ,
+		VetoThreshold:    opts.vetoThreshold(),
+		AlgorithmVersion: BMJAlgorithmVersion,
+	}, nil
+}
+
+// optionsQuerier is satisfied by both *sql.DB and *sql.Tx
+type optionsQuerier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// queryOptionsHash hashes the poll's option labels in display order
+func queryOptionsHash(ctx context.Context, q optionsQuerier, pollID string) (string, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT label FROM option WHERE poll_id = $1 ORDER BY position, id
+	`, pollID)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	labels := []string{}
+	for rows.Next() {
+		var label string
+		if err := rows.Scan(&label); err != nil {
+			return "", err
+		}
+		labels = append(labels, label)
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	return optionsHash(labels), nil
+}
+
+// optionsHash returns the hex SHA-256 of labels encoded as a JSON array,
+// so that no two distinct lists of labels share an encoding
+func optionsHash(labels []string) string {
+	encoded, _ := json.Marshal(labels) // a []string always encodes
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:])
+}
+
 // computeInputsHash creates a hash of all ballot IDs for verification
-func computeInputsHash(db *sql.DB, pollID string) string {
-	rows, err := db.Query(`
+func computeInputsHash(ctx context.Context, db *sql.DB, pollID string) string {
+	rows, err := db.QueryContext(ctx, `
 		SELECT id FROM ballot WHERE poll_id = $1 ORDER BY id
 	`, pollID)
 	if err != nil {
@@ -4,6 +4,14 @@
 package handlers
 
 import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"strings"
 	"testing"
 	"time"
 
@@ -85,7 +93,7 @@ func TestComputeBMJRankings(t *testing.T) {
 	}
 
 	// Compute rankings
-	rankings, err := ComputeBMJRankings(db, pollID)
+	rankings, err := ComputeBMJRankings(context.Background(), db, pollID)
 	if err != nil {
 		t.Fatalf("ComputeBMJRankings failed: %v", err)
 	}
@@ -136,6 +144,301 @@ func TestComputeBMJRankings(t *testing.T) {
 	}
 }
 
+func TestComputeBMJRankingsSampleSize(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	pollID, _ := auth.GenerateID(16)
+	_, err := db.Exec(`
+		INSERT INTO poll (id, title, creator_name, status, created_at)
+		VALUES ($1, 'Sample Size Poll', 'Alice', 'open', $2)
+	`, pollID, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to create poll: %v", err)
+	}
+
+	optionPopular, _ := auth.GenerateID(12)
+	optionNiche, _ := auth.GenerateID(12)
+	optionUnscored, _ := auth.GenerateID(12)
+	for _, id := range []string{optionPopular, optionNiche, optionUnscored} {
+		if _, err := db.Exec(`INSERT INTO option (id, poll_id, label) VALUES ($1, $2, $3)`, id, pollID, "Option "+id); err != nil {
+			t.Fatalf("Failed to create option: %v", err)
+		}
+	}
+
+	// Four voters score the popular option; only one scores the niche one
+	ballots := []map[string]float64{
+		{optionPopular: 0.8, optionNiche: 0.9},
+		{optionPopular: 0.7},
+		{optionPopular: 0.6},
+		{optionPopular: 0.9},
+	}
+	for i, scores := range ballots {
+		ballotID, _ := auth.GenerateID(16)
+		_, err := db.Exec(`
+			INSERT INTO ballot (id, poll_id, voter_token, submitted_at)
+			VALUES ($1, $2, $3, $4)
+		`, ballotID, pollID, "voter"+string(rune('a'+i)), time.Now())
+		if err != nil {
+			t.Fatalf("Failed to create ballot: %v", err)
+		}
+		for optionID, value := range scores {
+			if _, err := db.Exec(`INSERT INTO score (ballot_id, option_id, value01) VALUES ($1, $2, $3)`, ballotID, optionID, value); err != nil {
+				t.Fatalf("Failed to create score: %v", err)
+			}
+		}
+	}
+
+	tests := []struct {
+		name      string
+		threshold int
+		lowSample map[string]bool
+	}{
+		{"no threshold", 0, map[string]bool{optionPopular: false, optionNiche: false, optionUnscored: false}},
+		{"threshold 3", 3, map[string]bool{optionPopular: false, optionNiche: true, optionUnscored: true}},
+		{"threshold 5", 5, map[string]bool{optionPopular: true, optionNiche: true, optionUnscored: true}},
+	}
+
+	wantCounts := map[string]int{optionPopular: 4, optionNiche: 1, optionUnscored: 0}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rankings, err := ComputeBMJRankingsWithOptions(context.Background(), db, pollID, BMJOptions{LowSampleThreshold: tt.threshold})
+			if err != nil {
+				t.Fatalf("ComputeBMJRankingsWithOptions failed: %v", err)
+			}
+			for _, stat := range rankings {
+				if stat.VoteCount != wantCounts[stat.OptionID] {
+					t.Errorf("Expected vote_count %d for %s, got %d", wantCounts[stat.OptionID], stat.OptionID, stat.VoteCount)
+				}
+				if stat.LowSample != tt.lowSample[stat.OptionID] {
+					t.Errorf("Expected low_sample %v for %s, got %v", tt.lowSample[stat.OptionID], stat.OptionID, stat.LowSample)
+				}
+			}
+		})
+	}
+}
+
+func TestComputeBMJRankingsExcludesTestBallots(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	pollID, _ := auth.GenerateID(16)
+	_, err := db.Exec(`
+		INSERT INTO poll (id, title, creator_name, status, created_at)
+		VALUES ($1, 'Trial Poll', 'Alice', 'open', $2)
+	`, pollID, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to create poll: %v", err)
+	}
+	optionID, _ := auth.GenerateID(12)
+	if _, err := db.Exec(`INSERT INTO option (id, poll_id, label) VALUES ($1, $2, 'Option A')`, optionID, pollID); err != nil {
+		t.Fatalf("Failed to create option: %v", err)
+	}
+
+	// Two voters like the option; the creator's trial ballot hates it
+	ballots := []struct {
+		value  float64
+		isTest bool
+	}{
+		{0.8, false},
+		{0.6, false},
+		{0.0, true},
+	}
+	for i, ballot := range ballots {
+		ballotID, _ := auth.GenerateID(16)
+		_, err := db.Exec(`
+			INSERT INTO ballot (id, poll_id, voter_token, submitted_at, is_test)
+			VALUES ($1, $2, $3, $4, $5)
+		`, ballotID, pollID, "voter"+string(rune('a'+i)), time.Now(), ballot.isTest)
+		if err != nil {
+			t.Fatalf("Failed to create ballot: %v", err)
+		}
+		if _, err := db.Exec(`INSERT INTO score (ballot_id, option_id, value01) VALUES ($1, $2, $3)`, ballotID, optionID, ballot.value); err != nil {
+			t.Fatalf("Failed to create score: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name        string
+		exclude     bool
+		voteCount   int
+		negShare    float64
+		wantCounted int
+	}{
+		{"test ballots counted by default", false, 3, 1.0 / 3, 3},
+		{"test ballots excluded", true, 2, 0, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := db.Exec(`UPDATE poll SET exclude_test_ballots = $1 WHERE id = $2`, tt.exclude, pollID); err != nil {
+				t.Fatalf("Failed to update poll: %v", err)
+			}
+
+			rankings, err := ComputeBMJRankings(context.Background(), db, pollID)
+			if err != nil {
+				t.Fatalf("ComputeBMJRankings failed: %v", err)
+			}
+			if len(rankings) != 1 {
+				t.Fatalf("Expected 1 ranking, got %d", len(rankings))
+			}
+			if rankings[0].VoteCount != tt.voteCount {
+				t.Errorf("Expected vote_count %d, got %d", tt.voteCount, rankings[0].VoteCount)
+			}
+			if math.Abs(rankings[0].NegShare-tt.negShare) > 0.001 {
+				t.Errorf("Expected neg_share %v, got %v", tt.negShare, rankings[0].NegShare)
+			}
+
+			total, counted, err := countBallots(context.Background(), db, pollID)
+			if err != nil {
+				t.Fatalf("countBallots failed: %v", err)
+			}
+			if total != len(ballots) || counted != tt.wantCounted {
+				t.Errorf("Expected %d ballots with %d counted, got %d with %d counted", len(ballots), tt.wantCounted, total, counted)
+			}
+		})
+	}
+}
+
+func TestComputeBMJRankingsDefaultUnscored(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	pollID, _ := auth.GenerateID(16)
+	_, err := db.Exec(`
+		INSERT INTO poll (id, title, creator_name, status, created_at)
+		VALUES ($1, 'Partial Poll', 'Alice', 'open', $2)
+	`, pollID, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to create poll: %v", err)
+	}
+	optionA, _ := auth.GenerateID(12)
+	optionB, _ := auth.GenerateID(12)
+	for _, id := range []string{optionA, optionB} {
+		if _, err := db.Exec(`INSERT INTO option (id, poll_id, label) VALUES ($1, $2, $3)`, id, pollID, "Option "+id); err != nil {
+			t.Fatalf("Failed to create option: %v", err)
+		}
+	}
+
+	// Everyone scores A; only the third voter bothers with B, and loves it
+	ballots := []map[string]float64{
+		{optionA: 1.0},
+		{optionA: 1.0},
+		{optionA: 0.0, optionB: 1.0},
+	}
+	for i, scores := range ballots {
+		ballotID, _ := auth.GenerateID(16)
+		_, err := db.Exec(`
+			INSERT INTO ballot (id, poll_id, voter_token, submitted_at)
+			VALUES ($1, $2, $3, $4)
+		`, ballotID, pollID, "voter"+string(rune('a'+i)), time.Now())
+		if err != nil {
+			t.Fatalf("Failed to create ballot: %v", err)
+		}
+		for optionID, value := range scores {
+			if _, err := db.Exec(`INSERT INTO score (ballot_id, option_id, value01) VALUES ($1, $2, $3)`, ballotID, optionID, value); err != nil {
+				t.Fatalf("Failed to create score: %v", err)
+			}
+		}
+	}
+
+	rankingFor := func(rankings []models.OptionStats, optionID string) models.OptionStats {
+		for _, stat := range rankings {
+			if stat.OptionID == optionID {
+				return stat
+			}
+		}
+		t.Fatalf("No ranking for option %s", optionID)
+		return models.OptionStats{}
+	}
+
+	// Without a default, B is judged by its one fan alone
+	rankings, err := ComputeBMJRankings(context.Background(), db, pollID)
+	if err != nil {
+		t.Fatalf("ComputeBMJRankings failed: %v", err)
+	}
+	if b := rankingFor(rankings, optionB); b.Median != 1 || b.VoteCount != 1 {
+		t.Errorf("Expected B median 1 from 1 vote without a default, got median %v from %d", b.Median, b.VoteCount)
+	}
+
+	// With 0.5, the two voters who skipped B count as lukewarm on it
+	if _, err := db.Exec(`UPDATE poll SET default_unscored = 0.5 WHERE id = $1`, pollID); err != nil {
+		t.Fatalf("Failed to update poll: %v", err)
+	}
+	rankings, err = ComputeBMJRankings(context.Background(), db, pollID)
+	if err != nil {
+		t.Fatalf("ComputeBMJRankings failed: %v", err)
+	}
+	b := rankingFor(rankings, optionB)
+	if b.Median != 0 || math.Abs(b.Mean-1.0/3) > 0.001 {
+		t.Errorf("Expected B median 0 and mean 1/3 with a 0.5 default, got median %v and mean %v", b.Median, b.Mean)
+	}
+	if b.VoteCount != 1 {
+		t.Errorf("Expected filled-in scores to stay out of vote_count, got %d", b.VoteCount)
+	}
+	if a := rankingFor(rankings, optionA); a.Median != 1 || a.VoteCount != 3 {
+		t.Errorf("Expected A unchanged with median 1 from 3 votes, got median %v from %d", a.Median, a.VoteCount)
+	}
+	if rankings[0].OptionID != optionA {
+		t.Errorf("Expected A to rank first with a 0.5 default, got %s", rankings[0].OptionID)
+	}
+}
+
+func TestComputeSnapshotPayloadReproducible(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	pollID, _ := auth.GenerateID(16)
+	_, err := db.Exec(`
+		INSERT INTO poll (id, title, creator_name, status, created_at)
+		VALUES ($1, 'Repeat Poll', 'Alice', 'open', $2)
+	`, pollID, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to create poll: %v", err)
+	}
+	optionIDs := make([]string, 3)
+	for i := range optionIDs {
+		optionIDs[i], _ = auth.GenerateID(12)
+		if _, err := db.Exec(`INSERT INTO option (id, poll_id, label) VALUES ($1, $2, $3)`, optionIDs[i], pollID, "Option "+optionIDs[i]); err != nil {
+			t.Fatalf("Failed to create option: %v", err)
+		}
+	}
+
+	// Enough ballots with mixed scores that row order could vary
+	for i := 0; i < 12; i++ {
+		ballotID, _ := auth.GenerateID(16)
+		_, err := db.Exec(`
+			INSERT INTO ballot (id, poll_id, voter_token, submitted_at)
+			VALUES ($1, $2, $3, $4)
+		`, ballotID, pollID, "voter"+string(rune('a'+i)), time.Now())
+		if err != nil {
+			t.Fatalf("Failed to create ballot: %v", err)
+		}
+		for j, optionID := range optionIDs {
+			value := float64((i*7+j*3)%11) / 10
+			if _, err := db.Exec(`INSERT INTO score (ballot_id, option_id, value01) VALUES ($1, $2, $3)`, ballotID, optionID, value); err != nil {
+				t.Fatalf("Failed to create score: %v", err)
+			}
+		}
+	}
+
+	var payloads [2][]byte
+	for i := range payloads {
+		payload, err := computeSnapshotPayload(context.Background(), db, pollID, BMJOptions{})
+		if err != nil {
+			t.Fatalf("computeSnapshotPayload failed: %v", err)
+		}
+		payloads[i], err = json.Marshal(payload)
+		if err != nil {
+			t.Fatalf("Failed to marshal payload: %v", err)
+		}
+	}
+	if !bytes.Equal(payloads[0], payloads[1]) {
+		t.Errorf("Expected identical payloads, got:\n%s\n%s", payloads[0], payloads[1])
+	}
+}
+
 func TestSoftVeto(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Close()
@@ -205,7 +508,7 @@ func TestSoftVeto(t *testing.T) {
 	}
 
 	// Compute rankings
-	rankings, err := ComputeBMJRankings(db, pollID)
+	rankings, err := ComputeBMJRankings(context.Background(), db, pollID)
 	if err != nil {
 		t.Fatalf("ComputeBMJRankings failed: %v", err)
 	}
@@ -268,7 +571,7 @@ func TestNoVotes(t *testing.T) {
 	}
 
 	// Compute rankings (no ballots)
-	rankings, err := ComputeBMJRankings(db, pollID)
+	rankings, err := ComputeBMJRankings(context.Background(), db, pollID)
 	if err != nil {
 		t.Fatalf("ComputeBMJRankings failed: %v", err)
 	}
@@ -387,3 +690,247 @@ func findRanking(rankings []models.OptionStats, optionID string) *models.OptionS
 	}
 	return nil
 }
+
+func TestRankBMJStatsTieBreak(t *testing.T) {
+	// Identical statistics: only the final tie-break separates them
+	newStats := func() []BMJStats {
+		return []BMJStats{
+			{OptionID: "b-id", Label: "Alpha", Median: 0.5, P10: 0.1, P90: 0.9, Mean: 0.5},
+			{OptionID: "c-id", Label: "Bravo", Median: 0.5, P10: 0.1, P90: 0.9, Mean: 0.5},
+			{OptionID: "a-id", Label: "Charlie", Median: 0.5, P10: 0.1, P90: 0.9, Mean: 0.5},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		tieBreak TieBreakFunc
+		expected []string
+	}{
+		{
+			name:     "default breaks ties by option ID",
+			tieBreak: nil,
+			expected: []string{"a-id", "b-id", "c-id"},
+		},
+		{
+			name:     "explicit option ID tie-break",
+			tieBreak: TieBreakByOptionID,
+			expected: []string{"a-id", "b-id", "c-id"},
+		},
+		{
+			name: "custom key tie-break",
+			tieBreak: func(a, b BMJStats) bool {
+				return a.Label < b.Label
+			},
+			expected: []string{"b-id", "c-id", "a-id"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rankings := rankBMJStats(newStats(), tt.tieBreak)
+
+			if len(rankings) != len(tt.expected) {
+				t.Fatalf("Expected %d rankings, got %d", len(tt.expected), len(rankings))
+			}
+			for i, optionID := range tt.expected {
+				if rankings[i].OptionID != optionID {
+					t.Errorf("Expected %s at rank %d, got %s", optionID, i+1, rankings[i].OptionID)
+				}
+				if rankings[i].Rank != i+1 {
+					t.Errorf("Expected rank %d, got %d", i+1, rankings[i].Rank)
+				}
+			}
+		})
+	}
+
+	// The tie-break never overrides a real statistical difference
+	stats := newStats()
+	stats[2].Median = 0.4
+	rankings := rankBMJStats(stats, func(a, b BMJStats) bool { return a.Label > b.Label })
+	if rankings[2].OptionID != "a-id" {
+		t.Errorf("Expected lower-median option last, got %s", rankings[2].OptionID)
+	}
+}
+
+func TestFirstPlaceTie(t *testing.T) {
+	tests := []struct {
+		name     string
+		stats    []BMJStats
+		expected []string
+	}{
+		{
+			name: "identical top options are tied",
+			stats: []BMJStats{
+				{OptionID: "b-id", Median: 0.5, P10: 0.1, P90: 0.9, Mean: 0.5},
+				{OptionID: "a-id", Median: 0.5, P10: 0.1, P90: 0.9, Mean: 0.5},
+				{OptionID: "c-id", Median: 0.2, P10: 0.1, P90: 0.9, Mean: 0.3},
+			},
+			expected: []string{"a-id", "b-id"},
+		},
+		{
+			name: "a lower mean breaks the tie",
+			stats: []BMJStats{
+				{OptionID: "a-id", Median: 0.5, P10: 0.1, P90: 0.9, Mean: 0.5},
+				{OptionID: "b-id", Median: 0.5, P10: 0.1, P90: 0.9, Mean: 0.4},
+			},
+			expected: nil,
+		},
+		{
+			name: "a tie below first place is not reported",
+			stats: []BMJStats{
+				{OptionID: "a-id", Median: 0.9},
+				{OptionID: "b-id", Median: 0.5},
+				{OptionID: "c-id", Median: 0.5},
+			},
+			expected: nil,
+		},
+		{
+			name:     "a single option can't tie",
+			stats:    []BMJStats{{OptionID: "a-id"}},
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tie := firstPlaceTie(rankBMJStats(tt.stats, nil))
+
+			if tt.expected == nil {
+				if tie != nil {
+					t.Errorf("Expected no tie, got %v", tie.OptionIDs)
+				}
+				return
+			}
+			if tie == nil {
+				t.Fatalf("Expected a tie between %v, got none", tt.expected)
+			}
+			if strings.Join(tie.OptionIDs, ",") != strings.Join(tt.expected, ",") {
+				t.Errorf("Expected tie between %v, got %v", tt.expected, tie.OptionIDs)
+			}
+		})
+	}
+}
+
+func TestComputeBMJRankingsCancelled(t *testing.T) {
+	// sql.Open doesn't connect, and a done context fails before a
+	// connection is requested, so no database is needed
+	db, err := sql.Open("postgres", "postgres://localhost/unused")
+	if err != nil {
+		t.Fatalf("Failed to open database handle: %v", err)
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+	_, err = ComputeBMJRankings(ctx, db, "any-poll")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected a prompt return, took %v", elapsed)
+	}
+}
+
+func TestBootstrapWinsSeparated(t *testing.T) {
+	labels := map[string]string{"a-id": "Tacos", "b-id": "Sushi", "c-id": "Salad"}
+
+	// Every voter loves tacos and is lukewarm at best on the rest
+	var ballots [][]ballotScore
+	for i := 0; i < 25; i++ {
+		jitter := float64(i%5) / 50
+		ballots = append(ballots, []ballotScore{
+			{optionID: "a-id", value: 0.9 + jitter, scored: true},
+			{optionID: "b-id", value: 0.4 - jitter, scored: true},
+			{optionID: "c-id", value: 0.2 + jitter, scored: true},
+		})
+	}
+
+	rng := rand.New(rand.NewPCG(1, 2))
+	wins, err := bootstrapWins(context.Background(), labels, ballots, 200, rng, BMJOptions{})
+	if err != nil {
+		t.Fatalf("bootstrapWins failed: %v", err)
+	}
+	if wins["a-id"] != 200 {
+		t.Errorf("Expected the clear winner to win all 200 resamples, got %v", wins)
+	}
+
+	// Nothing to resample: no wins rather than a winner by tie-break
+	wins, err = bootstrapWins(context.Background(), labels, nil, 200, rng, BMJOptions{})
+	if err != nil || len(wins) != 0 {
+		t.Errorf("Expected no wins without ballots, got %v (err %v)", wins, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := bootstrapWins(ctx, labels, ballots, 200, rng, BMJOptions{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestSignedOptionScoresMatchStats(t *testing.T) {
+	labels := map[string]string{"a-id": "Tacos", "b-id": "Sushi"}
+	scores := map[string][]float64{
+		"a-id": {1.0, 0.25, 0.75, 0.5, 0.0},
+		"b-id": {0.6, 0.1, 0.9, 0.3},
+	}
+	scored := map[string]int{"a-id": 5, "b-id": 4}
+
+	signed := signedOptionScores(scores)
+	for _, stat := range rankOptionScores(labels, scores, scored, BMJOptions{}) {
+		values := signed[stat.OptionID]
+		if len(values) != len(scores[stat.OptionID]) {
+			t.Fatalf("%s: Expected %d scores, got %d", stat.OptionID, len(scores[stat.OptionID]), len(values))
+		}
+		for i := 1; i < len(values); i++ {
+			if values[i-1] > values[i] {
+				t.Fatalf("%s: Expected sorted scores, got %v", stat.OptionID, values)
+			}
+		}
+		if values[0] < -1 || values[len(values)-1] > 1 {
+			t.Errorf("%s: Expected signed scores in [-1, 1], got %v", stat.OptionID, values)
+		}
+
+		// The array's own median, taken independently of percentile
+		n := len(values)
+		median := values[n/2]
+		if n%2 == 0 {
+			median = (values[n/2-1] + values[n/2]) / 2
+		}
+		if math.Abs(stat.Median-median) > 1e-9 {
+			t.Errorf("%s: Expected median %v from the raw scores, got %v", stat.OptionID, median, stat.Median)
+		}
+
+		sum := 0.0
+		for _, v := range values {
+			sum += v
+		}
+		if math.Abs(stat.Mean-sum/float64(n)) > 1e-9 {
+			t.Errorf("%s: Expected mean %v from the raw scores, got %v", stat.OptionID, sum/float64(n), stat.Mean)
+		}
+	}
+}
+
+func TestOptionsHash(t *testing.T) {
+	hash := optionsHash([]string{"Tacos", "Sushi"})
+	if len(hash) != 64 {
+		t.Errorf("Expected a hex SHA-256, got %q", hash)
+	}
+	if again := optionsHash([]string{"Tacos", "Sushi"}); again != hash {
+		t.Errorf("Expected a stable hash, got %s then %s", hash, again)
+	}
+
+	// Order matters, and labels can't be split or merged to collide
+	for _, labels := range [][]string{
+		{"Sushi", "Tacos"},
+		{"Tacos", "Sushi", "Pizza"},
+		{"Tacos\n", "Sushi"},
+		{"Tac", "osSushi"},
+		{`Tacos","Sushi`},
+	} {
+		if optionsHash(labels) == hash {
+			t.Errorf("Expected %q to hash differently from [Tacos Sushi]", labels)
+		}
+	}
+}
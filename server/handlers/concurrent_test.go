@@ -5,13 +5,16 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"sync"
 	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/danielhkuo/quickly-pick/cliparse"
 	"github.com/danielhkuo/quickly-pick/models"
 	"github.com/danielhkuo/quickly-pick/testutil"
 )
@@ -208,9 +211,9 @@ func TestConcurrentPollClose(t *testing.T) {
 
 	wg.Wait()
 
-	// At least one should succeed
-	if successCount.Load() < 1 {
-		t.Error("Expected at least one successful close")
+	// ClosePoll locks the poll row, so exactly one close wins
+	if n := successCount.Load(); n != 1 {
+		t.Errorf("Expected exactly one successful close, got %d", n)
 	}
 
 	// Verify poll is closed
@@ -224,21 +227,165 @@ func TestConcurrentPollClose(t *testing.T) {
 		t.Errorf("Expected poll status 'closed', got '%s'", status)
 	}
 
-	// Verify at least one snapshot was created
-	// NOTE: Due to the race condition, multiple snapshots may be created.
-	// This is a known issue that would require transaction isolation to fix.
+	// Verify exactly one snapshot was created
 	var snapshotCount int
 	err = db.QueryRow("SELECT COUNT(*) FROM result_snapshot WHERE poll_id = $1", pollID).Scan(&snapshotCount)
 	if err != nil {
 		t.Fatalf("Failed to count snapshots: %v", err)
 	}
 
-	if snapshotCount < 1 {
-		t.Error("Expected at least 1 snapshot")
+	if snapshotCount != 1 {
+		t.Errorf("Expected 1 snapshot, got %d", snapshotCount)
 	}
-	if snapshotCount > 1 {
-		t.Logf("WARNING: Race condition detected - %d snapshots created (expected 1). "+
-			"Consider adding transaction isolation to ClosePoll handler.", snapshotCount)
+}
+
+// TestBallotDuringCloseIsRejected holds the poll row lock the way ClosePoll
+// does, submits a ballot while it is held, then closes the poll. The ballot
+// must wait for the close and then be rejected rather than slip in after
+// the snapshot.
+func TestBallotDuringCloseIsRejected(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	cfg := testutil.GetTestConfig()
+	votingHandler := NewVotingHandler(db, cfg)
+
+	pollID, _, shareSlug := testutil.CreateTestPoll(t, db, cfg, "open")
+	opt1 := testutil.AddTestOption(t, db, pollID, "A")
+	voterToken := testutil.CreateTestVoter(t, db, pollID, "LateVoter")
+
+	// Start "closing" the poll
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("Failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`SELECT 1 FROM poll WHERE id = $1 FOR UPDATE`, pollID); err != nil {
+		t.Fatalf("Failed to lock poll: %v", err)
+	}
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		body, _ := json.Marshal(models.SubmitBallotRequest{Scores: map[string]float64{opt1: 0.5}})
+		req := httptest.NewRequest("POST", "/polls/"+shareSlug+"/ballots", bytes.NewReader(body))
+		req.SetPathValue("slug", shareSlug)
+		req.Header.Set("X-Voter-Token", voterToken)
+		w := httptest.NewRecorder()
+		votingHandler.SubmitBallot(w, req)
+		done <- w
+	}()
+
+	// The submit has read status 'open' but must block on the lock
+	select {
+	case w := <-done:
+		t.Fatalf("Ballot completed while the poll was locked: %d %s", w.Code, w.Body.String())
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	if _, err := tx.Exec(`UPDATE poll SET status = 'closed', closed_at = NOW() WHERE id = $1`, pollID); err != nil {
+		t.Fatalf("Failed to close poll: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Failed to commit close: %v", err)
+	}
+
+	w := <-done
+	if w.Code != http.StatusConflict {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusConflict, w.Code, w.Body.String())
+	}
+	var resp models.ErrorResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Code != models.ErrorCodePollClosed {
+		t.Errorf("Expected code %q, got %q", models.ErrorCodePollClosed, resp.Code)
+	}
+
+	var ballotCount int
+	if err := db.QueryRow("SELECT COUNT(*) FROM ballot WHERE poll_id = $1", pollID).Scan(&ballotCount); err != nil {
+		t.Fatalf("Failed to count ballots: %v", err)
+	}
+	if ballotCount != 0 {
+		t.Errorf("Expected no ballots after the close, got %d", ballotCount)
+	}
+}
+
+// TestConcurrentCloseAndSubmit races ballot submissions against a close and
+// verifies the snapshot covers exactly the ballots that were accepted
+func TestConcurrentCloseAndSubmit(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	cfg := testutil.GetTestConfig()
+	pollHandler := NewPollHandler(db, cfg)
+	votingHandler := NewVotingHandler(db, cfg)
+
+	pollID, adminKey, shareSlug := testutil.CreateTestPoll(t, db, cfg, "open")
+	opt1 := testutil.AddTestOption(t, db, pollID, "A")
+	opt2 := testutil.AddTestOption(t, db, pollID, "B")
+
+	numVoters := 20
+	voterTokens := make([]string, numVoters)
+	for i := range voterTokens {
+		voterTokens[i] = testutil.CreateTestVoter(t, db, pollID, "RaceVoter"+string(rune('A'+i)))
+	}
+
+	var accepted atomic.Int32
+	var wg sync.WaitGroup
+	for i, voterToken := range voterTokens {
+		wg.Add(1)
+		go func(idx int, voterToken string) {
+			defer wg.Done()
+
+			body, _ := json.Marshal(models.SubmitBallotRequest{Scores: map[string]float64{opt1: 0.5, opt2: 0.5}})
+			req := httptest.NewRequest("POST", "/polls/"+shareSlug+"/ballots", bytes.NewReader(body))
+			req.SetPathValue("slug", shareSlug)
+			req.Header.Set("X-Voter-Token", voterToken)
+			w := httptest.NewRecorder()
+
+			votingHandler.SubmitBallot(w, req)
+			switch w.Code {
+			case http.StatusCreated:
+				accepted.Add(1)
+			case http.StatusConflict:
+			default:
+				t.Errorf("submit %d returned %d: %s", idx, w.Code, w.Body.String())
+			}
+		}(i, voterToken)
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		req := httptest.NewRequest("POST", "/polls/"+pollID+"/close", nil)
+		req.SetPathValue("id", pollID)
+		req.Header.Set("X-Admin-Key", adminKey)
+		w := httptest.NewRecorder()
+		pollHandler.ClosePoll(w, req)
+		if w.Code != http.StatusOK {
+			t.Errorf("close returned %d: %s", w.Code, w.Body.String())
+		}
+	}()
+
+	wg.Wait()
+
+	var ballotCount int
+	if err := db.QueryRow("SELECT COUNT(*) FROM ballot WHERE poll_id = $1", pollID).Scan(&ballotCount); err != nil {
+		t.Fatalf("Failed to count ballots: %v", err)
+	}
+	if ballotCount != int(accepted.Load()) {
+		t.Errorf("Expected %d stored ballots, got %d", accepted.Load(), ballotCount)
+	}
+
+	// Every stored ballot must be part of the sealed snapshot
+	var payloadJSON []byte
+	if err := db.QueryRow("SELECT payload FROM result_snapshot WHERE poll_id = $1", pollID).Scan(&payloadJSON); err != nil {
+		t.Fatalf("Failed to load snapshot: %v", err)
+	}
+	var payload snapshotPayload
+	if err := json.Unmarshal(payloadJSON, &payload); err != nil {
+		t.Fatalf("Failed to parse snapshot: %v", err)
+	}
+	if want := computeInputsHash(context.Background(), db, pollID); payload.InputsHash != want {
+		t.Errorf("Snapshot inputs_hash %q does not match stored ballots %q", payload.InputsHash, want)
 	}
 }
 
@@ -332,6 +479,79 @@ func TestConcurrentBallotUpdates(t *testing.T) {
 	}
 }
 
+// TestConcurrentFirstBallotForOneVoter hammers a single voter token that has
+// no ballot yet, where every submit races to create it
+func TestConcurrentFirstBallotForOneVoter(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	cfg := testutil.GetTestConfig()
+	votingHandler := NewVotingHandler(db, cfg)
+
+	pollID, _, shareSlug := testutil.CreateTestPoll(t, db, cfg, "open")
+	opt1 := testutil.AddTestOption(t, db, pollID, "A")
+	opt2 := testutil.AddTestOption(t, db, pollID, "B")
+
+	voterToken := testutil.CreateTestVoter(t, db, pollID, "RacingVoter")
+
+	numSubmits := 20
+	var serverErrors atomic.Int32
+	var wg sync.WaitGroup
+
+	for i := 0; i < numSubmits; i++ {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+
+			scores := map[string]float64{
+				opt1: float64(idx) / float64(numSubmits),
+				opt2: 1.0 - float64(idx)/float64(numSubmits),
+			}
+			body, _ := json.Marshal(models.SubmitBallotRequest{Scores: scores})
+			req := httptest.NewRequest("POST", "/polls/"+shareSlug+"/ballots", bytes.NewReader(body))
+			req.SetPathValue("slug", shareSlug)
+			req.Header.Set("X-Voter-Token", voterToken)
+			w := httptest.NewRecorder()
+
+			votingHandler.SubmitBallot(w, req)
+			if w.Code >= http.StatusInternalServerError {
+				serverErrors.Add(1)
+				t.Logf("submit %d returned %d: %s", idx, w.Code, w.Body.String())
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	if n := serverErrors.Load(); n > 0 {
+		t.Errorf("Expected no 5xx responses, got %d", n)
+	}
+
+	var ballotCount int
+	err := db.QueryRow("SELECT COUNT(*) FROM ballot WHERE poll_id = $1 AND voter_token = $2",
+		pollID, voterToken).Scan(&ballotCount)
+	if err != nil {
+		t.Fatalf("Failed to count ballots: %v", err)
+	}
+	if ballotCount != 1 {
+		t.Errorf("Expected 1 ballot, got %d", ballotCount)
+	}
+
+	// The surviving ballot must hold exactly one complete set of scores
+	var scoreCount int
+	err = db.QueryRow(`
+		SELECT COUNT(*) FROM score s
+		JOIN ballot b ON s.ballot_id = b.id
+		WHERE b.poll_id = $1 AND b.voter_token = $2
+	`, pollID, voterToken).Scan(&scoreCount)
+	if err != nil {
+		t.Fatalf("Failed to count scores: %v", err)
+	}
+	if scoreCount != 2 {
+		t.Errorf("Expected 2 scores, got %d", scoreCount)
+	}
+}
+
 // TestParallelPolls verifies that operations on different polls don't interfere
 func TestParallelPolls(t *testing.T) {
 	t.Parallel() // This test can run in parallel with others
@@ -436,3 +656,92 @@ func TestParallelPolls(t *testing.T) {
 		t.Errorf("Expected %d polls, got %d", numPolls, pollCount)
 	}
 }
+
+// TestCloseGracePeriodIncludesLateBallot verifies that a ballot written
+// during the close grace period is part of the sealed snapshot
+func TestCloseGracePeriodIncludesLateBallot(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	cfg := testutil.GetTestConfig()
+	cfg.CloseGracePeriod = 500
+	pollHandler := NewPollHandler(db, cfg)
+
+	pollID, adminKey, _ := testutil.CreateTestPoll(t, db, cfg, "open")
+	opt1 := testutil.AddTestOption(t, db, pollID, "A")
+	testutil.AddTestOption(t, db, pollID, "B")
+	emptyHash := computeInputsHash(context.Background(), db, pollID)
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		req := httptest.NewRequest("POST", "/polls/"+pollID+"/close", nil)
+		req.SetPathValue("id", pollID)
+		req.Header.Set("X-Admin-Key", adminKey)
+		w := httptest.NewRecorder()
+		pollHandler.ClosePoll(w, req)
+		done <- w
+	}()
+
+	// The close must still be waiting out the grace period
+	select {
+	case w := <-done:
+		t.Fatalf("Close completed before the grace period: %d %s", w.Code, w.Body.String())
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	voterToken := testutil.CreateTestVoter(t, db, pollID, "LateVoter")
+	testutil.SubmitTestBallot(t, db, pollID, voterToken, map[string]float64{opt1: 0.9})
+
+	w := <-done
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var resp models.ClosePollResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.Snapshot.InputsHash == emptyHash {
+		t.Error("Snapshot does not include the ballot written during the grace period")
+	}
+	if want := computeInputsHash(context.Background(), db, pollID); resp.Snapshot.InputsHash != want {
+		t.Errorf("Snapshot inputs_hash %q does not match stored ballots %q", resp.Snapshot.InputsHash, want)
+	}
+}
+
+// TestCloseGracePeriodRefusesEarly verifies that a close bound to fail is
+// refused without waiting out the grace period
+func TestCloseGracePeriodRefusesEarly(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	cfg := testutil.GetTestConfig()
+	cfg.CloseGracePeriod = cliparse.MaxCloseGracePeriod
+	pollHandler := NewPollHandler(db, cfg)
+
+	pollID, adminKey, _ := testutil.CreateTestPoll(t, db, cfg, "closed")
+
+	start := time.Now()
+	req := httptest.NewRequest("POST", "/polls/"+pollID+"/close", nil)
+	req.SetPathValue("id", pollID)
+	req.Header.Set("X-Admin-Key", adminKey)
+	w := httptest.NewRecorder()
+	pollHandler.ClosePoll(w, req)
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected status %d, got %d. Body: %s", http.StatusConflict, w.Code, w.Body.String())
+	}
+
+	body, _ := json.Marshal(models.CloseBatchRequest{Polls: []models.CloseBatchEntry{{ID: pollID, AdminKey: adminKey}}})
+	req = httptest.NewRequest("POST", "/polls:close-batch", bytes.NewReader(body))
+	w = httptest.NewRecorder()
+	pollHandler.CloseBatch(w, req)
+	var resp models.CloseBatchResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if len(resp.Results) != 1 || resp.Results[0].Outcome != models.CloseOutcomeSkippedNotOpen {
+		t.Errorf("Expected the closed poll skipped, got %+v", resp.Results)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected both requests refused before the grace period, took %v", elapsed)
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright (c) 2025 Daniel Kuo.
+// Source-available; no permission granted to use, copy, modify, or distribute. See LICENSE.
+
+package handlers
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultBallotCountTTL is how long a cached ballot count may be served
+const DefaultBallotCountTTL = 3 * time.Second
+
+// maxCachedCounts bounds the cache; expired entries are swept past this size
+const maxCachedCounts = 10000
+
+// BallotCountCache is an in-memory, TTL-based cache of ballot counts keyed
+// by poll ID. It spares hot polls a COUNT(*) per read, and SubmitBallot
+// invalidates a poll's entry so voters see their own ballot counted.
+// A nil cache or a zero TTL disables caching.
+type BallotCountCache struct {
+	ttl     time.Duration
+	now     func() time.Time
+	mu      sync.Mutex
+	entries map[string]ballotCountEntry
+	// generation increments on every Invalidate so a load that raced with
+	// a ballot write is never stored
+	generation uint64
+}
+
+type ballotCountEntry struct {
+	count     int
+	expiresAt time.Time
+}
+
+// NewBallotCountCache creates a cache serving counts for up to ttl
+func NewBallotCountCache(ttl time.Duration) *BallotCountCache {
+	return &BallotCountCache{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]ballotCountEntry),
+	}
+}
+
+// Get returns the cached count for pollID, calling load on a miss
+func (c *BallotCountCache) Get(pollID string, load func() (int, error)) (int, error) {
+	if c == nil || c.ttl <= 0 {
+		return load()
+	}
+
+	c.mu.Lock()
+	entry, ok := c.entries[pollID]
+	generation := c.generation
+	c.mu.Unlock()
+	if ok && c.now().Before(entry.expiresAt) {
+		return entry.count, nil
+	}
+
+	count, err := load()
+	if err != nil {
+		return 0, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.generation != generation {
+		return count, nil
+	}
+	if len(c.entries) >= maxCachedCounts {
+		c.sweepLocked()
+	}
+	c.entries[pollID] = ballotCountEntry{count: count, expiresAt: c.now().Add(c.ttl)}
+	return count, nil
+}
+
+// Invalidate drops the cached count for pollID
+func (c *BallotCountCache) Invalidate(pollID string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	delete(c.entries, pollID)
+	c.generation++
+	c.mu.Unlock()
+}
+
+// sweepLocked removes expired entries; c.mu must be held
+func (c *BallotCountCache) sweepLocked() {
+	now := c.now()
+	for pollID, entry := range c.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(c.entries, pollID)
+		}
+	}
+}
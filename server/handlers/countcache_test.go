@@ -0,0 +1,120 @@
+// Copyright (c) 2025 Daniel Kuo.
+// Source-available; no permission granted to use, copy, modify, or distribute. See LICENSE.
+
+package handlers
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBallotCountCache(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	cache := NewBallotCountCache(5 * time.Second)
+	cache.now = func() time.Time { return now }
+
+	loads := 0
+	count := 3
+	load := func() (int, error) {
+		loads++
+		return count, nil
+	}
+
+	get := func() int {
+		t.Helper()
+		n, err := cache.Get("poll-1", load)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		return n
+	}
+
+	if got := get(); got != 3 || loads != 1 {
+		t.Fatalf("Expected first read to load 3, got %d after %d loads", got, loads)
+	}
+
+	// Served from cache within the TTL, even if the source changed
+	count = 4
+	if got := get(); got != 3 || loads != 1 {
+		t.Errorf("Expected cached 3 with 1 load, got %d after %d loads", got, loads)
+	}
+
+	// Invalidation forces a fresh load
+	cache.Invalidate("poll-1")
+	if got := get(); got != 4 || loads != 2 {
+		t.Errorf("Expected 4 after invalidation, got %d after %d loads", got, loads)
+	}
+
+	// Expiry forces a fresh load
+	count = 5
+	now = now.Add(6 * time.Second)
+	if got := get(); got != 5 || loads != 3 {
+		t.Errorf("Expected 5 after expiry, got %d after %d loads", got, loads)
+	}
+}
+
+func TestBallotCountCacheDisabled(t *testing.T) {
+	loads := 0
+	load := func() (int, error) {
+		loads++
+		return 1, nil
+	}
+
+	for _, cache := range []*BallotCountCache{nil, NewBallotCountCache(0)} {
+		loads = 0
+		cache.Get("poll-1", load)
+		cache.Get("poll-1", load)
+		cache.Invalidate("poll-1")
+		if loads != 2 {
+			t.Errorf("Expected every read to load when disabled, got %d loads", loads)
+		}
+	}
+}
+
+func TestBallotCountCacheLoadError(t *testing.T) {
+	cache := NewBallotCountCache(time.Minute)
+	errLoad := errors.New("db down")
+
+	if _, err := cache.Get("poll-1", func() (int, error) { return 0, errLoad }); err != errLoad {
+		t.Fatalf("Expected load error, got %v", err)
+	}
+
+	// Errors are never cached
+	n, err := cache.Get("poll-1", func() (int, error) { return 7, nil })
+	if err != nil || n != 7 {
+		t.Errorf("Expected 7 after a failed load, got %d, %v", n, err)
+	}
+}
+
+func TestBallotCountCacheSkipsRacedLoad(t *testing.T) {
+	cache := NewBallotCountCache(time.Minute)
+
+	// A ballot lands while the count is being loaded
+	n, _ := cache.Get("poll-1", func() (int, error) {
+		cache.Invalidate("poll-1")
+		return 1, nil
+	})
+	if n != 1 {
+		t.Fatalf("Expected 1, got %d", n)
+	}
+
+	// The possibly stale result must not have been cached
+	n, _ = cache.Get("poll-1", func() (int, error) { return 2, nil })
+	if n != 2 {
+		t.Errorf("Expected a fresh load of 2, got %d", n)
+	}
+}
+
+func BenchmarkBallotCountCacheGet(b *testing.B) {
+	cache := NewBallotCountCache(time.Minute)
+	load := func() (int, error) { return 42, nil }
+	cache.Get("poll-1", load)
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			cache.Get("poll-1", load)
+		}
+	})
+}
@@ -5,8 +5,10 @@ package handlers
 
 import (
 	"database/sql"
+	"encoding/base64"
 	"log/slog"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/danielhkuo/quickly-pick/auth"
@@ -34,7 +36,7 @@ func (h *DeviceHandler) Register(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req models.RegisterDeviceRequest
-	if err := middleware.ParseJSONBody(r, &req); err != nil {
+	if err := middleware.DecodeRequiredJSON(r, &req); err != nil {
 		middleware.ErrorResponse(w, http.StatusBadRequest, "Invalid JSON")
 		return
 	}
@@ -55,7 +57,7 @@ func (h *DeviceHandler) Register(w http.ResponseWriter, r *http.Request) {
 		// Device exists, update last_seen_at
 		_, err = h.db.Exec(`
 			UPDATE device SET last_seen_at = $1 WHERE id = $2
-		`, time.Now(), existingID)
+		`, time.Now().UTC(), existingID)
 		if err != nil {
 			slog.Error("failed to update device last_seen_at", "error", err)
 		}
@@ -75,14 +77,14 @@ func (h *DeviceHandler) Register(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Create new device
-	deviceID, err := auth.GenerateID(16)
+	deviceID, err := auth.GenerateID(recordIDBytes)
 	if err != nil {
 		slog.Error("failed to generate device ID", "error", err)
 		middleware.ErrorResponse(w, http.StatusInternalServerError, "Failed to register device")
 		return
 	}
 
-	now := time.Now()
+	now := time.Now().UTC()
 	_, err = h.db.Exec(`
 		INSERT INTO device (id, device_uuid, platform, created_at, last_seen_at)
 		VALUES ($1, $2, $3, $4, $5)
@@ -131,7 +133,7 @@ func (h *DeviceHandler) GetMe(w http.ResponseWriter, r *http.Request) {
 	// Update last_seen_at
 	_, err = h.db.Exec(`
 		UPDATE device SET last_seen_at = $1 WHERE id = $2
-	`, time.Now(), device.ID)
+	`, time.Now().UTC(), device.ID)
 	if err != nil {
 		slog.Error("failed to update device last_seen_at", "error", err)
 	}
@@ -139,8 +141,35 @@ func (h *DeviceHandler) GetMe(w http.ResponseWriter, r *http.Request) {
 	middleware.JSONResponse(w, http.StatusOK, device)
 }
 
+// encodeMyPollsCursor returns the opaque GetMyPolls cursor for the page
+// after the link at linkedAt to pollID
+func encodeMyPollsCursor(linkedAt time.Time, pollID string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(linkedAt.UTC().Format(time.RFC3339Nano) + "|" + pollID))
+}
+
+// decodeMyPollsCursor reverses encodeMyPollsCursor. ok is false when
+// cursor wasn't made by it.
+func decodeMyPollsCursor(cursor string) (linkedAt time.Time, pollID string, ok bool) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, "", false
+	}
+	at, pollID, found := strings.Cut(string(raw), "|")
+	if !found || pollID == "" {
+		return time.Time{}, "", false
+	}
+	linkedAt, err = time.Parse(time.RFC3339Nano, at)
+	if err != nil {
+		return time.Time{}, "", false
+	}
+	return linkedAt, pollID, true
+}
+
 // GetMyPolls handles GET /devices/my-polls
-// Returns polls where this device is admin or voter
+// Returns polls where this device is admin or voter, most recently linked
+// first. ?limit= pages the list: each full page carries a next_cursor to
+// pass back as ?cursor=, which stays stable while polls are linked
+// concurrently. ?offset= still works but shifts under such inserts.
 func (h *DeviceHandler) GetMyPolls(w http.ResponseWriter, r *http.Request) {
 	deviceUUID := r.Header.Get("X-Device-UUID")
 	if deviceUUID == "" {
@@ -148,6 +177,28 @@ func (h *DeviceHandler) GetMyPolls(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var errs fieldErrors
+	limit := errs.queryInt(r, "limit", 0, 1)
+	offset := errs.queryInt(r, "offset", 0, 0)
+	var after sql.NullTime
+	var afterPollID string
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		linkedAt, pollID, ok := decodeMyPollsCursor(cursor)
+		switch {
+		case !ok:
+			errs.add("cursor", models.FieldCodeInvalid, "cursor must be a next_cursor returned by this endpoint")
+		case offset > 0:
+			errs.add("offset", models.FieldCodeInvalid, "offset cannot be combined with cursor")
+		default:
+			after = sql.NullTime{Time: linkedAt, Valid: true}
+			afterPollID = pollID
+		}
+	}
+	if errs.any() {
+		middleware.ValidationErrorResponse(w, errs)
+		return
+	}
+
 	// Get device ID
 	var deviceID string
 	err := h.db.QueryRow(`
@@ -167,12 +218,17 @@ func (h *DeviceHandler) GetMyPolls(w http.ResponseWriter, r *http.Request) {
 	// Update last_seen_at
 	_, err = h.db.Exec(`
 		UPDATE device SET last_seen_at = $1 WHERE id = $2
-	`, time.Now(), deviceID)
+	`, time.Now().UTC(), deviceID)
 	if err != nil {
 		slog.Error("failed to update device last_seen_at", "error", err)
 	}
 
-	// Get polls linked to this device with metadata
+	// Get polls linked to this device with metadata. One row past the page
+	// shows whether another follows.
+	fetch := 0
+	if limit > 0 {
+		fetch = limit + 1
+	}
 	rows, err := h.db.Query(`
 		SELECT
 			p.id,
@@ -186,8 +242,10 @@ func (h *DeviceHandler) GetMyPolls(w http.ResponseWriter, r *http.Request) {
 		FROM device_poll dp
 		JOIN poll p ON dp.poll_id = p.id
 		WHERE dp.device_id = $1
-		ORDER BY dp.linked_at DESC
-	`, deviceID)
+		  AND ($2::timestamptz IS NULL OR (dp.linked_at, dp.poll_id) < ($2, $3))
+		ORDER BY dp.linked_at DESC, dp.poll_id DESC
+		LIMIT NULLIF($4, 0) OFFSET $5
+	`, deviceID, after, afterPollID, fetch, offset)
 
 	if err != nil {
 		slog.Error("failed to query device polls", "error", err)
@@ -230,16 +288,108 @@ func (h *DeviceHandler) GetMyPolls(w http.ResponseWriter, r *http.Request) {
 
 		polls = append(polls, summary)
 	}
+	if err := rows.Err(); err != nil {
+		slog.Error("failed to read device polls", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	response := models.GetMyPollsResponse{Polls: polls}
+	if limit > 0 && len(polls) > limit {
+		response.Polls = polls[:limit]
+		last := response.Polls[limit-1]
+		next := encodeMyPollsCursor(last.LinkedAt, last.PollID)
+		response.NextCursor = &next
+	}
+	middleware.JSONResponse(w, http.StatusOK, response)
+}
+
+// defaultActiveWindow is the window GetActiveDevices counts over by default
+const defaultActiveWindow = 24 * time.Hour
+
+// maxActiveWindow bounds the window GetActiveDevices accepts
+const maxActiveWindow = 365 * 24 * time.Hour
+
+// GetActiveDevices handles GET /admin/active-devices
+// Counts devices whose last_seen_at falls within ?window= (a Go duration
+// such as 24h or 90m). Requires the X-Server-Key header.
+func (h *DeviceHandler) GetActiveDevices(w http.ResponseWriter, r *http.Request) {
+	if err := auth.ValidateServerKey(r.Header.Get("X-Server-Key"), h.cfg.ServerAdminKey); err != nil {
+		middleware.ErrorResponse(w, http.StatusUnauthorized, "Invalid server admin key")
+		return
+	}
 
-	middleware.JSONResponse(w, http.StatusOK, models.GetMyPollsResponse{
-		Polls: polls,
+	window := defaultActiveWindow
+	if value := r.URL.Query().Get("window"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 || parsed > maxActiveWindow {
+			middleware.ValidationErrorResponse(w, []models.FieldError{{
+				Field:   "window",
+				Code:    models.FieldCodeInvalid,
+				Message: "window must be a positive duration of at most 8760h, e.g. 24h",
+			}})
+			return
+		}
+		window = parsed
+	}
+
+	since := time.Now().UTC().Add(-window)
+	var count int
+	err := h.db.QueryRow(`
+		SELECT COUNT(*) FROM device WHERE last_seen_at >= $1
+	`, since).Scan(&count)
+	if err != nil {
+		slog.Error("failed to count active devices", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	middleware.JSONResponse(w, http.StatusOK, models.ActiveDevicesResponse{
+		Window:        window.String(),
+		Since:         since,
+		ActiveDevices: count,
 	})
 }
 
+// DeviceTrackingDisabled answers every device route when
+// cfg.DisableDevices is set, so clients can tell the feature is off rather
+// than missing
+func DeviceTrackingDisabled(w http.ResponseWriter, r *http.Request) {
+	middleware.ErrorResponseWithCode(w, http.StatusGone, models.ErrorCodeDeviceTrackingDisabled,
+		"Device tracking is disabled on this server")
+}
+
+// requestDeviceUUID returns the X-Device-UUID header, or "" when cfg
+// disables device tracking
+func requestDeviceUUID(cfg cliparse.Config, r *http.Request) string {
+	if cfg.DisableDevices {
+		return ""
+	}
+	return r.Header.Get("X-Device-UUID")
+}
+
+// registeredDeviceUUID returns the request's X-Device-UUID when a device
+// with it is already recorded, and "" otherwise. Unlike GetOrCreateDevice
+// it records nothing, so a made-up UUID gets the caller nothing.
+func registeredDeviceUUID(db *sql.DB, cfg cliparse.Config, r *http.Request) (string, error) {
+	deviceUUID := requestDeviceUUID(cfg, r)
+	if deviceUUID == "" {
+		return "", nil
+	}
+
+	var exists bool
+	err := db.QueryRow(`SELECT EXISTS(SELECT 1 FROM device WHERE device_uuid = $1)`, deviceUUID).Scan(&exists)
+	if err != nil || !exists {
+		return "", err
+	}
+	return deviceUUID, nil
+}
+
 // GetOrCreateDevice looks up or creates a device record from the X-Device-UUID header.
-// Returns device ID and whether it was newly created. Returns empty string if no header.
-func GetOrCreateDevice(db *sql.DB, r *http.Request) (string, error) {
-	deviceUUID := r.Header.Get("X-Device-UUID")
+// Returns an empty device ID, without touching db, if there is no header or
+// cfg disables device tracking.
+func GetOrCreateDevice(db *sql.DB, cfg cliparse.Config, r *http.Request) (string, error) {
+	deviceUUID := requestDeviceUUID(cfg, r)
 	if deviceUUID == "" {
 		return "", nil
 	}
@@ -252,7 +402,7 @@ func GetOrCreateDevice(db *sql.DB, r *http.Request) (string, error) {
 
 	if err == nil {
 		// Update last_seen_at
-		_, _ = db.Exec(`UPDATE device SET last_seen_at = $1 WHERE id = $2`, time.Now(), deviceID)
+		_, _ = db.Exec(`UPDATE device SET last_seen_at = $1 WHERE id = $2`, time.Now().UTC(), deviceID)
 		return deviceID, nil
 	}
 
@@ -262,12 +412,12 @@ func GetOrCreateDevice(db *sql.DB, r *http.Request) (string, error) {
 
 	// Create new device with 'web' as default platform
 	// (actual platform is set via /devices/register)
-	deviceID, err = auth.GenerateID(16)
+	deviceID, err = auth.GenerateID(recordIDBytes)
 	if err != nil {
 		return "", err
 	}
 
-	now := time.Now()
+	now := time.Now().UTC()
 	_, err = db.Exec(`
 		INSERT INTO device (id, device_uuid, platform, created_at, last_seen_at)
 		VALUES ($1, $2, $3, $4, $5)
@@ -298,7 +448,7 @@ func LinkDeviceToPoll(db *sql.DB, deviceID, pollID, role string, voterToken *str
 		ON CONFLICT (device_id, poll_id) DO UPDATE SET
 			role = CASE WHEN device_poll.role = 'admin' THEN 'admin' ELSE EXCLUDED.role END,
 			voter_token = COALESCE(device_poll.voter_token, EXCLUDED.voter_token)
-	`, deviceID, pollID, vt, role, time.Now())
+	`, deviceID, pollID, vt, role, time.Now().UTC())
 
 	return err
 }
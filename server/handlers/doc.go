@@ -23,28 +23,344 @@ Polls progress through three states: draft → open → closed
 
 	POST /polls           → CreatePoll (returns admin_key)
 	POST /polls/{id}/options → AddOption (draft only)
-	POST /polls/{id}/publish → PublishPoll (generates share_slug)
-	POST /polls/{id}/close   → ClosePoll (computes BMJ results)
+	POST /polls/{id}/options:import → ImportOptions (one label per line, skips duplicates)
+	DELETE /polls/{id}/options/{option_id} → DeleteOption (draft only, keeps at least 2)
+	POST /polls/{id}/publish → PublishPoll (generates share_slug; repeating it on an open poll returns the same one)
+	PUT /polls/{id}/vanity-slug → SetVanitySlug (open only, unique)
+	PUT /polls/{id}/close-webhook → SetCloseWebhook (draft or open, returns webhook_secret)
+	PATCH /polls/{id}/closes-at → SetClosesAt (draft or open, null clears it)
+	POST /polls/{id}/pause   → PausePoll (open only)
+	POST /polls/{id}/resume  → ResumePoll (open only)
+	POST /polls/{id}/close   → ClosePoll (computes BMJ results, optional per-voter breakdown)
+	POST /polls:close-batch  → CloseBatch (admin keys in the body, up to 50 polls)
+	PUT /polls/{id}/reveal-at → SetRevealAt (closed only, null lifts the embargo)
+	POST /polls/{id}/recompute → RecomputeResults (closed only, new final snapshot)
+	GET /polls/{id}/ballots.csv → ExportBallotsCSV (closed only, streamed)
+	POST /polls/{id}/duplicate → DuplicatePoll (clones as a new draft)
 
 Admin operations require the X-Admin-Key header.
 
+	GET /polls/{id}/admin → GetPollAdmin (drafts include future_share_slug and future_share_url)
+	GET /polls/{id}/admin/actions → GetAdminActions (audit log, newest first)
+	GET /polls/{id}/stability → GetStability (bootstrap win shares)
+	GET /polls/{id}/scores → GetRawScores (closed only, sorted signed scores per option)
+
+CreatePoll accepts an optional Idempotency-Key header (up to 255 bytes,
+e.g. a UUID) so a mobile client can retry safely. A retry with the same
+key from the same device within IdempotencyKeyTTL (24 hours) returns the
+original poll_id and admin_key with 201 instead of creating another poll.
+The key is only honored when X-Device-UUID names a device already
+registered, and keys are scoped to that device so clients never collide;
+without one the header is ignored. Reusing a key with a different body
+gets 422, code idempotency_key_reused.
+
+With cfg.MinPollCreateInterval set, a client creating another poll within
+that many seconds of its last one gets 429, code poll_create_too_soon, and
+a Retry-After header. The hashed client IP is always checked, from the
+connection unless cfg.TrustProxyHeaders is set, and so is the device when
+X-Device-UUID names one already registered; a made-up device UUID counts
+for nothing. An Idempotency-Key retry is never throttled. The
+ExpirySweeper deletes creation times once they are older than the
+interval.
+DuplicatePoll creates a poll too, so it shares the same interval.
+
+A creator may leave an opaque creator_contact (up to 512 bytes, e.g. an
+email address or push token) when creating a poll, for close
+notifications. Only GetPollAdmin returns it; it is not a Poll field, so no
+public endpoint can expose it.
+
+Option labels are compared ignoring case and runs of whitespace, so
+"Pizza" and " pizza" are the same option. AddOption rejects a duplicate
+with 409 and code duplicate_option; ImportOptions, meant for pasted
+lists, skips it instead. cfg.AllowDuplicateOptions turns the check off.
+
+AddOption also takes optional metadata, a JSON object of up to 1 KB that
+clients use for things like a slider color or icon. The server never reads
+it; GetPoll returns it with the option and DuplicatePoll copies it.
+
+RecomputeResults ranks a closed poll's ballots again after the BMJ
+parameters change or a ranking bug is fixed. It writes a new snapshot and
+repoints final_snapshot_id at it; the old snapshot stays in the results
+history and no ballot is modified. No webhook is sent. It also heals a
+poll whose final snapshot has gone missing, e.g. after a manual database
+edit: GetResults and GetResultsText answer such a poll with 500 and code
+snapshot_missing, logging the poll ID, until its results are recomputed.
+
+ExportBallotsCSV archives a closed poll's ballots: one row per voter with
+username, submitted_at (RFC 3339, UTC), and a column per option, headed by
+its label, holding value01 or a blank for an unscored option. Voter tokens
+and IP hashes are never exported. A username or label starting with =, +,
+-, @, a tab, or a carriage return gets a leading ' so spreadsheets don't
+run it as a formula.
+
+When a poll with a close webhook closes, the snapshot is POSTed to it in
+the background as a CloseWebhookPayload. The WebhookSignatureHeader holds
+"sha256=" and the HMAC-SHA256 of the body keyed by the poll's webhook
+secret. Deliveries time out after five seconds and are not retried;
+failures are logged and never affect the close. Webhooks only reach
+public addresses, checked after DNS resolution, and redirects are not
+followed. Close webhooks need cfg.WebhookSalt.
+
+A poll's closes_at, given to CreatePoll or SetClosesAt, must be after both
+the current time and opened_at; otherwise the request fails with 400 and
+code invalid_closes_at. PublishPoll applies the same check, since a time
+chosen while drafting may have passed by then.
+
+Pausing an open poll halts voting while the admin fixes its options:
+ClaimUsername and SubmitBallot fail with 409 and code poll_paused, while
+reads such as GetPoll and GetPreview carry on, with poll.paused set.
+ResumePoll lets voting continue; closing a paused poll clears the flag.
+
+With cfg.CloseGracePeriod set, ClosePoll waits that long before sealing so
+ballots committed on other instances just before the close are counted.
+The trade-off: every close request takes that much longer, and the poll
+keeps accepting ballots until the wait ends. A close that would fail, for
+a poll that isn't open or is within its minimum open duration, is refused
+before the wait. cliparse caps the period at MaxCloseGracePeriod.
+
+ClosePoll ranks the ballots synchronously, so its response reports
+compute_ms, the time spent ranking, and ballot_count, the ballots sealed.
+Both are logged with the close as well, to help diagnose slow closes of
+large polls.
+
+ClosePoll accepts an optional body with a future reveal_at, and
+SetRevealAt moves or lifts it later. Until reveal_at passes, GetResults
+answers the public with 403, code results_embargoed, and the reveal_at
+time; the poll's admin, sending X-Admin-Key, sees the results as usual.
+
+With cfg.PollMaxAge, cfg.PollPurgeAge, or cfg.MinPollCreateInterval set,
+main.go runs an ExpirySweeper every DefaultExpirySweepInterval. It closes
+open polls created more than PollMaxAge hours ago through the same path
+as ClosePoll, so snapshots and webhooks are unchanged, deletes polls
+closed more than PollPurgeAge hours ago, and prunes poll creation times. Each close locks the poll row
+and rechecks its status, so a manual close racing the sweeper wins or
+loses cleanly.
+
+Publishing, closing, and removing an option are recorded in the
+admin_action table by auditAdminAction, inside the same transaction as the
+change, along with the caller's hashed IP. Closes by the ExpirySweeper are
+recorded without one. A publish repeated on an open poll changes nothing
+and is not recorded.
+
+PublishPoll also stores options_hash, the hex SHA-256 of the option labels
+in display order encoded as a JSON array, and GetPoll returns it. Each
+results snapshot hashes the options it ranked the same way, so a snapshot
+whose options_hash differs from the poll's tells clients the options
+changed after voting began.
+
+CloseBatch closes several polls for an admin who manages many. Each entry
+carries its own admin key, and each poll is closed in its own transaction,
+so one failure never undoes or stops the others. Every entry gets an
+outcome: closed, skipped_not_open, unauthorized, not_found, too_early, or
+failed. The grace period is waited out once for the whole batch, only by
+entries that could still close, and no breakdown is returned.
+
 # Voting Flow
 
-Voters interact via the share slug:
+Voters interact via the share slug. A poll's vanity slug, if set, resolves
+to the same poll anywhere a slug is accepted:
 
 	POST /polls/{slug}/claim-username → ClaimUsername (returns voter_token)
+	PATCH /polls/{slug}/username      → RenameUsername (open only, keeps token and ballot)
 	POST /polls/{slug}/ballots        → SubmitBallot (create or update)
+	POST /ballots:sync                → SyncBallots (up to 50 queued ballots, tokens in the body)
+
+Voter operations require the X-Voter-Token header. A ballot's scores must
+be an object of numbers; each score that isn't a number is reported as a
+field error on scores.{option_id} with code wrong_type, and each option ID
+repeated within the object with code duplicate_option_key. Adding
+?validate_only=true to a ballot submission runs every check and returns
+{"valid": true} or the usual errors, without writing anything.
+
+SyncBallots submits ballots a client queued while offline, each entry
+naming its slug and voter_token. Every entry is checked like SubmitBallot
+and written in its own transaction, so a refused entry doesn't fail the
+batch: each result carries the status SubmitBallot would have answered
+with and, on failure, its error body.
+
+With cfg.MinBallotInterval set, updating a ballot again within that many
+seconds fails with 429, code ballot_too_soon, and a Retry-After header.
+A voter's first ballot is always accepted.
+
+With cfg.MaxBallotsPerIP set, a new ballot fails with 429 and code
+ip_ballot_limit once that many ballots on the poll share the caller's IP
+hash; updating an existing ballot is always allowed. Voters behind one NAT
+share an address, so the limit is off by default and should be generous.
+The address is the connection's, or with cfg.TrustProxyHeaders the one
+the proxy forwarded; never an X-Forwarded-For the client made up.
+cfg.MaxBallotsPerPoll likewise caps the ballots one poll may hold: a new
+voter gets 429 and code poll_full, while existing voters can still update.
+
+Usernames must be cfg.MinUsernameLength to cfg.MaxUsernameLength bytes
+long (2-50 unless configured). A poll created with min_username_length or
+max_username_length narrows those bounds for its voters; CreatePoll rejects
+bounds outside the server's, or a minimum not below the maximum.
+
+A poll created with allow_ballot_updates set to false takes each voter's
+first ballot as final: submitting again fails with 409 and code
+ballot_locked, including with ?validate_only=true.
+
+A poll created with creator_can_vote set to false keeps its organizer
+neutral. The creator is known only by the device linked to the poll as
+admin, so ClaimUsername fails with 403 and code creator_voting_disabled
+when X-Device-UUID names that device, and SubmitBallot and SyncBallots
+refuse a ballot sent from it or with a voter token it claimed. Without
+device tracking the flag has no effect.
+
+A voter who kept their tokens but has no registered device can list the
+polls they joined. Each token belongs to one poll, so several may be sent,
+comma-separated in X-Voter-Token or as voter_tokens in the body (up to
+50). Tokens that match no claim are skipped silently:
+
+	GET /voters/my-polls → GetVoterPolls (username and has_voted per poll)
+
+Polls with many options can be loaded a page at a time. ?limit= and
+?offset= select the page, and total counts every option:
+
+	GET /polls/{slug}/options → GetOptions (options in display order)
+
+Turnout charts count ballots per ?bucket= of submission time (a Go
+duration from 1m to 168h, default 1h), while the poll is open or closed:
+
+	GET /polls/{slug}/ballots/count-by-time → GetBallotCountByTime
+
+GetPoll, GetOptions, GetResults, and GetSummary answer Accept:
+application/msgpack with MessagePack instead of JSON. Option metadata is
+a map in MessagePack, just as it is an object in JSON.
+
+Descriptions are returned exactly as the creator wrote them. With
+cfg.SafeDescriptions set, every poll in a response also carries
+description_safe: the description with HTML tags stripped and the rest
+escaped, for clients that insert it into a page. It is present even when
+empty, so clients can rely on it instead of falling back to the raw
+description.
+
+Voting screens can load everything in one call; has_voted is included when
+X-Voter-Token or X-Device-UUID is sent:
 
-Voter operations require the X-Voter-Token header.
+	GET /polls/{slug}/summary → GetSummary (poll, options, counts)
+
+Feeds of shared polls can fetch several previews at once; unknown slugs are
+marked not_found individually:
+
+	POST /polls/previews → GetPreviews (up to 50 slugs)
+
+A poll ID shared before publishing can be checked without the admin key.
+Only the title, status, and option count are returned:
+
+	GET /polls/{id}/public-status → GetPublicStatus
 
 # BMJ Algorithm
 
 The Balanced Majority Judgment algorithm is implemented in bmj.go:
 
-	rankings, err := ComputeBMJRankings(db, pollID)
+	rankings, err := ComputeBMJRankings(r.Context(), db, pollID)
 
 This computes median, P10, P90, mean, negative share, and veto status
-for each option, then ranks them lexicographically.
+for each option, then ranks them lexicographically. Result snapshots record
+the method, veto threshold (BMJVetoThreshold unless BMJOptions overrides
+it), and BMJAlgorithmVersion alongside the rankings, so old results remain
+interpretable if those parameters change.
+
+Each option also reports vote_count, the ballots that scored it. With
+cfg.LowSampleThreshold set, an option scored by fewer ballots is marked
+low_sample so clients can caveat statistics drawn from a handful of
+voters. Snapshots store both fields as computed at close.
+
+Unscored options are normally left out: a ballot that skips an option
+adds nothing to that option's statistics, so an option is judged only by
+the voters who scored it. A poll created with default_unscored (0 to 1,
+e.g. 0.5 for "meh") changes that. Every ballot that scored at least one
+option is read as scoring each option it skipped at default_unscored, so
+all options are judged by the same voters. The filled-in values shape
+every statistic, including the veto, but not vote_count, which still
+counts only real scores. Changing a poll's options after ballots arrive
+therefore also changes what those ballots contribute. Without
+default_unscored, rankings are unchanged.
+
+Queries run under the request's context, so a client that disconnects
+cancels the computation instead of leaving it running.
+
+GetStability gives organizers a sense of how robust a ranking is. It
+draws ?resamples= bootstrap samples (default DefaultStabilityResamples,
+at most MaxStabilityResamples) of the counted ballots with replacement,
+ranks each exactly as the results would, and reports for every option the
+share of samples it won. A winner near 1.0 would survive a different
+turnout; shares split between options mean the result is close. It
+answers 409 unless the poll is closed, or open with live results, so it
+never reveals sealed rankings. Resamples are cut so no request draws more
+than MaxStabilityDraws ballots in all; resamples in the response is the
+number actually drawn, and is 0 when a single resample would exceed it.
+
+When options share first place on every statistic, only the final
+tie-break (option ID by default) orders them. GetResults then lists them
+under tie.option_ids; tie is null when the winner is clear.
+
+Statistics are reported on the signed axis s = 2*value01 - 1. GetResults
+returns it as score_scale (BMJScoreScale) so clients can draw the same axis.
+
+GetResults embeds option labels in the rankings by default. The include
+parameter picks the option fields instead; an empty value trims both:
+
+	GET /polls/{slug}/results?include=labels,descriptions
+
+Median, p10, p90, mean, and neg_share are rounded to cfg.ResultDigits
+decimal places in the response only; snapshots keep full precision, and
+?precision=full returns it. Previews carry no statistics, so they are
+unaffected.
+
+Keys are snake_case by default. ?case=camel renames every key in
+GetResults and GetResultsHistory bodies to camelCase (option_id becomes
+optionId) for clients that follow that convention; error bodies keep
+their snake_case fields either way.
+
+GetResultsText renders a closed poll's final ranking as text/plain for
+pasting into a group chat: the title, then one numbered line per option
+with its median, a trophy on the winner, and "(vetoed)" in place of the
+median for vetoed options. It shares GetResults' 403s, but never serves
+live results:
+
+	GET /polls/{slug}/results.txt → GetResultsText
+
+Results stay sealed (403) while a poll is open, unless it was created
+with live_results. GetResults then recomputes the rankings on every
+request and returns them with provisional set to true; nothing is stored
+until the poll closes.
+
+A ballot is marked is_test when its voter token is linked to the poll's
+admin device, as when the creator votes to try the poll out. Polls
+created with exclude_test_ballots leave those ballots out of
+ComputeBMJRankings. GetResults still counts them in ballot_count and
+reports the ballots the rankings use as counted_ballots.
+
+Every sealed snapshot is kept. The poll's admin can list them all, newest
+first, with a compact ranking each:
+
+	GET /polls/{slug}/results/history → GetResultsHistory (requires X-Admin-Key)
+
+# Ballot Count Cache
+
+GetBallotCount and GetPreview read counts through a BallotCountCache shared
+with the voting handler, which invalidates a poll's entry on every ballot
+write. Entries otherwise expire after DefaultBallotCountTTL:
+
+	counts := NewBallotCountCache(DefaultBallotCountTTL)
+	voting := NewVotingHandlerWithCache(db, cfg, counts)
+	results := NewResultsHandlerWithCache(db, cfg, counts)
+
+A nil cache or zero TTL disables caching.
+
+# Read Replica
+
+ResultsHandler can send its heavier reads to a replica: GetResults,
+GetPreview, GetPreviews, and GetBallotCount. Poll and option lookups
+elsewhere, and the voter's own has_voted flag, still come from the primary,
+so a poll is visible as soon as it is created or edited:
+
+	results := NewResultsHandlerWithReplica(db, readDB, cfg, counts)
+
+Counts, results, and previews may then trail the primary by the
+replication lag.
 
 # Device Tracking
 
@@ -55,5 +371,28 @@ Optional device tracking for native apps:
 	GET /devices/my-polls  → GetMyPolls
 
 Device operations require the X-Device-UUID header.
+
+GetMyPolls lists the most recently linked polls first. With ?limit= each
+full page returns a next_cursor, encoding the last row's linked_at and
+poll_id, to pass back as ?cursor=; polls linked meanwhile sort before the
+cursor, so pages never repeat or skip a poll. ?offset= remains for older
+clients but cannot be combined with cursor.
+
+A device holds one voter identity per poll. ClaimUsername with an
+X-Device-UUID that already claimed a name on the poll returns 200 with
+that claim's voter_token and username instead of minting a new one; the
+requested name is ignored. Concurrent first claims from one device settle
+on whichever the device link kept, and the other claim is removed.
+
+With cfg.DisableDevices set, no device rows are written: CreatePoll,
+DuplicatePoll, and ClaimUsername skip linking, GetSummary ignores
+X-Device-UUID, and the router answers device routes with
+DeviceTrackingDisabled (410).
+
+Operators can count recently active devices, using the last_seen_at stamps
+the device endpoints maintain. This requires the X-Server-Key header to
+match the configured server admin key:
+
+	GET /admin/active-devices?window=24h → GetActiveDevices
 */
 package handlers
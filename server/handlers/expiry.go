@@ -0,0 +1,194 @@
+// Copyright (c) 2025 Daniel Kuo.
+// Source-available; no permission granted to use, copy, modify, or distribute. See LICENSE.
+
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/danielhkuo/quickly-pick/cliparse"
+	"github.com/danielhkuo/quickly-pick/models"
+)
+
+// DefaultExpirySweepInterval is how often the expiry sweeper runs
+const DefaultExpirySweepInterval = 5 * time.Minute
+
+// expirySweepBatch caps the polls one sweep closes, and the polls it reads
+// at a time, so a backlog is worked off over several sweeps instead of in
+// one long pass
+const expirySweepBatch = 100
+
+// ExpirySweeper enforces cfg.PollMaxAge and cfg.PollPurgeAge. It closes
+// open polls created more than PollMaxAge hours ago once their minimum
+// open duration has passed, sealing their results as ClosePoll would, and
+// deletes polls closed more than PollPurgeAge hours ago. With
+// cfg.MinPollCreateInterval set it also deletes creation times that have
+// aged past the interval. Every instance may run one: closes take the same
+// row lock as a manual close, so a poll closed by an admin or another
+// sweeper in the meantime is skipped.
+type ExpirySweeper struct {
+	db             *sql.DB
+	polls          *PollHandler
+	maxAge         time.Duration
+	purgeAge       time.Duration
+	createInterval time.Duration
+}
+
+// NewExpirySweeper creates a sweeper for the limits in cfg
+func NewExpirySweeper(db *sql.DB, cfg cliparse.Config) *ExpirySweeper {
+	return &ExpirySweeper{
+		db:             db,
+		polls:          NewPollHandler(db, cfg),
+		maxAge:         time.Duration(cfg.PollMaxAge) * time.Hour,
+		purgeAge:       time.Duration(cfg.PollPurgeAge) * time.Hour,
+		createInterval: time.Duration(cfg.MinPollCreateInterval) * time.Second,
+	}
+}
+
+// Enabled reports whether cfg sets any limit for the sweeper to enforce
+func (s *ExpirySweeper) Enabled() bool {
+	return s.maxAge > 0 || s.purgeAge > 0 || s.createInterval > 0
+}
+
+// Run sweeps immediately and then every interval until ctx is done
+func (s *ExpirySweeper) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.Sweep(ctx); err != nil && ctx.Err() == nil {
+			slog.Error("expiry sweep failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sweep makes one pass, closing expired open polls, purging old closed
+// ones, and pruning stale poll creation times. A poll that fails to close
+// is logged and left for the next pass.
+func (s *ExpirySweeper) Sweep(ctx context.Context) error {
+	if s.maxAge > 0 {
+		if err := s.closeExpired(ctx); err != nil {
+			return err
+		}
+	}
+	if s.purgeAge > 0 {
+		if err := s.purgeClosed(ctx); err != nil {
+			return err
+		}
+	}
+	if s.createInterval > 0 {
+		if _, err := prunePollCreations(ctx, s.db, s.createInterval); err != nil {
+			return fmt.Errorf("prune poll creations: %w", err)
+		}
+	}
+	return nil
+}
+
+// closeExpired closes open polls created before the max age, skipping
+// those still within their minimum open duration. It pages past polls that
+// fail to close, so they can't keep the rest waiting, until
+// expirySweepBatch polls have closed or none are left.
+func (s *ExpirySweeper) closeExpired(ctx context.Context) error {
+	now := time.Now().UTC()
+	cutoff := now.Add(-s.maxAge)
+	closed := 0
+	var afterCreatedAt time.Time
+	var afterID string
+	for closed < expirySweepBatch {
+		page, err := s.expiredPage(ctx, cutoff, now.Add(clockSkewTolerance), afterCreatedAt, afterID)
+		if err != nil {
+			return err
+		}
+
+		for _, poll := range page {
+			afterCreatedAt, afterID = poll.createdAt, poll.id
+			resp, err := s.polls.closePoll(ctx, poll.id, false, nil, "")
+			var notOpen *pollNotOpenError
+			var tooEarly *closeTooEarlyError
+			switch {
+			case err == nil:
+				closed++
+				slog.Info("expired poll closed", "poll_id", poll.id, "snapshot_id", resp.Snapshot.ID)
+			case errors.Is(err, errPollNotFound), errors.As(err, &notOpen):
+				// Closed or deleted since the query; nothing left to do
+			case errors.As(err, &tooEarly):
+				slog.Warn("expired poll still within its minimum open duration", "poll_id", poll.id, "closable_at", tooEarly.closableAt)
+			default:
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				slog.Error("failed to close expired poll", "error", err, "poll_id", poll.id)
+			}
+		}
+		if len(page) < expirySweepBatch {
+			return nil
+		}
+	}
+	return nil
+}
+
+// expiredPoll identifies a poll closeExpired will close, with its position
+// in the sweep's order
+type expiredPoll struct {
+	id        string
+	createdAt time.Time
+}
+
+// expiredPage returns up to expirySweepBatch open polls created before
+// cutoff and closable by closableBy, ordered by creation time and id and
+// starting after the poll created at afterCreatedAt with afterID
+func (s *ExpirySweeper) expiredPage(ctx context.Context, cutoff, closableBy, afterCreatedAt time.Time, afterID string) ([]expiredPoll, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, created_at FROM poll
+		WHERE status = $1 AND created_at < $2
+		  AND (opened_at IS NULL OR opened_at + min_open_seconds * interval '1 second' <= $3)
+		  AND (created_at, id) > ($4, $5)
+		ORDER BY created_at, id
+		LIMIT $6
+	`, models.StatusOpen, cutoff, closableBy, afterCreatedAt, afterID, expirySweepBatch)
+	if err != nil {
+		return nil, fmt.Errorf("query expired polls: %w", err)
+	}
+	defer rows.Close()
+
+	var page []expiredPoll
+	for rows.Next() {
+		var poll expiredPoll
+		if err := rows.Scan(&poll.id, &poll.createdAt); err != nil {
+			return nil, fmt.Errorf("scan expired poll: %w", err)
+		}
+		page = append(page, poll)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate expired polls: %w", err)
+	}
+	return page, nil
+}
+
+// purgeClosed deletes polls closed before the purge age, along with their
+// options, ballots, and snapshots
+func (s *ExpirySweeper) purgeClosed(ctx context.Context) error {
+	cutoff := time.Now().UTC().Add(-s.purgeAge)
+	result, err := s.db.ExecContext(ctx, `
+		DELETE FROM poll WHERE status = $1 AND closed_at < $2
+	`, models.StatusClosed, cutoff)
+	if err != nil {
+		return fmt.Errorf("purge closed polls: %w", err)
+	}
+
+	if purged, err := result.RowsAffected(); err == nil && purged > 0 {
+		slog.Info("closed polls purged", "count", purged, "closed_before", cutoff)
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+// Copyright (c) 2025 Daniel Kuo.
+// Source-available; no permission granted to use, copy, modify, or distribute. See LICENSE.
+
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/danielhkuo/quickly-pick/auth"
+	"github.com/danielhkuo/quickly-pick/models"
+)
+
+func TestExpirySweeper(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	cfg := getTestConfig()
+	cfg.PollMaxAge = 24
+	cfg.PollPurgeAge = 24 * 30
+	sweeper := NewExpirySweeper(db, cfg)
+
+	createPoll := func(status string, createdAt time.Time, closedAt *time.Time) string {
+		pollID, _ := auth.GenerateID(16)
+		_, err := db.Exec(`
+			INSERT INTO poll (id, title, creator_name, status, created_at, opened_at, closed_at)
+			VALUES ($1, 'Expiring Poll', 'Alice', $2, $3, $3, $4)
+		`, pollID, status, createdAt, closedAt)
+		if err != nil {
+			t.Fatalf("Failed to create test poll: %v", err)
+		}
+		for _, label := range []string{"Option A", "Option B"} {
+			optionID, _ := auth.GenerateID(12)
+			if _, err := db.Exec(`INSERT INTO option (id, poll_id, label) VALUES ($1, $2, $3)`, optionID, pollID, label); err != nil {
+				t.Fatalf("Failed to create option: %v", err)
+			}
+		}
+		return pollID
+	}
+
+	now := time.Now()
+	longAgo := now.Add(-90 * 24 * time.Hour)
+	expiredID := createPoll(models.StatusOpen, now.Add(-48*time.Hour), nil)
+	freshID := createPoll(models.StatusOpen, now.Add(-time.Hour), nil)
+	draftID := createPoll(models.StatusDraft, now.Add(-48*time.Hour), nil)
+	oldClosedID := createPoll(models.StatusClosed, longAgo, &longAgo)
+	// Oldest of all, but opened recently with a minimum open duration
+	tooEarlyID := createPoll(models.StatusOpen, now.Add(-72*time.Hour), nil)
+	if _, err := db.Exec(`UPDATE poll SET opened_at = $1, min_open_seconds = 86400 WHERE id = $2`, now, tooEarlyID); err != nil {
+		t.Fatalf("Failed to set minimum open duration: %v", err)
+	}
+
+	if err := sweeper.Sweep(context.Background()); err != nil {
+		t.Fatalf("Sweep failed: %v", err)
+	}
+
+	statusOf := func(pollID string) (string, sql.NullString) {
+		var status string
+		var snapshotID sql.NullString
+		err := db.QueryRow(`SELECT status, final_snapshot_id FROM poll WHERE id = $1`, pollID).Scan(&status, &snapshotID)
+		if err == sql.ErrNoRows {
+			return "", snapshotID
+		}
+		if err != nil {
+			t.Fatalf("Failed to query poll: %v", err)
+		}
+		return status, snapshotID
+	}
+
+	if status, snapshotID := statusOf(expiredID); status != models.StatusClosed || !snapshotID.Valid {
+		t.Errorf("Expected the expired poll closed with a snapshot, got status %q (snapshot %v)", status, snapshotID.Valid)
+	}
+	if status, _ := statusOf(tooEarlyID); status != models.StatusOpen {
+		t.Errorf("Expected the poll within its minimum open duration to stay open, got %q", status)
+	}
+	if status, _ := statusOf(freshID); status != models.StatusOpen {
+		t.Errorf("Expected the fresh poll to stay open, got %q", status)
+	}
+	if status, _ := statusOf(draftID); status != models.StatusDraft {
+		t.Errorf("Expected the draft to be left alone, got %q", status)
+	}
+	if status, _ := statusOf(oldClosedID); status != "" {
+		t.Errorf("Expected the long-closed poll to be purged, got %q", status)
+	}
+
+	// A second pass finds nothing left to do; the poll it just closed is
+	// far from the purge age
+	if err := sweeper.Sweep(context.Background()); err != nil {
+		t.Fatalf("Second sweep failed: %v", err)
+	}
+	if status, _ := statusOf(expiredID); status != models.StatusClosed {
+		t.Errorf("Expected the expired poll to stay closed, got %q", status)
+	}
+}
+
+func TestExpirySweeperPrunesPollCreations(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	cfg := getTestConfig()
+	cfg.MinPollCreateInterval = 60
+	sweeper := NewExpirySweeper(db, cfg)
+	if !sweeper.Enabled() {
+		t.Fatal("Expected the sweeper to run for MinPollCreateInterval alone")
+	}
+
+	now := time.Now()
+	_, err := db.Exec(`
+		INSERT INTO poll_creation (scope, created_at) VALUES ('ip:stale', $1), ('ip:recent', $2)
+	`, now.Add(-time.Hour), now)
+	if err != nil {
+		t.Fatalf("Failed to record poll creations: %v", err)
+	}
+
+	if err := sweeper.Sweep(context.Background()); err != nil {
+		t.Fatalf("Sweep failed: %v", err)
+	}
+
+	var scopes []string
+	rows, err := db.Query(`SELECT scope FROM poll_creation ORDER BY scope`)
+	if err != nil {
+		t.Fatalf("Failed to query poll creations: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var scope string
+		if err := rows.Scan(&scope); err != nil {
+			t.Fatalf("Failed to scan scope: %v", err)
+		}
+		scopes = append(scopes, scope)
+	}
+	if len(scopes) != 1 || scopes[0] != "ip:recent" {
+		t.Errorf("Expected only the recent creation to remain, got %v", scopes)
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright (c) 2025 Daniel Kuo.
+// Source-available; no permission granted to use, copy, modify, or distribute. See LICENSE.
+
+package handlers
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/danielhkuo/quickly-pick/auth"
+	"github.com/danielhkuo/quickly-pick/middleware"
+	"github.com/danielhkuo/quickly-pick/models"
+)
+
+// IdempotencyKeyTTL is how long an Idempotency-Key sent to CreatePoll keeps
+// returning the poll it created; after that the key may create a new one
+const IdempotencyKeyTTL = 24 * time.Hour
+
+// maxIdempotencyKeyLength bounds the Idempotency-Key header; a UUID needs 36
+const maxIdempotencyKeyLength = 255
+
+// idempotencyScope returns the scope an Idempotency-Key sent with r is
+// kept under, so two clients picking the same key never see each other's
+// poll. A replay hands back the admin key, so the scope is only ever a
+// device that is already registered: never an IP address, which others
+// may share or spoof, nor a device UUID nobody has registered. An empty
+// scope means the key is ignored.
+func (h *PollHandler) idempotencyScope(r *http.Request) (string, error) {
+	deviceUUID, err := registeredDeviceUUID(h.db, h.cfg, r)
+	if err != nil || deviceUUID == "" {
+		return "", err
+	}
+	return "device:" + deviceUUID, nil
+}
+
+// createRequestHash fingerprints a CreatePollRequest as decoded, so a key
+// reused for a different poll can be told from a retry
+func createRequestHash(req models.CreatePollRequest) (string, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// findIdempotentPoll returns the poll created under key in scope within
+// IdempotencyKeyTTL and the hash of the request that created it, which is
+// empty for keys stored before hashes were. found is false when there is
+// none.
+func findIdempotentPoll(db *sql.DB, scope, key string) (pollID, requestHash string, found bool, err error) {
+	var hash sql.NullString
+	err = db.QueryRow(`
+		SELECT poll_id, request_hash FROM idempotency_key
+		WHERE scope = $1 AND key = $2 AND created_at >= $3
+	`, scope, key, time.Now().UTC().Add(-IdempotencyKeyTTL)).Scan(&pollID, &hash)
+	if err == sql.ErrNoRows {
+		return "", "", false, nil
+	}
+	if err != nil {
+		return "", "", false, err
+	}
+	return pollID, hash.String, true, nil
+}
+
+// replayIdempotentCreate answers CreatePoll with the poll already created
+// under key in scope, exactly as the first request was answered, and
+// reports whether it wrote a response. A key first used with a different
+// request is refused with 422 instead. With no such poll it writes nothing
+// and returns false.
+func (h *PollHandler) replayIdempotentCreate(w http.ResponseWriter, scope, key, requestHash string) bool {
+	pollID, storedHash, found, err := findIdempotentPoll(h.db, scope, key)
+	if err != nil {
+		slog.Error("failed to query idempotency key", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
+		return true
+	}
+	if !found {
+		return false
+	}
+	if storedHash != "" && storedHash != requestHash {
+		middleware.ErrorResponseWithCode(w, http.StatusUnprocessableEntity, models.ErrorCodeIdempotencyKeyReused,
+			"Idempotency-Key was already used for a different request")
+		return true
+	}
+
+	slog.Info("poll creation replayed", "poll_id", pollID)
+	middleware.JSONResponse(w, http.StatusCreated, models.CreatePollResponse{
+		PollID:   pollID,
+		AdminKey: auth.GenerateAdminKey(pollID, h.cfg.AdminKeySalt),
+	})
+	return true
+}
+
+// claimIdempotencyKey records within tx that key in scope created pollID
+// from the request with requestHash, taking over an expired claim. It reports false when a live claim exists,
+// including one committed by a concurrent request after tx began; the
+// unique key makes this insert wait for that request to finish. Expired
+// keys left behind in scope are deleted along the way.
+func claimIdempotencyKey(tx *sql.Tx, scope, key, pollID, requestHash string) (bool, error) {
+	now := time.Now().UTC()
+	cutoff := now.Add(-IdempotencyKeyTTL)
+
+	if _, err := tx.Exec(`
+		DELETE FROM idempotency_key WHERE scope = $1 AND key <> $2 AND created_at < $3
+	`, scope, key, cutoff); err != nil {
+		return false, err
+	}
+
+	var claimedPollID string
+	err := tx.QueryRow(`
+		INSERT INTO idempotency_key (scope, key, poll_id, request_hash, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (scope, key) DO UPDATE
+		SET poll_id = EXCLUDED.poll_id,
+		    request_hash = EXCLUDED.request_hash,
+		    created_at = EXCLUDED.created_at
+		WHERE idempotency_key.created_at < $6
+		RETURNING poll_id
+	`, scope, key, pollID, requestHash, now, cutoff).Scan(&claimedPollID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
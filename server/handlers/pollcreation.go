@@ -0,0 +1,103 @@
+// Copyright (c) 2025 Daniel Kuo.
+// Source-available; no permission granted to use, copy, modify, or distribute. See LICENSE.
+
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/danielhkuo/quickly-pick/middleware"
+	"github.com/danielhkuo/quickly-pick/models"
+)
+
+// throttlePollCreation claims a poll creation within tx for every scope
+// MinPollCreateInterval applies to r, and returns the longest wait any of
+// them imposes, or 0 when the interval is unset. The client IP is always
+// checked, so rotating X-Device-UUID gains nothing; a device already
+// registered is checked too, so clients sharing an IP behind one NAT are
+// not the only limit on a device.
+func (h *PollHandler) throttlePollCreation(tx *sql.Tx, r *http.Request) (time.Duration, error) {
+	if h.cfg.MinPollCreateInterval <= 0 {
+		return 0, nil
+	}
+	interval := time.Duration(h.cfg.MinPollCreateInterval) * time.Second
+
+	scopes := []string{"ip:" + h.actorIPHash(r)}
+	deviceUUID, err := registeredDeviceUUID(h.db, h.cfg, r)
+	if err != nil {
+		return 0, err
+	}
+	if deviceUUID != "" {
+		scopes = append(scopes, "device:"+deviceUUID)
+	}
+
+	var longest time.Duration
+	for _, scope := range scopes {
+		wait, err := claimPollCreation(tx, scope, interval)
+		if err != nil {
+			return 0, err
+		}
+		longest = max(longest, wait)
+	}
+	return longest, nil
+}
+
+// claimPollCreation records within tx that the client in scope is creating
+// a poll now, unless its last poll was created less than interval ago; it
+// then returns how long the client must wait instead. The primary key makes
+// concurrent creations from one client wait for each other, and a rolled
+// back creation leaves the previous time in place.
+func claimPollCreation(tx *sql.Tx, scope string, interval time.Duration) (time.Duration, error) {
+	now := time.Now().UTC()
+
+	var createdAt time.Time
+	err := tx.QueryRow(`
+		INSERT INTO poll_creation (scope, created_at)
+		VALUES ($1, $2)
+		ON CONFLICT (scope) DO UPDATE
+		SET created_at = EXCLUDED.created_at
+		WHERE poll_creation.created_at <= $3
+		RETURNING created_at
+	`, scope, now, now.Add(-interval)).Scan(&createdAt)
+	if err == nil {
+		return 0, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, err
+	}
+
+	// The conflicting row is locked by the insert, so this reads the time
+	// that refused it
+	err = tx.QueryRow(`SELECT created_at FROM poll_creation WHERE scope = $1`, scope).Scan(&createdAt)
+	if err != nil {
+		return 0, err
+	}
+	return max(createdAt.Add(interval).Sub(now), time.Second), nil
+}
+
+// prunePollCreations deletes creation times older than interval, which no
+// longer hold any client back
+func prunePollCreations(ctx context.Context, db *sql.DB, interval time.Duration) (int64, error) {
+	result, err := db.ExecContext(ctx, `
+		DELETE FROM poll_creation WHERE created_at < $1
+	`, time.Now().UTC().Add(-interval))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// pollCreateTooSoonResponse writes the 429 for a poll created inside
+// MinPollCreateInterval, with Retry-After rounded up to whole seconds
+func pollCreateTooSoonResponse(w http.ResponseWriter, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	middleware.ErrorResponseWithCode(w, http.StatusTooManyRequests, models.ErrorCodePollCreateTooSoon,
+		fmt.Sprintf("A poll was created too recently, retry in %d seconds", seconds))
+}
@@ -4,47 +4,198 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
 	"log/slog"
+	"mime"
 	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/danielhkuo/quickly-pick/auth"
 	"github.com/danielhkuo/quickly-pick/cliparse"
 	"github.com/danielhkuo/quickly-pick/middleware"
 	"github.com/danielhkuo/quickly-pick/models"
+	"github.com/lib/pq"
 )
 
 type PollHandler struct {
 	db  *sql.DB
 	cfg cliparse.Config
+	bmj BMJOptions // ranking parameters for new snapshots; zero means the defaults
 }
 
 func NewPollHandler(db *sql.DB, cfg cliparse.Config) *PollHandler {
-	return &PollHandler{db: db, cfg: cfg}
+	return &PollHandler{db: db, cfg: cfg, bmj: BMJOptions{LowSampleThreshold: cfg.LowSampleThreshold}}
 }
 
+// minOptionsPerPoll is the fewest options a published poll may have;
+// BMJ rankings are meaningless with a single option
+const minOptionsPerPoll = 2
+
+// hasMinimumOptions reports whether a poll with count options can be
+// (or remain) published
+func hasMinimumOptions(count int) bool {
+	return count >= minOptionsPerPoll
+}
+
+// publicBaseURL is the origin share links point at
+const publicBaseURL = "https://quickly-pick.com" // TODO: Make this configurable
+
+// shareURL builds the public link for a poll, honoring the configured base path
+func shareURL(cfg cliparse.Config, slug string) string {
+	return publicBaseURL + cfg.BasePath + "/polls/" + slug
+}
+
+// clockSkewTolerance absorbs small clock differences between server
+// instances when comparing the current time against stored timestamps
+const clockSkewTolerance = 2 * time.Second
+
+// pollColumns lists the poll columns read by scanPoll, in scan order
+const pollColumns = `id, title, description, creator_name, method, status,
+		       share_slug, closes_at, closed_at, final_snapshot_id, created_at,
+		       opened_at, min_open_seconds, vanity_slug, min_scored_options,
+		       live_results, paused, reveal_at, exclude_test_ballots, default_unscored,
+		       allow_ballot_updates, min_username_length, max_username_length, options_hash,
+		       creator_can_vote`
+
+// scanPoll scans a row selected with pollColumns into poll
+func scanPoll(row *sql.Row, poll *models.Poll) error {
+	return row.Scan(
+		&poll.ID, &poll.Title, &poll.Description, &poll.CreatorName,
+		&poll.Method, &poll.Status, &poll.ShareSlug, &poll.ClosesAt,
+		&poll.ClosedAt, &poll.FinalSnapshotID, &poll.CreatedAt,
+		&poll.OpenedAt, &poll.MinOpenSeconds, &poll.VanitySlug,
+		&poll.MinScoredOptions, &poll.LiveResults, &poll.Paused,
+		&poll.RevealAt, &poll.ExcludeTestBallots, &poll.DefaultUnscored,
+		&poll.AllowBallotUpdates, &poll.MinUsernameLength, &poll.MaxUsernameLength, &poll.OptionsHash,
+		&poll.CreatorCanVote,
+	)
+}
+
+// recordIDBytes is the random bytes behind IDs that never appear in links
+// or requests from voters: snapshots, ballots, and devices
+const recordIDBytes = 16
+
+// newPollID generates a poll ID of the configured length
+func (h *PollHandler) newPollID() (string, error) {
+	n := h.cfg.PollIDBytes
+	if n == 0 {
+		n = cliparse.DefaultPollIDBytes
+	}
+	return auth.GenerateID(n)
+}
+
+// newOptionID generates an option ID of the configured length
+func (h *PollHandler) newOptionID() (string, error) {
+	n := h.cfg.OptionIDBytes
+	if n == 0 {
+		n = cliparse.DefaultOptionIDBytes
+	}
+	return auth.GenerateID(n)
+}
+
+// htmlTag matches anything shaped like an HTML tag or comment
+var htmlTag = regexp.MustCompile(`<[^>]*>`)
+
+// withSafeDescription fills poll.DescriptionSafe when cfg asks for it: the
+// description with HTML tags stripped and the remaining text escaped, so a
+// client can insert it into a page as-is
+func withSafeDescription(cfg cliparse.Config, poll *models.Poll) {
+	if cfg.SafeDescriptions {
+		safe := html.EscapeString(htmlTag.ReplaceAllString(poll.Description, ""))
+		poll.DescriptionSafe = &safe
+	}
+}
+
+// slugMatch is the WHERE predicate resolving the $1 slug parameter to a
+// poll by either its deterministic share slug or its vanity slug
+const slugMatch = `(share_slug = $1 OR vanity_slug = $1)`
+
+// maxCreatorContactLength bounds the opaque contact string a creator may
+// leave for close notifications; enough for an email address or push token
+const maxCreatorContactLength = 512
+
 // CreatePoll handles POST /polls
 func (h *PollHandler) CreatePoll(w http.ResponseWriter, r *http.Request) {
 	var req models.CreatePollRequest
-	if err := middleware.ParseJSONBody(r, &req); err != nil {
+	if err := middleware.DecodeRequiredJSON(r, &req); err != nil {
 		middleware.ErrorResponse(w, http.StatusBadRequest, "Invalid JSON")
 		return
 	}
+	requestHash, err := createRequestHash(req)
+	if err != nil {
+		slog.Error("failed to hash poll request", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Failed to create poll")
+		return
+	}
 
 	// Validate input
-	if req.Title == "" {
-		middleware.ErrorResponse(w, http.StatusBadRequest, "title is required")
+	var errs fieldErrors
+	errs.required("title", req.Title)
+	errs.required("creator_name", req.CreatorName)
+	if req.MinOpenSeconds < 0 {
+		errs.add("min_open_seconds", models.FieldCodeOutOfRange, "min_open_seconds cannot be negative")
+	}
+	if req.MinScoredOptions < 0 {
+		errs.add("min_scored_options", models.FieldCodeOutOfRange, "min_scored_options cannot be negative")
+	}
+	if req.DefaultUnscored != nil && (*req.DefaultUnscored < 0 || *req.DefaultUnscored > 1) {
+		errs.add("default_unscored", models.FieldCodeOutOfRange, "default_unscored must be between 0 and 1")
+	}
+	req.CreatorContact = strings.TrimSpace(req.CreatorContact)
+	if len(req.CreatorContact) > maxCreatorContactLength {
+		errs.add("creator_contact", models.FieldCodeOutOfRange,
+			fmt.Sprintf("creator_contact cannot exceed %d bytes", maxCreatorContactLength))
+	}
+	validateUsernameBounds(&errs, h.cfg, req.MinUsernameLength, req.MaxUsernameLength)
+	idempotencyKey := strings.TrimSpace(r.Header.Get("Idempotency-Key"))
+	if len(idempotencyKey) > maxIdempotencyKeyLength {
+		errs.add("Idempotency-Key", models.FieldCodeOutOfRange,
+			fmt.Sprintf("Idempotency-Key cannot exceed %d bytes", maxIdempotencyKeyLength))
+	}
+	if errs.any() {
+		middleware.ValidationErrorResponse(w, errs)
 		return
 	}
-	if req.CreatorName == "" {
-		middleware.ErrorResponse(w, http.StatusBadRequest, "creator_name is required")
+
+	// A poll that would close the moment it opened is useless
+	if req.ClosesAt != nil && !validClosesAt(*req.ClosesAt, time.Time{}) {
+		invalidClosesAtResponse(w)
 		return
 	}
 
+	// Every ballot must score at least one option
+	if req.MinScoredOptions == 0 {
+		req.MinScoredOptions = 1
+	}
+
+	// A retry with the same Idempotency-Key from the same registered
+	// device gets the poll the first request created, however recently
+	var scope string
+	if idempotencyKey != "" {
+		if scope, err = h.idempotencyScope(r); err != nil {
+			slog.Error("failed to look up device", "error", err)
+			middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+		if scope == "" {
+			idempotencyKey = ""
+		} else if h.replayIdempotentCreate(w, scope, idempotencyKey, requestHash) {
+			return
+		}
+	}
+
 	// Generate poll ID
-	pollID, err := auth.GenerateID(16)
+	pollID, err := h.newPollID()
 	if err != nil {
 		slog.Error("failed to generate poll ID", "error", err)
 		middleware.ErrorResponse(w, http.StatusInternalServerError, "Failed to create poll")
@@ -54,11 +205,34 @@ func (h *PollHandler) CreatePoll(w http.ResponseWriter, r *http.Request) {
 	// Generate admin key
 	adminKey := auth.GenerateAdminKey(pollID, h.cfg.AdminKeySalt)
 
+	tx, err := h.db.Begin()
+	if err != nil {
+		slog.Error("failed to begin transaction", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer tx.Rollback()
+
+	wait, err := h.throttlePollCreation(tx, r)
+	if err != nil {
+		slog.Error("failed to claim poll creation", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Failed to create poll")
+		return
+	}
+	if wait > 0 {
+		pollCreateTooSoonResponse(w, wait)
+		return
+	}
+
 	// Insert poll into database
-	_, err = h.db.Exec(`
-		INSERT INTO poll (id, title, description, creator_name, method, status, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-	`, pollID, req.Title, req.Description, req.CreatorName, models.MethodBMJ, models.StatusDraft, time.Now())
+	_, err = tx.Exec(`
+		INSERT INTO poll (id, title, description, creator_name, method, status, created_at, min_open_seconds, min_scored_options, live_results, closes_at, creator_contact, exclude_test_ballots, default_unscored, allow_ballot_updates, min_username_length, max_username_length, creator_can_vote)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)
+	`, pollID, req.Title, req.Description, req.CreatorName, models.MethodBMJ, models.StatusDraft, time.Now().UTC(),
+		req.MinOpenSeconds, req.MinScoredOptions, req.LiveResults, req.ClosesAt,
+		sql.NullString{String: req.CreatorContact, Valid: req.CreatorContact != ""}, req.ExcludeTestBallots, req.DefaultUnscored,
+		req.AllowBallotUpdates == nil || *req.AllowBallotUpdates, req.MinUsernameLength, req.MaxUsernameLength,
+		req.CreatorCanVote == nil || *req.CreatorCanVote)
 
 	if err != nil {
 		slog.Error("failed to insert poll", "error", err)
@@ -66,8 +240,32 @@ func (h *PollHandler) CreatePoll(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// A concurrent retry that claimed the key first wins; this poll is
+	// rolled back and that one returned instead
+	if idempotencyKey != "" {
+		claimed, err := claimIdempotencyKey(tx, scope, idempotencyKey, pollID, requestHash)
+		if err != nil {
+			slog.Error("failed to claim idempotency key", "error", err)
+			middleware.ErrorResponse(w, http.StatusInternalServerError, "Failed to create poll")
+			return
+		}
+		if !claimed {
+			tx.Rollback()
+			if !h.replayIdempotentCreate(w, scope, idempotencyKey, requestHash) {
+				middleware.ErrorResponse(w, http.StatusConflict, "Poll creation is in progress for this Idempotency-Key, please retry")
+			}
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		slog.Error("failed to commit poll", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Failed to create poll")
+		return
+	}
+
 	// Link device to poll as admin (if X-Device-UUID header present)
-	deviceID, err := GetOrCreateDevice(h.db, r)
+	deviceID, err := GetOrCreateDevice(h.db, h.cfg, r)
 	if err != nil {
 		slog.Warn("failed to get/create device", "error", err)
 		// Non-fatal: poll was created, just no device linking
@@ -103,19 +301,32 @@ func (h *PollHandler) AddOption(w http.ResponseWriter, r *http.Request) {
 
 	// Parse request
 	var req models.AddOptionRequest
-	if err := middleware.ParseJSONBody(r, &req); err != nil {
+	if err := middleware.DecodeRequiredJSON(r, &req); err != nil {
 		middleware.ErrorResponse(w, http.StatusBadRequest, "Invalid JSON")
 		return
 	}
 
-	if req.Label == "" {
-		middleware.ErrorResponse(w, http.StatusBadRequest, "label is required")
+	var errs fieldErrors
+	errs.required("label", req.Label)
+	errs.jsonObject("metadata", req.Metadata, maxOptionMetadataBytes)
+	if errs.any() {
+		middleware.ValidationErrorResponse(w, errs)
+		return
+	}
+	req.Description = strings.TrimSpace(req.Description)
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		slog.Error("failed to begin transaction", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
 		return
 	}
+	defer tx.Rollback()
 
-	// Check poll exists and is in draft status
+	// Lock the poll so the option can't land after a concurrent publish
+	// has hashed the option set, or push it past the option limit
 	var status string
-	err := h.db.QueryRow("SELECT status FROM poll WHERE id = $1", pollID).Scan(&status)
+	err = tx.QueryRow(`SELECT status FROM poll WHERE id = $1 FOR UPDATE`, pollID).Scan(&status)
 	if err == sql.ErrNoRows {
 		middleware.ErrorResponse(w, http.StatusNotFound, "Poll not found")
 		return
@@ -131,19 +342,46 @@ func (h *PollHandler) AddOption(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var optionCount int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM option WHERE poll_id = $1`, pollID).Scan(&optionCount); err != nil {
+		slog.Error("failed to count options", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	if h.cfg.MaxOptions > 0 && optionCount >= h.cfg.MaxOptions {
+		middleware.ErrorResponse(w, http.StatusConflict, fmt.Sprintf("Poll cannot have more than %d options", h.cfg.MaxOptions))
+		return
+	}
+
+	if !h.cfg.AllowDuplicateOptions {
+		duplicate, err := hasOptionLabel(tx, pollID, req.Label)
+		if err != nil {
+			slog.Error("failed to query options", "error", err)
+			middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+		if duplicate {
+			middleware.ErrorResponseWithCode(w, http.StatusConflict, models.ErrorCodeDuplicateOption,
+				fmt.Sprintf("Poll already has an option labeled %q", strings.TrimSpace(req.Label)))
+			return
+		}
+	}
+
 	// Generate option ID
-	optionID, err := auth.GenerateID(12)
+	optionID, err := h.newOptionID()
 	if err != nil {
 		slog.Error("failed to generate option ID", "error", err)
 		middleware.ErrorResponse(w, http.StatusInternalServerError, "Failed to create option")
 		return
 	}
 
-	// Insert option
-	_, err = h.db.Exec(`
-		INSERT INTO option (id, poll_id, label)
-		VALUES ($1, $2, $3)
-	`, optionID, pollID, req.Label)
+	// Insert option after any existing ones
+	_, err = tx.Exec(`
+		INSERT INTO option (id, poll_id, label, description, position, metadata)
+		SELECT $1, $2, $3, $4, COALESCE(MAX(position) + 1, 0), $5::jsonb
+		FROM option WHERE poll_id = $2
+	`, optionID, pollID, req.Label, req.Description, optionMetadataArg(req.Metadata))
 
 	if err != nil {
 		slog.Error("failed to insert option", "error", err)
@@ -151,6 +389,12 @@ func (h *PollHandler) AddOption(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := tx.Commit(); err != nil {
+		slog.Error("failed to commit transaction", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Failed to create option")
+		return
+	}
+
 	slog.Info("option added", "poll_id", pollID, "option_id", optionID)
 
 	middleware.JSONResponse(w, http.StatusCreated, models.AddOptionResponse{
@@ -158,8 +402,54 @@ func (h *PollHandler) AddOption(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// PublishPoll handles POST /polls/:id/publish
-func (h *PollHandler) PublishPoll(w http.ResponseWriter, r *http.Request) {
+// maxOptionMetadataBytes bounds the client metadata stored with an option;
+// plenty for a color, an icon name, or a few flags
+const maxOptionMetadataBytes = 1024
+
+// optionMetadataArg converts option metadata to a query argument, with
+// absent or null metadata stored as NULL
+func optionMetadataArg(metadata json.RawMessage) sql.NullString {
+	trimmed := strings.TrimSpace(string(metadata))
+	return sql.NullString{String: trimmed, Valid: trimmed != "" && trimmed != "null"}
+}
+
+// normalizeOptionLabel folds case and whitespace so labels a voter would
+// read as the same option compare equal
+func normalizeOptionLabel(label string) string {
+	return strings.ToLower(strings.Join(strings.Fields(label), " "))
+}
+
+// hasOptionLabel reports whether the poll has an option whose label
+// normalizes to the same value as label
+func hasOptionLabel(tx *sql.Tx, pollID, label string) (bool, error) {
+	rows, err := tx.Query(`SELECT label FROM option WHERE poll_id = $1`, pollID)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	want := normalizeOptionLabel(label)
+	for rows.Next() {
+		var existing string
+		if err := rows.Scan(&existing); err != nil {
+			return false, err
+		}
+		if normalizeOptionLabel(existing) == want {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
+
+// maxImportBodyBytes bounds the plain-text body accepted by ImportOptions
+const maxImportBodyBytes = 64 << 10
+
+// ImportOptions handles POST /polls/:id/options:import
+// Each non-empty line of a text/plain body becomes an option, in line order.
+// Labels are trimmed; duplicates, within the body or of existing options,
+// are skipped. Unless cfg.AllowDuplicateOptions is set, labels differing
+// only in case or spacing count as duplicates.
+func (h *PollHandler) ImportOptions(w http.ResponseWriter, r *http.Request) {
 	pollID := r.PathValue("id")
 	if pollID == "" {
 		middleware.ErrorResponse(w, http.StatusBadRequest, "poll_id is required")
@@ -173,94 +463,50 @@ func (h *PollHandler) PublishPoll(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check poll exists and is in draft status
-	var status string
-	var optionCount int
-	err := h.db.QueryRow(`
-		SELECT p.status, COUNT(o.id)
-		FROM poll p
-		LEFT JOIN option o ON p.id = o.poll_id
-		WHERE p.id = $1
-		GROUP BY p.status
-	`, pollID).Scan(&status, &optionCount)
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		if mediaType, _, err := mime.ParseMediaType(contentType); err != nil || mediaType != "text/plain" {
+			middleware.ErrorResponse(w, http.StatusUnsupportedMediaType, "Content-Type must be text/plain")
+			return
+		}
+	}
 
-	if err == sql.ErrNoRows {
-		middleware.ErrorResponse(w, http.StatusNotFound, "Poll not found")
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxImportBodyBytes))
+	if errors.As(err, new(*http.MaxBytesError)) {
+		middleware.ErrorResponse(w, http.StatusRequestEntityTooLarge, "Request body too large")
 		return
 	}
 	if err != nil {
-		slog.Error("failed to query poll", "error", err)
-		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
+		middleware.ErrorResponse(w, http.StatusBadRequest, "Failed to read request body")
 		return
 	}
 
-	if status != models.StatusDraft {
-		middleware.ErrorResponse(w, http.StatusConflict, "Poll is not in draft status")
-		return
+	labelKey := func(label string) string { return label }
+	if !h.cfg.AllowDuplicateOptions {
+		labelKey = normalizeOptionLabel
 	}
 
-	if optionCount < 2 {
-		middleware.ErrorResponse(w, http.StatusBadRequest, "Poll must have at least 2 options")
-		return
+	var labels []string
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(string(body), "\n") {
+		label := strings.TrimSpace(line)
+		if label == "" || seen[labelKey(label)] {
+			continue
+		}
+		seen[labelKey(label)] = true
+		labels = append(labels, label)
 	}
 
-	// Generate share slug
-	shareSlug := auth.GenerateShareSlug(pollID, h.cfg.PollSlugSalt)
-
-	// Update poll to open status
-	_, err = h.db.Exec(`
-		UPDATE poll
-		SET status = $1, share_slug = $2
-		WHERE id = $3
-	`, models.StatusOpen, shareSlug, pollID)
-
+	tx, err := h.db.Begin()
 	if err != nil {
-		slog.Error("failed to publish poll", "error", err)
-		middleware.ErrorResponse(w, http.StatusInternalServerError, "Failed to publish poll")
-		return
-	}
-
-	slog.Info("poll published", "poll_id", pollID, "share_slug", shareSlug)
-
-	// Build share URL (could be configurable)
-	baseURL := "https://quickly-pick.com" // TODO: Make this configurable
-	shareURL := baseURL + "/polls/" + shareSlug
-
-	middleware.JSONResponse(w, http.StatusOK, models.PublishPollResponse{
-		ShareSlug: shareSlug,
-		ShareURL:  shareURL,
-	})
-}
-
-// GetPollAdmin handles GET /polls/:id/admin
-// Returns poll details for admin access using poll ID and admin key
-func (h *PollHandler) GetPollAdmin(w http.ResponseWriter, r *http.Request) {
-	pollID := r.PathValue("id")
-	if pollID == "" {
-		middleware.ErrorResponse(w, http.StatusBadRequest, "poll_id is required")
-		return
-	}
-
-	// Validate admin key
-	adminKey := r.Header.Get("X-Admin-Key")
-	if err := auth.ValidateAdminKey(pollID, adminKey, h.cfg.AdminKeySalt); err != nil {
-		middleware.ErrorResponse(w, http.StatusUnauthorized, "Invalid admin key")
+		slog.Error("failed to begin transaction", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
 		return
 	}
+	defer tx.Rollback()
 
-	// Get poll by ID
-	var poll models.Poll
-	err := h.db.QueryRow(`
-		SELECT id, title, description, creator_name, method, status, 
-		       share_slug, closes_at, closed_at, final_snapshot_id, created_at
-		FROM poll
-		WHERE id = $1
-	`, pollID).Scan(
-		&poll.ID, &poll.Title, &poll.Description, &poll.CreatorName,
-		&poll.Method, &poll.Status, &poll.ShareSlug, &poll.ClosesAt,
-		&poll.ClosedAt, &poll.FinalSnapshotID, &poll.CreatedAt,
-	)
-
+	// Lock the poll so concurrent imports can't overshoot the option limit
+	var status string
+	err = tx.QueryRow(`SELECT status FROM poll WHERE id = $1 FOR UPDATE`, pollID).Scan(&status)
 	if err == sql.ErrNoRows {
 		middleware.ErrorResponse(w, http.StatusNotFound, "Poll not found")
 		return
@@ -271,45 +517,102 @@ func (h *PollHandler) GetPollAdmin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get options
-	rows, err := h.db.Query(`
-		SELECT id, poll_id, label
-		FROM option
-		WHERE poll_id = $1
-		ORDER BY id
-	`, poll.ID)
+	if status != models.StatusDraft {
+		middleware.ErrorResponse(w, http.StatusConflict, "Cannot add options to non-draft poll")
+		return
+	}
 
+	// Skip labels the poll already has
+	rows, err := tx.Query(`SELECT label, position FROM option WHERE poll_id = $1`, pollID)
 	if err != nil {
 		slog.Error("failed to query options", "error", err)
 		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
 		return
 	}
-	defer rows.Close()
-
-	options := []models.Option{}
+	existing := make(map[string]bool)
+	existingCount := 0
+	nextPosition := 0
 	for rows.Next() {
-		var opt models.Option
-		if err := rows.Scan(&opt.ID, &opt.PollID, &opt.Label); err != nil {
+		var label string
+		var position int
+		if err := rows.Scan(&label, &position); err != nil {
+			rows.Close()
 			slog.Error("failed to scan option", "error", err)
 			middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
 			return
 		}
-		options = append(options, opt)
+		existing[labelKey(label)] = true
+		existingCount++
+		if position >= nextPosition {
+			nextPosition = position + 1
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		slog.Error("failed to read options", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	newLabels := labels[:0]
+	for _, label := range labels {
+		if !existing[labelKey(label)] {
+			newLabels = append(newLabels, label)
+		}
+	}
+
+	if len(newLabels) == 0 {
+		middleware.ErrorResponse(w, http.StatusBadRequest, "No new options to import")
+		return
 	}
 
-	response := models.PollWithOptions{
-		Poll:    poll,
-		Options: options,
+	if h.cfg.MaxOptions > 0 && existingCount+len(newLabels) > h.cfg.MaxOptions {
+		middleware.ErrorResponse(w, http.StatusConflict, fmt.Sprintf("Poll cannot have more than %d options", h.cfg.MaxOptions))
+		return
 	}
 
-	middleware.JSONResponse(w, http.StatusOK, response)
+	optionIDs := make([]string, 0, len(newLabels))
+	for i, label := range newLabels {
+		optionID, err := h.newOptionID()
+		if err != nil {
+			slog.Error("failed to generate option ID", "error", err)
+			middleware.ErrorResponse(w, http.StatusInternalServerError, "Failed to import options")
+			return
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO option (id, poll_id, label, position)
+			VALUES ($1, $2, $3, $4)
+		`, optionID, pollID, label, nextPosition+i)
+		if err != nil {
+			slog.Error("failed to insert option", "error", err)
+			middleware.ErrorResponse(w, http.StatusInternalServerError, "Failed to import options")
+			return
+		}
+		optionIDs = append(optionIDs, optionID)
+	}
+
+	if err := tx.Commit(); err != nil {
+		slog.Error("failed to commit transaction", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Failed to import options")
+		return
+	}
+
+	slog.Info("options imported", "poll_id", pollID, "count", len(optionIDs))
+
+	middleware.JSONResponse(w, http.StatusCreated, models.ImportOptionsResponse{
+		OptionIDs: optionIDs,
+	})
 }
 
-// ClosePoll handles POST /polls/:id/close
-func (h *PollHandler) ClosePoll(w http.ResponseWriter, r *http.Request) {
+// DeleteOption handles DELETE /polls/:id/options/:option_id
+// Options can only be removed from drafts, and never below the minimum
+// a poll needs to be published.
+func (h *PollHandler) DeleteOption(w http.ResponseWriter, r *http.Request) {
 	pollID := r.PathValue("id")
-	if pollID == "" {
-		middleware.ErrorResponse(w, http.StatusBadRequest, "poll_id is required")
+	optionID := r.PathValue("option_id")
+	if pollID == "" || optionID == "" {
+		middleware.ErrorResponse(w, http.StatusBadRequest, "poll_id and option_id are required")
 		return
 	}
 
@@ -320,9 +623,17 @@ func (h *PollHandler) ClosePoll(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check poll exists and is open
+	tx, err := h.db.Begin()
+	if err != nil {
+		slog.Error("failed to begin transaction", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer tx.Rollback()
+
+	// Lock the poll so concurrent deletes can't both pass the minimum check
 	var status string
-	err := h.db.QueryRow("SELECT status FROM poll WHERE id = $1", pollID).Scan(&status)
+	err = tx.QueryRow("SELECT status FROM poll WHERE id = $1 FOR UPDATE", pollID).Scan(&status)
 	if err == sql.ErrNoRows {
 		middleware.ErrorResponse(w, http.StatusNotFound, "Poll not found")
 		return
@@ -333,90 +644,1412 @@ func (h *PollHandler) ClosePoll(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if status != models.StatusOpen {
-		middleware.ErrorResponse(w, http.StatusConflict, "Poll is not open")
+	if status != models.StatusDraft {
+		middleware.ErrorResponse(w, http.StatusConflict, "Cannot remove options from non-draft poll")
 		return
 	}
 
-	// Compute BMJ results
-	rankings, err := ComputeBMJRankings(h.db, pollID)
+	var optionCount int
+	var optionExists bool
+	err = tx.QueryRow(`
+		SELECT COUNT(*), COALESCE(BOOL_OR(id = $2), false)
+		FROM option
+		WHERE poll_id = $1
+	`, pollID, optionID).Scan(&optionCount, &optionExists)
 	if err != nil {
-		slog.Error("failed to compute BMJ rankings", "error", err)
-		middleware.ErrorResponse(w, http.StatusInternalServerError, "Failed to compute results")
+		slog.Error("failed to count options", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
 		return
 	}
 
-	// Create payload JSON
-	payload := struct {
-		Rankings   []models.OptionStats `json:"rankings"`
-		InputsHash string               `json:"inputs_hash"`
-	}{
-		Rankings:   rankings,
-		InputsHash: computeInputsHash(h.db, pollID),
+	if !optionExists {
+		middleware.ErrorResponse(w, http.StatusNotFound, "Option not found")
+		return
 	}
 
-	payloadJSON, err := json.Marshal(payload)
-	if err != nil {
-		slog.Error("failed to marshal payload", "error", err)
-		middleware.ErrorResponse(w, http.StatusInternalServerError, "Failed to save results")
+	if !hasMinimumOptions(optionCount - 1) {
+		middleware.ErrorResponse(w, http.StatusConflict, "Poll must keep at least 2 options")
 		return
 	}
 
-	snapshotID, _ := auth.GenerateID(16)
-	closedAt := time.Now()
-
-	// Begin transaction
-	tx, err := h.db.Begin()
+	_, err = tx.Exec("DELETE FROM option WHERE id = $1 AND poll_id = $2", optionID, pollID)
 	if err != nil {
-		slog.Error("failed to begin transaction", "error", err)
-		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
+		slog.Error("failed to delete option", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Failed to delete option")
 		return
 	}
-	defer tx.Rollback()
 
-	// Update poll to closed
+	if err := auditAdminAction(tx, pollID, models.AdminActionDeleteOption, h.actorIPHash(r)); err != nil {
+		slog.Error("failed to audit option deletion", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Failed to delete option")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		slog.Error("failed to commit transaction", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Failed to delete option")
+		return
+	}
+
+	slog.Info("option deleted", "poll_id", pollID, "option_id", optionID)
+
+	middleware.JSONResponse(w, http.StatusOK, models.DeleteOptionResponse{
+		OptionID:    optionID,
+		OptionCount: optionCount - 1,
+	})
+}
+
+// PublishPoll handles POST /polls/:id/publish
+func (h *PollHandler) PublishPoll(w http.ResponseWriter, r *http.Request) {
+	pollID := r.PathValue("id")
+	if pollID == "" {
+		middleware.ErrorResponse(w, http.StatusBadRequest, "poll_id is required")
+		return
+	}
+
+	// Validate admin key
+	adminKey := r.Header.Get("X-Admin-Key")
+	if err := auth.ValidateAdminKey(pollID, adminKey, h.cfg.AdminKeySalt); err != nil {
+		middleware.ErrorResponse(w, http.StatusUnauthorized, "Invalid admin key")
+		return
+	}
+
+	// No body is expected, but reject malformed JSON if one is sent
+	var req struct{}
+	if err := middleware.DecodeOptionalJSON(r, &req); err != nil {
+		middleware.ErrorResponse(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		slog.Error("failed to begin transaction", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer tx.Rollback()
+
+	// Check poll exists and is in draft status. The row lock holds off
+	// option changes, which take it too, so the options counted and hashed
+	// below are exactly the ones the poll opens with.
+	var status string
+	var minScoredOptions int
+	var closesAt sql.NullTime
+	var publishedSlug sql.NullString
+	err = tx.QueryRow(`
+		SELECT status, min_scored_options, closes_at, share_slug
+		FROM poll
+		WHERE id = $1
+		FOR UPDATE
+	`, pollID).Scan(&status, &minScoredOptions, &closesAt, &publishedSlug)
+
+	if err == sql.ErrNoRows {
+		middleware.ErrorResponse(w, http.StatusNotFound, "Poll not found")
+		return
+	}
+	if err != nil {
+		slog.Error("failed to query poll", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	// Publishing again is harmless: the share link was fixed the first time,
+	// so a client retrying a publish gets it back instead of an error
+	if status == models.StatusOpen && publishedSlug.Valid {
+		middleware.JSONResponse(w, http.StatusOK, models.PublishPollResponse{
+			ShareSlug: publishedSlug.String,
+			ShareURL:  shareURL(h.cfg, publishedSlug.String),
+		})
+		return
+	}
+	if status != models.StatusDraft {
+		middleware.ErrorResponse(w, http.StatusConflict, "Poll is closed")
+		return
+	}
+
+	var optionCount int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM option WHERE poll_id = $1`, pollID).Scan(&optionCount); err != nil {
+		slog.Error("failed to count options", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	if !hasMinimumOptions(optionCount) {
+		middleware.ErrorResponse(w, http.StatusBadRequest, "Poll must have at least 2 options")
+		return
+	}
+
+	// The option set is final now, so the scoring requirement must be satisfiable
+	if minScoredOptions > optionCount {
+		middleware.ErrorResponse(w, http.StatusBadRequest,
+			fmt.Sprintf("min_scored_options (%d) exceeds the number of options (%d)", minScoredOptions, optionCount))
+		return
+	}
+
+	// A close time set while drafting may have passed since
+	openedAt := time.Now().UTC()
+	if closesAt.Valid && !validClosesAt(closesAt.Time, openedAt) {
+		invalidClosesAtResponse(w)
+		return
+	}
+
+	// Generate share slug
+	shareSlug := auth.GenerateShareSlug(pollID, h.cfg.PollSlugSalt)
+
+	// Fix the option set voters will see, so later changes are detectable
+	optionsHash, err := queryOptionsHash(r.Context(), tx, pollID)
+	if err != nil {
+		slog.Error("failed to hash options", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Failed to publish poll")
+		return
+	}
+
+	// Update poll to open status. The lock means no concurrent publish got
+	// there first, but the status guard keeps the audit entry to one.
+	result, err := tx.Exec(`
+		UPDATE poll
+		SET status = $1, share_slug = $2, opened_at = $3, options_hash = $4
+		WHERE id = $5 AND status = $6
+	`, models.StatusOpen, shareSlug, openedAt, optionsHash, pollID, models.StatusDraft)
+
+	if err != nil {
+		slog.Error("failed to publish poll", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Failed to publish poll")
+		return
+	}
+
+	if published, err := result.RowsAffected(); err == nil && published > 0 {
+		if err := auditAdminAction(tx, pollID, models.AdminActionPublish, h.actorIPHash(r)); err != nil {
+			slog.Error("failed to audit publish", "error", err)
+			middleware.ErrorResponse(w, http.StatusInternalServerError, "Failed to publish poll")
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		slog.Error("failed to commit transaction", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Failed to publish poll")
+		return
+	}
+
+	slog.Info("poll published", "poll_id", pollID, "share_slug", shareSlug)
+
+	middleware.JSONResponse(w, http.StatusOK, models.PublishPollResponse{
+		ShareSlug: shareSlug,
+		ShareURL:  shareURL(h.cfg, shareSlug),
+	})
+}
+
+// GetPollAdmin handles GET /polls/:id/admin
+// Returns poll details for admin access using poll ID and admin key
+func (h *PollHandler) GetPollAdmin(w http.ResponseWriter, r *http.Request) {
+	pollID := r.PathValue("id")
+	if pollID == "" {
+		middleware.ErrorResponse(w, http.StatusBadRequest, "poll_id is required")
+		return
+	}
+
+	// Validate admin key
+	adminKey := r.Header.Get("X-Admin-Key")
+	if err := auth.ValidateAdminKey(pollID, adminKey, h.cfg.AdminKeySalt); err != nil {
+		middleware.ErrorResponse(w, http.StatusUnauthorized, "Invalid admin key")
+		return
+	}
+
+	// Get poll by ID
+	var poll models.Poll
+	err := scanPoll(h.db.QueryRow(`
+		SELECT `+pollColumns+`
+		FROM poll
+		WHERE id = $1
+	`, pollID), &poll)
+
+	if err == sql.ErrNoRows {
+		middleware.ErrorResponse(w, http.StatusNotFound, "Poll not found")
+		return
+	}
+	if err != nil {
+		slog.Error("failed to query poll", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	withSafeDescription(h.cfg, &poll)
+
+	// Get options
+	options, err := queryOptions(h.db, poll.ID)
+	if err != nil {
+		slog.Error("failed to query options", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	// The contact is admin-only, so it stays out of pollColumns
+	var creatorContact sql.NullString
+	err = h.db.QueryRow(`
+		SELECT creator_contact FROM poll WHERE id = $1
+	`, poll.ID).Scan(&creatorContact)
+	if err != nil {
+		slog.Error("failed to query creator contact", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	response := models.PollAdminResponse{
+		PollWithOptions: models.PollWithOptions{
+			Poll:    poll,
+			Options: options,
+		},
+		CreatorContact: creatorContact.String,
+	}
+
+	// Share slugs are deterministic, so a draft's link is known before publish
+	if poll.Status == models.StatusDraft {
+		futureSlug := auth.GenerateShareSlug(poll.ID, h.cfg.PollSlugSalt)
+		response.FutureShareSlug = futureSlug
+		response.FutureShareURL = shareURL(h.cfg, futureSlug)
+	}
+
+	middleware.JSONResponse(w, http.StatusOK, response)
+}
+
+// vanitySlugPattern restricts vanity slugs to lowercase alphanumeric words
+// separated by single hyphens
+var vanitySlugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+const (
+	minVanitySlugLength = 3
+	maxVanitySlugLength = 64
+)
+
+// SetVanitySlug handles PUT /polls/:id/vanity-slug
+// Gives an open poll a human-friendly slug that resolves to the same poll
+// as its deterministic share slug.
+func (h *PollHandler) SetVanitySlug(w http.ResponseWriter, r *http.Request) {
+	pollID := r.PathValue("id")
+	if pollID == "" {
+		middleware.ErrorResponse(w, http.StatusBadRequest, "poll_id is required")
+		return
+	}
+
+	// Validate admin key
+	adminKey := r.Header.Get("X-Admin-Key")
+	if err := auth.ValidateAdminKey(pollID, adminKey, h.cfg.AdminKeySalt); err != nil {
+		middleware.ErrorResponse(w, http.StatusUnauthorized, "Invalid admin key")
+		return
+	}
+
+	var req models.SetVanitySlugRequest
+	if err := middleware.DecodeRequiredJSON(r, &req); err != nil {
+		middleware.ErrorResponse(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	var errs fieldErrors
+	errs.required("vanity_slug", req.VanitySlug)
+	if req.VanitySlug != "" {
+		if len(req.VanitySlug) < minVanitySlugLength || len(req.VanitySlug) > maxVanitySlugLength {
+			errs.add("vanity_slug", models.FieldCodeOutOfRange, "vanity_slug must be 3-64 characters")
+		} else if !vanitySlugPattern.MatchString(req.VanitySlug) {
+			errs.add("vanity_slug", models.FieldCodeInvalid, "vanity_slug may only contain lowercase letters, digits, and single hyphens")
+		}
+	}
+	if errs.any() {
+		middleware.ValidationErrorResponse(w, errs)
+		return
+	}
+
+	// Check poll exists and is open
+	var status string
+	var shareSlug sql.NullString
+	err := h.db.QueryRow(`
+		SELECT status, share_slug FROM poll WHERE id = $1
+	`, pollID).Scan(&status, &shareSlug)
+	if err == sql.ErrNoRows {
+		middleware.ErrorResponse(w, http.StatusNotFound, "Poll not found")
+		return
+	}
+	if err != nil {
+		slog.Error("failed to query poll", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	if status != models.StatusOpen {
+		middleware.ErrorResponse(w, http.StatusConflict, "Vanity slugs can only be set on open polls")
+		return
+	}
+
+	// The slug must not resolve to any other poll, by either kind of slug
+	var taken bool
+	err = h.db.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM poll WHERE `+slugMatch+` AND id <> $2)
+	`, req.VanitySlug, pollID).Scan(&taken)
+	if err != nil {
+		slog.Error("failed to check vanity slug", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if taken {
+		middleware.ErrorResponse(w, http.StatusConflict, "Vanity slug already taken")
+		return
+	}
+
+	_, err = h.db.Exec(`
+		UPDATE poll SET vanity_slug = $1 WHERE id = $2
+	`, req.VanitySlug, pollID)
+	if err != nil {
+		// A concurrent claim can still win the UNIQUE constraint
+		if isUniqueViolation(err) {
+			middleware.ErrorResponse(w, http.StatusConflict, "Vanity slug already taken")
+			return
+		}
+		slog.Error("failed to set vanity slug", "error", err, "poll_id", pollID)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Failed to set vanity slug")
+		return
+	}
+
+	slog.Info("vanity slug set", "poll_id", pollID, "vanity_slug", req.VanitySlug)
+
+	middleware.JSONResponse(w, http.StatusOK, models.SetVanitySlugResponse{
+		VanitySlug: req.VanitySlug,
+		ShareSlug:  shareSlug.String,
+	})
+}
+
+// SetClosesAt handles PATCH /polls/:id/closes-at
+// Schedules or, with a null closes_at, clears when a draft or open poll closes
+func (h *PollHandler) SetClosesAt(w http.ResponseWriter, r *http.Request) {
+	pollID := r.PathValue("id")
+	if pollID == "" {
+		middleware.ErrorResponse(w, http.StatusBadRequest, "poll_id is required")
+		return
+	}
+
+	// Validate admin key
+	adminKey := r.Header.Get("X-Admin-Key")
+	if err := auth.ValidateAdminKey(pollID, adminKey, h.cfg.AdminKeySalt); err != nil {
+		middleware.ErrorResponse(w, http.StatusUnauthorized, "Invalid admin key")
+		return
+	}
+
+	var req models.SetClosesAtRequest
+	if err := middleware.DecodeRequiredJSON(r, &req); err != nil {
+		middleware.ErrorResponse(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	var status string
+	var openedAt sql.NullTime
+	err := h.db.QueryRow(`
+		SELECT status, opened_at FROM poll WHERE id = $1
+	`, pollID).Scan(&status, &openedAt)
+	if err == sql.ErrNoRows {
+		middleware.ErrorResponse(w, http.StatusNotFound, "Poll not found")
+		return
+	}
+	if err != nil {
+		slog.Error("failed to query poll", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if status == models.StatusClosed {
+		middleware.ErrorResponse(w, http.StatusConflict, "Poll is already closed")
+		return
+	}
+
+	if req.ClosesAt != nil {
+		if !validClosesAt(*req.ClosesAt, openedAt.Time) {
+			invalidClosesAtResponse(w)
+			return
+		}
+		utc := req.ClosesAt.UTC()
+		req.ClosesAt = &utc
+	}
+
+	_, err = h.db.Exec(`
+		UPDATE poll SET closes_at = $1 WHERE id = $2
+	`, req.ClosesAt, pollID)
+	if err != nil {
+		slog.Error("failed to set closes_at", "error", err, "poll_id", pollID)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Failed to set closes_at")
+		return
+	}
+
+	middleware.JSONResponse(w, http.StatusOK, models.SetClosesAtResponse{ClosesAt: req.ClosesAt})
+}
+
+// validClosesAt reports whether a poll may be scheduled to close at
+// closesAt: strictly after now and after openedAt (zero for drafts)
+func validClosesAt(closesAt, openedAt time.Time) bool {
+	return closesAt.After(time.Now()) && closesAt.After(openedAt)
+}
+
+// invalidClosesAtResponse writes the 400 for a closes_at that would close
+// the poll immediately
+func invalidClosesAtResponse(w http.ResponseWriter) {
+	middleware.ErrorResponseWithCode(w, http.StatusBadRequest, models.ErrorCodeInvalidClosesAt,
+		"closes_at must be in the future and after the poll opens")
+}
+
+// SetRevealAt handles PUT /polls/:id/reveal-at
+// Embargoes a closed poll's results until reveal_at, or lifts the embargo
+// when reveal_at is null (closed only)
+func (h *PollHandler) SetRevealAt(w http.ResponseWriter, r *http.Request) {
+	pollID := r.PathValue("id")
+	if pollID == "" {
+		middleware.ErrorResponse(w, http.StatusBadRequest, "poll_id is required")
+		return
+	}
+
+	// Validate admin key
+	adminKey := r.Header.Get("X-Admin-Key")
+	if err := auth.ValidateAdminKey(pollID, adminKey, h.cfg.AdminKeySalt); err != nil {
+		middleware.ErrorResponse(w, http.StatusUnauthorized, "Invalid admin key")
+		return
+	}
+
+	var req models.SetRevealAtRequest
+	if err := middleware.DecodeRequiredJSON(r, &req); err != nil {
+		middleware.ErrorResponse(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	if req.RevealAt != nil {
+		if !req.RevealAt.After(time.Now()) {
+			middleware.ValidationErrorResponse(w, []models.FieldError{{
+				Field:   "reveal_at",
+				Code:    models.FieldCodeInvalid,
+				Message: "reveal_at must be in the future",
+			}})
+			return
+		}
+		utc := req.RevealAt.UTC()
+		req.RevealAt = &utc
+	}
+
+	var status string
+	err := h.db.QueryRow(`
+		UPDATE poll SET reveal_at = $1
+		WHERE id = $2 AND status = $3
+		RETURNING status
+	`, req.RevealAt, pollID, models.StatusClosed).Scan(&status)
+	if err == sql.ErrNoRows {
+		// Either the poll doesn't exist or it hasn't closed yet
+		err = h.db.QueryRow(`SELECT status FROM poll WHERE id = $1`, pollID).Scan(&status)
+		if err == sql.ErrNoRows {
+			middleware.ErrorResponse(w, http.StatusNotFound, "Poll not found")
+			return
+		}
+		if err == nil {
+			middleware.ErrorResponse(w, http.StatusConflict, "Only closed polls can embargo results")
+			return
+		}
+	}
+	if err != nil {
+		slog.Error("failed to set reveal_at", "error", err, "poll_id", pollID)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Failed to set reveal_at")
+		return
+	}
+
+	middleware.JSONResponse(w, http.StatusOK, models.SetRevealAtResponse{RevealAt: req.RevealAt})
+}
+
+// PausePoll handles POST /polls/:id/pause
+// Halts voting on an open poll without closing it; the poll stays readable
+func (h *PollHandler) PausePoll(w http.ResponseWriter, r *http.Request) {
+	h.setPaused(w, r, true)
+}
+
+// ResumePoll handles POST /polls/:id/resume
+// Lets voting on a paused poll continue
+func (h *PollHandler) ResumePoll(w http.ResponseWriter, r *http.Request) {
+	h.setPaused(w, r, false)
+}
+
+// setPaused sets an open poll's paused flag. Repeating a pause or resume
+// succeeds without change.
+func (h *PollHandler) setPaused(w http.ResponseWriter, r *http.Request, paused bool) {
+	pollID := r.PathValue("id")
+	if pollID == "" {
+		middleware.ErrorResponse(w, http.StatusBadRequest, "poll_id is required")
+		return
+	}
+
+	// Validate admin key
+	adminKey := r.Header.Get("X-Admin-Key")
+	if err := auth.ValidateAdminKey(pollID, adminKey, h.cfg.AdminKeySalt); err != nil {
+		middleware.ErrorResponse(w, http.StatusUnauthorized, "Invalid admin key")
+		return
+	}
+
+	// The status condition keeps a close that lands first from being undone
+	var status string
+	err := h.db.QueryRow(`
+		UPDATE poll SET paused = $1
+		WHERE id = $2 AND status = $3
+		RETURNING status
+	`, paused, pollID, models.StatusOpen).Scan(&status)
+	if err == sql.ErrNoRows {
+		err = h.db.QueryRow(`SELECT status FROM poll WHERE id = $1`, pollID).Scan(&status)
+		if err == sql.ErrNoRows {
+			middleware.ErrorResponse(w, http.StatusNotFound, "Poll not found")
+			return
+		}
+		if err == nil {
+			middleware.ErrorResponse(w, http.StatusConflict, "Only open polls can be paused or resumed")
+			return
+		}
+	}
+	if err != nil {
+		slog.Error("failed to set paused", "error", err, "poll_id", pollID)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	slog.Info("poll pause set", "poll_id", pollID, "paused", paused)
+
+	middleware.JSONResponse(w, http.StatusOK, models.PollPausedResponse{Paused: paused})
+}
+
+// SetCloseWebhook handles PUT /polls/:id/close-webhook
+// Sets or, with an empty URL, removes the URL notified when the poll closes
+func (h *PollHandler) SetCloseWebhook(w http.ResponseWriter, r *http.Request) {
+	pollID := r.PathValue("id")
+	if pollID == "" {
+		middleware.ErrorResponse(w, http.StatusBadRequest, "poll_id is required")
+		return
+	}
+
+	// Validate admin key
+	adminKey := r.Header.Get("X-Admin-Key")
+	if err := auth.ValidateAdminKey(pollID, adminKey, h.cfg.AdminKeySalt); err != nil {
+		middleware.ErrorResponse(w, http.StatusUnauthorized, "Invalid admin key")
+		return
+	}
+
+	var req models.SetCloseWebhookRequest
+	if err := middleware.DecodeRequiredJSON(r, &req); err != nil {
+		middleware.ErrorResponse(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	req.CloseWebhookURL = strings.TrimSpace(req.CloseWebhookURL)
+	var errs fieldErrors
+	if req.CloseWebhookURL != "" {
+		if h.cfg.WebhookSalt == "" {
+			errs.add("close_webhook_url", models.FieldCodeInvalid, "close webhooks are not enabled on this server")
+		} else if !validWebhookURL(req.CloseWebhookURL) {
+			errs.add("close_webhook_url", models.FieldCodeInvalid, "close_webhook_url must be an http or https URL")
+		}
+	}
+	if errs.any() {
+		middleware.ValidationErrorResponse(w, errs)
+		return
+	}
+
+	// A closed poll will never notify again
+	var status string
+	err := h.db.QueryRow(`
+		SELECT status FROM poll WHERE id = $1
+	`, pollID).Scan(&status)
+	if err == sql.ErrNoRows {
+		middleware.ErrorResponse(w, http.StatusNotFound, "Poll not found")
+		return
+	}
+	if err != nil {
+		slog.Error("failed to query poll", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if status == models.StatusClosed {
+		middleware.ErrorResponse(w, http.StatusConflict, "Poll is already closed")
+		return
+	}
+
+	webhookURL := sql.NullString{String: req.CloseWebhookURL, Valid: req.CloseWebhookURL != ""}
+	_, err = h.db.Exec(`
+		UPDATE poll SET close_webhook_url = $1 WHERE id = $2
+	`, webhookURL, pollID)
+	if err != nil {
+		slog.Error("failed to set close webhook", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Failed to set close webhook")
+		return
+	}
+
+	resp := models.SetCloseWebhookResponse{CloseWebhookURL: req.CloseWebhookURL}
+	if webhookURL.Valid {
+		resp.WebhookSecret = auth.GenerateWebhookSecret(pollID, h.cfg.WebhookSalt)
+	}
+	middleware.JSONResponse(w, http.StatusOK, resp)
+}
+
+// isUniqueViolation reports whether err is a PostgreSQL unique_violation
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// ClosePoll handles POST /polls/:id/close
+func (h *PollHandler) ClosePoll(w http.ResponseWriter, r *http.Request) {
+	pollID := r.PathValue("id")
+	if pollID == "" {
+		middleware.ErrorResponse(w, http.StatusBadRequest, "poll_id is required")
+		return
+	}
+
+	// Validate admin key
+	adminKey := r.Header.Get("X-Admin-Key")
+	if err := auth.ValidateAdminKey(pollID, adminKey, h.cfg.AdminKeySalt); err != nil {
+		middleware.ErrorResponse(w, http.StatusUnauthorized, "Invalid admin key")
+		return
+	}
+
+	// The body is optional; it only carries an embargo on the results
+	var req models.ClosePollRequest
+	if err := middleware.DecodeOptionalJSON(r, &req); err != nil {
+		middleware.ErrorResponse(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	var errs fieldErrors
+	includeBreakdown := errs.queryBool(r, "include_breakdown")
+	if req.RevealAt != nil {
+		if !req.RevealAt.After(time.Now()) {
+			errs.add("reveal_at", models.FieldCodeInvalid, "reveal_at must be in the future")
+		}
+		utc := req.RevealAt.UTC()
+		req.RevealAt = &utc
+	}
+	if errs.any() {
+		middleware.ValidationErrorResponse(w, errs)
+		return
+	}
+
+	// With a grace period, ballots other instances committed just before the
+	// close have time to become visible here. The poll stays open, and keeps
+	// accepting ballots, until the wait is over. A close bound to fail is
+	// refused first instead of after the wait.
+	if h.cfg.CloseGracePeriod > 0 {
+		if err := checkClosable(r.Context(), h.db, pollID); err != nil {
+			closePollErrorResponse(w, pollID, err)
+			return
+		}
+		select {
+		case <-time.After(time.Duration(h.cfg.CloseGracePeriod) * time.Millisecond):
+		case <-r.Context().Done():
+			slog.Warn("close abandoned during grace period", "poll_id", pollID)
+			return
+		}
+	}
+
+	resp, err := h.closePoll(r.Context(), pollID, includeBreakdown, req.RevealAt, h.actorIPHash(r))
+	if err != nil {
+		closePollErrorResponse(w, pollID, err)
+		return
+	}
+
+	middleware.JSONResponse(w, http.StatusOK, resp)
+}
+
+// closeTooEarlyError reports a close attempted within the poll's minimum
+// open duration
+type closeTooEarlyError struct {
+	closableAt time.Time
+}
+
+func (e *closeTooEarlyError) Error() string {
+	return "poll cannot be closed until " + e.closableAt.Format(time.RFC3339)
+}
+
+// errPollNotFound reports a poll ID that matches no poll
+var errPollNotFound = errors.New("poll not found")
+
+// checkClosable reports, without locking the poll, the error closePoll
+// would return before sealing it: errPollNotFound, a pollNotOpenError, or a
+// closeTooEarlyError. Callers use it to refuse a close before waiting out
+// the grace period; closePoll checks again under its lock.
+func checkClosable(ctx context.Context, db *sql.DB, pollID string) error {
+	var status string
+	var openedAt sql.NullTime
+	var minOpenSeconds int
+	err := db.QueryRowContext(ctx, `
+		SELECT status, opened_at, min_open_seconds FROM poll WHERE id = $1
+	`, pollID).Scan(&status, &openedAt, &minOpenSeconds)
+	if err == sql.ErrNoRows {
+		return errPollNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("query poll: %w", err)
+	}
+	return closableError(status, openedAt, minOpenSeconds)
+}
+
+// closableError returns a pollNotOpenError unless status is open, and a
+// closeTooEarlyError while the poll is within its minimum open duration.
+// opened_at may have been stamped by another instance, so it allows for
+// skew.
+func closableError(status string, openedAt sql.NullTime, minOpenSeconds int) error {
+	if status != models.StatusOpen {
+		return &pollNotOpenError{status: status}
+	}
+	if minOpenSeconds > 0 && openedAt.Valid {
+		closableAt := openedAt.Time.UTC().Add(time.Duration(minOpenSeconds) * time.Second)
+		if time.Now().UTC().Add(clockSkewTolerance).Before(closableAt) {
+			return &closeTooEarlyError{closableAt: closableAt}
+		}
+	}
+	return nil
+}
+
+// closePollErrorResponse writes the response for a closePoll error
+func closePollErrorResponse(w http.ResponseWriter, pollID string, err error) {
+	var notOpen *pollNotOpenError
+	var tooEarly *closeTooEarlyError
+	switch {
+	case errors.Is(err, errPollNotFound):
+		middleware.ErrorResponse(w, http.StatusNotFound, "Poll not found")
+	case errors.As(err, &notOpen):
+		middleware.ErrorResponse(w, http.StatusConflict, "Poll is not open")
+	case errors.As(err, &tooEarly):
+		middleware.ErrorResponse(w, http.StatusConflict,
+			"Poll cannot be closed until "+tooEarly.closableAt.Format(time.RFC3339))
+	default:
+		slog.Error("failed to close poll", "error", err, "poll_id", pollID)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Failed to close poll")
+	}
+}
+
+// closePoll seals an open poll's results in one transaction and then
+// notifies its close webhook, if any. The breakdown is only read when
+// includeBreakdown is set; a non-nil revealAt embargoes the results. The
+// close is audited with ipHash, empty when the server closes the poll.
+func (h *PollHandler) closePoll(ctx context.Context, pollID string, includeBreakdown bool, revealAt *time.Time, ipHash string) (models.ClosePollResponse, error) {
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.ClosePollResponse{}, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Check poll exists and is open. The row lock waits out ballot writes
+	// already in flight and holds off new ones, so the snapshot below
+	// covers every ballot the poll accepts.
+	var status string
+	var openedAt sql.NullTime
+	var minOpenSeconds int
+	var webhookURL sql.NullString
+	err = tx.QueryRow(`
+		SELECT status, opened_at, min_open_seconds, close_webhook_url FROM poll WHERE id = $1 FOR UPDATE
+	`, pollID).Scan(&status, &openedAt, &minOpenSeconds, &webhookURL)
+	if err == sql.ErrNoRows {
+		return models.ClosePollResponse{}, errPollNotFound
+	}
+	if err != nil {
+		return models.ClosePollResponse{}, fmt.Errorf("query poll: %w", err)
+	}
+
+	// Enforce the minimum open duration to prevent accidental instant closes
+	if err := closableError(status, openedAt, minOpenSeconds); err != nil {
+		return models.ClosePollResponse{}, err
+	}
+
+	// Compute BMJ results, timed so slow closes of large polls show up
+	computeStart := time.Now()
+	payload, err := computeSnapshotPayload(ctx, h.db, pollID, h.bmj)
+	if err != nil {
+		return models.ClosePollResponse{}, fmt.Errorf("compute BMJ rankings: %w", err)
+	}
+	computeMS := time.Since(computeStart).Milliseconds()
+	rankings := payload.Rankings
+
+	ballotCount, _, err := countBallots(ctx, h.db, pollID)
+	if err != nil {
+		return models.ClosePollResponse{}, fmt.Errorf("count ballots: %w", err)
+	}
+
+	// Create payload JSON
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return models.ClosePollResponse{}, fmt.Errorf("marshal payload: %w", err)
+	}
+
+	// The breakdown is read under the same lock as the snapshot, so it
+	// matches the sealed results exactly
+	var breakdown []models.VoterBreakdown
+	if includeBreakdown {
+		breakdown, err = queryVoterBreakdown(tx, pollID)
+		if err != nil {
+			return models.ClosePollResponse{}, fmt.Errorf("query voter breakdown: %w", err)
+		}
+	}
+
+	snapshotID, _ := auth.GenerateID(recordIDBytes)
+	closedAt := time.Now().UTC()
+
+	// Update poll to closed
 	_, err = tx.Exec(`
 		UPDATE poll
-		SET status = $1, closed_at = $2, final_snapshot_id = $3
-		WHERE id = $4
-	`, models.StatusClosed, closedAt, snapshotID, pollID)
+		SET status = $1, closed_at = $2, final_snapshot_id = $3, paused = FALSE, reveal_at = $4
+		WHERE id = $5
+	`, models.StatusClosed, closedAt, snapshotID, revealAt, pollID)
+	if err != nil {
+		return models.ClosePollResponse{}, fmt.Errorf("update poll: %w", err)
+	}
+
+	// Insert snapshot with BMJ results
+	_, err = tx.Exec(`
+		INSERT INTO result_snapshot (id, poll_id, method, computed_at, payload)
+		VALUES ($1, $2, $3, $4, $5)
+	`, snapshotID, pollID, models.MethodBMJ, closedAt, payloadJSON)
+	if err != nil {
+		return models.ClosePollResponse{}, fmt.Errorf("insert snapshot: %w", err)
+	}
+
+	if err := auditAdminAction(tx, pollID, models.AdminActionClose, ipHash); err != nil {
+		return models.ClosePollResponse{}, err
+	}
 
+	if err := tx.Commit(); err != nil {
+		return models.ClosePollResponse{}, fmt.Errorf("commit: %w", err)
+	}
+
+	slog.Info("poll closed", "poll_id", pollID, "snapshot_id", snapshotID, "option_count", len(rankings),
+		"ballot_count", ballotCount, "compute_ms", computeMS)
+
+	snapshot := models.ResultSnapshot{
+		ID:               snapshotID,
+		PollID:           pollID,
+		Method:           models.MethodBMJ,
+		ComputedAt:       closedAt,
+		Rankings:         rankings,
+		InputsHash:       payload.InputsHash,
+		OptionsHash:      payload.OptionsHash,
+		VetoThreshold:    payload.VetoThreshold,
+		AlgorithmVersion: payload.AlgorithmVersion,
+	}
+
+	// Notify the integrator without holding up the admin's response
+	if webhookURL.Valid && h.cfg.WebhookSalt != "" {
+		go sendCloseWebhook(webhookURL.String, auth.GenerateWebhookSecret(pollID, h.cfg.WebhookSalt), models.CloseWebhookPayload{
+			Event:    models.WebhookEventPollClosed,
+			PollID:   pollID,
+			ClosedAt: closedAt,
+			Snapshot: snapshot,
+		})
+	}
+
+	return models.ClosePollResponse{
+		ClosedAt:    closedAt,
+		Snapshot:    snapshot,
+		Breakdown:   breakdown,
+		ComputeMS:   computeMS,
+		BallotCount: ballotCount,
+	}, nil
+}
+
+// maxBatchClosePolls caps the polls accepted by one CloseBatch request
+const maxBatchClosePolls = 50
+
+// CloseBatch handles POST /polls:close-batch
+// Closes several polls at once, each authorized by its own admin key and
+// closed in its own transaction. Every entry gets an outcome; one poll
+// failing never stops the rest.
+func (h *PollHandler) CloseBatch(w http.ResponseWriter, r *http.Request) {
+	var req models.CloseBatchRequest
+	if err := middleware.DecodeRequiredJSON(r, &req); err != nil {
+		middleware.ErrorResponse(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	var errs fieldErrors
+	if len(req.Polls) == 0 {
+		errs.add("polls", models.FieldCodeRequired, "polls is required")
+	} else if len(req.Polls) > maxBatchClosePolls {
+		errs.add("polls", models.FieldCodeOutOfRange, fmt.Sprintf("polls cannot list more than %d entries", maxBatchClosePolls))
+	}
+	if errs.any() {
+		middleware.ValidationErrorResponse(w, errs)
+		return
+	}
+
+	// Entries that can't be closed get their outcome now, so only the rest
+	// wait out the grace period
+	results := make([]models.CloseBatchResult, len(req.Polls))
+	var pending []int
+	for i, entry := range req.Polls {
+		result := &results[i]
+		result.ID = entry.ID
+
+		if err := auth.ValidateAdminKey(entry.ID, entry.AdminKey, h.cfg.AdminKeySalt); entry.ID == "" || err != nil {
+			result.Outcome = models.CloseOutcomeUnauthorized
+			continue
+		}
+		if h.cfg.CloseGracePeriod > 0 {
+			if err := checkClosable(r.Context(), h.db, entry.ID); err != nil {
+				setCloseBatchOutcome(result, models.ClosePollResponse{}, err)
+				continue
+			}
+		}
+		pending = append(pending, i)
+	}
+
+	// One grace period covers the whole batch
+	if h.cfg.CloseGracePeriod > 0 && len(pending) > 0 {
+		select {
+		case <-time.After(time.Duration(h.cfg.CloseGracePeriod) * time.Millisecond):
+		case <-r.Context().Done():
+			slog.Warn("batch close abandoned during grace period", "poll_count", len(pending))
+			return
+		}
+	}
+
+	ipHash := h.actorIPHash(r)
+	for _, i := range pending {
+		resp, err := h.closePoll(r.Context(), req.Polls[i].ID, false, nil, ipHash)
+		setCloseBatchOutcome(&results[i], resp, err)
+	}
+
+	middleware.JSONResponse(w, http.StatusOK, models.CloseBatchResponse{Results: results})
+}
+
+// setCloseBatchOutcome records in result how closing its poll went, given
+// closePoll's or checkClosable's return values
+func setCloseBatchOutcome(result *models.CloseBatchResult, resp models.ClosePollResponse, err error) {
+	var notOpen *pollNotOpenError
+	var tooEarly *closeTooEarlyError
+	switch {
+	case err == nil:
+		result.Outcome = models.CloseOutcomeClosed
+		result.ClosedAt = &resp.ClosedAt
+		result.SnapshotID = resp.Snapshot.ID
+	case errors.Is(err, errPollNotFound):
+		result.Outcome = models.CloseOutcomeNotFound
+	case errors.As(err, &notOpen):
+		result.Outcome = models.CloseOutcomeSkippedNotOpen
+	case errors.As(err, &tooEarly):
+		result.Outcome = models.CloseOutcomeTooEarly
+		result.ClosableAt = &tooEarly.closableAt
+	default:
+		slog.Error("failed to close poll in batch", "error", err, "poll_id", result.ID)
+		result.Outcome = models.CloseOutcomeFailed
+	}
+}
+
+// RecomputeResults handles POST /polls/:id/recompute
+// Ranks a closed poll's sealed ballots again with the current BMJ
+// parameters and makes the new snapshot the final one. The previous
+// snapshot stays in the results history; ballots are never touched.
+func (h *PollHandler) RecomputeResults(w http.ResponseWriter, r *http.Request) {
+	pollID := r.PathValue("id")
+	if pollID == "" {
+		middleware.ErrorResponse(w, http.StatusBadRequest, "poll_id is required")
+		return
+	}
+
+	// Validate admin key
+	adminKey := r.Header.Get("X-Admin-Key")
+	if err := auth.ValidateAdminKey(pollID, adminKey, h.cfg.AdminKeySalt); err != nil {
+		middleware.ErrorResponse(w, http.StatusUnauthorized, "Invalid admin key")
+		return
+	}
+
+	// No body is expected, but reject malformed JSON if one is sent
+	var req struct{}
+	if err := middleware.DecodeOptionalJSON(r, &req); err != nil {
+		middleware.ErrorResponse(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	tx, err := h.db.Begin()
 	if err != nil {
-		slog.Error("failed to close poll", "error", err)
-		middleware.ErrorResponse(w, http.StatusInternalServerError, "Failed to close poll")
+		slog.Error("failed to begin transaction", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
 		return
 	}
+	defer tx.Rollback()
+
+	// The row lock serializes concurrent recomputes of the same poll
+	var status string
+	var previousSnapshotID sql.NullString
+	err = tx.QueryRow(`
+		SELECT status, final_snapshot_id FROM poll WHERE id = $1 FOR UPDATE
+	`, pollID).Scan(&status, &previousSnapshotID)
+	if err == sql.ErrNoRows {
+		middleware.ErrorResponse(w, http.StatusNotFound, "Poll not found")
+		return
+	}
+	if err != nil {
+		slog.Error("failed to query poll", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	if status != models.StatusClosed {
+		middleware.ErrorResponse(w, http.StatusConflict, "Only closed polls can be recomputed")
+		return
+	}
+
+	// A closed poll accepts no ballots, so these are the sealed ones
+	payload, err := computeSnapshotPayload(r.Context(), h.db, pollID, h.bmj)
+	if err != nil {
+		slog.Error("failed to compute BMJ rankings", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Failed to compute results")
+		return
+	}
+
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		slog.Error("failed to marshal payload", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Failed to save results")
+		return
+	}
+
+	snapshotID, _ := auth.GenerateID(recordIDBytes)
+	computedAt := time.Now().UTC()
 
-	// Insert snapshot with BMJ results
 	_, err = tx.Exec(`
 		INSERT INTO result_snapshot (id, poll_id, method, computed_at, payload)
 		VALUES ($1, $2, $3, $4, $5)
-	`, snapshotID, pollID, models.MethodBMJ, closedAt, payloadJSON)
-
+	`, snapshotID, pollID, models.MethodBMJ, computedAt, payloadJSON)
 	if err != nil {
 		slog.Error("failed to insert snapshot", "error", err)
 		middleware.ErrorResponse(w, http.StatusInternalServerError, "Failed to save results")
 		return
 	}
 
+	_, err = tx.Exec(`
+		UPDATE poll SET final_snapshot_id = $1 WHERE id = $2
+	`, snapshotID, pollID)
+	if err != nil {
+		slog.Error("failed to repoint final snapshot", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Failed to save results")
+		return
+	}
+
 	if err := tx.Commit(); err != nil {
 		slog.Error("failed to commit transaction", "error", err)
-		middleware.ErrorResponse(w, http.StatusInternalServerError, "Failed to close poll")
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Failed to save results")
 		return
 	}
 
-	slog.Info("poll closed", "poll_id", pollID, "snapshot_id", snapshotID, "option_count", len(rankings))
+	slog.Info("poll results recomputed", "poll_id", pollID,
+		"snapshot_id", snapshotID, "previous_snapshot_id", previousSnapshotID.String)
 
-	// Return response with computed rankings
-	middleware.JSONResponse(w, http.StatusOK, models.ClosePollResponse{
-		ClosedAt: closedAt,
+	middleware.JSONResponse(w, http.StatusOK, models.RecomputeResultsResponse{
+		PreviousSnapshotID: previousSnapshotID.String,
 		Snapshot: models.ResultSnapshot{
-			ID:         snapshotID,
-			PollID:     pollID,
-			Method:     models.MethodBMJ,
-			ComputedAt: closedAt,
-			Rankings:   rankings,
-			InputsHash: payload.InputsHash,
+			ID:               snapshotID,
+			PollID:           pollID,
+			Method:           payload.Method,
+			ComputedAt:       computedAt,
+			Rankings:         payload.Rankings,
+			InputsHash:       payload.InputsHash,
+			OptionsHash:      payload.OptionsHash,
+			VetoThreshold:    payload.VetoThreshold,
+			AlgorithmVersion: payload.AlgorithmVersion,
 		},
 	})
 }
+
+// queryVoterBreakdown returns every ballot's scores keyed by the voter's
+// username, ordered by username. Voter tokens never leave the database.
+func queryVoterBreakdown(tx *sql.Tx, pollID string) ([]models.VoterBreakdown, error) {
+	rows, err := tx.Query(`
+		SELECT uc.username, s.option_id, s.value01
+		FROM ballot b
+		JOIN username_claim uc ON uc.poll_id = b.poll_id AND uc.voter_token = b.voter_token
+		JOIN score s ON s.ballot_id = b.id
+		WHERE b.poll_id = $1
+		ORDER BY uc.username, s.option_id
+	`, pollID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	breakdown := []models.VoterBreakdown{}
+	for rows.Next() {
+		var username, optionID string
+		var value float64
+		if err := rows.Scan(&username, &optionID, &value); err != nil {
+			return nil, err
+		}
+		if n := len(breakdown); n == 0 || breakdown[n-1].Username != username {
+			breakdown = append(breakdown, models.VoterBreakdown{
+				Username: username,
+				Scores:   make(map[string]float64),
+			})
+		}
+		breakdown[len(breakdown)-1].Scores[optionID] = value
+	}
+	return breakdown, rows.Err()
+}
+
+// ExportBallotsCSV handles GET /polls/:id/ballots.csv
+// Streams every ballot of a closed poll as CSV: username, submitted_at,
+// then one column per option holding the voter's value01, blank where the
+// voter left the option unscored. Voter tokens and IP hashes are never
+// selected, and usernames and labels are escaped by csvCell.
+func (h *PollHandler) ExportBallotsCSV(w http.ResponseWriter, r *http.Request) {
+	pollID := r.PathValue("id")
+	if pollID == "" {
+		middleware.ErrorResponse(w, http.StatusBadRequest, "poll_id is required")
+		return
+	}
+
+	// Validate admin key
+	adminKey := r.Header.Get("X-Admin-Key")
+	if err := auth.ValidateAdminKey(pollID, adminKey, h.cfg.AdminKeySalt); err != nil {
+		middleware.ErrorResponse(w, http.StatusUnauthorized, "Invalid admin key")
+		return
+	}
+
+	var status string
+	err := h.db.QueryRow(`SELECT status FROM poll WHERE id = $1`, pollID).Scan(&status)
+	if err == sql.ErrNoRows {
+		middleware.ErrorResponse(w, http.StatusNotFound, "Poll not found")
+		return
+	}
+	if err != nil {
+		slog.Error("failed to query poll", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	// Ballots are final once the poll closes, so the export can't change
+	if status != models.StatusClosed {
+		middleware.ErrorResponse(w, http.StatusConflict, "Ballots can only be exported after the poll closes")
+		return
+	}
+
+	options, err := queryOptions(h.db, pollID)
+	if err != nil {
+		slog.Error("failed to query options", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	// Every ballot is paired with every option, in the header's order, so
+	// each ballot arrives as len(options) consecutive rows
+	rows, err := h.db.Query(`
+		SELECT b.id, uc.username, b.submitted_at, s.value01
+		FROM ballot b
+		JOIN username_claim uc ON uc.poll_id = b.poll_id AND uc.voter_token = b.voter_token
+		JOIN option o ON o.poll_id = b.poll_id
+		LEFT JOIN score s ON s.ballot_id = b.id AND s.option_id = o.id
+		WHERE b.poll_id = $1
+		ORDER BY uc.username, b.id, o.position, o.id
+	`, pollID)
+	if err != nil {
+		slog.Error("failed to query ballots", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer rows.Close()
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="ballots-`+pollID+`.csv"`)
+	w.WriteHeader(http.StatusOK)
+
+	out := csv.NewWriter(w)
+	header := []string{"username", "submitted_at"}
+	for _, opt := range options {
+		header = append(header, csvCell(opt.Label))
+	}
+	out.Write(header)
+
+	// The status is already sent, so a failure past this point can only
+	// truncate the file
+	record := make([]string, len(header))
+	column := 0
+	var lastBallotID string
+	for rows.Next() {
+		var ballotID, username string
+		var submittedAt time.Time
+		var value sql.NullFloat64
+		if err := rows.Scan(&ballotID, &username, &submittedAt, &value); err != nil {
+			slog.Error("failed to scan ballot", "error", err, "poll_id", pollID)
+			return
+		}
+		if ballotID != lastBallotID {
+			lastBallotID = ballotID
+			record[0] = csvCell(username)
+			record[1] = submittedAt.UTC().Format(time.RFC3339)
+			column = 2
+		}
+		record[column] = ""
+		if value.Valid {
+			record[column] = strconv.FormatFloat(value.Float64, 'f', -1, 64)
+		}
+		column++
+		if column == len(record) {
+			out.Write(record)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		slog.Error("failed to iterate ballots", "error", err, "poll_id", pollID)
+		return
+	}
+
+	out.Flush()
+	if err := out.Error(); err != nil {
+		slog.Error("failed to write ballot export", "error", err, "poll_id", pollID)
+	}
+}
+
+// csvCell keeps spreadsheets from running voter-supplied text as a formula
+// by prefixing a quote to a value that starts like one
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// DuplicatePoll handles POST /polls/:id/duplicate
+// Clones a poll (any status) into a new draft with the same title,
+// description, creator, and option labels. Ballots, usernames, share
+// slug, and results are never copied.
+func (h *PollHandler) DuplicatePoll(w http.ResponseWriter, r *http.Request) {
+	sourceID := r.PathValue("id")
+	if sourceID == "" {
+		middleware.ErrorResponse(w, http.StatusBadRequest, "poll_id is required")
+		return
+	}
+
+	// Validate admin key for the source poll
+	adminKey := r.Header.Get("X-Admin-Key")
+	if err := auth.ValidateAdminKey(sourceID, adminKey, h.cfg.AdminKeySalt); err != nil {
+		middleware.ErrorResponse(w, http.StatusUnauthorized, "Invalid admin key")
+		return
+	}
+
+	// Load the source poll
+	var source models.Poll
+	err := scanPoll(h.db.QueryRow(`
+		SELECT `+pollColumns+`
+		FROM poll
+		WHERE id = $1
+	`, sourceID), &source)
+	if err == sql.ErrNoRows {
+		middleware.ErrorResponse(w, http.StatusNotFound, "Poll not found")
+		return
+	}
+	if err != nil {
+		slog.Error("failed to query poll", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	// Load the source option labels
+	rows, err := h.db.Query(`
+		SELECT label, description, metadata FROM option WHERE poll_id = $1 ORDER BY position, id
+	`, sourceID)
+	if err != nil {
+		slog.Error("failed to query options", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer rows.Close()
+
+	var sourceOptions []models.Option
+	for rows.Next() {
+		var opt models.Option
+		var metadata []byte
+		if err := rows.Scan(&opt.Label, &opt.Description, &metadata); err != nil {
+			slog.Error("failed to scan option", "error", err)
+			middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+		opt.Metadata = metadata
+		sourceOptions = append(sourceOptions, opt)
+	}
+	if err := rows.Err(); err != nil {
+		slog.Error("failed to read options", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	// Generate the new poll ID and admin key
+	pollID, err := h.newPollID()
+	if err != nil {
+		slog.Error("failed to generate poll ID", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Failed to duplicate poll")
+		return
+	}
+	newAdminKey := auth.GenerateAdminKey(pollID, h.cfg.AdminKeySalt)
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		slog.Error("failed to begin transaction", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer tx.Rollback()
+
+	// A duplicate is a new poll, so it counts against the same interval
+	wait, err := h.throttlePollCreation(tx, r)
+	if err != nil {
+		slog.Error("failed to claim poll creation", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Failed to duplicate poll")
+		return
+	}
+	if wait > 0 {
+		pollCreateTooSoonResponse(w, wait)
+		return
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO poll (id, title, description, creator_name, method, status, created_at, min_open_seconds, min_scored_options, live_results, exclude_test_ballots, default_unscored, allow_ballot_updates, min_username_length, max_username_length, creator_can_vote)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+	`, pollID, source.Title, source.Description, source.CreatorName, source.Method,
+		models.StatusDraft, time.Now().UTC(), source.MinOpenSeconds, source.MinScoredOptions, source.LiveResults, source.ExcludeTestBallots,
+		source.DefaultUnscored, source.AllowBallotUpdates, source.MinUsernameLength, source.MaxUsernameLength,
+		source.CreatorCanVote)
+	if err != nil {
+		slog.Error("failed to insert poll", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Failed to duplicate poll")
+		return
+	}
+
+	for i, opt := range sourceOptions {
+		optionID, err := h.newOptionID()
+		if err != nil {
+			slog.Error("failed to generate option ID", "error", err)
+			middleware.ErrorResponse(w, http.StatusInternalServerError, "Failed to duplicate poll")
+			return
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO option (id, poll_id, label, description, position, metadata)
+			VALUES ($1, $2, $3, $4, $5, $6::jsonb)
+		`, optionID, pollID, opt.Label, opt.Description, i, optionMetadataArg(json.RawMessage(opt.Metadata)))
+		if err != nil {
+			slog.Error("failed to insert option", "error", err)
+			middleware.ErrorResponse(w, http.StatusInternalServerError, "Failed to duplicate poll")
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		slog.Error("failed to commit transaction", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Failed to duplicate poll")
+		return
+	}
+
+	// Link device to the new poll as admin (if X-Device-UUID header present)
+	deviceID, err := GetOrCreateDevice(h.db, h.cfg, r)
+	if err != nil {
+		slog.Warn("failed to get/create device", "error", err)
+	} else if deviceID != "" {
+		if err := LinkDeviceToPoll(h.db, deviceID, pollID, models.RoleAdmin, nil); err != nil {
+			slog.Warn("failed to link device to poll", "error", err)
+		}
+	}
+
+	slog.Info("poll duplicated", "source_poll_id", sourceID, "poll_id", pollID, "option_count", len(sourceOptions))
+
+	middleware.JSONResponse(w, http.StatusCreated, models.CreatePollResponse{
+		PollID:   pollID,
+		AdminKey: newAdminKey,
+	})
+}
@@ -6,21 +6,59 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
+	"math"
 	"net/http"
+	"strings"
+	"time"
 
+	"github.com/danielhkuo/quickly-pick/auth"
 	"github.com/danielhkuo/quickly-pick/cliparse"
 	"github.com/danielhkuo/quickly-pick/middleware"
 	"github.com/danielhkuo/quickly-pick/models"
+	"github.com/lib/pq"
 )
 
 type ResultsHandler struct {
-	db  *sql.DB
-	cfg cliparse.Config
+	db     *sql.DB // primary, for polls, options, and the caller's own writes
+	reads  *sql.DB // read replica for results, previews, and counts, or the primary
+	cfg    cliparse.Config
+	counts *BallotCountCache // may be nil (no caching)
 }
 
 func NewResultsHandler(db *sql.DB, cfg cliparse.Config) *ResultsHandler {
-	return &ResultsHandler{db: db, cfg: cfg}
+	return NewResultsHandlerWithCache(db, cfg, nil)
+}
+
+// NewResultsHandlerWithCache creates a ResultsHandler that serves ballot
+// counts from the given cache
+func NewResultsHandlerWithCache(db *sql.DB, cfg cliparse.Config, counts *BallotCountCache) *ResultsHandler {
+	return NewResultsHandlerWithReplica(db, nil, cfg, counts)
+}
+
+// NewResultsHandlerWithReplica creates a ResultsHandler that sends results,
+// preview, and ballot count queries to readDB, falling back to db when
+// readDB is nil. Polls, options, and per-voter state such as has_voted are
+// still read from db, so a poll is visible as soon as it is written.
+func NewResultsHandlerWithReplica(db, readDB *sql.DB, cfg cliparse.Config, counts *BallotCountCache) *ResultsHandler {
+	if readDB == nil {
+		readDB = db
+	}
+	return &ResultsHandler{db: db, reads: readDB, cfg: cfg, counts: counts}
+}
+
+// ballotCount returns the poll's ballot count, through the cache if any
+func (h *ResultsHandler) ballotCount(pollID string) (int, error) {
+	return h.counts.Get(pollID, func() (int, error) {
+		var count int
+		err := h.reads.QueryRow(`
+			SELECT COUNT(*) FROM ballot WHERE poll_id = $1
+		`, pollID).Scan(&count)
+		return count, err
+	})
 }
 
 // GetPoll handles GET /polls/:slug
@@ -34,16 +72,11 @@ func (h *ResultsHandler) GetPoll(w http.ResponseWriter, r *http.Request) {
 
 	// Get poll by share slug
 	var poll models.Poll
-	err := h.db.QueryRow(`
-		SELECT id, title, description, creator_name, method, status, 
-		       share_slug, closes_at, closed_at, final_snapshot_id, created_at
+	err := scanPoll(h.db.QueryRow(`
+		SELECT `+pollColumns+`
 		FROM poll
-		WHERE share_slug = $1
-	`, shareSlug).Scan(
-		&poll.ID, &poll.Title, &poll.Description, &poll.CreatorName,
-		&poll.Method, &poll.Status, &poll.ShareSlug, &poll.ClosesAt,
-		&poll.ClosedAt, &poll.FinalSnapshotID, &poll.CreatedAt,
-	)
+		WHERE `+slugMatch+`
+	`, shareSlug), &poll)
 
 	if err == sql.ErrNoRows {
 		middleware.ErrorResponse(w, http.StatusNotFound, "Poll not found")
@@ -54,60 +87,47 @@ func (h *ResultsHandler) GetPoll(w http.ResponseWriter, r *http.Request) {
 		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
 		return
 	}
+	withSafeDescription(h.cfg, &poll)
 
 	// Get options
-	rows, err := h.db.Query(`
-		SELECT id, poll_id, label
-		FROM option
-		WHERE poll_id = $1
-		ORDER BY id
-	`, poll.ID)
-
+	options, err := queryOptions(h.db, poll.ID)
 	if err != nil {
 		slog.Error("failed to query options", "error", err)
 		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
 		return
 	}
-	defer rows.Close()
-
-	options := []models.Option{}
-	for rows.Next() {
-		var opt models.Option
-		if err := rows.Scan(&opt.ID, &opt.PollID, &opt.Label); err != nil {
-			slog.Error("failed to scan option", "error", err)
-			middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
-			return
-		}
-		options = append(options, opt)
-	}
 
 	response := models.PollWithOptions{
 		Poll:    poll,
 		Options: options,
 	}
 
-	middleware.JSONResponse(w, http.StatusOK, response)
+	middleware.NegotiatedResponse(w, r, http.StatusOK, response)
 }
 
-// GetResults handles GET /polls/:slug/results
-// Returns 403 if poll is open (results are sealed)
-// Returns final snapshot if poll is closed
-func (h *ResultsHandler) GetResults(w http.ResponseWriter, r *http.Request) {
+// GetOptions handles GET /polls/:slug/options
+// Pages through a poll's options in display order with ?limit= and
+// ?offset=, for voting screens that load long option lists lazily.
+// Without limit every option from offset on is returned.
+func (h *ResultsHandler) GetOptions(w http.ResponseWriter, r *http.Request) {
 	shareSlug := r.PathValue("slug")
 	if shareSlug == "" {
 		middleware.ErrorResponse(w, http.StatusBadRequest, "slug is required")
 		return
 	}
 
-	// Get poll status and snapshot ID
-	var status string
-	var snapshotID sql.NullString
-	err := h.db.QueryRow(`
-		SELECT status, final_snapshot_id
-		FROM poll
-		WHERE share_slug = $1
-	`, shareSlug).Scan(&status, &snapshotID)
+	var errs fieldErrors
+	limit := errs.queryInt(r, "limit", 0, 1)
+	offset := errs.queryInt(r, "offset", 0, 0)
+	if errs.any() {
+		middleware.ValidationErrorResponse(w, errs)
+		return
+	}
 
+	var pollID string
+	err := h.db.QueryRow(`
+		SELECT id FROM poll WHERE `+slugMatch+`
+	`, shareSlug).Scan(&pollID)
 	if err == sql.ErrNoRows {
 		middleware.ErrorResponse(w, http.StatusNotFound, "Poll not found")
 		return
@@ -118,90 +138,543 @@ func (h *ResultsHandler) GetResults(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// CRITICAL: Results are sealed while poll is open
-	if status != models.StatusClosed {
-		middleware.ErrorResponse(w, http.StatusForbidden, "Results are hidden until poll is closed")
+	var total int
+	err = h.db.QueryRow(`SELECT COUNT(*) FROM option WHERE poll_id = $1`, pollID).Scan(&total)
+	if err != nil {
+		slog.Error("failed to count options", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
 		return
 	}
 
-	// Poll is closed, return final snapshot
-	if !snapshotID.Valid {
-		slog.Error("closed poll has no snapshot", "slug", shareSlug)
-		middleware.ErrorResponse(w, http.StatusInternalServerError, "Results not available")
+	options, err := queryOptionPage(h.db, pollID, limit, offset)
+	if err != nil {
+		slog.Error("failed to query options", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
 		return
 	}
 
-	// Get snapshot
+	middleware.NegotiatedResponse(w, r, http.StatusOK, models.OptionsResponse{
+		PollID:  pollID,
+		Total:   total,
+		Offset:  offset,
+		Limit:   limit,
+		Options: options,
+	})
+}
+
+// resultsInclude selects the optional option fields embedded in rankings
+type resultsInclude struct {
+	labels       bool
+	descriptions bool
+}
+
+// parseResultsInclude reads ?include=labels,descriptions. Without the
+// parameter rankings carry labels, as they always have; an empty value
+// strips both. It reports false for unknown fields.
+func parseResultsInclude(r *http.Request) (resultsInclude, bool) {
+	values, ok := r.URL.Query()["include"]
+	if !ok {
+		return resultsInclude{labels: true}, true
+	}
+
+	var include resultsInclude
+	for _, value := range values {
+		for _, field := range strings.Split(value, ",") {
+			switch strings.TrimSpace(field) {
+			case "":
+			case "labels":
+				include.labels = true
+			case "descriptions":
+				include.descriptions = true
+			default:
+				return resultsInclude{}, false
+			}
+		}
+	}
+	return include, true
+}
+
+// parseKeyCase reads ?case=, reporting whether results keys should be
+// camelCase. snake, the default, keeps the canonical names. It reports
+// false for any other value.
+func parseKeyCase(r *http.Request) (camel bool, ok bool) {
+	switch r.URL.Query().Get("case") {
+	case "", "snake":
+		return false, true
+	case "camel":
+		return true, true
+	}
+	return false, false
+}
+
+// invalidKeyCaseResponse writes the 400 for an unknown ?case= value
+func invalidKeyCaseResponse(w http.ResponseWriter) {
+	middleware.ValidationErrorResponse(w, []models.FieldError{{
+		Field:   "case",
+		Code:    models.FieldCodeInvalid,
+		Message: "case may only be snake or camel",
+	}})
+}
+
+// withKeyCase returns data with camelCase keys when camel is set, and data
+// itself otherwise
+func withKeyCase(data interface{}, camel bool) (interface{}, error) {
+	if !camel {
+		return data, nil
+	}
+	return middleware.CamelCaseKeys(data)
+}
+
+// roundRankings rounds each ranking's statistics to digits decimal places
+// for presentation. Stored snapshots keep full precision.
+func roundRankings(rankings []models.OptionStats, digits int) {
+	scale := math.Pow(10, float64(digits))
+	round := func(v float64) float64 { return math.Round(v*scale) / scale }
+	for i := range rankings {
+		rankings[i].Median = round(rankings[i].Median)
+		rankings[i].P10 = round(rankings[i].P10)
+		rankings[i].P90 = round(rankings[i].P90)
+		rankings[i].Mean = round(rankings[i].Mean)
+		rankings[i].NegShare = round(rankings[i].NegShare)
+	}
+}
+
+// embargoed reports whether the poll's reveal_at still hides its results
+// from the caller. The poll's admin, sending X-Admin-Key, is never held back.
+func (h *ResultsHandler) embargoed(r *http.Request, pollID string, revealAt sql.NullTime) bool {
+	return revealAt.Valid && time.Now().Before(revealAt.Time) &&
+		auth.ValidateAdminKey(pollID, r.Header.Get("X-Admin-Key"), h.cfg.AdminKeySalt) != nil
+}
+
+// embargoedResponse writes the 403 for results hidden until revealAt
+func embargoedResponse(w http.ResponseWriter, revealAt time.Time) {
+	middleware.JSONResponse(w, http.StatusForbidden, models.ResultsEmbargoedResponse{
+		ErrorResponse: models.ErrorResponse{
+			Error:   http.StatusText(http.StatusForbidden),
+			Message: "Results are hidden until " + revealAt.UTC().Format(time.RFC3339),
+			Code:    models.ErrorCodeResultsEmbargoed,
+		},
+		RevealAt: revealAt.UTC(),
+	})
+}
+
+// finalSnapshot loads a sealed snapshot from db, filling in the parameters
+// of snapshots written before they were recorded. Pass the connection the
+// poll was read from, so a snapshot the poll refers to is always there.
+func (h *ResultsHandler) finalSnapshot(db *sql.DB, snapshotID string) (models.ResultSnapshot, error) {
 	var snapshot models.ResultSnapshot
 	var payloadJSON []byte
-	err = h.db.QueryRow(`
+	err := db.QueryRow(`
 		SELECT id, poll_id, method, computed_at, payload
 		FROM result_snapshot
 		WHERE id = $1
-	`, snapshotID.String).Scan(
+	`, snapshotID).Scan(
 		&snapshot.ID, &snapshot.PollID, &snapshot.Method,
 		&snapshot.ComputedAt, &payloadJSON,
 	)
+	if err != nil {
+		return models.ResultSnapshot{}, fmt.Errorf("query snapshot: %w", err)
+	}
+
+	var payload snapshotPayload
+	if err := json.Unmarshal(payloadJSON, &payload); err != nil {
+		return models.ResultSnapshot{}, fmt.Errorf("parse snapshot payload: %w", err)
+	}
+	payload.withLegacyParameters()
+
+	snapshot.Rankings = payload.Rankings
+	snapshot.InputsHash = payload.InputsHash
+	snapshot.OptionsHash = payload.OptionsHash
+	snapshot.Method = payload.Method
+	snapshot.VetoThreshold = payload.VetoThreshold
+	snapshot.AlgorithmVersion = payload.AlgorithmVersion
+	return snapshot, nil
+}
+
+// snapshotMissingResponse writes the 500 for a closed poll whose
+// final_snapshot_id names a snapshot that no longer exists, e.g. after a
+// manual edit. RecomputeResults seals a new one and heals the poll.
+func snapshotMissingResponse(w http.ResponseWriter, pollID, snapshotID string) {
+	slog.Error("final snapshot is missing", "poll_id", pollID, "snapshot_id", snapshotID)
+	middleware.ErrorResponseWithCode(w, http.StatusInternalServerError, models.ErrorCodeSnapshotMissing,
+		"Results snapshot is missing; the poll admin can recompute the results")
+}
+
+// GetResults handles GET /polls/:slug/results
+// Returns 403 if poll is open (results are sealed), unless the poll shows
+// live results, in which case current rankings are marked provisional
+// Returns final snapshot if poll is closed
+func (h *ResultsHandler) GetResults(w http.ResponseWriter, r *http.Request) {
+	shareSlug := r.PathValue("slug")
+	if shareSlug == "" {
+		middleware.ErrorResponse(w, http.StatusBadRequest, "slug is required")
+		return
+	}
+
+	include, ok := parseResultsInclude(r)
+	if !ok {
+		middleware.ValidationErrorResponse(w, []models.FieldError{{
+			Field:   "include",
+			Code:    models.FieldCodeInvalid,
+			Message: "include may only list labels and descriptions",
+		}})
+		return
+	}
+
+	// Statistics are rounded to cfg.ResultDigits unless the raw values are asked for
+	fullPrecision := false
+	switch r.URL.Query().Get("precision") {
+	case "":
+	case "full":
+		fullPrecision = true
+	default:
+		middleware.ValidationErrorResponse(w, []models.FieldError{{
+			Field:   "precision",
+			Code:    models.FieldCodeInvalid,
+			Message: "precision may only be full",
+		}})
+		return
+	}
+
+	camel, ok := parseKeyCase(r)
+	if !ok {
+		invalidKeyCaseResponse(w)
+		return
+	}
 
+	// Get poll status and snapshot ID
+	var pollID string
+	var status string
+	var snapshotID sql.NullString
+	var liveResults bool
+	var revealAt sql.NullTime
+	err := h.reads.QueryRow(`
+		SELECT id, status, final_snapshot_id, live_results, reveal_at
+		FROM poll
+		WHERE `+slugMatch+`
+	`, shareSlug).Scan(&pollID, &status, &snapshotID, &liveResults, &revealAt)
+
+	if err == sql.ErrNoRows {
+		middleware.ErrorResponse(w, http.StatusNotFound, "Poll not found")
+		return
+	}
 	if err != nil {
-		slog.Error("failed to query snapshot", "error", err)
+		slog.Error("failed to query poll", "error", err)
 		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
 		return
 	}
 
-	// Parse JSON payload
-	var payload struct {
-		Rankings   []models.OptionStats `json:"rankings"`
-		InputsHash string               `json:"inputs_hash"`
+	// CRITICAL: Results are sealed while poll is open, unless the creator
+	// opted into live results
+	provisional := status == models.StatusOpen && liveResults
+	if status != models.StatusClosed && !provisional {
+		middleware.ErrorResponse(w, http.StatusForbidden, "Results are hidden until poll is closed")
+		return
 	}
-	if err := json.Unmarshal(payloadJSON, &payload); err != nil {
-		slog.Error("failed to parse snapshot payload", "error", err)
-		middleware.ErrorResponse(w, http.StatusInternalServerError, "Failed to parse results")
+
+	if h.embargoed(r, pollID, revealAt) {
+		embargoedResponse(w, revealAt.Time)
 		return
 	}
 
-	snapshot.Rankings = payload.Rankings
-	snapshot.InputsHash = payload.InputsHash
+	var snapshot models.ResultSnapshot
+	if provisional {
+		// Nothing is stored; the rankings are recomputed on every request
+		rankings, err := ComputeBMJRankingsWithOptions(r.Context(), h.reads, pollID,
+			BMJOptions{LowSampleThreshold: h.cfg.LowSampleThreshold})
+		if err != nil {
+			slog.Error("failed to compute live rankings", "error", err, "poll_id", pollID)
+			middleware.ErrorResponse(w, http.StatusInternalServerError, "Failed to compute results")
+			return
+		}
+		snapshot = models.ResultSnapshot{
+			PollID:           pollID,
+			Method:           models.MethodBMJ,
+			ComputedAt:       time.Now().UTC(),
+			Rankings:         rankings,
+			VetoThreshold:    BMJVetoThreshold,
+			AlgorithmVersion: BMJAlgorithmVersion,
+		}
+	} else {
+		// Poll is closed, return final snapshot
+		if !snapshotID.Valid {
+			slog.Error("closed poll has no snapshot", "slug", shareSlug)
+			middleware.ErrorResponse(w, http.StatusInternalServerError, "Results not available")
+			return
+		}
+
+		snapshot, err = h.finalSnapshot(h.reads, snapshotID.String)
+		if errors.Is(err, sql.ErrNoRows) {
+			snapshotMissingResponse(w, pollID, snapshotID.String)
+			return
+		}
+		if err != nil {
+			slog.Error("failed to load snapshot", "error", err)
+			middleware.ErrorResponse(w, http.StatusInternalServerError, "Failed to load results")
+			return
+		}
+	}
+
+	// Older snapshots predate the status field, so always derive it
+	for i := range snapshot.Rankings {
+		snapshot.Rankings[i].Status = optionStatus(snapshot.Rankings[i])
+	}
+
+	// Snapshots store labels but not descriptions; options are frozen once
+	// a poll is published, so the current rows match what voters saw
+	descriptions := make(map[string]string)
+	if include.descriptions {
+		options, err := queryOptions(h.reads, snapshot.PollID)
+		if err != nil {
+			slog.Error("failed to query options for results", "error", err)
+			middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+		for _, opt := range options {
+			descriptions[opt.ID] = opt.Description
+		}
+	}
+	for i := range snapshot.Rankings {
+		if !include.labels {
+			snapshot.Rankings[i].Label = ""
+		}
+		snapshot.Rankings[i].Description = descriptions[snapshot.Rankings[i].OptionID]
+	}
 
 	// Get poll information for the response
 	var poll models.Poll
-	err = h.db.QueryRow(`
-		SELECT id, title, description, creator_name, method, status, 
-		       share_slug, closes_at, closed_at, final_snapshot_id, created_at
+	err = scanPoll(h.reads.QueryRow(`
+		SELECT `+pollColumns+`
 		FROM poll
-		WHERE share_slug = $1
-	`, shareSlug).Scan(
-		&poll.ID, &poll.Title, &poll.Description, &poll.CreatorName,
-		&poll.Method, &poll.Status, &poll.ShareSlug, &poll.ClosesAt,
-		&poll.ClosedAt, &poll.FinalSnapshotID, &poll.CreatedAt,
-	)
+		WHERE `+slugMatch+`
+	`, shareSlug), &poll)
 
 	if err != nil {
 		slog.Error("failed to query poll for results", "error", err)
 		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
 		return
 	}
+	withSafeDescription(h.cfg, &poll)
 
-	// Get ballot count
-	var ballotCount int
-	err = h.db.QueryRow(`
-		SELECT COUNT(*) FROM ballot WHERE poll_id = $1
-	`, poll.ID).Scan(&ballotCount)
-
+	// Get ballot count, with test ballots, and the count the rankings use
+	ballotCount, countedBallots, err := countBallots(r.Context(), h.reads, poll.ID)
 	if err != nil {
 		slog.Error("failed to count ballots for results", "error", err)
 		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
 		return
 	}
 
+	// Ties are judged on the exact statistics, before any rounding
+	tie := firstPlaceTie(snapshot.Rankings)
+	if !fullPrecision && h.cfg.ResultDigits > 0 {
+		roundRankings(snapshot.Rankings, h.cfg.ResultDigits)
+	}
+
 	// Return results in the format expected by frontend
 	response := map[string]interface{}{
-		"poll":         poll,
-		"rankings":     snapshot.Rankings,
-		"ballot_count": ballotCount,
+		"poll":              poll,
+		"rankings":          snapshot.Rankings,
+		"ballot_count":      ballotCount,
+		"counted_ballots":   countedBallots,
+		"method":            snapshot.Method,
+		"veto_threshold":    snapshot.VetoThreshold,
+		"algorithm_version": snapshot.AlgorithmVersion,
+		"score_scale":       BMJScoreScale,
+		"tie":               tie,
+		"provisional":       provisional,
+	}
+
+	body, err := withKeyCase(response, camel)
+	if err != nil {
+		slog.Error("failed to rename results keys", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Failed to encode results")
+		return
 	}
 
-	middleware.JSONResponse(w, http.StatusOK, response)
+	middleware.NegotiatedResponse(w, r, http.StatusOK, body)
+}
+
+// GetResultsText handles GET /polls/:slug/results.txt
+// Returns a closed poll's final ranking as plain text for pasting into a
+// chat. Like GetResults, it answers 403 while the poll is open or embargoed.
+func (h *ResultsHandler) GetResultsText(w http.ResponseWriter, r *http.Request) {
+	shareSlug := r.PathValue("slug")
+	if shareSlug == "" {
+		middleware.ErrorResponse(w, http.StatusBadRequest, "slug is required")
+		return
+	}
+
+	var pollID, title, status string
+	var snapshotID sql.NullString
+	var revealAt sql.NullTime
+	err := h.db.QueryRow(`
+		SELECT id, title, status, final_snapshot_id, reveal_at
+		FROM poll
+		WHERE `+slugMatch+`
+	`, shareSlug).Scan(&pollID, &title, &status, &snapshotID, &revealAt)
+
+	if err == sql.ErrNoRows {
+		middleware.ErrorResponse(w, http.StatusNotFound, "Poll not found")
+		return
+	}
+	if err != nil {
+		slog.Error("failed to query poll", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	// Live results are provisional, so only sealed results are shared as text
+	if status != models.StatusClosed {
+		middleware.ErrorResponse(w, http.StatusForbidden, "Results are hidden until poll is closed")
+		return
+	}
+	if h.embargoed(r, pollID, revealAt) {
+		embargoedResponse(w, revealAt.Time)
+		return
+	}
+
+	if !snapshotID.Valid {
+		slog.Error("closed poll has no snapshot", "slug", shareSlug)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Results not available")
+		return
+	}
+	snapshot, err := h.finalSnapshot(h.db, snapshotID.String)
+	if errors.Is(err, sql.ErrNoRows) {
+		snapshotMissingResponse(w, pollID, snapshotID.String)
+		return
+	}
+	if err != nil {
+		slog.Error("failed to load snapshot", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Failed to load results")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	io.WriteString(w, formatResultsText(title, snapshot.Rankings))
+}
+
+// formatResultsText renders rankings as a numbered list under the poll
+// title, marking the winner with a trophy and vetoed options as such.
+// Medians are on the signed -1 to 1 axis, like every other statistic.
+func formatResultsText(title string, rankings []models.OptionStats) string {
+	var b strings.Builder
+	b.WriteString(title + "\n\n")
+	for _, stat := range rankings {
+		switch optionStatus(stat) {
+		case models.OptionStatusVetoed:
+			fmt.Fprintf(&b, "%d. %s (vetoed)\n", stat.Rank, stat.Label)
+		case models.OptionStatusWinner:
+			fmt.Fprintf(&b, "%d. %s (median %.2f) 🏆\n", stat.Rank, stat.Label, stat.Median)
+		default:
+			fmt.Fprintf(&b, "%d. %s (median %.2f)\n", stat.Rank, stat.Label, stat.Median)
+		}
+	}
+	if len(rankings) == 0 {
+		b.WriteString("No options were ranked.\n")
+	}
+	return b.String()
+}
+
+// GetResultsHistory handles GET /polls/:slug/results/history
+// Lists every snapshot sealed for the poll, newest first (admin only)
+func (h *ResultsHandler) GetResultsHistory(w http.ResponseWriter, r *http.Request) {
+	shareSlug := r.PathValue("slug")
+	if shareSlug == "" {
+		middleware.ErrorResponse(w, http.StatusBadRequest, "slug is required")
+		return
+	}
+
+	camel, ok := parseKeyCase(r)
+	if !ok {
+		invalidKeyCaseResponse(w)
+		return
+	}
+
+	var pollID string
+	var finalSnapshotID sql.NullString
+	err := h.db.QueryRow(`
+		SELECT id, final_snapshot_id FROM poll WHERE `+slugMatch+`
+	`, shareSlug).Scan(&pollID, &finalSnapshotID)
+	if err == sql.ErrNoRows {
+		middleware.ErrorResponse(w, http.StatusNotFound, "Poll not found")
+		return
+	}
+	if err != nil {
+		slog.Error("failed to query poll", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	// Earlier rounds can differ from the published results, so only the
+	// poll's admin may see them
+	adminKey := r.Header.Get("X-Admin-Key")
+	if err := auth.ValidateAdminKey(pollID, adminKey, h.cfg.AdminKeySalt); err != nil {
+		middleware.ErrorResponse(w, http.StatusUnauthorized, "Invalid admin key")
+		return
+	}
+
+	rows, err := h.db.Query(`
+		SELECT id, method, computed_at, payload
+		FROM result_snapshot
+		WHERE poll_id = $1
+		ORDER BY computed_at DESC, id
+	`, pollID)
+	if err != nil {
+		slog.Error("failed to query snapshots", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer rows.Close()
+
+	snapshots := []models.SnapshotSummary{}
+	for rows.Next() {
+		var summary models.SnapshotSummary
+		var payloadJSON []byte
+		if err := rows.Scan(&summary.ID, &summary.Method, &summary.ComputedAt, &payloadJSON); err != nil {
+			slog.Error("failed to scan snapshot", "error", err)
+			middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+
+		var payload snapshotPayload
+		if err := json.Unmarshal(payloadJSON, &payload); err != nil {
+			slog.Error("failed to parse snapshot payload", "error", err, "snapshot_id", summary.ID)
+			middleware.ErrorResponse(w, http.StatusInternalServerError, "Failed to parse results")
+			return
+		}
+
+		summary.InputsHash = payload.InputsHash
+		summary.Final = finalSnapshotID.Valid && finalSnapshotID.String == summary.ID
+		summary.Rankings = make([]models.RankingSummary, len(payload.Rankings))
+		for i, stats := range payload.Rankings {
+			summary.Rankings[i] = models.RankingSummary{
+				OptionID: stats.OptionID,
+				Label:    stats.Label,
+				Rank:     stats.Rank,
+				Median:   stats.Median,
+				Status:   optionStatus(stats),
+			}
+		}
+		snapshots = append(snapshots, summary)
+	}
+	if err := rows.Err(); err != nil {
+		slog.Error("failed to iterate snapshots", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	body, err := withKeyCase(models.ResultsHistoryResponse{
+		PollID:    pollID,
+		Snapshots: snapshots,
+	}, camel)
+	if err != nil {
+		slog.Error("failed to rename results history keys", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Failed to encode results")
+		return
+	}
+
+	middleware.JSONResponse(w, http.StatusOK, body)
 }
 
 // GetBallotCount handles GET /polls/:slug/ballot-count (optional convenience endpoint)
@@ -215,8 +688,8 @@ func (h *ResultsHandler) GetBallotCount(w http.ResponseWriter, r *http.Request)
 
 	// Get poll ID
 	var pollID string
-	err := h.db.QueryRow(`
-		SELECT id FROM poll WHERE share_slug = $1
+	err := h.reads.QueryRow(`
+		SELECT id FROM poll WHERE `+slugMatch+`
 	`, shareSlug).Scan(&pollID)
 
 	if err == sql.ErrNoRows {
@@ -230,11 +703,7 @@ func (h *ResultsHandler) GetBallotCount(w http.ResponseWriter, r *http.Request)
 	}
 
 	// Count ballots
-	var count int
-	err = h.db.QueryRow(`
-		SELECT COUNT(*) FROM ballot WHERE poll_id = $1
-	`, pollID).Scan(&count)
-
+	count, err := h.ballotCount(pollID)
 	if err != nil {
 		slog.Error("failed to count ballots", "error", err)
 		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
@@ -246,6 +715,102 @@ func (h *ResultsHandler) GetBallotCount(w http.ResponseWriter, r *http.Request)
 	})
 }
 
+// defaultBallotTimeBucket is the bucket GetBallotCountByTime uses without ?bucket=
+const defaultBallotTimeBucket = time.Hour
+
+// minBallotTimeBucket and maxBallotTimeBucket bound the ?bucket= accepted
+// by GetBallotCountByTime
+const (
+	minBallotTimeBucket = time.Minute
+	maxBallotTimeBucket = 7 * 24 * time.Hour
+)
+
+// GetBallotCountByTime handles GET /polls/:slug/ballots/count-by-time
+// Counts ballots per ?bucket= (a Go duration such as 1h or 15m, whole
+// seconds only) of submitted_at, for turnout charts. Buckets are aligned to
+// the Unix epoch in UTC, and those without ballots are omitted. An updated
+// ballot counts once, at its latest submission.
+func (h *ResultsHandler) GetBallotCountByTime(w http.ResponseWriter, r *http.Request) {
+	shareSlug := r.PathValue("slug")
+	if shareSlug == "" {
+		middleware.ErrorResponse(w, http.StatusBadRequest, "slug is required")
+		return
+	}
+
+	bucket := defaultBallotTimeBucket
+	if value := r.URL.Query().Get("bucket"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed < minBallotTimeBucket || parsed > maxBallotTimeBucket || parsed%time.Second != 0 {
+			middleware.ValidationErrorResponse(w, []models.FieldError{{
+				Field:   "bucket",
+				Code:    models.FieldCodeInvalid,
+				Message: "bucket must be a whole number of seconds between 1m and 168h, e.g. 1h",
+			}})
+			return
+		}
+		bucket = parsed
+	}
+
+	var pollID, status string
+	err := h.db.QueryRow(`
+		SELECT id, status FROM poll WHERE `+slugMatch+`
+	`, shareSlug).Scan(&pollID, &status)
+	if err == sql.ErrNoRows {
+		middleware.ErrorResponse(w, http.StatusNotFound, "Poll not found")
+		return
+	}
+	if err != nil {
+		slog.Error("failed to query poll", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	if status == models.StatusDraft {
+		middleware.ErrorResponseWithCode(w, http.StatusConflict, models.ErrorCodePollDraft, "Poll is not open for voting yet")
+		return
+	}
+
+	rows, err := h.db.QueryContext(r.Context(), `
+		SELECT to_timestamp(floor(extract(epoch FROM submitted_at) / $2) * $2) AS bucket_start, COUNT(*)
+		FROM ballot
+		WHERE poll_id = $1
+		GROUP BY bucket_start
+		ORDER BY bucket_start
+	`, pollID, int64(bucket/time.Second))
+	if err != nil {
+		slog.Error("failed to count ballots by time", "error", err, "poll_id", pollID)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer rows.Close()
+
+	buckets := []models.BallotTimeBucket{}
+	total := 0
+	for rows.Next() {
+		var b models.BallotTimeBucket
+		if err := rows.Scan(&b.Start, &b.Count); err != nil {
+			slog.Error("failed to scan ballot time bucket", "error", err, "poll_id", pollID)
+			middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+		b.Start = b.Start.UTC()
+		total += b.Count
+		buckets = append(buckets, b)
+	}
+	if err := rows.Err(); err != nil {
+		slog.Error("failed to count ballots by time", "error", err, "poll_id", pollID)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	middleware.JSONResponse(w, http.StatusOK, models.BallotCountByTimeResponse{
+		PollID:      pollID,
+		Bucket:      bucket.String(),
+		BallotCount: total,
+		Buckets:     buckets,
+	})
+}
+
 // GetPreview handles GET /polls/:slug/preview
 // Returns compact poll data for iMessage bubble display
 func (h *ResultsHandler) GetPreview(w http.ResponseWriter, r *http.Request) {
@@ -258,8 +823,8 @@ func (h *ResultsHandler) GetPreview(w http.ResponseWriter, r *http.Request) {
 	// Get poll info with counts
 	var title, status string
 	var pollID string
-	err := h.db.QueryRow(`
-		SELECT id, title, status FROM poll WHERE share_slug = $1
+	err := h.reads.QueryRow(`
+		SELECT id, title, status FROM poll WHERE `+slugMatch+`
 	`, shareSlug).Scan(&pollID, &title, &status)
 
 	if err == sql.ErrNoRows {
@@ -274,7 +839,7 @@ func (h *ResultsHandler) GetPreview(w http.ResponseWriter, r *http.Request) {
 
 	// Get option count
 	var optionCount int
-	err = h.db.QueryRow(`
+	err = h.reads.QueryRow(`
 		SELECT COUNT(*) FROM option WHERE poll_id = $1
 	`, pollID).Scan(&optionCount)
 	if err != nil {
@@ -284,10 +849,7 @@ func (h *ResultsHandler) GetPreview(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get ballot count
-	var ballotCount int
-	err = h.db.QueryRow(`
-		SELECT COUNT(*) FROM ballot WHERE poll_id = $1
-	`, pollID).Scan(&ballotCount)
+	ballotCount, err := h.ballotCount(pollID)
 	if err != nil {
 		slog.Error("failed to count ballots", "error", err)
 		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
@@ -301,3 +863,241 @@ func (h *ResultsHandler) GetPreview(w http.ResponseWriter, r *http.Request) {
 		BallotCount: ballotCount,
 	})
 }
+
+// GetPublicStatus handles GET /polls/:id/public-status
+// Lets collaborators holding only the poll ID see whether it has been
+// published, without the admin key. Only the title, status, and option
+// count are returned; no slugs, admin data, or ballots.
+func (h *ResultsHandler) GetPublicStatus(w http.ResponseWriter, r *http.Request) {
+	pollID := r.PathValue("id")
+	if pollID == "" {
+		middleware.ErrorResponse(w, http.StatusBadRequest, "id is required")
+		return
+	}
+
+	var resp models.PollPublicStatusResponse
+	err := h.db.QueryRow(`
+		SELECT p.title, p.status,
+			(SELECT COUNT(*) FROM option o WHERE o.poll_id = p.id)
+		FROM poll p WHERE p.id = $1
+	`, pollID).Scan(&resp.Title, &resp.Status, &resp.OptionCount)
+
+	if err == sql.ErrNoRows {
+		middleware.ErrorResponse(w, http.StatusNotFound, "Poll not found")
+		return
+	}
+	if err != nil {
+		slog.Error("failed to query poll", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	middleware.JSONResponse(w, http.StatusOK, resp)
+}
+
+// maxPreviewSlugs caps the slugs accepted by one GetPreviews request
+const maxPreviewSlugs = 50
+
+// GetPreviews handles POST /polls/previews
+// Resolves previews for several slugs in one query, in request order.
+// Unknown slugs are marked not_found rather than failing the request.
+func (h *ResultsHandler) GetPreviews(w http.ResponseWriter, r *http.Request) {
+	var req models.GetPreviewsRequest
+	if err := middleware.DecodeRequiredJSON(r, &req); err != nil {
+		middleware.ErrorResponse(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	var errs fieldErrors
+	if len(req.Slugs) == 0 {
+		errs.add("slugs", models.FieldCodeRequired, "slugs cannot be empty")
+	} else if len(req.Slugs) > maxPreviewSlugs {
+		errs.add("slugs", models.FieldCodeOutOfRange, fmt.Sprintf("slugs cannot contain more than %d entries", maxPreviewSlugs))
+	}
+	if errs.any() {
+		middleware.ValidationErrorResponse(w, errs)
+		return
+	}
+
+	rows, err := h.reads.Query(`
+		SELECT p.share_slug, p.vanity_slug, p.title, p.status,
+		       (SELECT COUNT(*) FROM option o WHERE o.poll_id = p.id),
+		       (SELECT COUNT(*) FROM ballot b WHERE b.poll_id = p.id)
+		FROM poll p
+		WHERE p.share_slug = ANY($1) OR p.vanity_slug = ANY($1)
+	`, pq.Array(req.Slugs))
+	if err != nil {
+		slog.Error("failed to query previews", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer rows.Close()
+
+	// A poll is reachable by its share slug and its vanity slug
+	previews := make(map[string]*models.PollPreviewResponse)
+	for rows.Next() {
+		var shareSlug, vanitySlug sql.NullString
+		var preview models.PollPreviewResponse
+		if err := rows.Scan(&shareSlug, &vanitySlug, &preview.Title, &preview.Status,
+			&preview.OptionCount, &preview.BallotCount); err != nil {
+			slog.Error("failed to scan preview", "error", err)
+			middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+		if shareSlug.Valid {
+			previews[shareSlug.String] = &preview
+		}
+		if vanitySlug.Valid {
+			previews[vanitySlug.String] = &preview
+		}
+	}
+	if err := rows.Err(); err != nil {
+		slog.Error("failed to read previews", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	resp := models.GetPreviewsResponse{Previews: make([]models.PollPreviewEntry, 0, len(req.Slugs))}
+	for _, slug := range req.Slugs {
+		entry := models.PollPreviewEntry{Slug: slug, PollPreviewResponse: previews[slug]}
+		entry.NotFound = entry.PollPreviewResponse == nil
+		resp.Previews = append(resp.Previews, entry)
+	}
+
+	middleware.JSONResponse(w, http.StatusOK, resp)
+}
+
+// GetSummary handles GET /polls/:slug/summary
+// Combines poll details, options, and live counts so voting screens need a
+// single request. Like GetPoll, it never reveals results.
+func (h *ResultsHandler) GetSummary(w http.ResponseWriter, r *http.Request) {
+	shareSlug := r.PathValue("slug")
+	if shareSlug == "" {
+		middleware.ErrorResponse(w, http.StatusBadRequest, "slug is required")
+		return
+	}
+
+	var poll models.Poll
+	err := scanPoll(h.db.QueryRow(`
+		SELECT `+pollColumns+`
+		FROM poll
+		WHERE `+slugMatch+`
+	`, shareSlug), &poll)
+
+	if err == sql.ErrNoRows {
+		middleware.ErrorResponse(w, http.StatusNotFound, "Poll not found")
+		return
+	}
+	if err != nil {
+		slog.Error("failed to query poll", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	withSafeDescription(h.cfg, &poll)
+
+	options, err := queryOptions(h.db, poll.ID)
+	if err != nil {
+		slog.Error("failed to query options", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	var ballotCount, voterCount int
+	err = h.db.QueryRow(`
+		SELECT
+			(SELECT COUNT(*) FROM ballot WHERE poll_id = $1),
+			(SELECT COUNT(*) FROM username_claim WHERE poll_id = $1)
+	`, poll.ID).Scan(&ballotCount, &voterCount)
+	if err != nil {
+		slog.Error("failed to count participation", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	response := models.PollSummaryResponse{
+		PollWithOptions: models.PollWithOptions{
+			Poll:    poll,
+			Options: options,
+		},
+		BallotCount: ballotCount,
+		VoterCount:  voterCount,
+	}
+
+	// Report has_voted only when the caller identified itself
+	voterToken := r.Header.Get("X-Voter-Token")
+	// With devices disabled none is ever linked, so it can't identify the caller
+	deviceUUID := requestDeviceUUID(h.cfg, r)
+	if voterToken != "" || deviceUUID != "" {
+		hasVoted, err := hasVoted(h.db, poll.ID, voterToken, deviceUUID)
+		if err != nil {
+			slog.Error("failed to check ballot", "error", err)
+			middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+		response.HasVoted = &hasVoted
+	}
+
+	middleware.NegotiatedResponse(w, r, http.StatusOK, response)
+}
+
+// queryOptions returns a poll's options in display order
+func queryOptions(db *sql.DB, pollID string) ([]models.Option, error) {
+	return queryOptionPage(db, pollID, 0, 0)
+}
+
+// queryOptionPage returns up to limit of a poll's options in display
+// order, skipping the first offset. A limit of zero returns the rest.
+func queryOptionPage(db *sql.DB, pollID string, limit, offset int) ([]models.Option, error) {
+	rows, err := db.Query(`
+		SELECT id, poll_id, label, description, position, metadata
+		FROM option
+		WHERE poll_id = $1
+		ORDER BY position, id
+		LIMIT NULLIF($2, 0) OFFSET $3
+	`, pollID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	options := []models.Option{}
+	for rows.Next() {
+		var opt models.Option
+		var metadata []byte
+		if err := rows.Scan(&opt.ID, &opt.PollID, &opt.Label, &opt.Description, &opt.Position, &metadata); err != nil {
+			return nil, err
+		}
+		opt.Metadata = metadata
+		options = append(options, opt)
+	}
+	return options, rows.Err()
+}
+
+// hasVoted reports whether a ballot exists for the voter token, or for the
+// voter token linked to the device
+func hasVoted(db *sql.DB, pollID, voterToken, deviceUUID string) (bool, error) {
+	var voted bool
+	if voterToken != "" {
+		err := db.QueryRow(`
+			SELECT EXISTS(SELECT 1 FROM ballot WHERE poll_id = $1 AND voter_token = $2)
+		`, pollID, voterToken).Scan(&voted)
+		if err != nil || voted {
+			return voted, err
+		}
+	}
+	if deviceUUID != "" {
+		err := db.QueryRow(`
+			SELECT EXISTS(
+				SELECT 1
+				FROM ballot b
+				JOIN device_poll dp ON dp.poll_id = b.poll_id AND dp.voter_token = b.voter_token
+				JOIN device d ON d.id = dp.device_id
+				WHERE b.poll_id = $1 AND d.device_uuid = $2
+			)
+		`, pollID, deviceUUID).Scan(&voted)
+		if err != nil {
+			return false, err
+		}
+	}
+	return voted, nil
+}
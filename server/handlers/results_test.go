@@ -4,15 +4,19 @@
 package handlers
 
 import (
+	"bytes"
+	"database/sql"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	_ "github.com/lib/pq"
 
 	"github.com/danielhkuo/quickly-pick/auth"
+	"github.com/danielhkuo/quickly-pick/cliparse"
 	"github.com/danielhkuo/quickly-pick/models"
 )
 
@@ -284,6 +288,73 @@ func TestGetResults(t *testing.T) {
 	}
 }
 
+func TestGetResultsOptionStatus(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	cfg := getTestConfig()
+	handler := NewResultsHandler(db, cfg)
+
+	pollID, _ := auth.GenerateID(16)
+	shareSlug := auth.GenerateShareSlug(pollID, cfg.PollSlugSalt)
+	snapshotID, _ := auth.GenerateID(16)
+
+	_, err := db.Exec(`
+		INSERT INTO poll (id, title, creator_name, status, share_slug, final_snapshot_id, created_at)
+		VALUES ($1, 'Closed Poll', 'Alice', 'closed', $2, $3, $4)
+	`, pollID, shareSlug, snapshotID, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to create test poll: %v", err)
+	}
+
+	// Snapshot written without status fields, as older snapshots were
+	payload := map[string]interface{}{
+		"rankings": []map[string]interface{}{
+			{"option_id": "opt-a", "label": "Sushi", "median": 0.6, "veto": false, "rank": 1},
+			{"option_id": "opt-b", "label": "Tacos", "median": 0.2, "veto": false, "rank": 2},
+			{"option_id": "opt-c", "label": "Pizza", "median": -0.4, "neg_share": 0.6, "veto": true, "rank": 3},
+		},
+		"inputs_hash": "test-hash",
+	}
+	payloadJSON, _ := json.Marshal(payload)
+
+	_, err = db.Exec(`
+		INSERT INTO result_snapshot (id, poll_id, method, computed_at, payload)
+		VALUES ($1, $2, 'bmj', $3, $4)
+	`, snapshotID, pollID, time.Now(), payloadJSON)
+	if err != nil {
+		t.Fatalf("Failed to create snapshot: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/polls/"+shareSlug+"/results", nil)
+	req.SetPathValue("slug", shareSlug)
+	w := httptest.NewRecorder()
+
+	handler.GetResults(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		Rankings []models.OptionStats `json:"rankings"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	expected := map[string]string{
+		"opt-a": models.OptionStatusWinner,
+		"opt-b": models.OptionStatusRanked,
+		"opt-c": models.OptionStatusVetoed,
+	}
+	for _, ranking := range resp.Rankings {
+		if ranking.Status != expected[ranking.OptionID] {
+			t.Errorf("Expected status %q for %s, got %q", expected[ranking.OptionID], ranking.Label, ranking.Status)
+		}
+	}
+}
+
 func TestGetResultsForOpenPoll(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Close()
@@ -464,6 +535,106 @@ func TestGetBallotCountForPollWithNoBallots(t *testing.T) {
 	}
 }
 
+func TestGetBallotCountByTime(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	cfg := getTestConfig()
+	handler := NewResultsHandler(db, cfg)
+
+	pollID, _ := auth.GenerateID(16)
+	shareSlug := auth.GenerateShareSlug(pollID, cfg.PollSlugSalt)
+	_, err := db.Exec(`
+		INSERT INTO poll (id, title, creator_name, status, share_slug, created_at)
+		VALUES ($1, 'Turnout Poll', 'Alice', 'closed', $2, $3)
+	`, pollID, shareSlug, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to create test poll: %v", err)
+	}
+
+	// Two ballots in the 09:00 hour, none at 10:00, one at 11:00
+	start := time.Date(2025, 1, 15, 9, 0, 0, 0, time.UTC)
+	for _, offset := range []time.Duration{5 * time.Minute, 50 * time.Minute, 2*time.Hour + 30*time.Minute} {
+		ballotID, _ := auth.GenerateID(16)
+		voterToken, _ := auth.GenerateVoterToken()
+		_, err := db.Exec(`
+			INSERT INTO ballot (id, poll_id, voter_token, submitted_at)
+			VALUES ($1, $2, $3, $4)
+		`, ballotID, pollID, voterToken, start.Add(offset))
+		if err != nil {
+			t.Fatalf("Failed to create ballot: %v", err)
+		}
+	}
+
+	tests := []struct {
+		query  string
+		bucket string
+		want   map[time.Time]int
+	}{
+		{"", "1h0m0s", map[time.Time]int{start: 2, start.Add(2 * time.Hour): 1}},
+		{"?bucket=30m", "30m0s", map[time.Time]int{start: 1, start.Add(30 * time.Minute): 1, start.Add(2*time.Hour + 30*time.Minute): 1}},
+		{"?bucket=24h", "24h0m0s", map[time.Time]int{time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC): 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.bucket, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/polls/"+shareSlug+"/ballots/count-by-time"+tt.query, nil)
+			req.SetPathValue("slug", shareSlug)
+			w := httptest.NewRecorder()
+
+			handler.GetBallotCountByTime(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, w.Code, w.Body.String())
+			}
+
+			var resp models.BallotCountByTimeResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if resp.Bucket != tt.bucket {
+				t.Errorf("Expected bucket %s, got %s", tt.bucket, resp.Bucket)
+			}
+			if resp.BallotCount != 3 {
+				t.Errorf("Expected ballot count 3, got %d", resp.BallotCount)
+			}
+			if len(resp.Buckets) != len(tt.want) {
+				t.Fatalf("Expected %d buckets, got %+v", len(tt.want), resp.Buckets)
+			}
+			for i, b := range resp.Buckets {
+				if i > 0 && !b.Start.After(resp.Buckets[i-1].Start) {
+					t.Errorf("Buckets out of order: %+v", resp.Buckets)
+				}
+				if want, ok := tt.want[b.Start.UTC()]; !ok || b.Count != want {
+					t.Errorf("Unexpected bucket %v with count %d, want %+v", b.Start, b.Count, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestGetBallotCountByTimeInvalidBucket(t *testing.T) {
+	// Rejected before the database is touched
+	handler := NewResultsHandler(nil, getTestConfig())
+
+	for _, bucket := range []string{"hourly", "30s", "169h", "90500ms", "-1h"} {
+		req := httptest.NewRequest("GET", "/polls/abc/ballots/count-by-time?bucket="+bucket, nil)
+		req.SetPathValue("slug", "abc")
+		w := httptest.NewRecorder()
+		handler.GetBallotCountByTime(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: Expected status %d, got %d", bucket, http.StatusBadRequest, w.Code)
+			continue
+		}
+		var resp models.ErrorResponse
+		json.NewDecoder(w.Body).Decode(&resp)
+		if len(resp.Fields) != 1 || resp.Fields[0].Field != "bucket" {
+			t.Errorf("%s: Expected an error on bucket, got %+v", bucket, resp.Fields)
+		}
+	}
+}
+
 func TestGetPollWithoutOptions(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Close()
@@ -501,3 +672,1321 @@ func TestGetPollWithoutOptions(t *testing.T) {
 		t.Errorf("Expected 0 options, got %d", len(resp.Options))
 	}
 }
+
+func TestGetOptionsPaging(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	cfg := getTestConfig()
+	handler := NewResultsHandler(db, cfg)
+
+	pollID, _ := auth.GenerateID(16)
+	shareSlug := auth.GenerateShareSlug(pollID, cfg.PollSlugSalt)
+	_, err := db.Exec(`
+		INSERT INTO poll (id, title, creator_name, status, share_slug, created_at)
+		VALUES ($1, 'Long Poll', 'Alice', 'open', $2, $3)
+	`, pollID, shareSlug, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to create test poll: %v", err)
+	}
+
+	// Insert out of display order so paging must follow position
+	labels := []string{"Option A", "Option B", "Option C", "Option D", "Option E"}
+	for _, position := range []int{3, 0, 4, 1, 2} {
+		optionID, _ := auth.GenerateID(12)
+		_, err := db.Exec(`
+			INSERT INTO option (id, poll_id, label, position)
+			VALUES ($1, $2, $3, $4)
+		`, optionID, pollID, labels[position], position)
+		if err != nil {
+			t.Fatalf("Failed to create option: %v", err)
+		}
+	}
+
+	getPage := func(query string) models.OptionsResponse {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/polls/"+shareSlug+"/options"+query, nil)
+		req.SetPathValue("slug", shareSlug)
+		w := httptest.NewRecorder()
+		handler.GetOptions(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: Expected status %d, got %d. Body: %s", query, http.StatusOK, w.Code, w.Body.String())
+		}
+		var resp models.OptionsResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return resp
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", labels},
+		{"?limit=2", labels[0:2]},
+		{"?limit=2&offset=2", labels[2:4]},
+		{"?limit=2&offset=4", labels[4:5]},
+		{"?offset=3", labels[3:]},
+		{"?limit=2&offset=6", []string{}},
+	}
+	for _, tt := range tests {
+		resp := getPage(tt.query)
+		if resp.PollID != pollID {
+			t.Errorf("%s: Expected poll ID %s, got %s", tt.query, pollID, resp.PollID)
+		}
+		if resp.Total != len(labels) {
+			t.Errorf("%s: Expected total %d, got %d", tt.query, len(labels), resp.Total)
+		}
+		got := make([]string, len(resp.Options))
+		for i, opt := range resp.Options {
+			got[i] = opt.Label
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%s: Expected options %v, got %v", tt.query, tt.want, got)
+		}
+	}
+}
+
+func TestGetOptionsInvalidPaging(t *testing.T) {
+	// Rejected before the database is touched
+	handler := NewResultsHandler(nil, getTestConfig())
+
+	tests := []struct {
+		query string
+		field string
+		code  string
+	}{
+		{"?limit=0", "limit", models.FieldCodeOutOfRange},
+		{"?limit=ten", "limit", models.FieldCodeInvalid},
+		{"?offset=-1", "offset", models.FieldCodeOutOfRange},
+		{"?offset=1.5", "offset", models.FieldCodeInvalid},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/polls/abc/options"+tt.query, nil)
+		req.SetPathValue("slug", "abc")
+		w := httptest.NewRecorder()
+		handler.GetOptions(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: Expected status %d, got %d", tt.query, http.StatusBadRequest, w.Code)
+			continue
+		}
+		var resp models.ErrorResponse
+		json.NewDecoder(w.Body).Decode(&resp)
+		if len(resp.Fields) != 1 || resp.Fields[0].Field != tt.field || resp.Fields[0].Code != tt.code {
+			t.Errorf("%s: Expected %s error on %s, got %+v", tt.query, tt.code, tt.field, resp.Fields)
+		}
+	}
+}
+
+func TestGetSummaryHasVoted(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	cfg := getTestConfig()
+	resultsHandler := NewResultsHandler(db, cfg)
+	votingHandler := NewVotingHandler(db, cfg)
+
+	// Create an open poll with two options and one claimed voter
+	pollID, _ := auth.GenerateID(16)
+	shareSlug := auth.GenerateShareSlug(pollID, cfg.PollSlugSalt)
+	_, err := db.Exec(`
+		INSERT INTO poll (id, title, creator_name, status, share_slug, created_at)
+		VALUES ($1, 'Summary Poll', 'Alice', 'open', $2, $3)
+	`, pollID, shareSlug, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to create test poll: %v", err)
+	}
+
+	var optionIDs []string
+	for _, label := range []string{"Pizza", "Sushi"} {
+		optionID, _ := auth.GenerateID(12)
+		if _, err := db.Exec(`INSERT INTO option (id, poll_id, label) VALUES ($1, $2, $3)`, optionID, pollID, label); err != nil {
+			t.Fatalf("Failed to create option: %v", err)
+		}
+		optionIDs = append(optionIDs, optionID)
+	}
+
+	voterToken, _ := auth.GenerateVoterToken()
+	_, err = db.Exec(`
+		INSERT INTO username_claim (poll_id, username, voter_token, created_at)
+		VALUES ($1, 'bob', $2, $3)
+	`, pollID, voterToken, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to create username claim: %v", err)
+	}
+
+	getSummary := func(voterToken string) models.PollSummaryResponse {
+		req := httptest.NewRequest("GET", "/polls/"+shareSlug+"/summary", nil)
+		req.SetPathValue("slug", shareSlug)
+		if voterToken != "" {
+			req.Header.Set("X-Voter-Token", voterToken)
+		}
+		w := httptest.NewRecorder()
+		resultsHandler.GetSummary(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		var resp models.PollSummaryResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return resp
+	}
+
+	resp := getSummary("")
+	if resp.HasVoted != nil {
+		t.Error("Expected has_voted to be omitted for anonymous callers")
+	}
+	if len(resp.Options) != 2 {
+		t.Errorf("Expected 2 options, got %d", len(resp.Options))
+	}
+	if resp.VoterCount != 1 || resp.BallotCount != 0 {
+		t.Errorf("Expected 1 voter and 0 ballots, got %d and %d", resp.VoterCount, resp.BallotCount)
+	}
+
+	resp = getSummary(voterToken)
+	if resp.HasVoted == nil || *resp.HasVoted {
+		t.Fatalf("Expected has_voted false before voting, got %v", resp.HasVoted)
+	}
+
+	// Submit a ballot as the caller
+	body, _ := json.Marshal(models.SubmitBallotRequest{
+		Scores: map[string]float64{optionIDs[0]: 0.9, optionIDs[1]: 0.2},
+	})
+	req := httptest.NewRequest("POST", "/polls/"+shareSlug+"/ballots", bytes.NewReader(body))
+	req.SetPathValue("slug", shareSlug)
+	req.Header.Set("X-Voter-Token", voterToken)
+	w := httptest.NewRecorder()
+	votingHandler.SubmitBallot(w, req)
+	if w.Code != http.StatusOK && w.Code != http.StatusCreated {
+		t.Fatalf("Failed to submit ballot: %d %s", w.Code, w.Body.String())
+	}
+
+	resp = getSummary(voterToken)
+	if resp.HasVoted == nil || !*resp.HasVoted {
+		t.Errorf("Expected has_voted true after voting, got %v", resp.HasVoted)
+	}
+	if resp.BallotCount != 1 {
+		t.Errorf("Expected 1 ballot, got %d", resp.BallotCount)
+	}
+}
+
+func TestGetBallotCountWithCache(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	cfg := getTestConfig()
+	counts := NewBallotCountCache(time.Minute)
+	resultsHandler := NewResultsHandlerWithCache(db, cfg, counts)
+	votingHandler := NewVotingHandlerWithCache(db, cfg, counts)
+
+	pollID, _ := auth.GenerateID(16)
+	shareSlug := auth.GenerateShareSlug(pollID, cfg.PollSlugSalt)
+	_, err := db.Exec(`
+		INSERT INTO poll (id, title, creator_name, status, share_slug, created_at)
+		VALUES ($1, 'Cached Poll', 'Alice', 'open', $2, $3)
+	`, pollID, shareSlug, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to create test poll: %v", err)
+	}
+	optionID, _ := auth.GenerateID(12)
+	if _, err := db.Exec(`INSERT INTO option (id, poll_id, label) VALUES ($1, $2, 'A')`, optionID, pollID); err != nil {
+		t.Fatalf("Failed to create option: %v", err)
+	}
+	voterToken, _ := auth.GenerateVoterToken()
+	_, err = db.Exec(`
+		INSERT INTO username_claim (poll_id, username, voter_token, created_at)
+		VALUES ($1, 'bob', $2, $3)
+	`, pollID, voterToken, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to create username claim: %v", err)
+	}
+
+	getCount := func() int {
+		req := httptest.NewRequest("GET", "/polls/"+shareSlug+"/ballot-count", nil)
+		req.SetPathValue("slug", shareSlug)
+		w := httptest.NewRecorder()
+		resultsHandler.GetBallotCount(w, req)
+		var resp map[string]int
+		json.NewDecoder(w.Body).Decode(&resp)
+		return resp["ballot_count"]
+	}
+
+	// Prime the cache
+	if got := getCount(); got != 0 {
+		t.Fatalf("Expected 0 ballots, got %d", got)
+	}
+
+	body, _ := json.Marshal(models.SubmitBallotRequest{Scores: map[string]float64{optionID: 1}})
+	req := httptest.NewRequest("POST", "/polls/"+shareSlug+"/ballots", bytes.NewReader(body))
+	req.SetPathValue("slug", shareSlug)
+	req.Header.Set("X-Voter-Token", voterToken)
+	w := httptest.NewRecorder()
+	votingHandler.SubmitBallot(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Failed to submit ballot: %d %s", w.Code, w.Body.String())
+	}
+
+	// The submit must invalidate the cached count despite the long TTL
+	if got := getCount(); got != 1 {
+		t.Errorf("Expected 1 ballot after submit, got %d", got)
+	}
+}
+
+func TestGetPreviews(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	cfg := getTestConfig()
+	handler := NewResultsHandler(db, cfg)
+
+	// Create two open polls, the second reached through its vanity slug
+	createPoll := func(title string, options int) string {
+		pollID, _ := auth.GenerateID(16)
+		shareSlug := auth.GenerateShareSlug(pollID, cfg.PollSlugSalt)
+		_, err := db.Exec(`
+			INSERT INTO poll (id, title, creator_name, status, share_slug, created_at)
+			VALUES ($1, $2, 'Alice', 'open', $3, $4)
+		`, pollID, title, shareSlug, time.Now())
+		if err != nil {
+			t.Fatalf("Failed to create test poll: %v", err)
+		}
+		for i := 0; i < options; i++ {
+			optionID, _ := auth.GenerateID(12)
+			if _, err := db.Exec(`INSERT INTO option (id, poll_id, label) VALUES ($1, $2, 'Option')`, optionID, pollID); err != nil {
+				t.Fatalf("Failed to create option: %v", err)
+			}
+		}
+		return shareSlug
+	}
+
+	lunchSlug := createPoll("Lunch", 2)
+	dinnerSlug := createPoll("Dinner", 3)
+	vanitySlug := "dinner-" + dinnerSlug
+	if _, err := db.Exec(`UPDATE poll SET vanity_slug = $1 WHERE share_slug = $2`, vanitySlug, dinnerSlug); err != nil {
+		t.Fatalf("Failed to set vanity slug: %v", err)
+	}
+
+	getPreviews := func(slugs []string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(models.GetPreviewsRequest{Slugs: slugs})
+		req := httptest.NewRequest("POST", "/polls/previews", bytes.NewReader(body))
+		w := httptest.NewRecorder()
+		handler.GetPreviews(w, req)
+		return w
+	}
+
+	t.Run("mixed known and unknown slugs", func(t *testing.T) {
+		w := getPreviews([]string{lunchSlug, "no-such-poll", vanitySlug})
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+
+		var resp models.GetPreviewsResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if len(resp.Previews) != 3 {
+			t.Fatalf("Expected 3 entries, got %d", len(resp.Previews))
+		}
+
+		lunch, missing, dinner := resp.Previews[0], resp.Previews[1], resp.Previews[2]
+		if lunch.Slug != lunchSlug || lunch.NotFound || lunch.PollPreviewResponse == nil {
+			t.Fatalf("Expected a preview for %s, got %+v", lunchSlug, lunch)
+		}
+		if lunch.Title != "Lunch" || lunch.OptionCount != 2 {
+			t.Errorf("Unexpected lunch preview: %+v", lunch.PollPreviewResponse)
+		}
+		if missing.Slug != "no-such-poll" || !missing.NotFound || missing.PollPreviewResponse != nil {
+			t.Errorf("Expected unknown slug to be marked not found, got %+v", missing)
+		}
+		if dinner.Slug != vanitySlug || dinner.NotFound || dinner.PollPreviewResponse == nil {
+			t.Fatalf("Expected a preview for %s, got %+v", vanitySlug, dinner)
+		}
+		if dinner.Title != "Dinner" || dinner.OptionCount != 3 {
+			t.Errorf("Unexpected dinner preview: %+v", dinner.PollPreviewResponse)
+		}
+	})
+
+	t.Run("too many slugs", func(t *testing.T) {
+		slugs := make([]string, maxPreviewSlugs+1)
+		for i := range slugs {
+			slugs[i] = lunchSlug
+		}
+		if w := getPreviews(slugs); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+
+	t.Run("no slugs", func(t *testing.T) {
+		if w := getPreviews(nil); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+}
+
+func TestResultsRecordComputationParameters(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	cfg := getTestConfig()
+	pollHandler := NewPollHandler(db, cfg)
+	resultsHandler := NewResultsHandler(db, cfg)
+
+	// Create an open poll with two options
+	pollID, _ := auth.GenerateID(16)
+	adminKey := auth.GenerateAdminKey(pollID, cfg.AdminKeySalt)
+	shareSlug := auth.GenerateShareSlug(pollID, cfg.PollSlugSalt)
+	_, err := db.Exec(`
+		INSERT INTO poll (id, title, creator_name, status, share_slug, created_at)
+		VALUES ($1, 'Parameter Poll', 'Alice', 'open', $2, $3)
+	`, pollID, shareSlug, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to create test poll: %v", err)
+	}
+	for _, label := range []string{"Pizza", "Sushi"} {
+		optionID, _ := auth.GenerateID(12)
+		if _, err := db.Exec(`INSERT INTO option (id, poll_id, label) VALUES ($1, $2, $3)`, optionID, pollID, label); err != nil {
+			t.Fatalf("Failed to create option: %v", err)
+		}
+	}
+
+	// Close the poll
+	req := httptest.NewRequest("POST", "/polls/"+pollID+"/close", nil)
+	req.SetPathValue("id", pollID)
+	req.Header.Set("X-Admin-Key", adminKey)
+	w := httptest.NewRecorder()
+	pollHandler.ClosePoll(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var closeResp models.ClosePollResponse
+	json.NewDecoder(w.Body).Decode(&closeResp)
+	if closeResp.Snapshot.VetoThreshold != BMJVetoThreshold || closeResp.Snapshot.AlgorithmVersion != BMJAlgorithmVersion {
+		t.Errorf("Expected close response to report its parameters, got %+v", closeResp.Snapshot)
+	}
+
+	// The parameters are stored with the snapshot itself
+	var stored snapshotPayload
+	var payloadJSON []byte
+	if err := db.QueryRow(`SELECT payload FROM result_snapshot WHERE poll_id = $1`, pollID).Scan(&payloadJSON); err != nil {
+		t.Fatalf("Failed to query snapshot: %v", err)
+	}
+	if err := json.Unmarshal(payloadJSON, &stored); err != nil {
+		t.Fatalf("Failed to parse payload: %v", err)
+	}
+	if stored.Method != models.MethodBMJ || stored.VetoThreshold != BMJVetoThreshold || stored.AlgorithmVersion != BMJAlgorithmVersion {
+		t.Errorf("Expected parameters in stored payload, got %s", payloadJSON)
+	}
+
+	// GetResults echoes them back
+	req = httptest.NewRequest("GET", "/polls/"+shareSlug+"/results", nil)
+	req.SetPathValue("slug", shareSlug)
+	w = httptest.NewRecorder()
+	resultsHandler.GetResults(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var results struct {
+		Method           string  `json:"method"`
+		VetoThreshold    float64 `json:"veto_threshold"`
+		AlgorithmVersion int     `json:"algorithm_version"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&results); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if results.Method != models.MethodBMJ {
+		t.Errorf("Expected method %q, got %q", models.MethodBMJ, results.Method)
+	}
+	if results.VetoThreshold != BMJVetoThreshold {
+		t.Errorf("Expected veto_threshold %v, got %v", BMJVetoThreshold, results.VetoThreshold)
+	}
+	if results.AlgorithmVersion != BMJAlgorithmVersion {
+		t.Errorf("Expected algorithm_version %d, got %d", BMJAlgorithmVersion, results.AlgorithmVersion)
+	}
+}
+
+func TestGetResultsEmbargo(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	cfg := getTestConfig()
+	pollHandler := NewPollHandler(db, cfg)
+	resultsHandler := NewResultsHandler(db, cfg)
+
+	pollID, _ := auth.GenerateID(16)
+	adminKey := auth.GenerateAdminKey(pollID, cfg.AdminKeySalt)
+	shareSlug := auth.GenerateShareSlug(pollID, cfg.PollSlugSalt)
+	_, err := db.Exec(`
+		INSERT INTO poll (id, title, creator_name, status, share_slug, created_at)
+		VALUES ($1, 'Embargoed Poll', 'Alice', 'open', $2, $3)
+	`, pollID, shareSlug, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to create test poll: %v", err)
+	}
+	for _, label := range []string{"Pizza", "Sushi"} {
+		optionID, _ := auth.GenerateID(12)
+		if _, err := db.Exec(`INSERT INTO option (id, poll_id, label) VALUES ($1, $2, $3)`, optionID, pollID, label); err != nil {
+			t.Fatalf("Failed to create option: %v", err)
+		}
+	}
+
+	// Close with results held back for an hour
+	revealAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	body, _ := json.Marshal(models.ClosePollRequest{RevealAt: &revealAt})
+	req := httptest.NewRequest("POST", "/polls/"+pollID+"/close", bytes.NewReader(body))
+	req.SetPathValue("id", pollID)
+	req.Header.Set("X-Admin-Key", adminKey)
+	w := httptest.NewRecorder()
+	pollHandler.ClosePoll(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	getResults := func(key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/polls/"+shareSlug+"/results", nil)
+		req.SetPathValue("slug", shareSlug)
+		if key != "" {
+			req.Header.Set("X-Admin-Key", key)
+		}
+		w := httptest.NewRecorder()
+		resultsHandler.GetResults(w, req)
+		return w
+	}
+
+	w = getResults("")
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("Expected status %d before reveal_at, got %d. Body: %s", http.StatusForbidden, w.Code, w.Body.String())
+	}
+	var embargoed models.ResultsEmbargoedResponse
+	json.NewDecoder(w.Body).Decode(&embargoed)
+	if embargoed.Code != models.ErrorCodeResultsEmbargoed {
+		t.Errorf("Expected code %q, got %q", models.ErrorCodeResultsEmbargoed, embargoed.Code)
+	}
+	if !embargoed.RevealAt.Equal(revealAt) {
+		t.Errorf("Expected reveal_at %v, got %v", revealAt, embargoed.RevealAt)
+	}
+
+	// The admin can always see them
+	if w := getResults(adminKey); w.Code != http.StatusOK {
+		t.Errorf("Expected status %d for the admin, got %d. Body: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	// Once reveal_at passes, everyone can
+	if _, err := db.Exec(`UPDATE poll SET reveal_at = $1 WHERE id = $2`, time.Now().Add(-time.Minute), pollID); err != nil {
+		t.Fatalf("Failed to move reveal_at: %v", err)
+	}
+	if w := getResults(""); w.Code != http.StatusOK {
+		t.Errorf("Expected status %d after reveal_at, got %d. Body: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+}
+
+func TestGetResultsInclude(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	cfg := getTestConfig()
+	pollHandler := NewPollHandler(db, cfg)
+	resultsHandler := NewResultsHandler(db, cfg)
+
+	// Create an open poll whose options have descriptions, then close it
+	pollID, _ := auth.GenerateID(16)
+	adminKey := auth.GenerateAdminKey(pollID, cfg.AdminKeySalt)
+	shareSlug := auth.GenerateShareSlug(pollID, cfg.PollSlugSalt)
+	_, err := db.Exec(`
+		INSERT INTO poll (id, title, creator_name, status, share_slug, created_at)
+		VALUES ($1, 'Include Poll', 'Alice', 'open', $2, $3)
+	`, pollID, shareSlug, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to create test poll: %v", err)
+	}
+	for _, label := range []string{"Pizza", "Sushi"} {
+		optionID, _ := auth.GenerateID(12)
+		_, err := db.Exec(`
+			INSERT INTO option (id, poll_id, label, description) VALUES ($1, $2, $3, $4)
+		`, optionID, pollID, label, label+" from down the street")
+		if err != nil {
+			t.Fatalf("Failed to create option: %v", err)
+		}
+	}
+
+	req := httptest.NewRequest("POST", "/polls/"+pollID+"/close", nil)
+	req.SetPathValue("id", pollID)
+	req.Header.Set("X-Admin-Key", adminKey)
+	w := httptest.NewRecorder()
+	pollHandler.ClosePoll(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	getRankings := func(t *testing.T, query string) []map[string]interface{} {
+		req := httptest.NewRequest("GET", "/polls/"+shareSlug+"/results"+query, nil)
+		req.SetPathValue("slug", shareSlug)
+		w := httptest.NewRecorder()
+		resultsHandler.GetResults(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		var resp struct {
+			Rankings []map[string]interface{} `json:"rankings"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if len(resp.Rankings) != 2 {
+			t.Fatalf("Expected 2 rankings, got %d", len(resp.Rankings))
+		}
+		return resp.Rankings
+	}
+
+	testCases := []struct {
+		name               string
+		query              string
+		expectLabels       bool
+		expectDescriptions bool
+	}{
+		{"default", "", true, false},
+		{"empty include", "?include=", false, false},
+		{"descriptions only", "?include=descriptions", false, true},
+		{"labels and descriptions", "?include=labels,descriptions", true, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, ranking := range getRankings(t, tc.query) {
+				if _, ok := ranking["label"]; ok != tc.expectLabels {
+					t.Errorf("Expected label present=%v, got %v", tc.expectLabels, ranking)
+				}
+				if _, ok := ranking["description"]; ok != tc.expectDescriptions {
+					t.Errorf("Expected description present=%v, got %v", tc.expectDescriptions, ranking)
+				}
+			}
+		})
+	}
+
+	t.Run("unknown field", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/polls/"+shareSlug+"/results?include=votes", nil)
+		req.SetPathValue("slug", shareSlug)
+		w := httptest.NewRecorder()
+		resultsHandler.GetResults(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+}
+
+func TestResultsReadFromReplica(t *testing.T) {
+	readDB := setupTestDB(t)
+	defer readDB.Close()
+
+	// A closed primary fails every query, so any success below must have
+	// come from the replica
+	primary, err := sql.Open("postgres", "host=invalid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	primary.Close()
+
+	cfg := getTestConfig()
+	handler := NewResultsHandlerWithReplica(primary, readDB, cfg, nil)
+
+	pollID, _ := auth.GenerateID(16)
+	shareSlug := auth.GenerateShareSlug(pollID, cfg.PollSlugSalt)
+	_, err = readDB.Exec(`
+		INSERT INTO poll (id, title, creator_name, status, share_slug, created_at)
+		VALUES ($1, 'Replica Poll', 'Alice', 'open', $2, $3)
+	`, pollID, shareSlug, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to create test poll: %v", err)
+	}
+
+	reads := []struct {
+		name    string
+		path    string
+		handler http.HandlerFunc
+	}{
+		{"ballot count", "/ballot-count", handler.GetBallotCount},
+		{"preview", "/preview", handler.GetPreview},
+	}
+	for _, tt := range reads {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/polls/"+shareSlug+tt.path, nil)
+			req.SetPathValue("slug", shareSlug)
+			w := httptest.NewRecorder()
+
+			tt.handler(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Errorf("Expected status 200, got %d. Body: %s", w.Code, w.Body.String())
+			}
+		})
+	}
+
+	// Without a replica, reads go to the primary
+	handler = NewResultsHandlerWithReplica(primary, nil, cfg, nil)
+	req := httptest.NewRequest("GET", "/polls/"+shareSlug+"/ballot-count", nil)
+	req.SetPathValue("slug", shareSlug)
+	w := httptest.NewRecorder()
+
+	handler.GetBallotCount(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status 500 from the closed primary, got %d", w.Code)
+	}
+}
+
+func TestPollReadsFromPrimary(t *testing.T) {
+	primary := setupTestDB(t)
+	defer primary.Close()
+
+	// A closed replica fails every query, so polls and options must come
+	// from the primary, where a poll is visible as soon as it is created
+	readDB, err := sql.Open("postgres", "host=invalid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	readDB.Close()
+
+	cfg := getTestConfig()
+	handler := NewResultsHandlerWithReplica(primary, readDB, cfg, nil)
+
+	pollID, _ := auth.GenerateID(16)
+	shareSlug := auth.GenerateShareSlug(pollID, cfg.PollSlugSalt)
+	_, err = primary.Exec(`
+		INSERT INTO poll (id, title, creator_name, status, share_slug, created_at)
+		VALUES ($1, 'Primary Poll', 'Alice', 'open', $2, $3)
+	`, pollID, shareSlug, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to create test poll: %v", err)
+	}
+
+	reads := []struct {
+		name    string
+		path    string
+		handler http.HandlerFunc
+	}{
+		{"poll", "", handler.GetPoll},
+		{"options", "/options", handler.GetOptions},
+		{"summary", "/summary", handler.GetSummary},
+	}
+	for _, tt := range reads {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/polls/"+shareSlug+tt.path, nil)
+			req.SetPathValue("slug", shareSlug)
+			w := httptest.NewRecorder()
+
+			tt.handler(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Errorf("Expected status 200, got %d. Body: %s", w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestGetResultsHistory(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	cfg := getTestConfig()
+	handler := NewResultsHandler(db, cfg)
+
+	pollID, _ := auth.GenerateID(16)
+	shareSlug := auth.GenerateShareSlug(pollID, cfg.PollSlugSalt)
+	adminKey := auth.GenerateAdminKey(pollID, cfg.AdminKeySalt)
+	_, err := db.Exec(`
+		INSERT INTO poll (id, title, creator_name, status, share_slug, created_at)
+		VALUES ($1, 'Two Rounds', 'Alice', 'closed', $2, $3)
+	`, pollID, shareSlug, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to create test poll: %v", err)
+	}
+
+	// Two closing rounds with different winners; the later one is final
+	rounds := []struct {
+		id         string
+		computedAt time.Time
+		winner     string
+	}{
+		{"snap-first", time.Now().Add(-time.Hour), "opt-a"},
+		{"snap-second", time.Now(), "opt-b"},
+	}
+	for _, round := range rounds {
+		payload, _ := json.Marshal(snapshotPayload{
+			Rankings: []models.OptionStats{
+				{OptionID: round.winner, Label: round.winner, Median: 0.8, Rank: 1},
+			},
+			InputsHash: "hash-" + round.id,
+		})
+		_, err := db.Exec(`
+			INSERT INTO result_snapshot (id, poll_id, method, computed_at, payload)
+			VALUES ($1, $2, 'bmj', $3, $4)
+		`, round.id, pollID, round.computedAt, payload)
+		if err != nil {
+			t.Fatalf("Failed to create snapshot %s: %v", round.id, err)
+		}
+	}
+	if _, err := db.Exec(`UPDATE poll SET final_snapshot_id = 'snap-second' WHERE id = $1`, pollID); err != nil {
+		t.Fatalf("Failed to set final snapshot: %v", err)
+	}
+
+	get := func(key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/polls/"+shareSlug+"/results/history", nil)
+		req.SetPathValue("slug", shareSlug)
+		req.Header.Set("X-Admin-Key", key)
+		w := httptest.NewRecorder()
+		handler.GetResultsHistory(w, req)
+		return w
+	}
+
+	if w := get("wrong-key"); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d for a wrong admin key, got %d", http.StatusUnauthorized, w.Code)
+	}
+
+	w := get(adminKey)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var resp models.ResultsHistoryResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(resp.Snapshots) != 2 {
+		t.Fatalf("Expected 2 snapshots, got %d", len(resp.Snapshots))
+	}
+
+	newest, oldest := resp.Snapshots[0], resp.Snapshots[1]
+	if newest.ID != "snap-second" || oldest.ID != "snap-first" {
+		t.Errorf("Expected newest first, got %s then %s", newest.ID, oldest.ID)
+	}
+	if !newest.Final || oldest.Final {
+		t.Errorf("Expected only the newest snapshot to be final, got %v and %v", newest.Final, oldest.Final)
+	}
+	if len(newest.Rankings) != 1 || newest.Rankings[0].OptionID != "opt-b" {
+		t.Errorf("Expected the newest round to rank opt-b first, got %+v", newest.Rankings)
+	}
+	if len(oldest.Rankings) != 1 || oldest.Rankings[0].OptionID != "opt-a" {
+		t.Errorf("Expected the oldest round to rank opt-a first, got %+v", oldest.Rankings)
+	}
+	if oldest.InputsHash != "hash-snap-first" {
+		t.Errorf("Expected inputs hash hash-snap-first, got %q", oldest.InputsHash)
+	}
+}
+
+func TestGetResultsScoreScale(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	cfg := getTestConfig()
+	handler := NewResultsHandler(db, cfg)
+
+	pollID, _ := auth.GenerateID(16)
+	shareSlug := auth.GenerateShareSlug(pollID, cfg.PollSlugSalt)
+	_, err := db.Exec(`
+		INSERT INTO poll (id, title, creator_name, status, share_slug, created_at, final_snapshot_id)
+		VALUES ($1, 'Scaled Poll', 'Alice', 'closed', $2, $3, $4)
+	`, pollID, shareSlug, time.Now(), "snap-"+pollID)
+	if err != nil {
+		t.Fatalf("Failed to create test poll: %v", err)
+	}
+	_, err = db.Exec(`
+		INSERT INTO result_snapshot (id, poll_id, method, computed_at, payload)
+		VALUES ($1, $2, 'bmj', $3, '{"rankings": [], "inputs_hash": ""}')
+	`, "snap-"+pollID, pollID, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to create snapshot: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/polls/"+shareSlug+"/results", nil)
+	req.SetPathValue("slug", shareSlug)
+	w := httptest.NewRecorder()
+	handler.GetResults(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var results struct {
+		ScoreScale *models.ScoreScale `json:"score_scale"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&results); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if results.ScoreScale == nil {
+		t.Fatal("Expected score_scale in the results")
+	}
+	want := models.ScoreScale{Min: -1, Mid: 0, Max: 1, Transform: "2*value01-1"}
+	if *results.ScoreScale != want {
+		t.Errorf("Expected score_scale %+v, got %+v", want, *results.ScoreScale)
+	}
+	if got := signedScore(0.75); got != 0.5 {
+		t.Errorf("Expected value01 0.75 to map to 0.5, got %v", got)
+	}
+}
+
+func TestGetResultsLive(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	cfg := getTestConfig()
+	handler := NewResultsHandler(db, cfg)
+
+	createOpenPoll := func(liveResults bool) string {
+		pollID, _ := auth.GenerateID(16)
+		shareSlug := auth.GenerateShareSlug(pollID, cfg.PollSlugSalt)
+		_, err := db.Exec(`
+			INSERT INTO poll (id, title, creator_name, status, share_slug, created_at, live_results)
+			VALUES ($1, 'Lunch?', 'Alice', 'open', $2, $3, $4)
+		`, pollID, shareSlug, time.Now(), liveResults)
+		if err != nil {
+			t.Fatalf("Failed to create test poll: %v", err)
+		}
+
+		optionID, _ := auth.GenerateID(12)
+		if _, err := db.Exec(`INSERT INTO option (id, poll_id, label) VALUES ($1, $2, 'Tacos')`, optionID, pollID); err != nil {
+			t.Fatalf("Failed to create option: %v", err)
+		}
+		ballotID, _ := auth.GenerateID(16)
+		if _, err := db.Exec(`
+			INSERT INTO ballot (id, poll_id, voter_token, submitted_at)
+			VALUES ($1, $2, 'voter-token', $3)
+		`, ballotID, pollID, time.Now()); err != nil {
+			t.Fatalf("Failed to create ballot: %v", err)
+		}
+		if _, err := db.Exec(`
+			INSERT INTO score (ballot_id, option_id, value01)
+			VALUES ($1, $2, 0.9)
+		`, ballotID, optionID); err != nil {
+			t.Fatalf("Failed to create score: %v", err)
+		}
+		return shareSlug
+	}
+
+	getResults := func(shareSlug string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/polls/"+shareSlug+"/results", nil)
+		req.SetPathValue("slug", shareSlug)
+		w := httptest.NewRecorder()
+		handler.GetResults(w, req)
+		return w
+	}
+
+	t.Run("live poll shows provisional results while open", func(t *testing.T) {
+		w := getResults(createOpenPoll(true))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+
+		var results struct {
+			Provisional bool                 `json:"provisional"`
+			BallotCount int                  `json:"ballot_count"`
+			Rankings    []models.OptionStats `json:"rankings"`
+			Poll        models.Poll          `json:"poll"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&results); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if !results.Provisional {
+			t.Error("Expected provisional results for an open poll")
+		}
+		if !results.Poll.LiveResults {
+			t.Error("Expected poll.live_results to be true")
+		}
+		if results.BallotCount != 1 {
+			t.Errorf("Expected ballot_count 1, got %d", results.BallotCount)
+		}
+		if len(results.Rankings) != 1 || results.Rankings[0].Label != "Tacos" {
+			t.Errorf("Expected the current ranking of Tacos, got %+v", results.Rankings)
+		}
+	})
+
+	t.Run("normal poll stays sealed while open", func(t *testing.T) {
+		w := getResults(createOpenPoll(false))
+		if w.Code != http.StatusForbidden {
+			t.Errorf("Expected status %d, got %d. Body: %s", http.StatusForbidden, w.Code, w.Body.String())
+		}
+	})
+}
+
+func TestGetResultsRounding(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	cfg := getTestConfig()
+	cfg.ResultDigits = cliparse.DefaultResultDigits
+	handler := NewResultsHandler(db, cfg)
+
+	pollID, _ := auth.GenerateID(16)
+	shareSlug := auth.GenerateShareSlug(pollID, cfg.PollSlugSalt)
+	_, err := db.Exec(`
+		INSERT INTO poll (id, title, creator_name, status, share_slug, created_at, final_snapshot_id)
+		VALUES ($1, 'Precise Poll', 'Alice', 'closed', $2, $3, $4)
+	`, pollID, shareSlug, time.Now(), "snap-"+pollID)
+	if err != nil {
+		t.Fatalf("Failed to create test poll: %v", err)
+	}
+	_, err = db.Exec(`
+		INSERT INTO result_snapshot (id, poll_id, method, computed_at, payload)
+		VALUES ($1, $2, 'bmj', $3, '{"rankings": [{"option_id": "opt1", "median": 0.7333333333333333, "p10": -0.123456789, "p90": 1, "mean": 0.55555555, "neg_share": 0.3333333333333333, "rank": 1}], "inputs_hash": ""}')
+	`, "snap-"+pollID, pollID, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to create snapshot: %v", err)
+	}
+
+	getRanking := func(query string) models.OptionStats {
+		req := httptest.NewRequest("GET", "/polls/"+shareSlug+"/results"+query, nil)
+		req.SetPathValue("slug", shareSlug)
+		w := httptest.NewRecorder()
+		handler.GetResults(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		var results struct {
+			Rankings []models.OptionStats `json:"rankings"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&results); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if len(results.Rankings) != 1 {
+			t.Fatalf("Expected 1 ranking, got %d", len(results.Rankings))
+		}
+		return results.Rankings[0]
+	}
+
+	got := getRanking("")
+	want := models.OptionStats{Median: 0.7333, P10: -0.1235, P90: 1, Mean: 0.5556, NegShare: 0.3333}
+	if got.Median != want.Median || got.P10 != want.P10 || got.P90 != want.P90 ||
+		got.Mean != want.Mean || got.NegShare != want.NegShare {
+		t.Errorf("Expected statistics rounded to 4 digits %+v, got %+v", want, got)
+	}
+
+	got = getRanking("?precision=full")
+	if got.Median != 0.7333333333333333 || got.NegShare != 0.3333333333333333 {
+		t.Errorf("Expected full precision, got median %v and neg_share %v", got.Median, got.NegShare)
+	}
+
+	req := httptest.NewRequest("GET", "/polls/"+shareSlug+"/results?precision=2", nil)
+	req.SetPathValue("slug", shareSlug)
+	w := httptest.NewRecorder()
+	handler.GetResults(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for an unknown precision, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestGetResultsCamelCase(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	cfg := getTestConfig()
+	handler := NewResultsHandler(db, cfg)
+
+	pollID, _ := auth.GenerateID(16)
+	shareSlug := auth.GenerateShareSlug(pollID, cfg.PollSlugSalt)
+	_, err := db.Exec(`
+		INSERT INTO poll (id, title, creator_name, status, share_slug, created_at, final_snapshot_id)
+		VALUES ($1, 'Camel Poll', 'Alice', 'closed', $2, $3, $4)
+	`, pollID, shareSlug, time.Now(), "snap-"+pollID)
+	if err != nil {
+		t.Fatalf("Failed to create test poll: %v", err)
+	}
+	_, err = db.Exec(`
+		INSERT INTO result_snapshot (id, poll_id, method, computed_at, payload)
+		VALUES ($1, $2, 'bmj', $3, '{"rankings": [{"option_id": "opt1", "median": 0.5, "p10": 0, "p90": 1, "mean": 0.5, "neg_share": 0.25, "rank": 1}], "inputs_hash": ""}')
+	`, "snap-"+pollID, pollID, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to create snapshot: %v", err)
+	}
+
+	getResults := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/polls/"+shareSlug+"/results"+query, nil)
+		req.SetPathValue("slug", shareSlug)
+		w := httptest.NewRecorder()
+		handler.GetResults(w, req)
+		return w
+	}
+
+	w := getResults("")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if body := w.Body.String(); !strings.Contains(body, `"option_id"`) || !strings.Contains(body, `"neg_share"`) {
+		t.Errorf("Expected snake_case keys by default, got %s", body)
+	}
+
+	w = getResults("?case=camel")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var results struct {
+		BallotCount *int `json:"ballotCount"`
+		Rankings    []struct {
+			OptionID string  `json:"optionId"`
+			NegShare float64 `json:"negShare"`
+		} `json:"rankings"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &results); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if results.BallotCount == nil {
+		t.Error("Expected ballotCount in camelCase response")
+	}
+	if len(results.Rankings) != 1 || results.Rankings[0].OptionID != "opt1" || results.Rankings[0].NegShare != 0.25 {
+		t.Errorf("Expected camelCase ranking for opt1 with negShare 0.25, got %+v", results.Rankings)
+	}
+	if strings.Contains(w.Body.String(), `"option_id"`) {
+		t.Errorf("Expected no snake_case keys with case=camel, got %s", w.Body.String())
+	}
+
+	if w := getResults("?case=kebab"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for an unknown case, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestGetPublicStatus(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	cfg := getTestConfig()
+	handler := NewResultsHandler(db, cfg)
+
+	pollID, _ := auth.GenerateID(16)
+	_, err := db.Exec(`
+		INSERT INTO poll (id, title, creator_name, status, creator_contact, created_at)
+		VALUES ($1, 'Team Offsite', 'Alice', 'draft', 'alice@example.com', $2)
+	`, pollID, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to create test poll: %v", err)
+	}
+	for _, label := range []string{"Lisbon", "Porto"} {
+		optionID, _ := auth.GenerateID(12)
+		if _, err := db.Exec(`INSERT INTO option (id, poll_id, label) VALUES ($1, $2, $3)`, optionID, pollID, label); err != nil {
+			t.Fatalf("Failed to create option: %v", err)
+		}
+	}
+
+	getStatus := func(id string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/polls/"+id+"/public-status", nil)
+		req.SetPathValue("id", id)
+		w := httptest.NewRecorder()
+		handler.GetPublicStatus(w, req)
+		return w
+	}
+
+	w := getStatus(pollID)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	// Decode loosely so any field beyond the safe ones is caught
+	var resp map[string]any
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(resp) != 3 {
+		t.Errorf("Expected only title, status, and option_count, got %v", resp)
+	}
+	if resp["title"] != "Team Offsite" || resp["status"] != "draft" || resp["option_count"] != float64(2) {
+		t.Errorf("Unexpected public status: %v", resp)
+	}
+
+	if w := getStatus("no-such-poll"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for unknown ID, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestGetPollSafeDescription(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	cfg := getTestConfig()
+	pollID, _ := auth.GenerateID(16)
+	shareSlug := auth.GenerateShareSlug(pollID, cfg.PollSlugSalt)
+	description := `Pick one <script>alert("hi")</script><b>soon</b> & "fast"`
+	_, err := db.Exec(`
+		INSERT INTO poll (id, title, description, creator_name, status, share_slug, created_at)
+		VALUES ($1, 'Lunch', $2, 'Alice', 'open', $3, $4)
+	`, pollID, description, shareSlug, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to create test poll: %v", err)
+	}
+
+	getPoll := func(cfg cliparse.Config) models.Poll {
+		req := httptest.NewRequest("GET", "/polls/"+shareSlug, nil)
+		req.SetPathValue("slug", shareSlug)
+		w := httptest.NewRecorder()
+		NewResultsHandler(db, cfg).GetPoll(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		var resp models.PollWithOptions
+		json.NewDecoder(w.Body).Decode(&resp)
+		return resp.Poll
+	}
+
+	// Off by default
+	if poll := getPoll(cfg); poll.DescriptionSafe != nil {
+		t.Errorf("Expected no description_safe by default, got %q", *poll.DescriptionSafe)
+	}
+
+	cfg.SafeDescriptions = true
+	poll := getPoll(cfg)
+	if poll.Description != description {
+		t.Errorf("Expected the raw description to be unchanged, got %q", poll.Description)
+	}
+	want := `Pick one alert(&#34;hi&#34;)soon &amp; &#34;fast&#34;`
+	if poll.DescriptionSafe == nil || *poll.DescriptionSafe != want {
+		t.Fatalf("Expected description_safe %q, got %v", want, poll.DescriptionSafe)
+	}
+	if strings.ContainsAny(*poll.DescriptionSafe, "<>") {
+		t.Errorf("Expected no markup in description_safe, got %q", *poll.DescriptionSafe)
+	}
+
+	// An empty or all-markup description still yields the field, empty
+	if _, err := db.Exec(`UPDATE poll SET description = '<br>' WHERE id = $1`, pollID); err != nil {
+		t.Fatalf("Failed to update description: %v", err)
+	}
+	if poll := getPoll(cfg); poll.DescriptionSafe == nil || *poll.DescriptionSafe != "" {
+		t.Errorf("Expected an empty description_safe, got %v", poll.DescriptionSafe)
+	}
+}
+
+func TestGetResultsSnapshotMissing(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	cfg := getTestConfig()
+	pollHandler := NewPollHandler(db, cfg)
+	handler := NewResultsHandler(db, cfg)
+
+	pollID, _ := auth.GenerateID(16)
+	adminKey := auth.GenerateAdminKey(pollID, cfg.AdminKeySalt)
+	shareSlug := auth.GenerateShareSlug(pollID, cfg.PollSlugSalt)
+	_, err := db.Exec(`
+		INSERT INTO poll (id, title, creator_name, status, share_slug, created_at)
+		VALUES ($1, 'Dinner', 'Alice', 'open', $2, $3)
+	`, pollID, shareSlug, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to create test poll: %v", err)
+	}
+	optionID, _ := auth.GenerateID(12)
+	if _, err := db.Exec(`INSERT INTO option (id, poll_id, label) VALUES ($1, $2, 'Sushi')`, optionID, pollID); err != nil {
+		t.Fatalf("Failed to create option: %v", err)
+	}
+
+	adminRequest := func(path string, handle http.HandlerFunc) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, nil)
+		req.SetPathValue("id", pollID)
+		req.Header.Set("X-Admin-Key", adminKey)
+		w := httptest.NewRecorder()
+		handle(w, req)
+		return w
+	}
+	getResults := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/polls/"+shareSlug+"/results", nil)
+		req.SetPathValue("slug", shareSlug)
+		w := httptest.NewRecorder()
+		handler.GetResults(w, req)
+		return w
+	}
+
+	if w := adminRequest("/polls/"+pollID+"/close", pollHandler.ClosePoll); w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if _, err := db.Exec(`DELETE FROM result_snapshot WHERE poll_id = $1`, pollID); err != nil {
+		t.Fatalf("Failed to delete snapshot: %v", err)
+	}
+
+	w := getResults()
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusInternalServerError, w.Code, w.Body.String())
+	}
+	var resp models.ErrorResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Code != models.ErrorCodeSnapshotMissing {
+		t.Errorf("Expected code %q, got %q", models.ErrorCodeSnapshotMissing, resp.Code)
+	}
+
+	// Recomputing seals a new snapshot and heals the poll
+	if w := adminRequest("/polls/"+pollID+"/recompute", pollHandler.RecomputeResults); w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if w := getResults(); w.Code != http.StatusOK {
+		t.Errorf("Expected status %d after recomputing, got %d. Body: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+}
+
+func TestGetResultsText(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	cfg := getTestConfig()
+	handler := NewResultsHandler(db, cfg)
+
+	createPoll := func(status string) string {
+		pollID, _ := auth.GenerateID(16)
+		shareSlug := auth.GenerateShareSlug(pollID, cfg.PollSlugSalt)
+		_, err := db.Exec(`
+			INSERT INTO poll (id, title, creator_name, status, share_slug, created_at, final_snapshot_id)
+			VALUES ($1, 'Dinner', 'Alice', $2, $3, $4, $5)
+		`, pollID, status, shareSlug, time.Now(), "snap-"+pollID)
+		if err != nil {
+			t.Fatalf("Failed to create test poll: %v", err)
+		}
+		_, err = db.Exec(`
+			INSERT INTO result_snapshot (id, poll_id, method, computed_at, payload)
+			VALUES ($1, $2, 'bmj', $3, '{"rankings": [
+				{"option_id": "opt1", "label": "Sushi", "median": 0.62, "rank": 1},
+				{"option_id": "opt2", "label": "Tacos", "median": 0.4, "rank": 2},
+				{"option_id": "opt3", "label": "Pizza", "median": -0.5, "neg_share": 0.8, "veto": true, "rank": 3}
+			], "inputs_hash": ""}')
+		`, "snap-"+pollID, pollID, time.Now())
+		if err != nil {
+			t.Fatalf("Failed to create snapshot: %v", err)
+		}
+		return shareSlug
+	}
+
+	getText := func(shareSlug string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/polls/"+shareSlug+"/results.txt", nil)
+		req.SetPathValue("slug", shareSlug)
+		w := httptest.NewRecorder()
+		handler.GetResultsText(w, req)
+		return w
+	}
+
+	w := getText(createPoll(models.StatusClosed))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("Expected text/plain, got %q", ct)
+	}
+
+	body := w.Body.String()
+	wantLines := []string{
+		"1. Sushi (median 0.62) 🏆",
+		"2. Tacos (median 0.40)",
+		"3. Pizza (vetoed)",
+	}
+	last := -1
+	for _, line := range wantLines {
+		i := strings.Index(body, line)
+		if i < 0 {
+			t.Fatalf("Expected %q in body:\n%s", line, body)
+		}
+		if i < last {
+			t.Errorf("Expected %q after the previous line in body:\n%s", line, body)
+		}
+		last = i
+	}
+	if !strings.HasPrefix(body, "Dinner\n") {
+		t.Errorf("Expected the poll title first, got:\n%s", body)
+	}
+
+	if w := getText(createPoll(models.StatusOpen)); w.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d for an open poll, got %d", http.StatusForbidden, w.Code)
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright (c) 2025 Daniel Kuo.
+// Source-available; no permission granted to use, copy, modify, or distribute. See LICENSE.
+
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/danielhkuo/quickly-pick/auth"
+	"github.com/danielhkuo/quickly-pick/middleware"
+	"github.com/danielhkuo/quickly-pick/models"
+)
+
+// MaxRawScores caps the scores GetRawScores returns across all options;
+// larger polls are better served by ExportBallotsCSV
+const MaxRawScores = 100000
+
+// GetRawScores handles GET /polls/:id/scores
+// Returns every signed score each option was ranked on, sorted, for
+// analysis in external statistical tools. Scores are pooled per option, so
+// nothing links them back to a ballot or voter. Closed polls only.
+func (h *PollHandler) GetRawScores(w http.ResponseWriter, r *http.Request) {
+	pollID := r.PathValue("id")
+	if pollID == "" {
+		middleware.ErrorResponse(w, http.StatusBadRequest, "poll_id is required")
+		return
+	}
+
+	// Validate admin key
+	adminKey := r.Header.Get("X-Admin-Key")
+	if err := auth.ValidateAdminKey(pollID, adminKey, h.cfg.AdminKeySalt); err != nil {
+		middleware.ErrorResponse(w, http.StatusUnauthorized, "Invalid admin key")
+		return
+	}
+
+	var status string
+	err := h.db.QueryRow(`SELECT status FROM poll WHERE id = $1`, pollID).Scan(&status)
+	if err == sql.ErrNoRows {
+		middleware.ErrorResponse(w, http.StatusNotFound, "Poll not found")
+		return
+	}
+	if err != nil {
+		slog.Error("failed to query poll", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	// Ballots are final once the poll closes, so the scores match the results
+	if status != models.StatusClosed {
+		middleware.ErrorResponse(w, http.StatusConflict, "Scores are only available after the poll closes")
+		return
+	}
+
+	ctx := r.Context()
+	optionLabels, err := getOptionLabels(ctx, h.db, pollID)
+	if err != nil {
+		slog.Error("failed to get option labels", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	ballots, err := getBallotScores(ctx, h.db, pollID)
+	if err != nil {
+		slog.Error("failed to get ballot scores", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	total := 0
+	for _, ballot := range ballots {
+		total += len(ballot)
+	}
+	if total > MaxRawScores {
+		middleware.ErrorResponse(w, http.StatusUnprocessableEntity,
+			fmt.Sprintf("Poll has more than %d scores; export the ballots as CSV instead", MaxRawScores))
+		return
+	}
+
+	scores, scored := groupBallotScores(ballots)
+	rankings := rankOptionScores(optionLabels, scores, scored, h.bmj)
+	signed := signedOptionScores(scores)
+	options := make([]models.OptionRawScores, len(rankings))
+	for i, stat := range rankings {
+		options[i] = models.OptionRawScores{
+			OptionID: stat.OptionID,
+			Label:    stat.Label,
+			Rank:     stat.Rank,
+			Scores:   signed[stat.OptionID],
+		}
+		if options[i].Scores == nil {
+			options[i].Scores = []float64{}
+		}
+	}
+
+	middleware.JSONResponse(w, http.StatusOK, models.RawScoresResponse{
+		PollID:  pollID,
+		Scale:   BMJScoreScale,
+		Options: options,
+	})
+}
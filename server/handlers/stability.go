@@ -0,0 +1,160 @@
+// Copyright (c) 2025 Daniel Kuo.
+// Source-available; no permission granted to use, copy, modify, or distribute. See LICENSE.
+
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+
+	"github.com/danielhkuo/quickly-pick/auth"
+	"github.com/danielhkuo/quickly-pick/middleware"
+	"github.com/danielhkuo/quickly-pick/models"
+)
+
+// DefaultStabilityResamples is how many bootstrap resamples GetStability
+// draws when the request doesn't say
+const DefaultStabilityResamples = 200
+
+// MaxStabilityResamples caps the resamples one GetStability request may
+// ask for; each one ranks every option again
+const MaxStabilityResamples = 1000
+
+// MaxStabilityDraws caps the ballots one GetStability request draws across
+// all its resamples, so a poll with many ballots gets fewer resamples
+// instead of a request that runs for minutes
+const MaxStabilityDraws = 1_000_000
+
+// GetStability handles GET /polls/:id/stability
+// Estimates how robust the ranking is by bootstrap: the poll's counted
+// ballots are resampled with replacement ?resamples= times, each resample
+// is ranked with BMJ, and every option reports the share of resamples it
+// won. Like the results, it is only available once the poll has closed, or
+// while it is open with live results.
+func (h *PollHandler) GetStability(w http.ResponseWriter, r *http.Request) {
+	pollID := r.PathValue("id")
+	if pollID == "" {
+		middleware.ErrorResponse(w, http.StatusBadRequest, "poll_id is required")
+		return
+	}
+
+	// Validate admin key
+	adminKey := r.Header.Get("X-Admin-Key")
+	if err := auth.ValidateAdminKey(pollID, adminKey, h.cfg.AdminKeySalt); err != nil {
+		middleware.ErrorResponse(w, http.StatusUnauthorized, "Invalid admin key")
+		return
+	}
+
+	resamples := DefaultStabilityResamples
+	if value := r.URL.Query().Get("resamples"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > MaxStabilityResamples {
+			middleware.ValidationErrorResponse(w, []models.FieldError{{
+				Field:   "resamples",
+				Code:    models.FieldCodeOutOfRange,
+				Message: fmt.Sprintf("resamples must be between 1 and %d", MaxStabilityResamples),
+			}})
+			return
+		}
+		resamples = parsed
+	}
+
+	var status string
+	var liveResults bool
+	err := h.db.QueryRow(`SELECT status, live_results FROM poll WHERE id = $1`, pollID).Scan(&status, &liveResults)
+	if err == sql.ErrNoRows {
+		middleware.ErrorResponse(w, http.StatusNotFound, "Poll not found")
+		return
+	}
+	if err != nil {
+		slog.Error("failed to query poll", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if status != models.StatusClosed && !(status == models.StatusOpen && liveResults) {
+		middleware.ErrorResponse(w, http.StatusConflict, "Stability is only available after the poll closes, or while it shows live results")
+		return
+	}
+
+	ctx := r.Context()
+	optionLabels, err := getOptionLabels(ctx, h.db, pollID)
+	if err != nil {
+		slog.Error("failed to get option labels", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	ballots, err := getBallotScores(ctx, h.db, pollID)
+	if err != nil {
+		slog.Error("failed to get ballot scores", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	if len(ballots) > 0 {
+		resamples = min(resamples, MaxStabilityDraws/len(ballots))
+	}
+
+	rng := rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64()))
+	wins, err := bootstrapWins(ctx, optionLabels, ballots, resamples, rng, h.bmj)
+	if err != nil {
+		slog.Error("failed to compute stability", "error", err, "poll_id", pollID)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Failed to compute stability")
+		return
+	}
+	if len(ballots) == 0 {
+		resamples = 0
+	}
+
+	// Report options in the order the actual ballots rank them
+	scores, scored := groupBallotScores(ballots)
+	rankings := rankOptionScores(optionLabels, scores, scored, h.bmj)
+	options := make([]models.OptionStability, len(rankings))
+	for i, stat := range rankings {
+		options[i] = models.OptionStability{
+			OptionID: stat.OptionID,
+			Label:    stat.Label,
+			Rank:     stat.Rank,
+		}
+		if resamples > 0 {
+			options[i].WinShare = float64(wins[stat.OptionID]) / float64(resamples)
+		}
+	}
+
+	middleware.JSONResponse(w, http.StatusOK, models.StabilityResponse{
+		PollID:      pollID,
+		Resamples:   resamples,
+		BallotCount: len(ballots),
+		Options:     options,
+	})
+}
+
+// bootstrapWins ranks resamples draws of len(ballots) ballots, taken with
+// replacement, and counts how often each option ranked first. It returns
+// no wins when there are no ballots to draw from, and ctx's error if ctx
+// is done before it finishes.
+func bootstrapWins(ctx context.Context, optionLabels map[string]string, ballots [][]ballotScore, resamples int, rng *rand.Rand, opts BMJOptions) (map[string]int, error) {
+	wins := make(map[string]int)
+	if len(ballots) == 0 {
+		return wins, nil
+	}
+
+	sample := make([][]ballotScore, len(ballots))
+	for range resamples {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		for i := range sample {
+			sample[i] = ballots[rng.IntN(len(ballots))]
+		}
+		scores, scored := groupBallotScores(sample)
+		if rankings := rankOptionScores(optionLabels, scores, scored, opts); len(rankings) > 0 {
+			wins[rankings[0].OptionID]++
+		}
+	}
+	return wins, nil
+}
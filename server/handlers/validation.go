@@ -0,0 +1,83 @@
+// Copyright (c) 2025 Daniel Kuo.
+// Source-available; no permission granted to use, copy, modify, or distribute. See LICENSE.
+
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/danielhkuo/quickly-pick/models"
+)
+
+// fieldErrors collects every validation problem in a request so clients
+// can fix them all in one round trip instead of one error at a time
+type fieldErrors []models.FieldError
+
+// add records a problem with field
+func (e *fieldErrors) add(field, code, message string) {
+	*e = append(*e, models.FieldError{Field: field, Code: code, Message: message})
+}
+
+// required records a missing-field problem when value is empty
+func (e *fieldErrors) required(field, value string) {
+	if value == "" {
+		e.add(field, models.FieldCodeRequired, field+" is required")
+	}
+}
+
+// queryBool parses the boolean query parameter name, which is false when
+// absent, recording a problem when the value isn't a boolean
+func (e *fieldErrors) queryBool(r *http.Request, name string) bool {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return false
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		e.add(name, models.FieldCodeInvalid, name+" must be true or false")
+		return false
+	}
+	return parsed
+}
+
+// queryInt parses the integer query parameter name, which is def when
+// absent, recording a problem when the value isn't an integer of at least
+// min
+func (e *fieldErrors) queryInt(r *http.Request, name string, def, min int) int {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return def
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		e.add(name, models.FieldCodeInvalid, name+" must be an integer")
+		return def
+	}
+	if parsed < min {
+		e.add(name, models.FieldCodeOutOfRange, fmt.Sprintf("%s must be at least %d", name, min))
+		return def
+	}
+	return parsed
+}
+
+// jsonObject records a problem when raw, if present, isn't a JSON object
+// of at most maxBytes. An explicit null counts as absent.
+func (e *fieldErrors) jsonObject(field string, raw json.RawMessage, maxBytes int) {
+	trimmed := strings.TrimSpace(string(raw))
+	switch {
+	case trimmed == "" || trimmed == "null":
+	case len(trimmed) > maxBytes:
+		e.add(field, models.FieldCodeOutOfRange, fmt.Sprintf("%s cannot exceed %d bytes", field, maxBytes))
+	case trimmed[0] != '{':
+		e.add(field, models.FieldCodeWrongType, field+" must be a JSON object")
+	}
+}
+
+// any reports whether any problems were recorded
+func (e fieldErrors) any() bool {
+	return len(e) > 0
+}
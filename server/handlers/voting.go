@@ -4,24 +4,41 @@
 package handlers
 
 import (
+	"bytes"
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
+	"math"
 	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/danielhkuo/quickly-pick/auth"
 	"github.com/danielhkuo/quickly-pick/cliparse"
 	"github.com/danielhkuo/quickly-pick/middleware"
 	"github.com/danielhkuo/quickly-pick/models"
+	"github.com/lib/pq"
 )
 
 type VotingHandler struct {
-	db  *sql.DB
-	cfg cliparse.Config
+	db     *sql.DB
+	cfg    cliparse.Config
+	counts *BallotCountCache // invalidated on ballot writes; may be nil
 }
 
 func NewVotingHandler(db *sql.DB, cfg cliparse.Config) *VotingHandler {
-	return &VotingHandler{db: db, cfg: cfg}
+	return NewVotingHandlerWithCache(db, cfg, nil)
+}
+
+// NewVotingHandlerWithCache creates a VotingHandler that invalidates counts
+// in the given cache whenever a ballot is written
+func NewVotingHandlerWithCache(db *sql.DB, cfg cliparse.Config, counts *BallotCountCache) *VotingHandler {
+	return &VotingHandler{db: db, cfg: cfg, counts: counts}
 }
 
 // ClaimUsername handles POST /polls/:slug/claim-username
@@ -34,31 +51,29 @@ func (h *VotingHandler) ClaimUsername(w http.ResponseWriter, r *http.Request) {
 
 	// Parse request
 	var req models.ClaimUsernameRequest
-	if err := middleware.ParseJSONBody(r, &req); err != nil {
+	if err := middleware.DecodeRequiredJSON(r, &req); err != nil {
 		middleware.ErrorResponse(w, http.StatusBadRequest, "Invalid JSON")
 		return
 	}
 
-	if req.Username == "" {
-		middleware.ErrorResponse(w, http.StatusBadRequest, "username is required")
-		return
-	}
-
-	// Validate username (basic validation)
-	if len(req.Username) < 2 || len(req.Username) > 50 {
-		middleware.ErrorResponse(w, http.StatusBadRequest, "username must be 2-50 characters")
+	// Polls only narrow the server's bounds, so this can be checked first
+	if msg := usernameBounds(h.cfg, nil, nil).usernameError(req.Username); msg != "" {
+		middleware.ErrorResponse(w, http.StatusBadRequest, msg)
 		return
 	}
 
 	// Find poll by share slug
 	var pollID string
 	var status string
+	var paused bool
+	var minLength, maxLength *int
+	var creatorCanVote bool
 	err := h.db.QueryRow(`
-		SELECT id, status FROM poll WHERE share_slug = $1
-	`, shareSlug).Scan(&pollID, &status)
+		SELECT id, status, paused, min_username_length, max_username_length, creator_can_vote FROM poll WHERE `+slugMatch+`
+	`, shareSlug).Scan(&pollID, &status, &paused, &minLength, &maxLength, &creatorCanVote)
 
 	if err == sql.ErrNoRows {
-		middleware.ErrorResponse(w, http.StatusNotFound, "Poll not found")
+		pollNotFoundResponse(w)
 		return
 	}
 	if err != nil {
@@ -69,10 +84,47 @@ func (h *VotingHandler) ClaimUsername(w http.ResponseWriter, r *http.Request) {
 
 	// Can only claim username for open polls
 	if status != models.StatusOpen {
-		middleware.ErrorResponse(w, http.StatusConflict, "Poll is not open for voting")
+		pollNotOpenResponse(w, status)
+		return
+	}
+	if paused {
+		pollPausedResponse(w)
+		return
+	}
+	if msg := usernameBounds(h.cfg, minLength, maxLength).usernameError(req.Username); msg != "" {
+		middleware.ErrorResponse(w, http.StatusBadRequest, msg)
 		return
 	}
 
+	// The creator is recognized by their device, so only claims sent with
+	// X-Device-UUID can be kept out
+	if !creatorCanVote {
+		creator, err := isCreatorVoter(h.db, pollID, "", requestDeviceUUID(h.cfg, r))
+		if err != nil {
+			slog.Error("failed to check creator device", "error", err)
+			middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+		if creator {
+			refuseCreatorVoting().write(w)
+			return
+		}
+	}
+
+	// A device holds one voter identity per poll, so claiming again from
+	// it returns the identity it already has
+	var deviceID string
+	var deviceErr error
+	if requestDeviceUUID(h.cfg, r) != "" {
+		deviceID, deviceErr = GetOrCreateDevice(h.db, h.cfg, r)
+		if deviceErr != nil {
+			slog.Warn("failed to get/create device", "error", deviceErr)
+			// Non-fatal: the username can still be claimed, just not linked
+		} else if h.respondExistingClaim(w, deviceID, pollID) {
+			return
+		}
+	}
+
 	// Generate voter token
 	voterToken, err := auth.GenerateVoterToken()
 	if err != nil {
@@ -85,7 +137,7 @@ func (h *VotingHandler) ClaimUsername(w http.ResponseWriter, r *http.Request) {
 	_, err = h.db.Exec(`
 		INSERT INTO username_claim (poll_id, username, voter_token, created_at)
 		VALUES ($1, $2, $3, $4)
-	`, pollID, req.Username, voterToken, time.Now())
+	`, pollID, req.Username, voterToken, time.Now().UTC())
 
 	if err != nil {
 		// Check if it's a uniqueness violation
@@ -99,24 +151,232 @@ func (h *VotingHandler) ClaimUsername(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	response := models.ClaimUsernameResponse{
+		VoterToken: voterToken,
+	}
+
 	// Link device to poll as voter (if X-Device-UUID header present)
-	deviceID, err := GetOrCreateDevice(h.db, r)
-	if err != nil {
-		slog.Warn("failed to get/create device", "error", err)
-		// Non-fatal: username was claimed, just no device linking
-	} else if deviceID != "" {
-		if err := LinkDeviceToPoll(h.db, deviceID, pollID, models.RoleVoter, &voterToken); err != nil {
-			slog.Warn("failed to link device to poll", "error", err)
+	if requestDeviceUUID(h.cfg, r) != "" {
+		linked := false
+		if deviceErr == nil {
+			if err := LinkDeviceToPoll(h.db, deviceID, pollID, models.RoleVoter, &voterToken); err != nil {
+				slog.Warn("failed to link device to poll", "error", err)
+			} else {
+				linked = true
+			}
+		}
+		response.DeviceLinked = &linked
+
+		// The link keeps the first voter token it is given. If a concurrent
+		// claim from this device got there first, give up this claim for it.
+		if linked {
+			existing, _, err := deviceVoterClaim(h.db, deviceID, pollID)
+			if err != nil {
+				slog.Warn("failed to check device voter claim", "error", err)
+			} else if existing != "" && existing != voterToken {
+				if _, err := h.db.Exec(`
+					DELETE FROM username_claim WHERE poll_id = $1 AND voter_token = $2
+				`, pollID, voterToken); err != nil {
+					slog.Warn("failed to remove duplicate device claim", "error", err, "poll_id", pollID)
+				}
+				h.respondExistingClaim(w, deviceID, pollID)
+				return
+			}
 		}
 	}
 
 	slog.Info("username claimed", "poll_id", pollID, "username", req.Username)
 
-	middleware.JSONResponse(w, http.StatusCreated, models.ClaimUsernameResponse{
-		VoterToken: voterToken,
+	middleware.JSONResponse(w, http.StatusCreated, response)
+}
+
+// respondExistingClaim answers a claim from a device already voting on
+// the poll with that device's voter token and username, reporting whether
+// it did. Errors are logged and treated as no existing claim.
+func (h *VotingHandler) respondExistingClaim(w http.ResponseWriter, deviceID, pollID string) bool {
+	voterToken, username, err := deviceVoterClaim(h.db, deviceID, pollID)
+	if err != nil {
+		slog.Warn("failed to look up device voter claim", "error", err, "poll_id", pollID)
+		return false
+	}
+	if voterToken == "" {
+		return false
+	}
+
+	slog.Info("username claim returned for device", "poll_id", pollID, "username", username)
+
+	linked := true
+	middleware.JSONResponse(w, http.StatusOK, models.ClaimUsernameResponse{
+		VoterToken:   voterToken,
+		DeviceLinked: &linked,
+		Username:     username,
+	})
+	return true
+}
+
+// deviceVoterClaim returns the voter token and username deviceID holds on
+// pollID, or empty strings when it has not claimed a username there
+func deviceVoterClaim(db *sql.DB, deviceID, pollID string) (voterToken, username string, err error) {
+	err = db.QueryRow(`
+		SELECT uc.voter_token, uc.username
+		FROM device_poll dp
+		JOIN username_claim uc ON uc.poll_id = dp.poll_id AND uc.voter_token = dp.voter_token
+		WHERE dp.device_id = $1 AND dp.poll_id = $2
+	`, deviceID, pollID).Scan(&voterToken, &username)
+	if err == sql.ErrNoRows {
+		return "", "", nil
+	}
+	return voterToken, username, err
+}
+
+// RenameUsername handles PATCH /polls/:slug/username
+// Changes the caller's username while keeping their voter token and ballot
+func (h *VotingHandler) RenameUsername(w http.ResponseWriter, r *http.Request) {
+	shareSlug := r.PathValue("slug")
+	if shareSlug == "" {
+		middleware.ErrorResponse(w, http.StatusBadRequest, "slug is required")
+		return
+	}
+
+	voterToken := r.Header.Get("X-Voter-Token")
+	if voterToken == "" {
+		middleware.ErrorResponse(w, http.StatusUnauthorized, "X-Voter-Token header required")
+		return
+	}
+
+	var req models.RenameUsernameRequest
+	if err := middleware.DecodeRequiredJSON(r, &req); err != nil {
+		middleware.ErrorResponse(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	if msg := usernameBounds(h.cfg, nil, nil).usernameError(req.Username); msg != "" {
+		middleware.ErrorResponse(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	var pollID string
+	var status string
+	var minLength, maxLength *int
+	err := h.db.QueryRow(`
+		SELECT id, status, min_username_length, max_username_length FROM poll WHERE `+slugMatch+`
+	`, shareSlug).Scan(&pollID, &status, &minLength, &maxLength)
+	if err == sql.ErrNoRows {
+		pollNotFoundResponse(w)
+		return
+	}
+	if err != nil {
+		slog.Error("failed to query poll", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	// Names are frozen with the results once voting ends
+	if status != models.StatusOpen {
+		pollNotOpenResponse(w, status)
+		return
+	}
+	if msg := usernameBounds(h.cfg, minLength, maxLength).usernameError(req.Username); msg != "" {
+		middleware.ErrorResponse(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	// The voter token keys the claim and the ballot, so both survive
+	result, err := h.db.Exec(`
+		UPDATE username_claim SET username = $1
+		WHERE poll_id = $2 AND voter_token = $3
+	`, req.Username, pollID, voterToken)
+	if isUniqueViolation(err) {
+		middleware.ErrorResponse(w, http.StatusConflict, "Username already taken")
+		return
+	}
+	if err != nil {
+		slog.Error("failed to rename username", "error", err, "poll_id", pollID)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Failed to rename username")
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		middleware.ErrorResponse(w, http.StatusUnauthorized, "Invalid voter token for this poll")
+		return
+	}
+
+	slog.Info("username renamed", "poll_id", pollID, "username", req.Username)
+
+	middleware.JSONResponse(w, http.StatusOK, models.RenameUsernameResponse{
+		Username: req.Username,
 	})
 }
 
+// usernameLimits are the shortest and longest username, in bytes, a voter
+// may claim on a poll
+type usernameLimits struct {
+	min, max int
+}
+
+// usernameBounds returns the username limits of a poll: its own bounds
+// where it sets them, and cfg's otherwise. With nil bounds it returns the
+// server-wide limits, which every poll's lie within.
+func usernameBounds(cfg cliparse.Config, pollMin, pollMax *int) usernameLimits {
+	limits := usernameLimits{min: cfg.MinUsernameLength, max: cfg.MaxUsernameLength}
+	if limits.min == 0 {
+		limits.min = cliparse.DefaultMinUsernameLength
+	}
+	if limits.max == 0 {
+		limits.max = cliparse.DefaultMaxUsernameLength
+	}
+	if pollMin != nil {
+		limits.min = *pollMin
+	}
+	if pollMax != nil {
+		limits.max = *pollMax
+	}
+	return limits
+}
+
+// usernameError returns why username can't be claimed within limits, or ""
+// if it can
+func (limits usernameLimits) usernameError(username string) string {
+	if username == "" {
+		return "username is required"
+	}
+	if len(username) < limits.min || len(username) > limits.max {
+		return fmt.Sprintf("username must be %d-%d characters", limits.min, limits.max)
+	}
+	return ""
+}
+
+// validateUsernameBounds records a problem when a poll's username bounds
+// fall outside the server's or leave no valid length
+func validateUsernameBounds(errs *fieldErrors, cfg cliparse.Config, pollMin, pollMax *int) {
+	server := usernameBounds(cfg, nil, nil)
+	valid := true
+	for _, bound := range []struct {
+		field string
+		value *int
+	}{
+		{"min_username_length", pollMin},
+		{"max_username_length", pollMax},
+	} {
+		if bound.value != nil && (*bound.value < server.min || *bound.value > server.max) {
+			errs.add(bound.field, models.FieldCodeOutOfRange,
+				fmt.Sprintf("%s must be between %d and %d", bound.field, server.min, server.max))
+			valid = false
+		}
+	}
+	if !valid {
+		return
+	}
+
+	if limits := usernameBounds(cfg, pollMin, pollMax); limits.min >= limits.max {
+		field := "min_username_length"
+		if pollMax != nil {
+			field = "max_username_length"
+		}
+		errs.add(field, models.FieldCodeOutOfRange,
+			fmt.Sprintf("min_username_length must be less than max_username_length, got %d-%d", limits.min, limits.max))
+	}
+}
+
 // GetMyBallot handles GET /polls/:slug/my-ballot
 func (h *VotingHandler) GetMyBallot(w http.ResponseWriter, r *http.Request) {
 	shareSlug := r.PathValue("slug")
@@ -135,7 +395,7 @@ func (h *VotingHandler) GetMyBallot(w http.ResponseWriter, r *http.Request) {
 	// Find poll by share slug
 	var pollID string
 	err := h.db.QueryRow(`
-		SELECT id FROM poll WHERE share_slug = $1
+		SELECT id FROM poll WHERE `+slugMatch+`
 	`, shareSlug).Scan(&pollID)
 
 	if err == sql.ErrNoRows {
@@ -215,47 +475,186 @@ func (h *VotingHandler) SubmitBallot(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Parse request
-	var req models.SubmitBallotRequest
-	if err := middleware.ParseJSONBody(r, &req); err != nil {
+	// validate_only runs every check without writing the ballot
+	var queryErrs fieldErrors
+	validateOnly := queryErrs.queryBool(r, "validate_only")
+	if queryErrs.any() {
+		middleware.ValidationErrorResponse(w, queryErrs)
+		return
+	}
+
+	// Parse request. Scores are decoded separately so a malformed value is
+	// reported against its option instead of as invalid JSON.
+	var body struct {
+		Scores json.RawMessage `json:"scores"`
+	}
+	if err := middleware.DecodeRequiredJSON(r, &body); err != nil {
 		middleware.ErrorResponse(w, http.StatusBadRequest, "Invalid JSON")
 		return
 	}
 
-	if len(req.Scores) == 0 {
-		middleware.ErrorResponse(w, http.StatusBadRequest, "scores cannot be empty")
+	submitted, refusal := h.submitBallot(ballotSubmission{
+		shareSlug:    shareSlug,
+		voterToken:   voterToken,
+		scores:       body.Scores,
+		validateOnly: validateOnly,
+		ipHash:       auth.HashIP(middleware.ClientIP(r, h.cfg.TrustProxyHeaders), h.cfg.AdminKeySalt), // Reuse admin salt for IP hashing
+		userAgent:    r.UserAgent(),
+		deviceUUID:   requestDeviceUUID(h.cfg, r),
+	})
+	if refusal != nil {
+		refusal.write(w)
 		return
 	}
 
-	// Validate all scores are in range [0, 1]
-	for optionID, score := range req.Scores {
-		if score < 0 || score > 1 {
-			middleware.ErrorResponse(w, http.StatusBadRequest, "score for "+optionID+" must be between 0 and 1")
-			return
+	if validateOnly {
+		middleware.JSONResponse(w, http.StatusOK, models.ValidateBallotResponse{Valid: true})
+		return
+	}
+
+	middleware.JSONResponse(w, http.StatusCreated, models.SubmitBallotResponse{
+		BallotID: submitted.ballotID,
+		Message:  submitted.message(),
+	})
+}
+
+// ballotSubmission is one ballot as submitted to SubmitBallot or SyncBallots
+type ballotSubmission struct {
+	shareSlug    string
+	voterToken   string
+	scores       json.RawMessage
+	validateOnly bool
+	ipHash       string
+	userAgent    string
+	deviceUUID   string // X-Device-UUID, empty without one or with device tracking disabled
+}
+
+// submittedBallot is a ballot submitBallot wrote
+type submittedBallot struct {
+	ballotID string
+	isUpdate bool
+}
+
+// message is the confirmation SubmitBallot sends for the ballot
+func (b submittedBallot) message() string {
+	if b.isUpdate {
+		return "Ballot updated successfully"
+	}
+	return "Ballot submitted successfully"
+}
+
+// ballotRefusal is why a ballot was not accepted: the status and error body
+// SubmitBallot answers with
+type ballotRefusal struct {
+	status     int
+	code       string
+	message    string
+	fields     []models.FieldError // for validation errors, which are always 400
+	retryAfter time.Duration       // sent as Retry-After when positive
+}
+
+// refuse returns a refusal with status, an optional code, and message
+func refuse(status int, code, message string) *ballotRefusal {
+	return &ballotRefusal{status: status, code: code, message: message}
+}
+
+// refuseFields returns the 400 refusal listing every invalid field
+func refuseFields(fields []models.FieldError) *ballotRefusal {
+	return &ballotRefusal{status: http.StatusBadRequest, fields: fields}
+}
+
+// write sends the refusal as an error response
+func (e *ballotRefusal) write(w http.ResponseWriter) {
+	if e.retryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(e.retryAfter.Seconds()))))
+	}
+	if len(e.fields) > 0 {
+		middleware.ValidationErrorResponse(w, e.fields)
+		return
+	}
+	middleware.ErrorResponseWithCode(w, e.status, e.code, e.message)
+}
+
+// body returns the error response write sends
+func (e *ballotRefusal) body() models.ErrorResponse {
+	resp := models.ErrorResponse{
+		Error:   http.StatusText(e.status),
+		Message: e.message,
+		Code:    e.code,
+		Fields:  e.fields,
+	}
+	if len(e.fields) > 0 {
+		resp.Message = e.fields[0].Message
+	}
+	return resp
+}
+
+// submitBallot validates a ballot and, unless validateOnly is set, creates
+// or updates it. Every check SubmitBallot makes happens here, so a ballot
+// synced in a batch is held to the same rules.
+func (h *VotingHandler) submitBallot(sub ballotSubmission) (submittedBallot, *ballotRefusal) {
+	scores, scoreErrs := decodeScores(sub.scores)
+	if scoreErrs.any() {
+		return submittedBallot{}, refuseFields(scoreErrs)
+	}
+	req := models.SubmitBallotRequest{Scores: scores}
+
+	// Bound the logical size of the ballot before doing any per-score work;
+	// no valid ballot can score more options than a poll may have
+	if h.cfg.MaxOptions > 0 && len(req.Scores) > h.cfg.MaxOptions {
+		return submittedBallot{}, refuseFields([]models.FieldError{{
+			Field:   "scores",
+			Code:    models.FieldCodeOutOfRange,
+			Message: fmt.Sprintf("scores cannot contain more than %d options", h.cfg.MaxOptions),
+		}})
+	}
+
+	var errs fieldErrors
+	if len(req.Scores) == 0 {
+		errs.add("scores", models.FieldCodeRequired, "scores cannot be empty")
+	}
+
+	// Validate all scores are in range [0, 1] (sorted for stable output)
+	optionIDs := make([]string, 0, len(req.Scores))
+	for optionID := range req.Scores {
+		optionIDs = append(optionIDs, optionID)
+	}
+	sort.Strings(optionIDs)
+	for _, optionID := range optionIDs {
+		if score := req.Scores[optionID]; score < 0 || score > 1 {
+			errs.add("scores."+optionID, models.FieldCodeOutOfRange, "score for "+optionID+" must be between 0 and 1")
 		}
 	}
 
+	if errs.any() {
+		return submittedBallot{}, refuseFields(errs)
+	}
+
 	// Find poll by share slug
 	var pollID string
 	var status string
+	var minScoredOptions int
+	var paused bool
+	var allowUpdates bool
+	var creatorCanVote bool
 	err := h.db.QueryRow(`
-		SELECT id, status FROM poll WHERE share_slug = $1
-	`, shareSlug).Scan(&pollID, &status)
+		SELECT id, status, min_scored_options, paused, allow_ballot_updates, creator_can_vote FROM poll WHERE `+slugMatch+`
+	`, sub.shareSlug).Scan(&pollID, &status, &minScoredOptions, &paused, &allowUpdates, &creatorCanVote)
 
 	if err == sql.ErrNoRows {
-		middleware.ErrorResponse(w, http.StatusNotFound, "Poll not found")
-		return
+		return submittedBallot{}, refusePollNotFound()
 	}
 	if err != nil {
 		slog.Error("failed to query poll", "error", err)
-		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
-		return
+		return submittedBallot{}, refuse(http.StatusInternalServerError, "", "Database error")
 	}
 
 	// Can only vote on open polls
 	if status != models.StatusOpen {
-		middleware.ErrorResponse(w, http.StatusConflict, "Poll is not open for voting")
-		return
+		return submittedBallot{}, refusePollNotOpen(status)
+	}
+	if paused {
+		return submittedBallot{}, refusePollPaused()
 	}
 
 	// Verify voter token is valid for this poll
@@ -265,27 +664,139 @@ func (h *VotingHandler) SubmitBallot(w http.ResponseWriter, r *http.Request) {
 			SELECT 1 FROM username_claim
 			WHERE poll_id = $1 AND voter_token = $2
 		)
-	`, pollID, voterToken).Scan(&exists)
+	`, pollID, sub.voterToken).Scan(&exists)
 
 	if err != nil {
 		slog.Error("failed to verify voter token", "error", err)
-		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
-		return
+		return submittedBallot{}, refuse(http.StatusInternalServerError, "", "Database error")
 	}
 
 	if !exists {
-		middleware.ErrorResponse(w, http.StatusUnauthorized, "Invalid voter token for this poll")
-		return
+		return submittedBallot{}, refuse(http.StatusUnauthorized, "", "Invalid voter token for this poll")
+	}
+
+	// The creator is recognized by a voter token their admin device
+	// claimed, or by that device sending the ballot
+	if !creatorCanVote {
+		creator, err := isCreatorVoter(h.db, pollID, sub.voterToken, sub.deviceUUID)
+		if err != nil {
+			slog.Error("failed to check creator device", "error", err)
+			return submittedBallot{}, refuse(http.StatusInternalServerError, "", "Database error")
+		}
+		if creator {
+			return submittedBallot{}, refuseCreatorVoting()
+		}
+	}
+
+	// Refuse early when the voter's ballot is already final; upsertBallot
+	// checks again so concurrent first submissions can't both land
+	if !allowUpdates {
+		var voted bool
+		err = h.db.QueryRow(`
+			SELECT EXISTS(SELECT 1 FROM ballot WHERE poll_id = $1 AND voter_token = $2)
+		`, pollID, sub.voterToken).Scan(&voted)
+		if err != nil {
+			slog.Error("failed to check existing ballot", "error", err)
+			return submittedBallot{}, refuse(http.StatusInternalServerError, "", "Database error")
+		}
+		if voted {
+			return submittedBallot{}, refuseBallotLocked()
+		}
 	}
 
 	// Get all valid option IDs for this poll
-	rows, err := h.db.Query(`
+	validOptions, err := pollOptionIDs(h.db, pollID)
+	if err != nil {
+		slog.Error("failed to query options", "error", err)
+		return submittedBallot{}, refuse(http.StatusInternalServerError, "", "Database error")
+	}
+
+	// An open poll always has options unless it was left in a broken
+	// state; say so rather than rejecting every score as unknown
+	if len(validOptions) == 0 {
+		return submittedBallot{}, refuse(http.StatusConflict, models.ErrorCodePollNoOptions,
+			"This poll has no options to score")
+	}
+
+	// Verify all submitted scores are for valid options
+	for _, optionID := range optionIDs {
+		if !validOptions[optionID] {
+			errs.add("scores."+optionID, models.FieldCodeInvalid, "Invalid option_id: "+optionID)
+		}
+	}
+	if errs.any() {
+		return submittedBallot{}, refuseFields(errs)
+	}
+
+	// Enforce the poll's minimum number of scored options
+	if len(optionIDs) < minScoredOptions {
+		return submittedBallot{}, refuse(http.StatusBadRequest, models.ErrorCodeTooFewScores,
+			fmt.Sprintf("Ballot must score at least %d options", minScoredOptions))
+	}
+
+	if sub.validateOnly {
+		return submittedBallot{}, nil
+	}
+
+	// Upsert the ballot; serialization failures and deadlocks are retried
+	limits := ballotLimits{
+		minInterval: time.Duration(h.cfg.MinBallotInterval) * time.Second,
+		maxPerIP:    h.cfg.MaxBallotsPerIP,
+		maxPerPoll:  h.cfg.MaxBallotsPerPoll,
+	}
+	var ballotID string
+	var isUpdate bool
+	for attempt := 1; ; attempt++ {
+		ballotID, isUpdate, err = upsertBallot(h.db, pollID, sub.voterToken, optionIDs, req.Scores, sub.ipHash, sub.userAgent, limits)
+		if err == nil || !isRetryableTxError(err) || attempt == maxBallotUpsertAttempts {
+			break
+		}
+		slog.Warn("retrying ballot upsert", "error", err, "poll_id", pollID, "attempt", attempt)
+	}
+	if err != nil {
+		var notOpen *pollNotOpenError
+		if errors.As(err, &notOpen) {
+			return submittedBallot{}, refusePollNotOpen(notOpen.status)
+		}
+		if errors.Is(err, errPollPaused) {
+			return submittedBallot{}, refusePollPaused()
+		}
+		var tooSoon *ballotTooSoonError
+		if errors.As(err, &tooSoon) {
+			return submittedBallot{}, refuseBallotTooSoon(tooSoon.retryAfter)
+		}
+		if errors.Is(err, errIPBallotLimit) {
+			return submittedBallot{}, refuse(http.StatusTooManyRequests, models.ErrorCodeIPBallotLimit,
+				fmt.Sprintf("No more than %d ballots may be cast from one network", limits.maxPerIP))
+		}
+		if errors.Is(err, errPollFull) {
+			return submittedBallot{}, refuse(http.StatusTooManyRequests, models.ErrorCodePollFull,
+				fmt.Sprintf("This poll has reached its limit of %d ballots", limits.maxPerPoll))
+		}
+		if errors.Is(err, errBallotLocked) {
+			return submittedBallot{}, refuseBallotLocked()
+		}
+		if isRetryableTxError(err) {
+			return submittedBallot{}, refuse(http.StatusConflict, "", "Ballot is being updated concurrently, please retry")
+		}
+		slog.Error("failed to upsert ballot", "error", err, "poll_id", pollID)
+		return submittedBallot{}, refuse(http.StatusInternalServerError, "", "Failed to submit ballot")
+	}
+
+	h.counts.Invalidate(pollID)
+
+	slog.Info("ballot submitted", "poll_id", pollID, "ballot_id", ballotID, "is_update", isUpdate)
+
+	return submittedBallot{ballotID: ballotID, isUpdate: isUpdate}, nil
+}
+
+// pollOptionIDs returns the set of the poll's option IDs
+func pollOptionIDs(db *sql.DB, pollID string) (map[string]bool, error) {
+	rows, err := db.Query(`
 		SELECT id FROM option WHERE poll_id = $1
 	`, pollID)
 	if err != nil {
-		slog.Error("failed to query options", "error", err)
-		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
-		return
+		return nil, err
 	}
 	defer rows.Close()
 
@@ -293,110 +804,514 @@ func (h *VotingHandler) SubmitBallot(w http.ResponseWriter, r *http.Request) {
 	for rows.Next() {
 		var optionID string
 		if err := rows.Scan(&optionID); err != nil {
-			slog.Error("failed to scan option", "error", err)
-			middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
-			return
+			return nil, err
 		}
 		validOptions[optionID] = true
 	}
+	return validOptions, rows.Err()
+}
 
-	// Verify all submitted scores are for valid options
-	for optionID := range req.Scores {
-		if !validOptions[optionID] {
-			middleware.ErrorResponse(w, http.StatusBadRequest, "Invalid option_id: "+optionID)
-			return
+// maxSyncBallots caps the ballots accepted by one SyncBallots request
+const maxSyncBallots = 50
+
+// SyncBallots handles POST /ballots:sync
+// Submits ballots a client queued while offline, each for its own poll and
+// with its own voter token, in request order. Each ballot is checked like
+// SubmitBallot and written in its own transaction, so one refused ballot
+// leaves the rest in place; its result carries the status and error body
+// SubmitBallot would have answered with.
+func (h *VotingHandler) SyncBallots(w http.ResponseWriter, r *http.Request) {
+	var req models.SyncBallotsRequest
+	if err := middleware.DecodeRequiredJSON(r, &req); err != nil {
+		middleware.ErrorResponse(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	var errs fieldErrors
+	if len(req.Ballots) == 0 {
+		errs.add("ballots", models.FieldCodeRequired, "ballots is required")
+	} else if len(req.Ballots) > maxSyncBallots {
+		errs.add("ballots", models.FieldCodeOutOfRange, fmt.Sprintf("ballots cannot list more than %d entries", maxSyncBallots))
+	}
+	if errs.any() {
+		middleware.ValidationErrorResponse(w, errs)
+		return
+	}
+
+	ipHash := auth.HashIP(middleware.ClientIP(r, h.cfg.TrustProxyHeaders), h.cfg.AdminKeySalt) // Reuse admin salt for IP hashing
+	results := make([]models.SyncBallotResult, len(req.Ballots))
+	for i, entry := range req.Ballots {
+		result := &results[i]
+		result.Slug = entry.Slug
+
+		var refusal *ballotRefusal
+		switch {
+		case entry.Slug == "":
+			refusal = refuse(http.StatusBadRequest, "", "slug is required")
+		case entry.VoterToken == "":
+			refusal = refuse(http.StatusUnauthorized, "", "voter_token is required")
+		default:
+			var submitted submittedBallot
+			submitted, refusal = h.submitBallot(ballotSubmission{
+				shareSlug:  entry.Slug,
+				voterToken: entry.VoterToken,
+				scores:     entry.Scores,
+				ipHash:     ipHash,
+				userAgent:  r.UserAgent(),
+				deviceUUID: requestDeviceUUID(h.cfg, r),
+			})
+			if refusal == nil {
+				result.Status = http.StatusCreated
+				result.BallotID = submitted.ballotID
+				result.Message = submitted.message()
+			}
+		}
+		if refusal != nil {
+			body := refusal.body()
+			result.Status = refusal.status
+			result.Error = &body
 		}
 	}
 
-	// Get IP hash for tracking
-	clientIP := middleware.GetClientIP(r)
-	ipHash := auth.HashIP(clientIP, h.cfg.AdminKeySalt) // Reuse admin salt for IP hashing
-	userAgent := r.UserAgent()
+	middleware.JSONResponse(w, http.StatusOK, models.SyncBallotsResponse{Results: results})
+}
 
-	// Begin transaction for UPSERT
-	tx, err := h.db.Begin()
-	if err != nil {
-		slog.Error("failed to begin transaction", "error", err)
-		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
-		return
+// decodeScores parses a ballot's scores object. A missing or null object,
+// a value that isn't an object, every option ID that appears more than
+// once, and every score that isn't a number are reported as field errors,
+// the scores sorted by option ID.
+func decodeScores(raw json.RawMessage) (map[string]float64, fieldErrors) {
+	var errs fieldErrors
+	if len(raw) == 0 || string(raw) == "null" {
+		errs.add("scores", models.FieldCodeRequired, "scores is required")
+		return nil, errs
 	}
-	defer tx.Rollback()
 
-	// Check if ballot already exists
-	var existingBallotID string
-	err = tx.QueryRow(`
-		SELECT id FROM ballot WHERE poll_id = $1 AND voter_token = $2
-	`, pollID, voterToken).Scan(&existingBallotID)
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &values); err != nil {
+		errs.add("scores", models.FieldCodeWrongType, "scores must be an object mapping option IDs to numbers")
+		return nil, errs
+	}
 
-	isUpdate := err != sql.ErrNoRows
-	var ballotID string
+	// Unmarshal keeps only the last value of a repeated key, which would
+	// hide a client bug behind a silently dropped score
+	for _, optionID := range duplicateKeys(raw) {
+		errs.add("scores."+optionID, models.FieldCodeDuplicateOptionKey, optionID+" is scored more than once")
+	}
+	if errs.any() {
+		return nil, errs
+	}
 
-	if isUpdate {
-		// Update existing ballot
-		ballotID = existingBallotID
-		_, err = tx.Exec(`
-			UPDATE ballot
-			SET submitted_at = $1, ip_hash = $2, user_agent = $3
-			WHERE id = $4
-		`, time.Now(), ipHash, userAgent, ballotID)
+	optionIDs := make([]string, 0, len(values))
+	for optionID := range values {
+		optionIDs = append(optionIDs, optionID)
+	}
+	sort.Strings(optionIDs)
 
-		if err != nil {
-			slog.Error("failed to update ballot", "error", err)
-			middleware.ErrorResponse(w, http.StatusInternalServerError, "Failed to update ballot")
-			return
+	scores := make(map[string]float64, len(values))
+	for _, optionID := range optionIDs {
+		var score float64
+		value := values[optionID]
+		if string(value) == "null" || json.Unmarshal(value, &score) != nil {
+			errs.add("scores."+optionID, models.FieldCodeWrongType, "score for "+optionID+" must be a number")
+			continue
 		}
+		scores[optionID] = score
+	}
+	return scores, errs
+}
 
-		// Delete old scores
-		_, err = tx.Exec(`DELETE FROM score WHERE ballot_id = $1`, ballotID)
+// duplicateKeys returns, sorted, the keys that appear more than once in the
+// JSON object raw. raw must already be known to be a valid object.
+func duplicateKeys(raw json.RawMessage) []string {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	if _, err := dec.Token(); err != nil { // opening brace
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	var duplicates []string
+	for dec.More() {
+		token, err := dec.Token()
 		if err != nil {
-			slog.Error("failed to delete old scores", "error", err)
-			middleware.ErrorResponse(w, http.StatusInternalServerError, "Failed to update ballot")
-			return
+			return duplicates
 		}
-	} else {
-		// Create new ballot
-		ballotID, _ = auth.GenerateID(16)
-		_, err = tx.Exec(`
-			INSERT INTO ballot (id, poll_id, voter_token, submitted_at, ip_hash, user_agent)
-			VALUES ($1, $2, $3, $4, $5, $6)
-		`, ballotID, pollID, voterToken, time.Now(), ipHash, userAgent)
+		key, _ := token.(string)
+		if seen[key] && !slices.Contains(duplicates, key) {
+			duplicates = append(duplicates, key)
+		}
+		seen[key] = true
 
-		if err != nil {
-			slog.Error("failed to insert ballot", "error", err)
-			middleware.ErrorResponse(w, http.StatusInternalServerError, "Failed to submit ballot")
-			return
+		// Skip the value, whatever its type
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return duplicates
 		}
 	}
+	sort.Strings(duplicates)
+	return duplicates
+}
 
-	// Insert scores
-	for optionID, score := range req.Scores {
-		_, err = tx.Exec(`
-			INSERT INTO score (ballot_id, option_id, value01)
-			VALUES ($1, $2, $3)
-		`, ballotID, optionID, score)
+// maxBallotUpsertAttempts bounds retries of a ballot upsert that lost a race
+const maxBallotUpsertAttempts = 3
 
-		if err != nil {
-			slog.Error("failed to insert score", "error", err)
-			middleware.ErrorResponse(w, http.StatusInternalServerError, "Failed to save scores")
-			return
+// pollNotOpenError reports that a poll stopped accepting ballots between
+// SubmitBallot's status check and its write
+type pollNotOpenError struct {
+	status string
+}
+
+func (e *pollNotOpenError) Error() string {
+	return "poll is " + e.status
+}
+
+// errPollPaused reports that the admin paused the poll between
+// SubmitBallot's status check and its write
+var errPollPaused = errors.New("poll is paused")
+
+// ballotLimits are the optional server-wide restrictions on ballot writes;
+// zero values disable them
+type ballotLimits struct {
+	minInterval time.Duration // between updates of one voter's ballot
+	maxPerIP    int           // distinct ballots per poll from one IP hash
+	maxPerPoll  int           // ballots one poll may hold
+}
+
+// errIPBallotLimit reports that a new ballot would exceed the per-IP limit
+var errIPBallotLimit = errors.New("ballot limit per IP reached")
+
+// errPollFull reports that a new ballot would exceed the per-poll limit
+var errPollFull = errors.New("ballot limit per poll reached")
+
+// errBallotLocked reports that the voter already has a ballot on a poll
+// that doesn't allow updates
+var errBallotLocked = errors.New("ballot already submitted")
+
+// ballotTooSoonError reports that the voter's ballot was last written less
+// than the minimum update interval ago
+type ballotTooSoonError struct {
+	retryAfter time.Duration
+}
+
+func (e *ballotTooSoonError) Error() string {
+	return "ballot updated too recently, retry in " + e.retryAfter.String()
+}
+
+// upsertBallot creates or replaces the voter's ballot and its scores in one
+// transaction. INSERT ... ON CONFLICT takes the ballot row lock up front, so
+// concurrent submits for one voter serialize instead of racing between a
+// read and a write. The poll row is share-locked and its status re-checked,
+// so a ballot can't commit into a poll that ClosePoll has already sealed.
+// An existing ballot written less than limits.minInterval ago is left
+// alone, and a new one is refused once limits.maxPerIP ballots share ipHash
+// or the poll holds limits.maxPerPoll ballots.
+// On a poll that doesn't allow updates an existing ballot is never replaced.
+// optionIDs must be sorted so score rows lock in a stable order.
+func upsertBallot(db *sql.DB, pollID, voterToken string, optionIDs []string, scores map[string]float64, ipHash, userAgent string, limits ballotLimits) (string, bool, error) {
+	newID, err := auth.GenerateID(recordIDBytes)
+	if err != nil {
+		return "", false, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return "", false, err
+	}
+	defer tx.Rollback()
+
+	// ClosePoll holds FOR UPDATE while it snapshots, so this waits for any
+	// close or pause in progress and then sees its result
+	var status string
+	var paused bool
+	var allowUpdates bool
+	err = tx.QueryRow(`
+		SELECT status, paused, allow_ballot_updates FROM poll WHERE id = $1 FOR SHARE
+	`, pollID).Scan(&status, &paused, &allowUpdates)
+	if err != nil {
+		return "", false, err
+	}
+	if status != models.StatusOpen {
+		return "", false, &pollNotOpenError{status: status}
+	}
+	if paused {
+		return "", false, errPollPaused
+	}
+
+	// Locking the existing ballot makes concurrent updates from one voter
+	// see each other's submitted_at. New ballots are never throttled.
+	if limits.minInterval > 0 || limits.maxPerIP > 0 || limits.maxPerPoll > 0 {
+		var submittedAt time.Time
+		err = tx.QueryRow(`
+			SELECT submitted_at FROM ballot
+			WHERE poll_id = $1 AND voter_token = $2
+			FOR UPDATE
+		`, pollID, voterToken).Scan(&submittedAt)
+		if err != nil && err != sql.ErrNoRows {
+			return "", false, err
+		}
+		exists := err == nil
+
+		if exists && limits.minInterval > 0 {
+			if wait := limits.minInterval - time.Since(submittedAt); wait > 0 {
+				return "", false, &ballotTooSoonError{retryAfter: wait}
+			}
+		}
+
+		// Updating a ballot never counts against the IP limit. The advisory
+		// lock serializes new ballots from one IP so they can't all pass
+		// the count at once.
+		if !exists && limits.maxPerIP > 0 {
+			if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext($1 || ':' || $2))`, pollID, ipHash); err != nil {
+				return "", false, err
+			}
+			var count int
+			err = tx.QueryRow(`
+				SELECT COUNT(*) FROM ballot WHERE poll_id = $1 AND ip_hash = $2
+			`, pollID, ipHash).Scan(&count)
+			if err != nil {
+				return "", false, err
+			}
+			if count >= limits.maxPerIP {
+				return "", false, errIPBallotLimit
+			}
+		}
+
+		// Likewise for the whole poll, taken after the IP lock so every
+		// new ballot acquires the two in the same order
+		if !exists && limits.maxPerPoll > 0 {
+			if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext($1))`, pollID); err != nil {
+				return "", false, err
+			}
+			var count int
+			err = tx.QueryRow(`
+				SELECT COUNT(*) FROM ballot WHERE poll_id = $1
+			`, pollID).Scan(&count)
+			if err != nil {
+				return "", false, err
+			}
+			if count >= limits.maxPerPoll {
+				return "", false, errPollFull
+			}
 		}
 	}
 
+	// xmax is zero only for a freshly inserted row. A voter token linked to
+	// the poll's admin device marks a test ballot: the creator trying out
+	// their own poll. When the poll doesn't allow updates the conflict
+	// clause matches nothing, so an existing ballot returns no row.
+	var ballotID string
+	var isUpdate bool
+	err = tx.QueryRow(`
+		INSERT INTO ballot (id, poll_id, voter_token, submitted_at, ip_hash, user_agent, is_test)
+		VALUES ($1, $2, $3, $4, $5, $6, EXISTS (
+			SELECT 1 FROM device_poll WHERE poll_id = $2 AND voter_token = $3 AND role = 'admin'
+		))
+		ON CONFLICT (poll_id, voter_token) DO UPDATE
+		SET submitted_at = EXCLUDED.submitted_at,
+		    ip_hash = EXCLUDED.ip_hash,
+		    user_agent = EXCLUDED.user_agent,
+		    is_test = EXCLUDED.is_test
+		WHERE $7::boolean
+		RETURNING id, xmax <> 0
+	`, newID, pollID, voterToken, time.Now().UTC(), ipHash, userAgent, allowUpdates).Scan(&ballotID, &isUpdate)
+	if err == sql.ErrNoRows {
+		return "", false, errBallotLocked
+	}
+	if err != nil {
+		return "", false, err
+	}
+
+	// Replace scores wholesale; options left out of an update are unscored
+	if _, err := tx.Exec(`DELETE FROM score WHERE ballot_id = $1`, ballotID); err != nil {
+		return "", false, err
+	}
+
+	values := make([]float64, len(optionIDs))
+	for i, optionID := range optionIDs {
+		values[i] = scores[optionID]
+	}
+	_, err = tx.Exec(`
+		INSERT INTO score (ballot_id, option_id, value01)
+		SELECT $1, option_id, value01
+		FROM unnest($2::text[], $3::real[]) AS s(option_id, value01)
+	`, ballotID, pq.Array(optionIDs), pq.Array(values))
+	if err != nil {
+		return "", false, err
+	}
+
 	if err := tx.Commit(); err != nil {
-		slog.Error("failed to commit transaction", "error", err)
-		middleware.ErrorResponse(w, http.StatusInternalServerError, "Failed to submit ballot")
+		return "", false, err
+	}
+	return ballotID, isUpdate, nil
+}
+
+// isRetryableTxError reports whether err is a PostgreSQL serialization
+// failure or deadlock, either of which succeeds if the transaction is rerun
+func isRetryableTxError(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	return pqErr.Code == "40001" || pqErr.Code == "40P01"
+}
+
+// pollNotFoundResponse writes the 404 for a slug matching no poll
+func pollNotFoundResponse(w http.ResponseWriter) {
+	refusePollNotFound().write(w)
+}
+
+// refusePollNotFound is the 404 for a slug matching no poll
+func refusePollNotFound() *ballotRefusal {
+	return refuse(http.StatusNotFound, models.ErrorCodePollNotFound, "Poll not found")
+}
+
+// isCreatorVoter reports whether the device linked to pollID as admin is
+// the one voting: voterToken was claimed from it, or deviceUUID is it.
+// Empty arguments match nothing.
+func isCreatorVoter(db *sql.DB, pollID, voterToken, deviceUUID string) (bool, error) {
+	var creator bool
+	err := db.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 FROM device_poll dp
+			JOIN device d ON d.id = dp.device_id
+			WHERE dp.poll_id = $1 AND dp.role = 'admin'
+			  AND ((dp.voter_token = $2 AND $2 <> '') OR (d.device_uuid = $3 AND $3 <> ''))
+		)
+	`, pollID, voterToken, deviceUUID).Scan(&creator)
+	return creator, err
+}
+
+// refuseCreatorVoting is the 403 for the admin device voting on a poll
+// created with creator_can_vote set to false
+func refuseCreatorVoting() *ballotRefusal {
+	return refuse(http.StatusForbidden, models.ErrorCodeCreatorVoting,
+		"The poll's creator has chosen not to vote on it")
+}
+
+// refuseBallotLocked is the 409 for a second ballot on a poll that doesn't
+// allow updates
+func refuseBallotLocked() *ballotRefusal {
+	return refuse(http.StatusConflict, models.ErrorCodeBallotLocked,
+		"Your ballot has already been submitted and cannot be changed")
+}
+
+// refuseBallotTooSoon is the 429 for a ballot update inside the minimum
+// interval, with Retry-After rounded up to whole seconds
+func refuseBallotTooSoon(retryAfter time.Duration) *ballotRefusal {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	refusal := refuse(http.StatusTooManyRequests, models.ErrorCodeBallotTooSoon,
+		fmt.Sprintf("Ballot was updated too recently, retry in %d seconds", seconds))
+	refusal.retryAfter = retryAfter
+	return refusal
+}
+
+// pollPausedResponse writes the 409 for voting on an open poll its admin
+// has paused
+func pollPausedResponse(w http.ResponseWriter) {
+	refusePollPaused().write(w)
+}
+
+// refusePollPaused is the 409 for voting on an open poll its admin has
+// paused
+func refusePollPaused() *ballotRefusal {
+	return refuse(http.StatusConflict, models.ErrorCodePollPaused, "Voting on this poll is paused")
+}
+
+// pollNotOpenResponse writes the 409 for voting on a poll that isn't open,
+// with a code telling clients whether voting hasn't started or has ended
+func pollNotOpenResponse(w http.ResponseWriter, status string) {
+	refusePollNotOpen(status).write(w)
+}
+
+// refusePollNotOpen is the 409 for voting on a poll that isn't open
+func refusePollNotOpen(status string) *ballotRefusal {
+	code, message := models.ErrorCodePollClosed, "Poll is closed for voting"
+	if status == models.StatusDraft {
+		code, message = models.ErrorCodePollDraft, "Poll is not open for voting yet"
+	}
+	return refuse(http.StatusConflict, code, message)
+}
+
+// maxVoterPollTokens caps the tokens accepted by one GetVoterPolls request
+const maxVoterPollTokens = 50
+
+// GetVoterPolls handles GET /voters/my-polls
+// Lists the polls a voter joined, for voters who kept their tokens but have
+// no registered device. Tokens are scoped to one poll each, so several may
+// be sent: comma-separated in X-Voter-Token, or as voter_tokens in the
+// body. Tokens that match no claim are skipped without comment.
+func (h *VotingHandler) GetVoterPolls(w http.ResponseWriter, r *http.Request) {
+	var req models.GetVoterPollsRequest
+	if err := middleware.DecodeOptionalJSON(r, &req); err != nil {
+		middleware.ErrorResponse(w, http.StatusBadRequest, "Invalid JSON")
 		return
 	}
 
-	message := "Ballot submitted successfully"
-	if isUpdate {
-		message = "Ballot updated successfully"
+	var tokens []string
+	for _, token := range append(strings.Split(r.Header.Get("X-Voter-Token"), ","), req.VoterTokens...) {
+		if token = strings.TrimSpace(token); token != "" && !slices.Contains(tokens, token) {
+			tokens = append(tokens, token)
+		}
+	}
+	if len(tokens) == 0 {
+		middleware.ErrorResponse(w, http.StatusUnauthorized, "X-Voter-Token header required")
+		return
+	}
+	if len(tokens) > maxVoterPollTokens {
+		middleware.ValidationErrorResponse(w, []models.FieldError{{
+			Field:   "voter_tokens",
+			Code:    models.FieldCodeOutOfRange,
+			Message: fmt.Sprintf("cannot look up more than %d voter tokens", maxVoterPollTokens),
+		}})
+		return
 	}
 
-	slog.Info("ballot submitted", "poll_id", pollID, "ballot_id", ballotID, "is_update", isUpdate)
+	rows, err := h.db.Query(`
+		SELECT
+			p.id,
+			p.title,
+			p.status,
+			p.share_slug,
+			uc.username,
+			EXISTS (SELECT 1 FROM ballot b WHERE b.poll_id = p.id AND b.voter_token = uc.voter_token),
+			(SELECT COUNT(*) FROM ballot b WHERE b.poll_id = p.id),
+			uc.created_at
+		FROM username_claim uc
+		JOIN poll p ON uc.poll_id = p.id
+		WHERE uc.voter_token = ANY($1)
+		ORDER BY uc.created_at DESC
+	`, pq.Array(tokens))
+	if err != nil {
+		slog.Error("failed to query voter polls", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer rows.Close()
 
-	middleware.JSONResponse(w, http.StatusCreated, models.SubmitBallotResponse{
-		BallotID: ballotID,
-		Message:  message,
-	})
+	polls := []models.VoterPollSummary{}
+	for rows.Next() {
+		var summary models.VoterPollSummary
+		if err := rows.Scan(
+			&summary.PollID,
+			&summary.Title,
+			&summary.Status,
+			&summary.ShareSlug,
+			&summary.Username,
+			&summary.HasVoted,
+			&summary.BallotCount,
+			&summary.ClaimedAt,
+		); err != nil {
+			slog.Error("failed to scan voter poll", "error", err)
+			middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+		polls = append(polls, summary)
+	}
+	if err := rows.Err(); err != nil {
+		slog.Error("failed to iterate voter polls", "error", err)
+		middleware.ErrorResponse(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	middleware.JSONResponse(w, http.StatusOK, models.GetVoterPollsResponse{Polls: polls})
 }
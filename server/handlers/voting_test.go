@@ -5,9 +5,13 @@ package handlers
 
 import (
 	"bytes"
+	"database/sql"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -215,6 +219,81 @@ func TestClaimUsernameForClosedPoll(t *testing.T) {
 	}
 }
 
+func TestClaimUsernameLengthBounds(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	long := strings.Repeat("a", 60)
+	tests := []struct {
+		name       string
+		maxLength  int
+		pollMax    int // 0 leaves the poll on the server's bounds
+		wantStatus int
+	}{
+		{"default rejects 60 characters", 0, 0, http.StatusBadRequest},
+		{"configured max accepts 60 characters", 80, 0, http.StatusCreated},
+		{"poll narrows the configured max", 80, 40, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := getTestConfig()
+			cfg.MaxUsernameLength = tt.maxLength
+			handler := NewVotingHandler(db, cfg)
+
+			pollID, _ := auth.GenerateID(16)
+			shareSlug := auth.GenerateShareSlug(pollID, cfg.PollSlugSalt)
+			_, err := db.Exec(`
+				INSERT INTO poll (id, title, creator_name, status, share_slug, created_at, max_username_length)
+				VALUES ($1, 'Test Poll', 'Alice', 'open', $2, $3, $4)
+			`, pollID, shareSlug, time.Now(), sql.NullInt64{Int64: int64(tt.pollMax), Valid: tt.pollMax != 0})
+			if err != nil {
+				t.Fatalf("Failed to create test poll: %v", err)
+			}
+
+			body, _ := json.Marshal(models.ClaimUsernameRequest{Username: long})
+			req := httptest.NewRequest("POST", "/polls/"+shareSlug+"/claim-username", bytes.NewReader(body))
+			req.SetPathValue("slug", shareSlug)
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			handler.ClaimUsername(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestUsernameBounds(t *testing.T) {
+	long := strings.Repeat("a", 60)
+
+	cfg := getTestConfig()
+	if msg := usernameBounds(cfg, nil, nil).usernameError(long); msg != "username must be 2-50 characters" {
+		t.Errorf("default bounds: got %q", msg)
+	}
+
+	cfg.MaxUsernameLength = 80
+	three, ten, forty, over := 3, 10, 40, 81
+	if msg := usernameBounds(cfg, nil, nil).usernameError(long); msg != "" {
+		t.Errorf("max 80: got %q, want accepted", msg)
+	}
+	if msg := usernameBounds(cfg, &three, &forty).usernameError(long); msg != "username must be 3-40 characters" {
+		t.Errorf("poll bounds: got %q", msg)
+	}
+
+	var errs fieldErrors
+	validateUsernameBounds(&errs, cfg, nil, &over)
+	validateUsernameBounds(&errs, cfg, &ten, &ten)
+	if len(errs) != 2 {
+		t.Fatalf("Expected 2 field errors, got %+v", errs)
+	}
+	if errs[0].Field != "max_username_length" || errs[1].Field != "max_username_length" {
+		t.Errorf("Expected errors on max_username_length, got %+v", errs)
+	}
+}
+
 func TestSubmitBallot(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Close()
@@ -759,3 +838,1056 @@ func TestSubmitBallotToClosedPoll(t *testing.T) {
 		t.Errorf("Expected status %d, got %d", http.StatusConflict, w.Code)
 	}
 }
+
+func TestSubmitBallotTooManyScores(t *testing.T) {
+	cfg := getTestConfig()
+	cfg.MaxOptions = 3
+
+	// A nil database proves the guard runs before any query
+	handler := NewVotingHandler(nil, cfg)
+
+	scores := map[string]float64{}
+	for i := 0; i < 4; i++ {
+		optionID, _ := auth.GenerateID(12)
+		scores[optionID] = 0.5
+	}
+	body, _ := json.Marshal(models.SubmitBallotRequest{Scores: scores})
+
+	req := httptest.NewRequest("POST", "/polls/some-slug/ballots", bytes.NewReader(body))
+	req.SetPathValue("slug", "some-slug")
+	req.Header.Set("X-Voter-Token", "some-token")
+	w := httptest.NewRecorder()
+
+	handler.SubmitBallot(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusBadRequest, w.Code, w.Body.String())
+	}
+
+	var resp models.ErrorResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if len(resp.Fields) != 1 || resp.Fields[0].Field != "scores" || resp.Fields[0].Code != models.FieldCodeOutOfRange {
+		t.Errorf("Expected a single out_of_range error on scores, got %+v", resp.Fields)
+	}
+}
+
+func TestVotingOnUnopenedPollReportsStatusCode(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	cfg := getTestConfig()
+	handler := NewVotingHandler(db, cfg)
+
+	createPoll := func(status string) string {
+		pollID, _ := auth.GenerateID(16)
+		shareSlug := auth.GenerateShareSlug(pollID, cfg.PollSlugSalt)
+		_, err := db.Exec(`
+			INSERT INTO poll (id, title, creator_name, status, share_slug, created_at)
+			VALUES ($1, 'Status Poll', 'Alice', $2, $3, $4)
+		`, pollID, status, shareSlug, time.Now())
+		if err != nil {
+			t.Fatalf("Failed to create test poll: %v", err)
+		}
+		return shareSlug
+	}
+
+	decodeError := func(t *testing.T, w *httptest.ResponseRecorder) models.ErrorResponse {
+		var resp models.ErrorResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("Failed to decode error: %v", err)
+		}
+		return resp
+	}
+
+	testCases := []struct {
+		status       string
+		expectedCode string
+	}{
+		{models.StatusDraft, models.ErrorCodePollDraft},
+		{models.StatusClosed, models.ErrorCodePollClosed},
+	}
+
+	for _, tc := range testCases {
+		shareSlug := createPoll(tc.status)
+
+		t.Run("claim on "+tc.status, func(t *testing.T) {
+			body, _ := json.Marshal(models.ClaimUsernameRequest{Username: "bob"})
+			req := httptest.NewRequest("POST", "/polls/"+shareSlug+"/claim-username", bytes.NewReader(body))
+			req.SetPathValue("slug", shareSlug)
+			w := httptest.NewRecorder()
+
+			handler.ClaimUsername(w, req)
+
+			if w.Code != http.StatusConflict {
+				t.Fatalf("Expected status %d, got %d", http.StatusConflict, w.Code)
+			}
+			if resp := decodeError(t, w); resp.Code != tc.expectedCode {
+				t.Errorf("Expected code %q, got %q", tc.expectedCode, resp.Code)
+			}
+		})
+
+		t.Run("ballot on "+tc.status, func(t *testing.T) {
+			body, _ := json.Marshal(models.SubmitBallotRequest{Scores: map[string]float64{"some-option": 0.5}})
+			req := httptest.NewRequest("POST", "/polls/"+shareSlug+"/ballots", bytes.NewReader(body))
+			req.SetPathValue("slug", shareSlug)
+			req.Header.Set("X-Voter-Token", "some-token")
+			w := httptest.NewRecorder()
+
+			handler.SubmitBallot(w, req)
+
+			if w.Code != http.StatusConflict {
+				t.Fatalf("Expected status %d, got %d", http.StatusConflict, w.Code)
+			}
+			if resp := decodeError(t, w); resp.Code != tc.expectedCode {
+				t.Errorf("Expected code %q, got %q", tc.expectedCode, resp.Code)
+			}
+		})
+	}
+
+	t.Run("unknown slug", func(t *testing.T) {
+		body, _ := json.Marshal(models.ClaimUsernameRequest{Username: "bob"})
+		req := httptest.NewRequest("POST", "/polls/no-such-poll/claim-username", bytes.NewReader(body))
+		req.SetPathValue("slug", "no-such-poll")
+		w := httptest.NewRecorder()
+
+		handler.ClaimUsername(w, req)
+
+		if w.Code != http.StatusNotFound {
+			t.Fatalf("Expected status %d, got %d", http.StatusNotFound, w.Code)
+		}
+		if resp := decodeError(t, w); resp.Code != models.ErrorCodePollNotFound {
+			t.Errorf("Expected code %q, got %q", models.ErrorCodePollNotFound, resp.Code)
+		}
+	})
+}
+
+func TestSubmitBallotTooFewScores(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	cfg := getTestConfig()
+	handler := NewVotingHandler(db, cfg)
+
+	// Create an open poll requiring two scored options
+	pollID, _ := auth.GenerateID(16)
+	shareSlug := auth.GenerateShareSlug(pollID, cfg.PollSlugSalt)
+	_, err := db.Exec(`
+		INSERT INTO poll (id, title, creator_name, status, share_slug, created_at, min_scored_options)
+		VALUES ($1, 'Strict Poll', 'Alice', 'open', $2, $3, 2)
+	`, pollID, shareSlug, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to create test poll: %v", err)
+	}
+
+	optionA, _ := auth.GenerateID(12)
+	optionB, _ := auth.GenerateID(12)
+	_, err = db.Exec(`
+		INSERT INTO option (id, poll_id, label)
+		VALUES ($1, $3, 'Option A'), ($2, $3, 'Option B')
+	`, optionA, optionB, pollID)
+	if err != nil {
+		t.Fatalf("Failed to create options: %v", err)
+	}
+
+	voterToken, _ := auth.GenerateVoterToken()
+	_, err = db.Exec(`
+		INSERT INTO username_claim (poll_id, username, voter_token, created_at)
+		VALUES ($1, 'voter1', $2, $3)
+	`, pollID, voterToken, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to create username claim: %v", err)
+	}
+
+	submit := func(scores map[string]float64) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(models.SubmitBallotRequest{Scores: scores})
+		req := httptest.NewRequest("POST", "/polls/"+shareSlug+"/ballots", bytes.NewReader(body))
+		req.SetPathValue("slug", shareSlug)
+		req.Header.Set("X-Voter-Token", voterToken)
+		w := httptest.NewRecorder()
+		handler.SubmitBallot(w, req)
+		return w
+	}
+
+	w := submit(map[string]float64{optionA: 0.5})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusBadRequest, w.Code, w.Body.String())
+	}
+	var resp models.ErrorResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Code != models.ErrorCodeTooFewScores {
+		t.Errorf("Expected code %q, got %q", models.ErrorCodeTooFewScores, resp.Code)
+	}
+
+	var count int
+	db.QueryRow(`SELECT COUNT(*) FROM ballot WHERE poll_id = $1`, pollID).Scan(&count)
+	if count != 0 {
+		t.Errorf("Expected no ballot to be stored, got %d", count)
+	}
+
+	w = submit(map[string]float64{optionA: 0.5, optionB: 0.8})
+	if w.Code != http.StatusCreated {
+		t.Errorf("Expected status %d, got %d. Body: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+}
+
+func TestSubmitBallotPollWithoutOptions(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	cfg := getTestConfig()
+	handler := NewVotingHandler(db, cfg)
+
+	// An open poll whose options have all gone missing
+	pollID, _ := auth.GenerateID(16)
+	shareSlug := auth.GenerateShareSlug(pollID, cfg.PollSlugSalt)
+	_, err := db.Exec(`
+		INSERT INTO poll (id, title, creator_name, status, share_slug, created_at)
+		VALUES ($1, 'Empty Poll', 'Alice', 'open', $2, $3)
+	`, pollID, shareSlug, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to create test poll: %v", err)
+	}
+
+	voterToken, _ := auth.GenerateVoterToken()
+	_, err = db.Exec(`
+		INSERT INTO username_claim (poll_id, username, voter_token, created_at)
+		VALUES ($1, 'voter1', $2, $3)
+	`, pollID, voterToken, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to create username claim: %v", err)
+	}
+
+	body, _ := json.Marshal(models.SubmitBallotRequest{Scores: map[string]float64{"opt1": 0.5}})
+	req := httptest.NewRequest("POST", "/polls/"+shareSlug+"/ballots", bytes.NewReader(body))
+	req.SetPathValue("slug", shareSlug)
+	req.Header.Set("X-Voter-Token", voterToken)
+	w := httptest.NewRecorder()
+	handler.SubmitBallot(w, req)
+
+	if w.Code != http.StatusConflict {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusConflict, w.Code, w.Body.String())
+	}
+	var resp models.ErrorResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Code != models.ErrorCodePollNoOptions {
+		t.Errorf("Expected code %q, got %q", models.ErrorCodePollNoOptions, resp.Code)
+	}
+	if len(resp.Fields) != 0 {
+		t.Errorf("Expected no per-option errors, got %+v", resp.Fields)
+	}
+}
+
+func TestSubmitBallotValidateOnly(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	cfg := getTestConfig()
+	handler := NewVotingHandler(db, cfg)
+
+	// Create an open poll with one option and a claimed voter
+	pollID, _ := auth.GenerateID(16)
+	shareSlug := auth.GenerateShareSlug(pollID, cfg.PollSlugSalt)
+	_, err := db.Exec(`
+		INSERT INTO poll (id, title, creator_name, status, share_slug, created_at)
+		VALUES ($1, 'Validate Poll', 'Alice', 'open', $2, $3)
+	`, pollID, shareSlug, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to create test poll: %v", err)
+	}
+
+	optionID, _ := auth.GenerateID(12)
+	_, err = db.Exec(`
+		INSERT INTO option (id, poll_id, label)
+		VALUES ($1, $2, 'Option A')
+	`, optionID, pollID)
+	if err != nil {
+		t.Fatalf("Failed to create option: %v", err)
+	}
+
+	voterToken, _ := auth.GenerateVoterToken()
+	_, err = db.Exec(`
+		INSERT INTO username_claim (poll_id, username, voter_token, created_at)
+		VALUES ($1, 'voter1', $2, $3)
+	`, pollID, voterToken, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to create username claim: %v", err)
+	}
+
+	validate := func(scores map[string]float64) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(models.SubmitBallotRequest{Scores: scores})
+		req := httptest.NewRequest("POST", "/polls/"+shareSlug+"/ballots?validate_only=true", bytes.NewReader(body))
+		req.SetPathValue("slug", shareSlug)
+		req.Header.Set("X-Voter-Token", voterToken)
+		w := httptest.NewRecorder()
+		handler.SubmitBallot(w, req)
+		return w
+	}
+
+	ballotCount := func() int {
+		var count int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM ballot WHERE poll_id = $1`, pollID).Scan(&count); err != nil {
+			t.Fatalf("Failed to count ballots: %v", err)
+		}
+		return count
+	}
+
+	t.Run("out of range score", func(t *testing.T) {
+		w := validate(map[string]float64{optionID: 1.5})
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusBadRequest, w.Code, w.Body.String())
+		}
+		var resp models.ErrorResponse
+		json.NewDecoder(w.Body).Decode(&resp)
+		if len(resp.Fields) != 1 || resp.Fields[0].Code != models.FieldCodeOutOfRange {
+			t.Errorf("Expected a single out_of_range error, got %+v", resp.Fields)
+		}
+	})
+
+	t.Run("valid ballot", func(t *testing.T) {
+		w := validate(map[string]float64{optionID: 0.5})
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		var resp models.ValidateBallotResponse
+		json.NewDecoder(w.Body).Decode(&resp)
+		if !resp.Valid {
+			t.Error("Expected valid to be true")
+		}
+	})
+
+	if count := ballotCount(); count != 0 {
+		t.Errorf("Expected validate_only to write no ballots, got %d", count)
+	}
+}
+
+func TestSubmitBallotUpdateThrottled(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	cfg := getTestConfig()
+	cfg.MinBallotInterval = 10
+	handler := NewVotingHandler(db, cfg)
+
+	pollID, _ := auth.GenerateID(16)
+	shareSlug := auth.GenerateShareSlug(pollID, cfg.PollSlugSalt)
+	_, err := db.Exec(`
+		INSERT INTO poll (id, title, creator_name, status, share_slug, created_at)
+		VALUES ($1, 'Throttled Poll', 'Alice', 'open', $2, $3)
+	`, pollID, shareSlug, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to create test poll: %v", err)
+	}
+
+	optionA, _ := auth.GenerateID(12)
+	_, err = db.Exec(`
+		INSERT INTO option (id, poll_id, label) VALUES ($1, $2, 'Option A')
+	`, optionA, pollID)
+	if err != nil {
+		t.Fatalf("Failed to create option: %v", err)
+	}
+
+	voterToken, _ := auth.GenerateVoterToken()
+	_, err = db.Exec(`
+		INSERT INTO username_claim (poll_id, username, voter_token, created_at)
+		VALUES ($1, 'voter1', $2, $3)
+	`, pollID, voterToken, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to create username claim: %v", err)
+	}
+
+	submit := func(score float64) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(models.SubmitBallotRequest{Scores: map[string]float64{optionA: score}})
+		req := httptest.NewRequest("POST", "/polls/"+shareSlug+"/ballots", bytes.NewReader(body))
+		req.SetPathValue("slug", shareSlug)
+		req.Header.Set("X-Voter-Token", voterToken)
+		w := httptest.NewRecorder()
+		handler.SubmitBallot(w, req)
+		return w
+	}
+
+	// The first ballot is never throttled
+	w := submit(0.2)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	w = submit(0.9)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusTooManyRequests, w.Code, w.Body.String())
+	}
+	retryAfter, err := strconv.Atoi(w.Header().Get("Retry-After"))
+	if err != nil || retryAfter < 1 || retryAfter > 10 {
+		t.Errorf("Expected Retry-After between 1 and 10 seconds, got %q", w.Header().Get("Retry-After"))
+	}
+	var resp models.ErrorResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Code != models.ErrorCodeBallotTooSoon {
+		t.Errorf("Expected code %q, got %q", models.ErrorCodeBallotTooSoon, resp.Code)
+	}
+
+	// The throttled update must not have replaced the stored score
+	var value float64
+	err = db.QueryRow(`
+		SELECT s.value01 FROM score s JOIN ballot b ON b.id = s.ballot_id
+		WHERE b.poll_id = $1 AND s.option_id = $2
+	`, pollID, optionA).Scan(&value)
+	if err != nil {
+		t.Fatalf("Failed to read score: %v", err)
+	}
+	if value < 0.19 || value > 0.21 {
+		t.Errorf("Expected the original score 0.2 to remain, got %v", value)
+	}
+}
+
+func TestSubmitBallotLocked(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	cfg := getTestConfig()
+	handler := NewVotingHandler(db, cfg)
+
+	for _, tt := range []struct {
+		name         string
+		allowUpdates bool
+		wantStatus   int
+	}{
+		{"updates allowed", true, http.StatusCreated},
+		{"updates locked", false, http.StatusConflict},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			pollID, _ := auth.GenerateID(16)
+			shareSlug := auth.GenerateShareSlug(pollID, cfg.PollSlugSalt)
+			_, err := db.Exec(`
+				INSERT INTO poll (id, title, creator_name, status, share_slug, created_at, allow_ballot_updates)
+				VALUES ($1, 'Locked Poll', 'Alice', 'open', $2, $3, $4)
+			`, pollID, shareSlug, time.Now(), tt.allowUpdates)
+			if err != nil {
+				t.Fatalf("Failed to create test poll: %v", err)
+			}
+
+			optionA, _ := auth.GenerateID(12)
+			_, err = db.Exec(`
+				INSERT INTO option (id, poll_id, label) VALUES ($1, $2, 'Option A')
+			`, optionA, pollID)
+			if err != nil {
+				t.Fatalf("Failed to create option: %v", err)
+			}
+
+			voterToken, _ := auth.GenerateVoterToken()
+			_, err = db.Exec(`
+				INSERT INTO username_claim (poll_id, username, voter_token, created_at)
+				VALUES ($1, 'voter1', $2, $3)
+			`, pollID, voterToken, time.Now())
+			if err != nil {
+				t.Fatalf("Failed to create username claim: %v", err)
+			}
+
+			submit := func(score float64) *httptest.ResponseRecorder {
+				body, _ := json.Marshal(models.SubmitBallotRequest{Scores: map[string]float64{optionA: score}})
+				req := httptest.NewRequest("POST", "/polls/"+shareSlug+"/ballots", bytes.NewReader(body))
+				req.SetPathValue("slug", shareSlug)
+				req.Header.Set("X-Voter-Token", voterToken)
+				w := httptest.NewRecorder()
+				handler.SubmitBallot(w, req)
+				return w
+			}
+
+			// The first ballot is always accepted
+			w := submit(0.2)
+			if w.Code != http.StatusCreated {
+				t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusCreated, w.Code, w.Body.String())
+			}
+
+			w = submit(0.9)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d. Body: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+
+			want := 0.9
+			if !tt.allowUpdates {
+				var resp models.ErrorResponse
+				json.NewDecoder(w.Body).Decode(&resp)
+				if resp.Code != models.ErrorCodeBallotLocked {
+					t.Errorf("Expected code %q, got %q", models.ErrorCodeBallotLocked, resp.Code)
+				}
+				want = 0.2
+			}
+
+			var value float64
+			err = db.QueryRow(`
+				SELECT s.value01 FROM score s JOIN ballot b ON b.id = s.ballot_id
+				WHERE b.poll_id = $1 AND s.option_id = $2
+			`, pollID, optionA).Scan(&value)
+			if err != nil {
+				t.Fatalf("Failed to read score: %v", err)
+			}
+			if value < want-0.01 || value > want+0.01 {
+				t.Errorf("Expected stored score %v, got %v", want, value)
+			}
+		})
+	}
+}
+
+func TestSubmitBallotIPLimit(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	cfg := getTestConfig()
+	cfg.MaxBallotsPerIP = 2
+	handler := NewVotingHandler(db, cfg)
+
+	pollID, _ := auth.GenerateID(16)
+	shareSlug := auth.GenerateShareSlug(pollID, cfg.PollSlugSalt)
+	_, err := db.Exec(`
+		INSERT INTO poll (id, title, creator_name, status, share_slug, created_at)
+		VALUES ($1, 'Limited Poll', 'Alice', 'open', $2, $3)
+	`, pollID, shareSlug, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to create test poll: %v", err)
+	}
+
+	optionA, _ := auth.GenerateID(12)
+	_, err = db.Exec(`
+		INSERT INTO option (id, poll_id, label) VALUES ($1, $2, 'Option A')
+	`, optionA, pollID)
+	if err != nil {
+		t.Fatalf("Failed to create option: %v", err)
+	}
+
+	tokens := make([]string, 3)
+	for i := range tokens {
+		tokens[i], _ = auth.GenerateVoterToken()
+		_, err = db.Exec(`
+			INSERT INTO username_claim (poll_id, username, voter_token, created_at)
+			VALUES ($1, $2, $3, $4)
+		`, pollID, fmt.Sprintf("voter%d", i+1), tokens[i], time.Now())
+		if err != nil {
+			t.Fatalf("Failed to create username claim: %v", err)
+		}
+	}
+
+	// httptest requests all come from the same RemoteAddr
+	submit := func(voterToken string, score float64) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(models.SubmitBallotRequest{Scores: map[string]float64{optionA: score}})
+		req := httptest.NewRequest("POST", "/polls/"+shareSlug+"/ballots", bytes.NewReader(body))
+		req.SetPathValue("slug", shareSlug)
+		req.Header.Set("X-Voter-Token", voterToken)
+		w := httptest.NewRecorder()
+		handler.SubmitBallot(w, req)
+		return w
+	}
+
+	for _, token := range tokens[:2] {
+		if w := submit(token, 0.5); w.Code != http.StatusCreated {
+			t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusCreated, w.Code, w.Body.String())
+		}
+	}
+
+	w := submit(tokens[2], 0.5)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusTooManyRequests, w.Code, w.Body.String())
+	}
+	var resp models.ErrorResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Code != models.ErrorCodeIPBallotLimit {
+		t.Errorf("Expected code %q, got %q", models.ErrorCodeIPBallotLimit, resp.Code)
+	}
+
+	// Updates don't count against the limit
+	if w := submit(tokens[0], 0.9); w.Code != http.StatusCreated {
+		t.Errorf("Expected update status %d, got %d. Body: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM ballot WHERE poll_id = $1`, pollID).Scan(&count); err != nil {
+		t.Fatalf("Failed to count ballots: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 ballots, got %d", count)
+	}
+}
+
+func TestSubmitBallotPollLimit(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	cfg := getTestConfig()
+	cfg.MaxBallotsPerPoll = 2
+	handler := NewVotingHandler(db, cfg)
+
+	pollID, _ := auth.GenerateID(16)
+	shareSlug := auth.GenerateShareSlug(pollID, cfg.PollSlugSalt)
+	_, err := db.Exec(`
+		INSERT INTO poll (id, title, creator_name, status, share_slug, created_at)
+		VALUES ($1, 'Full Poll', 'Alice', 'open', $2, $3)
+	`, pollID, shareSlug, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to create test poll: %v", err)
+	}
+
+	optionA, _ := auth.GenerateID(12)
+	_, err = db.Exec(`
+		INSERT INTO option (id, poll_id, label) VALUES ($1, $2, 'Option A')
+	`, optionA, pollID)
+	if err != nil {
+		t.Fatalf("Failed to create option: %v", err)
+	}
+
+	tokens := make([]string, 3)
+	for i := range tokens {
+		tokens[i], _ = auth.GenerateVoterToken()
+		_, err = db.Exec(`
+			INSERT INTO username_claim (poll_id, username, voter_token, created_at)
+			VALUES ($1, $2, $3, $4)
+		`, pollID, fmt.Sprintf("voter%d", i+1), tokens[i], time.Now())
+		if err != nil {
+			t.Fatalf("Failed to create username claim: %v", err)
+		}
+	}
+
+	// Seed the poll up to the limit
+	for i, token := range tokens[:2] {
+		ballotID, _ := auth.GenerateID(16)
+		_, err = db.Exec(`
+			INSERT INTO ballot (id, poll_id, voter_token, submitted_at, ip_hash)
+			VALUES ($1, $2, $3, $4, $5)
+		`, ballotID, pollID, token, time.Now(), fmt.Sprintf("ip%d", i))
+		if err != nil {
+			t.Fatalf("Failed to create ballot: %v", err)
+		}
+	}
+
+	submit := func(voterToken string, score float64) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(models.SubmitBallotRequest{Scores: map[string]float64{optionA: score}})
+		req := httptest.NewRequest("POST", "/polls/"+shareSlug+"/ballots", bytes.NewReader(body))
+		req.SetPathValue("slug", shareSlug)
+		req.Header.Set("X-Voter-Token", voterToken)
+		w := httptest.NewRecorder()
+		handler.SubmitBallot(w, req)
+		return w
+	}
+
+	w := submit(tokens[2], 0.5)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusTooManyRequests, w.Code, w.Body.String())
+	}
+	var resp models.ErrorResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Code != models.ErrorCodePollFull {
+		t.Errorf("Expected code %q, got %q", models.ErrorCodePollFull, resp.Code)
+	}
+
+	// Existing voters can still update
+	w = submit(tokens[0], 0.9)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected update status %d, got %d. Body: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	var submitResp models.SubmitBallotResponse
+	json.NewDecoder(w.Body).Decode(&submitResp)
+	if submitResp.Message != "Ballot updated successfully" {
+		t.Errorf("Expected update message, got: %s", submitResp.Message)
+	}
+
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM ballot WHERE poll_id = $1`, pollID).Scan(&count); err != nil {
+		t.Fatalf("Failed to count ballots: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 ballots, got %d", count)
+	}
+}
+
+func TestSubmitBallotMalformedScores(t *testing.T) {
+	// Every case is rejected before the database is touched
+	handler := NewVotingHandler(nil, getTestConfig())
+
+	tests := []struct {
+		name       string
+		body       string
+		wantFields []models.FieldError
+	}{
+		{
+			name:       "missing scores",
+			body:       `{}`,
+			wantFields: []models.FieldError{{Field: "scores", Code: models.FieldCodeRequired}},
+		},
+		{
+			name:       "null scores",
+			body:       `{"scores": null}`,
+			wantFields: []models.FieldError{{Field: "scores", Code: models.FieldCodeRequired}},
+		},
+		{
+			name:       "empty scores",
+			body:       `{"scores": {}}`,
+			wantFields: []models.FieldError{{Field: "scores", Code: models.FieldCodeRequired}},
+		},
+		{
+			name:       "scores not an object",
+			body:       `{"scores": [0.5]}`,
+			wantFields: []models.FieldError{{Field: "scores", Code: models.FieldCodeWrongType}},
+		},
+		{
+			name: "non-numeric scores",
+			body: `{"scores": {"opt-b": "high", "opt-a": 0.5, "opt-c": null}}`,
+			wantFields: []models.FieldError{
+				{Field: "scores.opt-b", Code: models.FieldCodeWrongType},
+				{Field: "scores.opt-c", Code: models.FieldCodeWrongType},
+			},
+		},
+		{
+			name: "duplicate option keys",
+			body: `{"scores": {"opt2": 1, "opt1": 0.2, "opt2": 0, "opt1": 0.8, "opt1": 0.5}}`,
+			wantFields: []models.FieldError{
+				{Field: "scores.opt1", Code: models.FieldCodeDuplicateOptionKey},
+				{Field: "scores.opt2", Code: models.FieldCodeDuplicateOptionKey},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/polls/some-slug/ballots", bytes.NewBufferString(tt.body))
+			req.SetPathValue("slug", "some-slug")
+			req.Header.Set("X-Voter-Token", "token")
+			w := httptest.NewRecorder()
+
+			handler.SubmitBallot(w, req)
+
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusBadRequest, w.Code, w.Body.String())
+			}
+			var resp models.ErrorResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if len(resp.Fields) != len(tt.wantFields) {
+				t.Fatalf("Expected %d field errors, got %+v", len(tt.wantFields), resp.Fields)
+			}
+			for i, want := range tt.wantFields {
+				got := resp.Fields[i]
+				if got.Field != want.Field || got.Code != want.Code {
+					t.Errorf("Field error %d: expected %s/%s, got %s/%s", i, want.Field, want.Code, got.Field, got.Code)
+				}
+			}
+		})
+	}
+}
+
+func TestSyncBallots(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	cfg := getTestConfig()
+	handler := NewVotingHandler(db, cfg)
+
+	// Two open polls, each with two options and one claimed voter
+	type syncPoll struct {
+		id, slug, voterToken string
+		optionIDs            []string
+	}
+	polls := make([]syncPoll, 2)
+	for i := range polls {
+		p := &polls[i]
+		p.id, _ = auth.GenerateID(16)
+		p.slug = auth.GenerateShareSlug(p.id, cfg.PollSlugSalt)
+		p.voterToken, _ = auth.GenerateVoterToken()
+		_, err := db.Exec(`
+			INSERT INTO poll (id, title, creator_name, status, share_slug, created_at)
+			VALUES ($1, 'Sync Poll', 'Alice', 'open', $2, $3)
+		`, p.id, p.slug, time.Now())
+		if err != nil {
+			t.Fatalf("Failed to create test poll: %v", err)
+		}
+		for _, label := range []string{"Option A", "Option B"} {
+			optionID, _ := auth.GenerateID(12)
+			if _, err := db.Exec(`INSERT INTO option (id, poll_id, label) VALUES ($1, $2, $3)`, optionID, p.id, label); err != nil {
+				t.Fatalf("Failed to create option: %v", err)
+			}
+			p.optionIDs = append(p.optionIDs, optionID)
+		}
+		_, err = db.Exec(`
+			INSERT INTO username_claim (poll_id, username, voter_token, created_at)
+			VALUES ($1, 'voter1', $2, $3)
+		`, p.id, p.voterToken, time.Now())
+		if err != nil {
+			t.Fatalf("Failed to create username claim: %v", err)
+		}
+	}
+
+	entry := func(p syncPoll, voterToken string) models.SyncBallotEntry {
+		scores, _ := json.Marshal(map[string]float64{p.optionIDs[0]: 0.9, p.optionIDs[1]: 0.2})
+		return models.SyncBallotEntry{Slug: p.slug, VoterToken: voterToken, Scores: scores}
+	}
+	wrongToken, _ := auth.GenerateVoterToken()
+	body, _ := json.Marshal(models.SyncBallotsRequest{Ballots: []models.SyncBallotEntry{
+		entry(polls[0], polls[0].voterToken),
+		entry(polls[1], wrongToken),
+		entry(polls[1], polls[1].voterToken),
+	}})
+	req := httptest.NewRequest("POST", "/ballots:sync", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.SyncBallots(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var resp models.SyncBallotsResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(resp.Results) != 3 {
+		t.Fatalf("Expected 3 results, got %+v", resp.Results)
+	}
+
+	// The refused entry doesn't undo the ones around it
+	for i, want := range []int{http.StatusCreated, http.StatusUnauthorized, http.StatusCreated} {
+		if resp.Results[i].Status != want {
+			t.Errorf("Result %d: expected status %d, got %+v", i, want, resp.Results[i])
+		}
+	}
+	if resp.Results[1].Error == nil || resp.Results[1].BallotID != "" {
+		t.Errorf("Expected an error and no ballot for the wrong token, got %+v", resp.Results[1])
+	}
+
+	for i, p := range polls {
+		result := resp.Results[2*i]
+		var scoreCount int
+		err := db.QueryRow(`
+			SELECT COUNT(*) FROM score s JOIN ballot b ON b.id = s.ballot_id
+			WHERE b.id = $1 AND b.poll_id = $2 AND b.voter_token = $3
+		`, result.BallotID, p.id, p.voterToken).Scan(&scoreCount)
+		if err != nil {
+			t.Fatalf("Failed to query ballot: %v", err)
+		}
+		if scoreCount != 2 {
+			t.Errorf("Poll %d: expected the synced ballot with 2 scores, got %d", i, scoreCount)
+		}
+	}
+}
+
+func TestSyncBallotsValidation(t *testing.T) {
+	// None of these reach the database
+	handler := NewVotingHandler(nil, getTestConfig())
+
+	for _, count := range []int{0, maxSyncBallots + 1} {
+		body, _ := json.Marshal(models.SyncBallotsRequest{Ballots: make([]models.SyncBallotEntry, count)})
+		req := httptest.NewRequest("POST", "/ballots:sync", bytes.NewReader(body))
+		w := httptest.NewRecorder()
+		handler.SyncBallots(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%d ballots: Expected status %d, got %d", count, http.StatusBadRequest, w.Code)
+		}
+	}
+
+	body, _ := json.Marshal(models.SyncBallotsRequest{Ballots: []models.SyncBallotEntry{
+		{VoterToken: "token"},
+		{Slug: "abc"},
+	}})
+	req := httptest.NewRequest("POST", "/ballots:sync", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	handler.SyncBallots(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var resp models.SyncBallotsResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if len(resp.Results) != 2 || resp.Results[0].Status != http.StatusBadRequest || resp.Results[1].Status != http.StatusUnauthorized {
+		t.Errorf("Expected per-entry 400 and 401, got %+v", resp.Results)
+	}
+}
+
+func TestRenameUsername(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	cfg := getTestConfig()
+	handler := NewVotingHandler(db, cfg)
+
+	pollID, _ := auth.GenerateID(16)
+	shareSlug := auth.GenerateShareSlug(pollID, cfg.PollSlugSalt)
+	_, err := db.Exec(`
+		INSERT INTO poll (id, title, creator_name, status, share_slug, created_at)
+		VALUES ($1, 'Rename Poll', 'Alice', 'open', $2, $3)
+	`, pollID, shareSlug, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to create test poll: %v", err)
+	}
+
+	optionA, _ := auth.GenerateID(12)
+	_, err = db.Exec(`
+		INSERT INTO option (id, poll_id, label) VALUES ($1, $2, 'Option A')
+	`, optionA, pollID)
+	if err != nil {
+		t.Fatalf("Failed to create option: %v", err)
+	}
+
+	claim := func(username string) string {
+		voterToken, _ := auth.GenerateVoterToken()
+		_, err := db.Exec(`
+			INSERT INTO username_claim (poll_id, username, voter_token, created_at)
+			VALUES ($1, $2, $3, $4)
+		`, pollID, username, voterToken, time.Now())
+		if err != nil {
+			t.Fatalf("Failed to claim %s: %v", username, err)
+		}
+		return voterToken
+	}
+	voterToken := claim("Bobb")
+	claim("Carol")
+
+	body, _ := json.Marshal(models.SubmitBallotRequest{Scores: map[string]float64{optionA: 0.7}})
+	req := httptest.NewRequest("POST", "/polls/"+shareSlug+"/ballots", bytes.NewReader(body))
+	req.SetPathValue("slug", shareSlug)
+	req.Header.Set("X-Voter-Token", voterToken)
+	w := httptest.NewRecorder()
+	handler.SubmitBallot(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Failed to submit ballot: %d %s", w.Code, w.Body.String())
+	}
+	var ballot models.SubmitBallotResponse
+	json.NewDecoder(w.Body).Decode(&ballot)
+
+	rename := func(token, username string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(models.RenameUsernameRequest{Username: username})
+		req := httptest.NewRequest("PATCH", "/polls/"+shareSlug+"/username", bytes.NewReader(body))
+		req.SetPathValue("slug", shareSlug)
+		req.Header.Set("X-Voter-Token", token)
+		w := httptest.NewRecorder()
+		handler.RenameUsername(w, req)
+		return w
+	}
+
+	tests := []struct {
+		name           string
+		token          string
+		username       string
+		expectedStatus int
+	}{
+		{"name taken", voterToken, "Carol", http.StatusConflict},
+		{"name too short", voterToken, "B", http.StatusBadRequest},
+		{"unknown token", "not-a-voter", "Robert", http.StatusUnauthorized},
+		{"rename", voterToken, "Bob", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := rename(tt.token, tt.username); w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d. Body: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	// The claim keeps its token and the ballot still belongs to it
+	var username, ballotID string
+	err = db.QueryRow(`
+		SELECT uc.username, b.id
+		FROM username_claim uc
+		JOIN ballot b ON b.poll_id = uc.poll_id AND b.voter_token = uc.voter_token
+		WHERE uc.poll_id = $1 AND uc.voter_token = $2
+	`, pollID, voterToken).Scan(&username, &ballotID)
+	if err != nil {
+		t.Fatalf("Failed to load renamed voter: %v", err)
+	}
+	if username != "Bob" {
+		t.Errorf("Expected username Bob, got %q", username)
+	}
+	if ballotID != ballot.BallotID {
+		t.Errorf("Expected ballot %s to be kept, got %s", ballot.BallotID, ballotID)
+	}
+
+	// Renaming is only allowed while the poll is open
+	if _, err := db.Exec(`UPDATE poll SET status = 'closed' WHERE id = $1`, pollID); err != nil {
+		t.Fatalf("Failed to close poll: %v", err)
+	}
+	if w := rename(voterToken, "Bobby"); w.Code != http.StatusConflict {
+		t.Errorf("Expected status %d for a closed poll, got %d", http.StatusConflict, w.Code)
+	}
+}
+
+func TestGetVoterPolls(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	cfg := getTestConfig()
+	handler := NewVotingHandler(db, cfg)
+
+	// One voter joined two polls, and voted in the first
+	createClaim := func(title, username string, voted bool) (string, string) {
+		pollID, _ := auth.GenerateID(16)
+		shareSlug := auth.GenerateShareSlug(pollID, cfg.PollSlugSalt)
+		_, err := db.Exec(`
+			INSERT INTO poll (id, title, creator_name, status, share_slug, created_at)
+			VALUES ($1, $2, 'Alice', 'open', $3, $4)
+		`, pollID, title, shareSlug, time.Now())
+		if err != nil {
+			t.Fatalf("Failed to create test poll: %v", err)
+		}
+		voterToken, _ := auth.GenerateVoterToken()
+		_, err = db.Exec(`
+			INSERT INTO username_claim (poll_id, username, voter_token, created_at)
+			VALUES ($1, $2, $3, $4)
+		`, pollID, username, voterToken, time.Now())
+		if err != nil {
+			t.Fatalf("Failed to create username claim: %v", err)
+		}
+		if voted {
+			ballotID, _ := auth.GenerateID(16)
+			_, err = db.Exec(`
+				INSERT INTO ballot (id, poll_id, voter_token, submitted_at) VALUES ($1, $2, $3, $4)
+			`, ballotID, pollID, voterToken, time.Now())
+			if err != nil {
+				t.Fatalf("Failed to create ballot: %v", err)
+			}
+		}
+		return pollID, voterToken
+	}
+	lunchID, lunchToken := createClaim("Lunch", "bob", true)
+	movieID, movieToken := createClaim("Movie", "bobby", false)
+
+	req := httptest.NewRequest("GET", "/voters/my-polls", nil)
+	req.Header.Set("X-Voter-Token", lunchToken+", not-a-token,"+movieToken)
+	w := httptest.NewRecorder()
+	handler.GetVoterPolls(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var resp models.GetVoterPollsResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(resp.Polls) != 2 {
+		t.Fatalf("Expected 2 polls with the invalid token skipped, got %+v", resp.Polls)
+	}
+	byID := make(map[string]models.VoterPollSummary)
+	for _, poll := range resp.Polls {
+		byID[poll.PollID] = poll
+	}
+	if lunch := byID[lunchID]; lunch.Title != "Lunch" || lunch.Username != "bob" || !lunch.HasVoted || lunch.BallotCount != 1 {
+		t.Errorf("Unexpected summary for the voted poll: %+v", lunch)
+	}
+	if movie := byID[movieID]; movie.Title != "Movie" || movie.Username != "bobby" || movie.HasVoted {
+		t.Errorf("Unexpected summary for the unvoted poll: %+v", movie)
+	}
+
+	// The tokens may come in the body instead
+	body, _ := json.Marshal(models.GetVoterPollsRequest{VoterTokens: []string{movieToken}})
+	req = httptest.NewRequest("GET", "/voters/my-polls", bytes.NewReader(body))
+	w = httptest.NewRecorder()
+	handler.GetVoterPolls(w, req)
+	resp = models.GetVoterPollsResponse{}
+	json.NewDecoder(w.Body).Decode(&resp)
+	if w.Code != http.StatusOK || len(resp.Polls) != 1 || resp.Polls[0].PollID != movieID {
+		t.Errorf("Expected only the movie poll from a body token, got %d: %+v", w.Code, resp.Polls)
+	}
+
+	req = httptest.NewRequest("GET", "/voters/my-polls", nil)
+	w = httptest.NewRecorder()
+	handler.GetVoterPolls(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d without tokens, got %d", http.StatusUnauthorized, w.Code)
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright (c) 2025 Daniel Kuo.
+// Source-available; no permission granted to use, copy, modify, or distribute. See LICENSE.
+
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+
+	"github.com/danielhkuo/quickly-pick/auth"
+	"github.com/danielhkuo/quickly-pick/models"
+)
+
+// WebhookSignatureHeader carries "sha256=" and the hex HMAC-SHA256 of a
+// webhook body, keyed by the poll's webhook secret
+const WebhookSignatureHeader = "X-Quickly-Pick-Signature"
+
+// webhookTimeout bounds a single delivery so slow receivers can't pile up
+// goroutines
+const webhookTimeout = 5 * time.Second
+
+// webhookDrainLimit caps how much of a response body is read so the
+// connection can be reused
+const webhookDrainLimit = 64 << 10
+
+// webhookClient delivers webhooks only to public addresses, checked after
+// DNS resolution so a hostname can't point it at the server's own network
+// or a cloud metadata endpoint. It never goes through a proxy and never
+// follows redirects, which could lead it there instead.
+var webhookClient = &http.Client{
+	Timeout: webhookTimeout,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: webhookTimeout,
+			Control: webhookDialControl,
+		}).DialContext,
+		TLSHandshakeTimeout: webhookTimeout,
+	},
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// webhookIPAllowed reports whether webhooks may be delivered to ip. Tests
+// replace it to reach a loopback receiver.
+var webhookIPAllowed = publicIP
+
+// publicIP reports whether ip is neither private, loopback, link-local
+// (which covers 169.254.169.254), multicast nor unspecified
+func publicIP(ip net.IP) bool {
+	return !ip.IsPrivate() && !ip.IsLoopback() && !ip.IsLinkLocalUnicast() &&
+		!ip.IsLinkLocalMulticast() && !ip.IsInterfaceLocalMulticast() &&
+		!ip.IsMulticast() && !ip.IsUnspecified()
+}
+
+// webhookDialControl refuses to connect to an address webhookIPAllowed
+// rejects. address is the resolved IP and port.
+func webhookDialControl(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !webhookIPAllowed(ip) {
+		return fmt.Errorf("webhook address %s is not public", host)
+	}
+	return nil
+}
+
+// validWebhookURL reports whether raw is an absolute http or https URL
+// whose host, when it is an IP address, is one webhooks may be delivered
+// to. Hostnames are checked when the webhook is sent.
+func validWebhookURL(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return false
+	}
+	if ip := net.ParseIP(u.Hostname()); ip != nil && !webhookIPAllowed(ip) {
+		return false
+	}
+	return true
+}
+
+// sendCloseWebhook POSTs payload to webhookURL, signed with secret. It runs
+// after the close has committed, so failures are only logged.
+func sendCloseWebhook(webhookURL, secret string, payload models.CloseWebhookPayload) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		slog.Error("failed to marshal close webhook", "error", err, "poll_id", payload.PollID)
+		return
+	}
+
+	req, err := http.NewRequest(http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		slog.Warn("invalid close webhook request", "error", err, "poll_id", payload.PollID)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookSignatureHeader, "sha256="+auth.SignWebhook(secret, body))
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		slog.Warn("close webhook delivery failed", "error", err, "poll_id", payload.PollID)
+		return
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, webhookDrainLimit))
+	resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		slog.Warn("close webhook rejected", "status", resp.StatusCode, "poll_id", payload.PollID)
+		return
+	}
+	slog.Info("close webhook delivered", "poll_id", payload.PollID)
+}
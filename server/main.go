@@ -4,6 +4,7 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"log/slog"
 	"net/http"
@@ -11,12 +12,14 @@ import (
 	"os/signal"
 	"strconv"
 	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 
 	"github.com/danielhkuo/quickly-pick/cliparse"
 	"github.com/danielhkuo/quickly-pick/db"
+	"github.com/danielhkuo/quickly-pick/handlers"
 	"github.com/danielhkuo/quickly-pick/middleware"
 	"github.com/danielhkuo/quickly-pick/router"
 )
@@ -35,9 +38,11 @@ func main() {
 		slog.Error("Error parsing flags", "error", err)
 		os.Exit(1)
 	}
+	slog.Info("Configuration loaded", "config", cfg.Redacted())
 
 	// Connect to PostgreSQL
-	dbConn, err := sql.Open("postgres", cfg.DatabaseURL)
+	dsn := db.StatementTimeoutDataSourceName(db.UTCDataSourceName(cfg.DatabaseURL), cfg.DBStatementTimeout)
+	dbConn, err := sql.Open("postgres", dsn)
 	if err != nil {
 		slog.Error("database connection failed", "error", err)
 		os.Exit(1)
@@ -57,30 +62,100 @@ func main() {
 	}
 	slog.Info("Database schema ready")
 
+	// Connect to the read replica, if configured. Results and previews read
+	// from it; everything else stays on the primary.
+	var readConn *sql.DB
+	if cfg.ReadDatabaseURL != "" {
+		readDSN := db.StatementTimeoutDataSourceName(db.UTCDataSourceName(cfg.ReadDatabaseURL), cfg.DBStatementTimeout)
+		readConn, err = sql.Open("postgres", readDSN)
+		if err != nil {
+			slog.Error("read replica connection failed", "error", err)
+			os.Exit(1)
+		}
+		defer readConn.Close()
+
+		if err := readConn.Ping(); err != nil {
+			slog.Error("read replica ping failed", "error", err)
+			os.Exit(1)
+		}
+		slog.Info("Read replica connected")
+	}
+
+	// Close and purge polls past the configured ages in the background
+	sweepCtx, stopSweeper := context.WithCancel(context.Background())
+	defer stopSweeper()
+	if sweeper := handlers.NewExpirySweeper(dbConn, cfg); sweeper.Enabled() {
+		go sweeper.Run(sweepCtx, handlers.DefaultExpirySweepInterval)
+		slog.Info("Poll expiry enabled", "max_age_hours", cfg.PollMaxAge, "purge_age_hours", cfg.PollPurgeAge)
+	}
+
 	// Create router
-	mux := router.NewRouter(dbConn, cfg)
+	mux := router.NewRouterWithReplica(dbConn, readConn, cfg)
 
 	// Create server with CORS middleware
+	cors := middleware.CORSWithOptions(middleware.CORSOptions{
+		MaxAge:           cfg.CORSMaxAge,
+		ExposeHeaders:    middleware.DefaultExposeHeaders,
+		AllowedOrigins:   cfg.CORSOrigins,
+		AllowCredentials: cfg.CORSCredentials,
+	})
 	server := http.Server{
-		Handler: middleware.CORS(mux),
+		Handler: cors(mux),
 		Addr:    ":" + strconv.Itoa(cfg.Port),
+		// Oversized headers are rejected while reading, before any handler
+		// or middleware allocates for them
+		MaxHeaderBytes: cfg.MaxHeaderBytes,
 	}
 
 	// signal.Notify requires the channel to be buffered
 	ctrlc := make(chan os.Signal, 1)
 	signal.Notify(ctrlc, os.Interrupt, syscall.SIGTERM)
+	shutdownDone := make(chan struct{})
 	go func() {
+		defer close(shutdownDone)
 		// Wait for Ctrl-C signal
 		<-ctrlc
-		server.Close()
+		stopSweeper()
+		if err := shutdown(&server, cfg.DisableKeepAlives, shutdownTimeout); err != nil {
+			slog.Error("graceful shutdown failed", "error", err)
+		}
 	}()
 
-	// Start server
-	slog.Info("Listening", "port", cfg.Port)
-	err = server.ListenAndServe()
+	// Start server. Both variants return http.ErrServerClosed on shutdown;
+	// ListenAndServeTLS also negotiates HTTP/2 with clients that support it.
+	if cfg.TLSCertFile != "" {
+		slog.Info("Listening", "port", cfg.Port, "tls", true)
+		err = server.ListenAndServeTLS(cfg.TLSCertFile, cfg.TLSKeyFile)
+	} else {
+		slog.Info("Listening", "port", cfg.Port)
+		err = server.ListenAndServe()
+	}
 	if err != nil && err != http.ErrServerClosed {
 		slog.Error("Server closed", "error", err)
-	} else {
-		slog.Info("Server closed", "error", err)
+		return
+	}
+
+	// Serve returns as soon as Shutdown begins; wait for in-flight requests
+	<-shutdownDone
+	slog.Info("Server closed", "error", err)
+}
+
+// shutdownTimeout bounds how long shutdown waits for in-flight requests
+const shutdownTimeout = 30 * time.Second
+
+// shutdown stops server accepting connections and waits up to timeout for
+// in-flight requests to finish, then closes whatever remains. With
+// disableKeepAlives, idle and finishing connections are closed rather than
+// held open for more requests while the server drains.
+func shutdown(server *http.Server, disableKeepAlives bool, timeout time.Duration) error {
+	if disableKeepAlives {
+		server.SetKeepAlivesEnabled(false)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		server.Close()
+		return err
 	}
+	return nil
 }
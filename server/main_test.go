@@ -0,0 +1,91 @@
+// Copyright (c) 2025 Daniel Kuo.
+// Source-available; no permission granted to use, copy, modify, or distribute. See LICENSE.
+
+package main
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestShutdownDrainsInFlightRequests(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		io.WriteString(w, "done")
+	})}
+	go server.Serve(ln)
+
+	type result struct {
+		resp *http.Response
+		err  error
+	}
+	results := make(chan result, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String())
+		results <- result{resp, err}
+	}()
+	<-started
+
+	shutdownErr := make(chan error, 1)
+	go func() {
+		shutdownErr <- shutdown(server, true, 5*time.Second)
+	}()
+
+	// New connections are refused while the request is still running
+	time.Sleep(50 * time.Millisecond)
+	if conn, err := net.DialTimeout("tcp", ln.Addr().String(), time.Second); err == nil {
+		conn.Close()
+		t.Error("Expected new connections to be refused during shutdown")
+	}
+
+	close(release)
+	res := <-results
+	if res.err != nil {
+		t.Fatalf("Expected the in-flight request to complete, got %v", res.err)
+	}
+	defer res.resp.Body.Close()
+	body, _ := io.ReadAll(res.resp.Body)
+	if res.resp.StatusCode != http.StatusOK || string(body) != "done" {
+		t.Errorf("Expected 200 done, got %d %q", res.resp.StatusCode, body)
+	}
+	if !res.resp.Close {
+		t.Error("Expected the response to close its connection with keep-alives disabled")
+	}
+
+	if err := <-shutdownErr; err != nil {
+		t.Errorf("Expected a clean shutdown, got %v", err)
+	}
+}
+
+func TestShutdownTimesOut(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})}
+	go server.Serve(ln)
+	go http.Get("http://" + ln.Addr().String())
+	<-started
+
+	if err := shutdown(server, false, 50*time.Millisecond); err == nil {
+		t.Error("Expected shutdown to report the request it could not drain")
+	}
+}
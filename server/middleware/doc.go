@@ -11,6 +11,15 @@ Wrap handlers with request logging:
 	mux.HandleFunc("GET /health", middleware.WithLogging(handler))
 
 Logs request start (method, path, remote) and completion (duration_ms).
+Headers are not logged.
+
+LogHeaders additionally logs every request's headers. The router applies
+it only when cfg.LogHeaders is set. Headers pass through RedactHeaders
+first, which masks Authorization, Proxy-Authorization, Cookie,
+X-Admin-Key, X-Voter-Token, X-Server-Key, X-Device-UUID, and
+Idempotency-Key. Any other code that logs headers must do the same:
+
+	slog.Info("request headers", "headers", middleware.RedactHeaders(r.Header))
 
 # CORS Middleware
 
@@ -20,8 +29,46 @@ Enable cross-origin requests for frontend access:
 		Handler: middleware.CORS(mux),
 	}
 
-Allows methods GET, POST, PUT, DELETE, OPTIONS with headers
-Content-Type, Authorization, X-Admin-Key, X-Voter-Token.
+Allows headers Content-Type, Authorization, X-Admin-Key, X-Voter-Token,
+X-Device-UUID, and Idempotency-Key. Access-Control-Allow-Methods lists the methods the
+wrapped handler reports through MethodLister, plus OPTIONS, so it follows
+the routes as they change. CORSOptions.AllowMethods overrides that; for a
+handler that lists none, DefaultAllowMethods (GET, POST, PUT, PATCH,
+DELETE) is used.
+
+Preflight responses carry Access-Control-Max-Age (600 seconds by default)
+and every response exposes X-Request-ID. Use CORSWithOptions to configure:
+
+	cors := middleware.CORSWithOptions(middleware.CORSOptions{
+		MaxAge:        cfg.CORSMaxAge,
+		ExposeHeaders: middleware.DefaultExposeHeaders,
+	})
+
+By default any origin may call the API and Access-Control-Allow-Credentials
+is never sent. Setting AllowedOrigins restricts access to those origins;
+setting AllowCredentials as well sends the credentials header to them.
+Credentials are never combined with a wildcard or unlisted origin.
+
+Preflight (OPTIONS) requests are answered with 200 without reaching the
+wrapped handler, unless it implements RouteChecker and reports that the
+path has no route. The handler then answers, so the router's JSON 404 is
+returned with the CORS headers already set.
+
+# Security Headers
+
+SecurityHeaders sets X-Content-Type-Options: nosniff and a
+Content-Security-Policy on every response, so a browser never sniffs a
+JSON body as HTML or runs anything it references. The policy defaults to
+DefaultContentSecurityPolicy, which allows nothing; the API serves no
+HTML. A Server header is sent only when ServerName is set:
+
+	secure := middleware.SecurityHeaders(middleware.SecurityHeaderOptions{
+		ServerName: cfg.ServerHeader,
+	})
+
+The router applies it to every route and to its own 404 and 405
+responses. CORS preflights answered by the CORS middleware don't reach
+the router and carry only the CORS headers.
 
 # JSON Helpers
 
@@ -29,21 +76,59 @@ Write JSON responses:
 
 	middleware.JSONResponse(w, http.StatusOK, data)
 	middleware.ErrorResponse(w, http.StatusBadRequest, "message")
+	middleware.ErrorResponseWithCode(w, http.StatusConflict, models.ErrorCodePollDraft, "message")
+
+Responses carry Content-Type JSONContentType, application/json with
+charset=utf-8. The body is encoded before the status is written, so a
+value that can't be encoded yields a 500 error response rather than a
+truncated body under the intended status.
+
+Read endpoints that native clients fetch over slow links negotiate the
+encoding instead. A request whose Accept header lists application/msgpack
+gets MessagePack, with the same field names as the JSON; anything else
+gets JSON. Errors are always JSON:
+
+	middleware.NegotiatedResponse(w, r, http.StatusOK, data)
+
+CamelCaseKeys re-encodes a value with every object key in camelCase
+(option_id becomes optionId), for endpoints that let clients pick their
+naming convention:
+
+	body, err := middleware.CamelCaseKeys(data)
 
 Parse JSON request bodies:
 
 	var req models.CreatePollRequest
-	if err := middleware.ParseJSONBody(r, &req); err != nil {
+	if err := middleware.DecodeRequiredJSON(r, &req); err != nil {
 		middleware.ErrorResponse(w, http.StatusBadRequest, "Invalid JSON")
 		return
 	}
 
+DecodeRequiredJSON rejects an empty body with ErrEmptyBody.
+DecodeOptionalJSON accepts one and leaves the target untouched; both
+return an error for malformed JSON. ParseJSONBody is the required variant.
+
 # Client IP Extraction
 
-Get the original client IP (handles X-Forwarded-For, X-Real-IP):
+ClientIP returns the address a request came from, for IP hashing and
+limits. X-Forwarded-For and X-Real-IP are only read when the server is
+configured to trust them (cfg.TrustProxyHeaders), since any client can set
+them; ClientIP then takes the entry the nearest proxy appended:
+
+	ip := middleware.ClientIP(r, cfg.TrustProxyHeaders)
+
+GetClientIP always believes the headers, taking the first forwarded
+address, so it is only fit for display.
+
+# Rate Limiting
+
+Cap requests per client IP, as ClientIP sees it, on routes open to
+guessing:
 
-	ip := middleware.GetClientIP(r)
+	limiter := middleware.NewRateLimiter(30, time.Minute, cfg.TrustProxyHeaders)
+	mux.HandleFunc("GET /polls/{id}/public-status", limiter.Limit(handler))
 
-Used for IP hashing in fraud detection.
+Past the limit a request gets 429 with code rate_limited and a Retry-After
+header until its window resets. Counts live in memory, per instance.
 */
 package middleware
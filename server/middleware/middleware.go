@@ -4,12 +4,21 @@
 package middleware
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"io"
 	"log/slog"
+	"net"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/danielhkuo/quickly-pick/models"
+	"github.com/vmihailenco/msgpack/v5"
 )
 
 // WithLogging wraps a handler with request logging
@@ -37,16 +46,171 @@ func WithLogging(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
-// JSONResonse writes a JSON response
+// SensitiveHeaders are the request headers that carry credentials, or
+// values that act as them: a registered device UUID reaches that device's
+// polls and an Idempotency-Key replays an admin key. RedactHeaders masks
+// their values.
+var SensitiveHeaders = []string{
+	"Authorization", "Proxy-Authorization", "Cookie",
+	"X-Admin-Key", "X-Voter-Token", "X-Server-Key",
+	"X-Device-UUID", "Idempotency-Key",
+}
+
+// RedactedHeaderValue replaces each value of a sensitive header
+const RedactedHeaderValue = "[REDACTED]"
+
+// RedactHeaders returns a copy of header that is safe to log, with every
+// value of SensitiveHeaders masked. Code that logs request headers must
+// pass them through it; header itself is left untouched.
+func RedactHeaders(header http.Header) http.Header {
+	redacted := header.Clone()
+	for _, name := range SensitiveHeaders {
+		values := redacted.Values(name)
+		if len(values) == 0 {
+			continue
+		}
+		masked := make([]string, len(values))
+		for i := range masked {
+			masked[i] = RedactedHeaderValue
+		}
+		redacted[http.CanonicalHeaderKey(name)] = masked
+	}
+	return redacted
+}
+
+// LogHeaders returns middleware that logs each request's headers, passed
+// through RedactHeaders, before calling next. It is meant for debugging
+// clients and is off unless the server enables it.
+func LogHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		slog.Info("request headers",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"headers", RedactHeaders(r.Header),
+		)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// JSONContentType is the Content-Type of every JSON response
+const JSONContentType = "application/json; charset=utf-8"
+
+// JSONResponse writes a JSON response. data is encoded before anything is
+// written, so a value that fails to encode becomes a 500 error response
+// instead of statusCode with a truncated body.
 func JSONResponse(w http.ResponseWriter, statusCode int, data interface{}) {
-	w.Header().Set("Content-Type", "application/json")
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(data); err != nil {
+		slog.Error("failed to encode JSON response", "error", err)
+		ErrorResponse(w, http.StatusInternalServerError, "Failed to encode response")
+		return
+	}
+
+	w.Header().Set("Content-Type", JSONContentType)
+	w.WriteHeader(statusCode)
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		slog.Error("failed to write JSON response", "error", err)
+	}
+}
+
+// MsgpackContentType is the media type a client lists in Accept to receive
+// MessagePack instead of JSON
+const MsgpackContentType = "application/msgpack"
+
+// NegotiatedResponse writes data as MessagePack when the request's Accept
+// header lists MsgpackContentType, and as JSON otherwise. Struct fields
+// keep their json tag names in either encoding
+func NegotiatedResponse(w http.ResponseWriter, r *http.Request, statusCode int, data interface{}) {
+	w.Header().Add("Vary", "Accept")
+	if !acceptsMsgpack(r) {
+		JSONResponse(w, statusCode, data)
+		return
+	}
+
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	enc.UseCompactInts(true)
+	if err := enc.Encode(data); err != nil {
+		slog.Error("failed to encode msgpack response", "error", err)
+		ErrorResponse(w, http.StatusInternalServerError, "Failed to encode response")
+		return
+	}
+
+	w.Header().Set("Content-Type", MsgpackContentType)
 	w.WriteHeader(statusCode)
-	err := json.NewEncoder(w).Encode(data)
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		slog.Error("failed to write msgpack response", "error", err)
+	}
+}
+
+// acceptsMsgpack reports whether the Accept header lists MsgpackContentType
+func acceptsMsgpack(r *http.Request) bool {
+	for _, mediaRange := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, _ := strings.Cut(mediaRange, ";")
+		if strings.EqualFold(strings.TrimSpace(mediaType), MsgpackContentType) {
+			return true
+		}
+	}
+	return false
+}
+
+// CamelCaseKeys returns data as generic JSON values with every object key
+// converted from snake_case to camelCase, e.g. neg_share to negShare, for
+// clients that expect that convention. Pass the result to JSONResponse or
+// NegotiatedResponse in place of data.
+func CamelCaseKeys(data interface{}) (interface{}, error) {
+	raw, err := json.Marshal(data)
 	if err != nil {
-		slog.Error("failed to encode JSON response", "error", err)
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		return nil, err
+	}
+	return camelCaseValue(value), nil
+}
+
+// camelCaseValue renames the keys of every object within value. Numbers
+// become int64 or float64 again so MessagePack keeps their types.
+func camelCaseValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		renamed := make(map[string]interface{}, len(v))
+		for key, elem := range v {
+			renamed[camelCase(key)] = camelCaseValue(elem)
+		}
+		return renamed
+	case []interface{}:
+		for i, elem := range v {
+			v[i] = camelCaseValue(elem)
+		}
+		return v
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	default:
+		return v
 	}
 }
 
+// camelCase converts a snake_case key to camelCase
+func camelCase(key string) string {
+	parts := strings.Split(key, "_")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
+
 // ErrorResponse writes a JSON error response
 func ErrorResponse(w http.ResponseWriter, statusCode int, message string) {
 	JSONResponse(w, statusCode, models.ErrorResponse{
@@ -55,41 +219,235 @@ func ErrorResponse(w http.ResponseWriter, statusCode int, message string) {
 	})
 }
 
-// ParseJSONBody parses the request body into the given struct
+// ErrorResponseWithCode writes an error response carrying a machine-readable
+// code, for errors clients need to tell apart
+func ErrorResponseWithCode(w http.ResponseWriter, statusCode int, code, message string) {
+	JSONResponse(w, statusCode, models.ErrorResponse{
+		Error:   http.StatusText(statusCode),
+		Message: message,
+		Code:    code,
+	})
+}
+
+// ValidationErrorResponse writes a 400 listing every invalid field.
+// The top-level message repeats the first field's message for clients
+// that only read error/message.
+func ValidationErrorResponse(w http.ResponseWriter, fields []models.FieldError) {
+	resp := models.ErrorResponse{
+		Error:  http.StatusText(http.StatusBadRequest),
+		Fields: fields,
+	}
+	if len(fields) > 0 {
+		resp.Message = fields[0].Message
+	}
+	JSONResponse(w, http.StatusBadRequest, resp)
+}
+
+// ErrEmptyBody is returned when a request body is required but missing
+var ErrEmptyBody = errors.New("request body is required")
+
+// ParseJSONBody parses the request body into the given struct.
+// It is equivalent to DecodeRequiredJSON.
 func ParseJSONBody(r *http.Request, v interface{}) error {
+	return DecodeRequiredJSON(r, v)
+}
+
+// DecodeRequiredJSON parses the request body into v, returning ErrEmptyBody
+// when the body is empty
+func DecodeRequiredJSON(r *http.Request, v interface{}) error {
+	return decodeJSON(r, v, true)
+}
+
+// DecodeOptionalJSON parses the request body into v, leaving v untouched when
+// the body is empty. Malformed JSON is still an error.
+func DecodeOptionalJSON(r *http.Request, v interface{}) error {
+	return decodeJSON(r, v, false)
+}
+
+func decodeJSON(r *http.Request, v interface{}, required bool) error {
+	empty := func() error {
+		if required {
+			return ErrEmptyBody
+		}
+		return nil
+	}
+
+	if r.Body == nil || r.Body == http.NoBody {
+		return empty()
+	}
 	defer r.Body.Close()
+
 	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		// The decoder reports a body with no JSON value as io.EOF
+		if errors.Is(err, io.EOF) {
+			return empty()
+		}
 		return err
 	}
 	return nil
 }
 
+// CORSOptions configures the CORS middleware
+type CORSOptions struct {
+	// MaxAge is how long (seconds) browsers may cache a preflight result
+	MaxAge int
+	// ExposeHeaders lists response headers browser scripts may read
+	ExposeHeaders []string
+	// AllowedOrigins lists the origins allowed to make cross-origin
+	// requests. Empty allows any origin, unless AllowCredentials is set.
+	AllowedOrigins []string
+	// AllowCredentials lets browsers send cookies and HTTP auth along with
+	// requests. The spec forbids pairing it with a wildcard origin, so it
+	// only ever applies to origins listed in AllowedOrigins.
+	AllowCredentials bool
+	// AllowMethods lists the methods preflights allow. Empty uses the
+	// methods the wrapped handler reports as a MethodLister, or
+	// DefaultAllowMethods if it reports none. OPTIONS is always added.
+	AllowMethods []string
+}
+
+// DefaultAllowMethods are the methods allowed when neither CORSOptions nor
+// the wrapped handler says otherwise
+var DefaultAllowMethods = []string{
+	http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete,
+}
+
+// DefaultExposeHeaders are the response headers exposed to browser clients
+var DefaultExposeHeaders = []string{"X-Request-ID", "Retry-After"}
+
+// DefaultCORSOptions returns the options used by CORS
+func DefaultCORSOptions() CORSOptions {
+	return CORSOptions{
+		MaxAge:        600,
+		ExposeHeaders: DefaultExposeHeaders,
+	}
+}
+
 // CORS middleware allows cross-origin requests from the frontend
 func CORS(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Allow requests from Vite dev server and production domains
-		origin := r.Header.Get("Origin")
-		if origin == "" {
-			origin = "*"
-		}
+	return CORSWithOptions(DefaultCORSOptions())(next)
+}
 
-		w.Header().Set("Access-Control-Allow-Origin", origin)
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Admin-Key, X-Voter-Token, X-Device-UUID")
-		w.Header().Set("Access-Control-Allow-Credentials", "true")
+// CORSWithOptions returns CORS middleware configured by opts
+func CORSWithOptions(opts CORSOptions) func(http.Handler) http.Handler {
+	exposeHeaders := strings.Join(opts.ExposeHeaders, ", ")
+	maxAge := strconv.Itoa(opts.MaxAge)
 
-		// Handle preflight requests
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
-			return
+	allowed := make(map[string]bool, len(opts.AllowedOrigins))
+	for _, origin := range opts.AllowedOrigins {
+		allowed[origin] = true
+	}
+	restricted := len(allowed) > 0 || opts.AllowCredentials
+
+	return func(next http.Handler) http.Handler {
+		// Methods come from the router when it can list them, so the header
+		// stays accurate as routes are added
+		methods := opts.AllowMethods
+		if lister, ok := next.(MethodLister); ok && len(methods) == 0 {
+			methods = lister.RoutedMethods()
+		}
+		if len(methods) == 0 {
+			methods = DefaultAllowMethods
 		}
+		allowMethods := strings.Join(append(slices.Clone(methods), http.MethodOptions), ", ")
 
-		next.ServeHTTP(w, r)
-	})
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Allow requests from Vite dev server and production domains.
+			// The allowed origin depends on the request's, so caches must
+			// key on it.
+			origin := r.Header.Get("Origin")
+			w.Header().Add("Vary", "Origin")
+			switch {
+			case restricted && allowed[origin]:
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				if opts.AllowCredentials {
+					w.Header().Set("Access-Control-Allow-Credentials", "true")
+				}
+			case restricted:
+				// Not on the allowlist: omit the header so browsers block it
+			case origin == "":
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			default:
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+			}
+
+			w.Header().Set("Access-Control-Allow-Methods", allowMethods)
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Admin-Key, X-Voter-Token, X-Device-UUID, Idempotency-Key")
+			if exposeHeaders != "" {
+				w.Header().Set("Access-Control-Expose-Headers", exposeHeaders)
+			}
+
+			// Handle preflight requests. A path the router doesn't know is
+			// passed on so it gets the router's 404, CORS headers included.
+			if r.Method == "OPTIONS" {
+				if router, ok := next.(RouteChecker); ok && !router.HasRoute(r) {
+					next.ServeHTTP(w, r)
+					return
+				}
+				// Let browsers cache the preflight instead of repeating it
+				w.Header().Set("Access-Control-Max-Age", maxAge)
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RouteChecker is implemented by handlers that can tell whether a path is
+// routed. CORS answers preflights for unrouted paths with the handler's own
+// response, normally a 404, instead of a blanket 200.
+type RouteChecker interface {
+	HasRoute(r *http.Request) bool
+}
+
+// MethodLister is implemented by handlers that know which methods their
+// routes are registered for. CORS allows exactly those in preflights.
+type MethodLister interface {
+	RoutedMethods() []string
+}
+
+// DefaultContentSecurityPolicy forbids a browser from loading anything a
+// response references or framing it. The API serves no HTML, so nothing
+// legitimate needs more.
+const DefaultContentSecurityPolicy = "default-src 'none'; frame-ancestors 'none'"
+
+// SecurityHeaderOptions configures the SecurityHeaders middleware
+type SecurityHeaderOptions struct {
+	// ContentSecurityPolicy is sent on every response. Empty uses
+	// DefaultContentSecurityPolicy.
+	ContentSecurityPolicy string
+	// ServerName is sent as the Server header. Empty sends none.
+	ServerName string
+}
+
+// SecurityHeaders returns middleware that sets X-Content-Type-Options:
+// nosniff, a Content-Security-Policy, and optionally a Server header on
+// every response. They are set before next runs, so a handler can still
+// override them, and they leave Content-Type and CORS headers alone.
+func SecurityHeaders(opts SecurityHeaderOptions) func(http.Handler) http.Handler {
+	csp := opts.ContentSecurityPolicy
+	if csp == "" {
+		csp = DefaultContentSecurityPolicy
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Content-Type-Options", "nosniff")
+			w.Header().Set("Content-Security-Policy", csp)
+			if opts.ServerName != "" {
+				w.Header().Set("Server", opts.ServerName)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
 }
 
 // GetClientIP extracts the client IP address
-// Checks X-Forwarded-For, X-Real-IP, then falls back to RemoteAddr
+// Checks X-Forwarded-For, X-Real-IP, then falls back to RemoteAddr.
+// Those headers are whatever the client sent unless a proxy rewrote them,
+// so anything that limits or identifies clients should use ClientIP.
 func GetClientIP(r *http.Request) string {
 	// Check X-Forwarded-For (load balancers)
 	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
@@ -117,3 +475,101 @@ func GetClientIP(r *http.Request) string {
 	}
 	return addr
 }
+
+// ClientIP returns the address a request came from. Unless trustProxy is
+// set that is the connection's own address, since a client can put
+// anything in X-Forwarded-For. Behind a proxy, set trustProxy and ClientIP
+// takes the last X-Forwarded-For entry, which the nearest proxy appended,
+// or X-Real-IP without one.
+func ClientIP(r *http.Request, trustProxy bool) string {
+	if trustProxy {
+		if xff := r.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+			last := xff[len(xff)-1]
+			if i := strings.LastIndexByte(last, ','); i >= 0 {
+				last = last[i+1:]
+			}
+			if last = strings.TrimSpace(last); last != "" {
+				return last
+			}
+		}
+		if xri := strings.TrimSpace(r.Header.Get("X-Real-IP")); xri != "" {
+			return xri
+		}
+	}
+
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// RateLimiter caps requests per client IP in fixed windows. Counts are kept
+// in memory, so each server instance enforces its own limit.
+type RateLimiter struct {
+	limit      int
+	window     time.Duration
+	trustProxy bool // passed to ClientIP
+
+	mu        sync.Mutex
+	counts    map[string]rateWindow
+	lastSweep time.Time
+}
+
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+// NewRateLimiter allows limit requests per client IP every window. The IP
+// comes from ClientIP, so forwarded headers only count with trustProxy.
+func NewRateLimiter(limit int, window time.Duration, trustProxy bool) *RateLimiter {
+	return &RateLimiter{
+		limit:      limit,
+		window:     window,
+		trustProxy: trustProxy,
+		counts:     make(map[string]rateWindow),
+	}
+}
+
+// allow records a request from key and reports whether it is within the
+// limit; if not, it also returns how long until the window resets
+func (l *RateLimiter) allow(key string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Drop finished windows once per window so idle IPs don't accumulate
+	if now.Sub(l.lastSweep) >= l.window {
+		for k, c := range l.counts {
+			if now.Sub(c.start) >= l.window {
+				delete(l.counts, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	c, ok := l.counts[key]
+	if !ok || now.Sub(c.start) >= l.window {
+		c = rateWindow{start: now}
+	}
+	if c.count >= l.limit {
+		return false, c.start.Add(l.window).Sub(now)
+	}
+	c.count++
+	l.counts[key] = c
+	return true, 0
+}
+
+// Limit wraps next, answering 429 with a Retry-After header and code
+// rate_limited once the caller's IP has used up its window
+func (l *RateLimiter) Limit(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ok, wait := l.allow(ClientIP(r, l.trustProxy), time.Now())
+		if !ok {
+			seconds := int((wait + time.Second - 1) / time.Second)
+			w.Header().Set("Retry-After", strconv.Itoa(seconds))
+			ErrorResponseWithCode(w, http.StatusTooManyRequests, models.ErrorCodeRateLimited, "Too many requests; try again later")
+			return
+		}
+		next(w, r)
+	}
+}
@@ -7,12 +7,17 @@ import (
 	"bytes"
 	"encoding/json"
 	"io"
+	"log/slog"
+	"math"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/danielhkuo/quickly-pick/models"
+	"github.com/vmihailenco/msgpack/v5"
 )
 
 func TestWithLogging(t *testing.T) {
@@ -130,8 +135,8 @@ func TestJSONResponse(t *testing.T) {
 
 			// Check Content-Type header
 			contentType := w.Header().Get("Content-Type")
-			if contentType != "application/json" {
-				t.Errorf("Expected Content-Type 'application/json', got '%s'", contentType)
+			if contentType != "application/json; charset=utf-8" {
+				t.Errorf("Expected Content-Type 'application/json; charset=utf-8', got '%s'", contentType)
 			}
 
 			// Check body (trim newline added by Encode)
@@ -143,6 +148,28 @@ func TestJSONResponse(t *testing.T) {
 	}
 }
 
+func TestJSONResponseEncodeFailure(t *testing.T) {
+	w := httptest.NewRecorder()
+
+	// JSON has no encoding for infinity
+	JSONResponse(w, http.StatusCreated, map[string]float64{"score": math.Inf(1)})
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status %d, got %d", http.StatusInternalServerError, w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != JSONContentType {
+		t.Errorf("Expected Content-Type %q, got %q", JSONContentType, ct)
+	}
+
+	var resp models.ErrorResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Expected a well-formed error body: %v", err)
+	}
+	if resp.Message != "Failed to encode response" {
+		t.Errorf("Expected message 'Failed to encode response', got '%s'", resp.Message)
+	}
+}
+
 func TestErrorResponse(t *testing.T) {
 	testCases := []struct {
 		name           string
@@ -194,8 +221,8 @@ func TestErrorResponse(t *testing.T) {
 			}
 
 			// Check Content-Type
-			if w.Header().Get("Content-Type") != "application/json" {
-				t.Error("Expected Content-Type 'application/json'")
+			if w.Header().Get("Content-Type") != "application/json; charset=utf-8" {
+				t.Error("Expected Content-Type 'application/json; charset=utf-8'")
 			}
 
 			// Decode and verify error response
@@ -214,6 +241,40 @@ func TestErrorResponse(t *testing.T) {
 	}
 }
 
+func TestValidationErrorResponse(t *testing.T) {
+	fields := []models.FieldError{
+		{Field: "title", Code: models.FieldCodeRequired, Message: "title is required"},
+		{Field: "creator_name", Code: models.FieldCodeRequired, Message: "creator_name is required"},
+	}
+
+	w := httptest.NewRecorder()
+	ValidationErrorResponse(w, fields)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+
+	var resp models.ErrorResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if resp.Error != "Bad Request" {
+		t.Errorf("Expected error 'Bad Request', got '%s'", resp.Error)
+	}
+	if resp.Message != "title is required" {
+		t.Errorf("Expected first field message, got '%s'", resp.Message)
+	}
+	if len(resp.Fields) != 2 {
+		t.Fatalf("Expected 2 fields, got %d", len(resp.Fields))
+	}
+	for i, f := range fields {
+		if resp.Fields[i] != f {
+			t.Errorf("Expected field %+v, got %+v", f, resp.Fields[i])
+		}
+	}
+}
+
 func TestParseJSONBody(t *testing.T) {
 	t.Run("valid JSON", func(t *testing.T) {
 		body := `{"title":"Test Poll","creator_name":"Alice"}`
@@ -304,6 +365,106 @@ func TestParseJSONBody(t *testing.T) {
 	})
 }
 
+func TestDecodeRequiredJSON(t *testing.T) {
+	testCases := []struct {
+		name    string
+		body    string
+		wantErr error
+	}{
+		{"empty body", "", ErrEmptyBody},
+		{"whitespace body", "  \n", ErrEmptyBody},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/", strings.NewReader(tc.body))
+
+			var parsed models.CreatePollRequest
+			err := DecodeRequiredJSON(req, &parsed)
+			if err != tc.wantErr {
+				t.Errorf("Expected %v, got %v", tc.wantErr, err)
+			}
+		})
+	}
+
+	t.Run("no body", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/", nil)
+
+		var parsed models.CreatePollRequest
+		if err := DecodeRequiredJSON(req, &parsed); err != ErrEmptyBody {
+			t.Errorf("Expected ErrEmptyBody, got %v", err)
+		}
+	})
+
+	t.Run("malformed JSON", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(`{"title":`))
+
+		var parsed models.CreatePollRequest
+		err := DecodeRequiredJSON(req, &parsed)
+		if err == nil || err == ErrEmptyBody {
+			t.Errorf("Expected syntax error, got %v", err)
+		}
+	})
+
+	t.Run("valid JSON", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(`{"title":"Lunch"}`))
+
+		var parsed models.CreatePollRequest
+		if err := DecodeRequiredJSON(req, &parsed); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if parsed.Title != "Lunch" {
+			t.Errorf("Expected title 'Lunch', got '%s'", parsed.Title)
+		}
+	})
+}
+
+func TestDecodeOptionalJSON(t *testing.T) {
+	t.Run("empty bodies are accepted", func(t *testing.T) {
+		for _, body := range []string{"", "  "} {
+			req := httptest.NewRequest("POST", "/", strings.NewReader(body))
+
+			var parsed models.CreatePollRequest
+			if err := DecodeOptionalJSON(req, &parsed); err != nil {
+				t.Errorf("Expected no error for body %q, got %v", body, err)
+			}
+			if parsed.Title != "" {
+				t.Errorf("Expected zero value for body %q, got title '%s'", body, parsed.Title)
+			}
+		}
+	})
+
+	t.Run("no body", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/", nil)
+
+		var parsed struct{}
+		if err := DecodeOptionalJSON(req, &parsed); err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+	})
+
+	t.Run("malformed JSON", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(`{invalid`))
+
+		var parsed struct{}
+		if err := DecodeOptionalJSON(req, &parsed); err == nil {
+			t.Error("Expected error for malformed JSON")
+		}
+	})
+
+	t.Run("valid JSON", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(`{"title":"Lunch"}`))
+
+		var parsed models.CreatePollRequest
+		if err := DecodeOptionalJSON(req, &parsed); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if parsed.Title != "Lunch" {
+			t.Errorf("Expected title 'Lunch', got '%s'", parsed.Title)
+		}
+	})
+}
+
 func TestCORS(t *testing.T) {
 	// Create a simple handler that returns OK
 	nextHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -334,8 +495,8 @@ func TestCORS(t *testing.T) {
 		if w.Header().Get("Access-Control-Allow-Origin") != "http://localhost:5173" {
 			t.Error("Expected Access-Control-Allow-Origin to match request origin")
 		}
-		if w.Header().Get("Access-Control-Allow-Credentials") != "true" {
-			t.Error("Expected Access-Control-Allow-Credentials to be 'true'")
+		if w.Header().Get("Access-Control-Allow-Credentials") != "" {
+			t.Error("Expected no Access-Control-Allow-Credentials by default")
 		}
 	})
 
@@ -401,7 +562,7 @@ func TestCORS(t *testing.T) {
 
 		allowedMethods := w.Header().Get("Access-Control-Allow-Methods")
 
-		requiredMethods := []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
+		requiredMethods := []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
 		for _, method := range requiredMethods {
 			if !strings.Contains(allowedMethods, method) {
 				t.Errorf("Expected %s in allowed methods", method)
@@ -410,6 +571,84 @@ func TestCORS(t *testing.T) {
 	})
 }
 
+// routedHandler reports a fixed set of methods, as the router does
+type routedHandler struct {
+	http.HandlerFunc
+	methods []string
+}
+
+func (h routedHandler) RoutedMethods() []string { return h.methods }
+
+func TestCORSAllowMethods(t *testing.T) {
+	next := routedHandler{
+		HandlerFunc: func(w http.ResponseWriter, r *http.Request) {},
+		methods:     []string{"GET", "PATCH"},
+	}
+
+	preflight := func(handler http.Handler) string {
+		req := httptest.NewRequest("OPTIONS", "/api/polls", nil)
+		req.Header.Set("Origin", "http://localhost:5173")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Header().Get("Access-Control-Allow-Methods")
+	}
+
+	if got := preflight(CORS(next)); got != "GET, PATCH, OPTIONS" {
+		t.Errorf("Expected the handler's methods, got %q", got)
+	}
+
+	cors := CORSWithOptions(CORSOptions{AllowMethods: []string{"GET"}})
+	if got := preflight(cors(next)); got != "GET, OPTIONS" {
+		t.Errorf("Expected AllowMethods to take precedence, got %q", got)
+	}
+}
+
+func TestCORSPreflightCaching(t *testing.T) {
+	nextHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	t.Run("default options", func(t *testing.T) {
+		req := httptest.NewRequest("OPTIONS", "/api/polls", nil)
+		req.Header.Set("Origin", "http://localhost:5173")
+		w := httptest.NewRecorder()
+
+		CORS(nextHandler).ServeHTTP(w, req)
+
+		if got := w.Header().Get("Access-Control-Max-Age"); got != "600" {
+			t.Errorf("Expected Access-Control-Max-Age '600', got '%s'", got)
+		}
+		if got := w.Header().Get("Access-Control-Expose-Headers"); !strings.Contains(got, "X-Request-ID") {
+			t.Errorf("Expected X-Request-ID in exposed headers, got '%s'", got)
+		}
+	})
+
+	t.Run("configured max age", func(t *testing.T) {
+		req := httptest.NewRequest("OPTIONS", "/api/polls", nil)
+		w := httptest.NewRecorder()
+
+		CORSWithOptions(CORSOptions{MaxAge: 120, ExposeHeaders: DefaultExposeHeaders})(nextHandler).ServeHTTP(w, req)
+
+		if got := w.Header().Get("Access-Control-Max-Age"); got != "120" {
+			t.Errorf("Expected Access-Control-Max-Age '120', got '%s'", got)
+		}
+	})
+
+	t.Run("max age only on preflight", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/polls", nil)
+		w := httptest.NewRecorder()
+
+		CORS(nextHandler).ServeHTTP(w, req)
+
+		if got := w.Header().Get("Access-Control-Max-Age"); got != "" {
+			t.Errorf("Expected no Access-Control-Max-Age on non-preflight, got '%s'", got)
+		}
+		if got := w.Header().Get("Access-Control-Expose-Headers"); !strings.Contains(got, "X-Request-ID") {
+			t.Errorf("Expected X-Request-ID in exposed headers, got '%s'", got)
+		}
+	})
+}
+
 func TestGetClientIP(t *testing.T) {
 	testCases := []struct {
 		name       string
@@ -496,3 +735,462 @@ func TestGetClientIP(t *testing.T) {
 		})
 	}
 }
+
+func TestClientIP(t *testing.T) {
+	testCases := []struct {
+		name       string
+		headers    map[string]string
+		remoteAddr string
+		trustProxy bool
+		expectedIP string
+	}{
+		{
+			name:       "forwarded headers ignored by default",
+			headers:    map[string]string{"X-Forwarded-For": "192.168.1.100", "X-Real-IP": "203.0.113.50"},
+			remoteAddr: "10.0.0.1:12345",
+			expectedIP: "10.0.0.1",
+		},
+		{
+			name:       "IPv6 RemoteAddr loses its port and brackets",
+			remoteAddr: "[::1]:12345",
+			expectedIP: "::1",
+		},
+		{
+			name:       "RemoteAddr without port",
+			remoteAddr: "192.168.1.50",
+			expectedIP: "192.168.1.50",
+		},
+		{
+			name:       "trusted proxy's entry is the last one",
+			headers:    map[string]string{"X-Forwarded-For": "1.2.3.4, 203.0.113.195"},
+			remoteAddr: "10.0.0.1:12345",
+			trustProxy: true,
+			expectedIP: "203.0.113.195",
+		},
+		{
+			name:       "trusted X-Real-IP without X-Forwarded-For",
+			headers:    map[string]string{"X-Real-IP": "203.0.113.50"},
+			remoteAddr: "10.0.0.1:12345",
+			trustProxy: true,
+			expectedIP: "203.0.113.50",
+		},
+		{
+			name:       "trusted but no headers falls back to RemoteAddr",
+			remoteAddr: "10.0.0.5:8080",
+			trustProxy: true,
+			expectedIP: "10.0.0.5",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = tc.remoteAddr
+			for k, v := range tc.headers {
+				req.Header.Set(k, v)
+			}
+
+			if result := ClientIP(req, tc.trustProxy); result != tc.expectedIP {
+				t.Errorf("Expected IP '%s', got '%s'", tc.expectedIP, result)
+			}
+		})
+	}
+
+	// A spoofed header sent as a separate line comes before the proxy's
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Add("X-Forwarded-For", "1.2.3.4")
+	req.Header.Add("X-Forwarded-For", "203.0.113.7")
+	if result := ClientIP(req, true); result != "203.0.113.7" {
+		t.Errorf("Expected the last X-Forwarded-For line, got '%s'", result)
+	}
+}
+
+func TestCORSCredentialModes(t *testing.T) {
+	nextHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	request := func(opts CORSOptions, origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/polls", nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		w := httptest.NewRecorder()
+		CORSWithOptions(opts)(nextHandler).ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("public mode allows any origin without credentials", func(t *testing.T) {
+		opts := DefaultCORSOptions()
+
+		w := request(opts, "https://anywhere.example")
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://anywhere.example" {
+			t.Errorf("Expected the request origin to be allowed, got %q", got)
+		}
+		if got := w.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+			t.Errorf("Expected no credentials header, got %q", got)
+		}
+
+		w = request(opts, "")
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+			t.Errorf("Expected wildcard origin without an Origin header, got %q", got)
+		}
+	})
+
+	t.Run("credentialed mode only allows listed origins", func(t *testing.T) {
+		opts := DefaultCORSOptions()
+		opts.AllowedOrigins = []string{"https://app.example"}
+		opts.AllowCredentials = true
+
+		w := request(opts, "https://app.example")
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example" {
+			t.Errorf("Expected the listed origin to be allowed, got %q", got)
+		}
+		if got := w.Header().Get("Access-Control-Allow-Credentials"); got != "true" {
+			t.Errorf("Expected credentials to be allowed, got %q", got)
+		}
+		if got := w.Header().Get("Vary"); !strings.Contains(got, "Origin") {
+			t.Errorf("Expected Vary to include Origin, got %q", got)
+		}
+
+		for _, origin := range []string{"https://evil.example", ""} {
+			w := request(opts, origin)
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+				t.Errorf("Expected no allowed origin for %q, got %q", origin, got)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+				t.Errorf("Expected no credentials header for %q, got %q", origin, got)
+			}
+		}
+	})
+
+	t.Run("credentials without an allowlist allow no origin", func(t *testing.T) {
+		opts := DefaultCORSOptions()
+		opts.AllowCredentials = true
+
+		w := request(opts, "https://app.example")
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+			t.Errorf("Expected no allowed origin, got %q", got)
+		}
+	})
+}
+
+func TestNegotiatedResponse(t *testing.T) {
+	shareSlug := "k3x9m2"
+	data := models.PollWithOptions{
+		Poll: models.Poll{ID: "poll123", Title: "Lunch?", Status: models.StatusOpen, ShareSlug: &shareSlug},
+		Options: []models.Option{
+			{ID: "opt1", PollID: "poll123", Label: "Tacos", Metadata: models.OptionMetadata(`{"color":"#f80","rank":2,"tags":["spicy"]}`)},
+			{ID: "opt2", PollID: "poll123", Label: "Ramen", Description: "Noodles"},
+		},
+	}
+
+	t.Run("msgpack when accepted", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/polls/slug", nil)
+		req.Header.Set("Accept", "application/json;q=0.5, application/msgpack")
+		w := httptest.NewRecorder()
+
+		NegotiatedResponse(w, req, http.StatusOK, data)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		if got := w.Header().Get("Content-Type"); got != MsgpackContentType {
+			t.Errorf("Expected Content-Type %q, got %q", MsgpackContentType, got)
+		}
+		if got := w.Header().Get("Vary"); got != "Accept" {
+			t.Errorf("Expected Vary 'Accept', got %q", got)
+		}
+
+		// Field names follow the json tags
+		var raw map[string]interface{}
+		if err := msgpack.Unmarshal(w.Body.Bytes(), &raw); err != nil {
+			t.Fatalf("Failed to decode msgpack: %v", err)
+		}
+		if _, ok := raw["options"]; !ok {
+			t.Errorf("Expected an options key, got %v", raw)
+		}
+
+		// Metadata is a map, not its JSON text as binary
+		options, _ := raw["options"].([]interface{})
+		if len(options) != 2 {
+			t.Fatalf("Expected 2 options, got %v", raw["options"])
+		}
+		first, _ := options[0].(map[string]interface{})
+		metadata, ok := first["metadata"].(map[string]interface{})
+		if !ok {
+			t.Fatalf("Expected metadata to be a map, got %T", first["metadata"])
+		}
+		if metadata["color"] != "#f80" || metadata["rank"] != int8(2) {
+			t.Errorf("Expected color and integer rank in metadata, got %v", metadata)
+		}
+		if second, _ := options[1].(map[string]interface{}); second["metadata"] != nil {
+			t.Errorf("Expected no metadata on the second option, got %v", second["metadata"])
+		}
+
+		var decoded models.PollWithOptions
+		dec := msgpack.NewDecoder(bytes.NewReader(w.Body.Bytes()))
+		dec.SetCustomStructTag("json")
+		if err := dec.Decode(&decoded); err != nil {
+			t.Fatalf("Failed to decode msgpack: %v", err)
+		}
+		if decoded.Poll.ID != "poll123" || decoded.Poll.Title != "Lunch?" {
+			t.Errorf("Expected poll123 'Lunch?', got %s %q", decoded.Poll.ID, decoded.Poll.Title)
+		}
+		if decoded.Poll.ShareSlug == nil || *decoded.Poll.ShareSlug != shareSlug {
+			t.Errorf("Expected share slug %q, got %v", shareSlug, decoded.Poll.ShareSlug)
+		}
+		if len(decoded.Options) != 2 || decoded.Options[1].Description != "Noodles" {
+			t.Errorf("Expected both options to round-trip, got %+v", decoded.Options)
+		}
+		if got := string(decoded.Options[0].Metadata); got != `{"color":"#f80","rank":2,"tags":["spicy"]}` {
+			t.Errorf("Expected metadata to round-trip, got %s", got)
+		}
+	})
+
+	t.Run("json by default", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/polls/slug", nil)
+		req.Header.Set("Accept", "*/*")
+		w := httptest.NewRecorder()
+
+		NegotiatedResponse(w, req, http.StatusOK, data)
+
+		if got := w.Header().Get("Content-Type"); got != "application/json; charset=utf-8" {
+			t.Errorf("Expected Content-Type 'application/json; charset=utf-8', got %q", got)
+		}
+		var decoded models.PollWithOptions
+		if err := json.NewDecoder(w.Body).Decode(&decoded); err != nil {
+			t.Fatalf("Failed to decode JSON: %v", err)
+		}
+		if decoded.Poll.ID != "poll123" {
+			t.Errorf("Expected poll123, got %s", decoded.Poll.ID)
+		}
+		if got := string(decoded.Options[0].Metadata); got != `{"color":"#f80","rank":2,"tags":["spicy"]}` {
+			t.Errorf("Expected metadata as a JSON object, got %s", got)
+		}
+		if decoded.Options[1].Metadata != nil {
+			t.Errorf("Expected no metadata on the second option, got %s", decoded.Options[1].Metadata)
+		}
+	})
+}
+
+func TestRateLimiter(t *testing.T) {
+	limiter := NewRateLimiter(2, time.Minute, false)
+	handler := limiter.Limit(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	forwardedFor := 0
+	request := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/polls/abc/public-status", nil)
+		req.RemoteAddr = remoteAddr
+		// A fresh X-Forwarded-For each time must not reset the count
+		forwardedFor++
+		req.Header.Set("X-Forwarded-For", "203.0.113."+strconv.Itoa(forwardedFor))
+		w := httptest.NewRecorder()
+		handler(w, req)
+		return w
+	}
+
+	for i := 0; i < 2; i++ {
+		if w := request("192.0.2.1:1234"); w.Code != http.StatusOK {
+			t.Fatalf("Request %d: expected status %d, got %d", i+1, http.StatusOK, w.Code)
+		}
+	}
+
+	w := request("192.0.2.1:5678")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status %d, got %d", http.StatusTooManyRequests, w.Code)
+	}
+	if retryAfter := w.Header().Get("Retry-After"); retryAfter == "" || retryAfter == "0" {
+		t.Errorf("Expected a positive Retry-After, got %q", retryAfter)
+	}
+	var resp models.ErrorResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Code != models.ErrorCodeRateLimited {
+		t.Errorf("Expected code %q, got %q", models.ErrorCodeRateLimited, resp.Code)
+	}
+
+	// Other IPs keep their own count
+	if w := request("198.51.100.7:1234"); w.Code != http.StatusOK {
+		t.Errorf("Expected another IP to be allowed, got %d", w.Code)
+	}
+
+	// A new window resets the count
+	start := time.Now()
+	if ok, _ := limiter.allow("192.0.2.1", start.Add(time.Minute+time.Second)); !ok {
+		t.Error("Expected the limit to reset after the window")
+	}
+
+	// Behind a trusted proxy every client shares RemoteAddr, so the
+	// forwarded address is the key
+	trusted := NewRateLimiter(1, time.Minute, true).Limit(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	for _, client := range []string{"203.0.113.1", "203.0.113.2"} {
+		req := httptest.NewRequest("GET", "/polls/abc/public-status", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		req.Header.Set("X-Forwarded-For", client)
+		w := httptest.NewRecorder()
+		trusted(w, req)
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected %s behind the proxy to be allowed, got %d", client, w.Code)
+		}
+	}
+}
+
+func TestCamelCaseKeys(t *testing.T) {
+	data := map[string]interface{}{
+		"ballot_count": 3,
+		"rankings": []models.OptionStats{
+			{OptionID: "opt1", NegShare: 0.25},
+		},
+	}
+
+	got, err := CamelCaseKeys(data)
+	if err != nil {
+		t.Fatalf("CamelCaseKeys failed: %v", err)
+	}
+	encoded, _ := json.Marshal(got)
+
+	var decoded struct {
+		BallotCount int `json:"ballotCount"`
+		Rankings    []struct {
+			OptionID string  `json:"optionId"`
+			NegShare float64 `json:"negShare"`
+		} `json:"rankings"`
+	}
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatalf("Failed to decode result: %v", err)
+	}
+	if decoded.BallotCount != 3 {
+		t.Errorf("Expected ballotCount 3, got %d", decoded.BallotCount)
+	}
+	if len(decoded.Rankings) != 1 || decoded.Rankings[0].OptionID != "opt1" || decoded.Rankings[0].NegShare != 0.25 {
+		t.Errorf("Expected camelCase ranking, got %s", encoded)
+	}
+	if strings.Contains(string(encoded), "_") {
+		t.Errorf("Expected no snake_case keys, got %s", encoded)
+	}
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	handler := SecurityHeaders(SecurityHeaderOptions{ServerName: "quickly-pick"})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			JSONResponse(w, http.StatusOK, map[string]string{"status": "ok"})
+		}))
+
+	req := httptest.NewRequest("GET", "/test", nil)
+	w := httptest.NewRecorder()
+	CORS(handler).ServeHTTP(w, req)
+
+	if got := w.Header().Get("X-Content-Type-Options"); got != "nosniff" {
+		t.Errorf("Expected X-Content-Type-Options nosniff, got %q", got)
+	}
+	if got := w.Header().Get("Content-Security-Policy"); got != DefaultContentSecurityPolicy {
+		t.Errorf("Expected Content-Security-Policy %q, got %q", DefaultContentSecurityPolicy, got)
+	}
+	if got := w.Header().Get("Server"); got != "quickly-pick" {
+		t.Errorf("Expected Server quickly-pick, got %q", got)
+	}
+
+	// Content type and CORS headers are untouched
+	if ct := w.Header().Get("Content-Type"); ct != "application/json; charset=utf-8" {
+		t.Errorf("Expected Content-Type application/json; charset=utf-8, got %q", ct)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("Expected Access-Control-Allow-Origin *, got %q", got)
+	}
+
+	// A handler can still set its own policy
+	handler = SecurityHeaders(SecurityHeaderOptions{})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Security-Policy", "default-src 'self'")
+			w.WriteHeader(http.StatusOK)
+		}))
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if got := w.Header().Get("Content-Security-Policy"); got != "default-src 'self'" {
+		t.Errorf("Expected the handler's policy to win, got %q", got)
+	}
+	if got := w.Header().Get("Server"); got != "" {
+		t.Errorf("Expected no Server header without ServerName, got %q", got)
+	}
+}
+
+func TestRedactHeaders(t *testing.T) {
+	header := http.Header{}
+	header.Set("X-Admin-Key", "admin-secret")
+	header.Set("x-voter-token", "voter-secret")
+	header.Add("Authorization", "Bearer one")
+	header.Add("Authorization", "Bearer two")
+	header.Set("X-Device-UUID", "device-1")
+	header.Set("Idempotency-Key", "retry-1")
+	header.Set("Cookie", "session=secret")
+	header.Set("Proxy-Authorization", "Basic secret")
+	header.Set("User-Agent", "quickly-pick-test")
+
+	redacted := RedactHeaders(header)
+
+	for _, name := range []string{"X-Admin-Key", "X-Voter-Token", "Authorization",
+		"X-Device-UUID", "Idempotency-Key", "Cookie", "Proxy-Authorization"} {
+		if len(redacted.Values(name)) == 0 {
+			t.Errorf("Expected %s in the redacted copy", name)
+		}
+		for _, value := range redacted.Values(name) {
+			if value != RedactedHeaderValue {
+				t.Errorf("Expected %s to be redacted, got %q", name, value)
+			}
+		}
+	}
+	if got := len(redacted.Values("Authorization")); got != 2 {
+		t.Errorf("Expected both Authorization values masked, got %d", got)
+	}
+	if got := redacted.Get("User-Agent"); got != "quickly-pick-test" {
+		t.Errorf("Expected User-Agent kept, got %q", got)
+	}
+
+	// The request's own headers are untouched
+	if got := header.Get("X-Admin-Key"); got != "admin-secret" {
+		t.Errorf("Expected original X-Admin-Key unchanged, got %q", got)
+	}
+}
+
+func TestLogHeaders(t *testing.T) {
+	var logs bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, nil)))
+	defer slog.SetDefault(previous)
+
+	called := false
+	handler := LogHeaders(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		if got := r.Header.Get("X-Admin-Key"); got != "admin-secret" {
+			t.Errorf("Expected handler to see the real X-Admin-Key, got %q", got)
+		}
+	}))
+
+	req := httptest.NewRequest("GET", "/polls/abc/admin", nil)
+	req.Header.Set("X-Admin-Key", "admin-secret")
+	req.Header.Set("X-Voter-Token", "voter-secret")
+	req.Header.Set("Authorization", "Bearer token-secret")
+	req.Header.Set("X-Device-UUID", "device-secret")
+	req.Header.Set("User-Agent", "quickly-pick-test")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if !called {
+		t.Fatal("Expected the wrapped handler to be called")
+	}
+	output := logs.String()
+	for _, secret := range []string{"admin-secret", "voter-secret", "token-secret", "device-secret"} {
+		if strings.Contains(output, secret) {
+			t.Errorf("Header log leaks %q: %s", secret, output)
+		}
+	}
+	if !strings.Contains(output, RedactedHeaderValue) {
+		t.Errorf("Expected redacted values in the header log: %s", output)
+	}
+	if !strings.Contains(output, "quickly-pick-test") {
+		t.Errorf("Expected other headers in the header log: %s", output)
+	}
+}
@@ -8,10 +8,21 @@ Package models defines request, response, and domain types for the API.
 
 Types for parsing incoming JSON:
 
-  - CreatePollRequest: title, description, creator_name
-  - AddOptionRequest: label
+  - CreatePollRequest: title, description, creator_name, min_scored_options,
+    live_results, closes_at, creator_contact, exclude_test_ballots,
+    default_unscored, allow_ballot_updates, min_username_length,
+    max_username_length, creator_can_vote
+  - AddOptionRequest: label, description, metadata
+  - SetVanitySlugRequest: vanity_slug
+  - SetClosesAtRequest: closes_at (null clears it)
+  - SetCloseWebhookRequest: close_webhook_url (empty removes it)
+  - CloseBatchRequest: polls (id and admin_key for each)
+  - ClosePollRequest: reveal_at (optional results embargo)
+  - SetRevealAtRequest: reveal_at (null lifts the embargo)
   - ClaimUsernameRequest: username
+  - RenameUsernameRequest: username
   - SubmitBallotRequest: scores (map[string]float64)
+  - GetPreviewsRequest: slugs
   - RegisterDeviceRequest: platform
 
 # Response Types
@@ -20,22 +31,69 @@ Types for JSON responses:
 
   - CreatePollResponse: poll_id, admin_key
   - AddOptionResponse: option_id
+  - StabilityResponse: poll_id, resamples, ballot_count, options (rank and win_share)
+  - ImportOptionsResponse: option_ids
+  - DeleteOptionResponse: option_id, option_count
   - PublishPollResponse: share_slug, share_url
-  - ClaimUsernameResponse: voter_token
+  - SetVanitySlugResponse: vanity_slug, share_slug
+  - SetClosesAtResponse: closes_at
+  - PollPausedResponse: paused
+  - SetRevealAtResponse: reveal_at
+  - SetCloseWebhookResponse: close_webhook_url, webhook_secret
+  - CloseWebhookPayload: event, poll_id, closed_at, snapshot (POSTed on close)
+  - ClaimUsernameResponse: voter_token, device_linked, username (when the
+    device had already claimed one)
+  - RenameUsernameResponse: username
   - SubmitBallotResponse: ballot_id, message
-  - ClosePollResponse: closed_at, snapshot
-  - ErrorResponse: error, message
+  - ActiveDevicesResponse: window, since, active_devices
+  - ValidateBallotResponse: valid
+  - RecomputeResultsResponse: previous_snapshot_id, snapshot
+  - ClosePollResponse: closed_at, snapshot, breakdown (username → scores, on request),
+    compute_ms, ballot_count
+  - CloseBatchResponse: results (id, outcome, closed_at, snapshot_id,
+    closable_at)
+  - GetPreviewsResponse: previews (slug plus preview fields, or not_found)
+  - PollPublicStatusResponse: title, status, option_count
+  - PollAdminResponse: poll, options, future_share_slug, future_share_url,
+    creator_contact
+  - ResultsHistoryResponse: poll_id, snapshots (newest first, compact rankings)
+  - PollSummaryResponse: poll, options, ballot_count, voter_count, has_voted
+  - ReadinessResponse: status, database, schema_version, expected_schema_version
+  - ErrorResponse: error, message, code, fields (validation errors)
+  - ResultsEmbargoedResponse: the ErrorResponse fields plus reveal_at
+
+Voting endpoints set code to poll_not_found, poll_draft (voting hasn't
+started), poll_closed (voting has ended), or poll_paused (the admin has
+halted voting). SubmitBallot sets too_few_scores when a ballot scores
+fewer options than the poll's min_scored_options, ip_ballot_limit when
+the caller's IP has already cast the server's maximum number of ballots
+on the poll, poll_full when the poll itself holds that many, ballot_locked when the poll doesn't allow ballot updates
+and the voter has already voted, and poll_has_no_options when an open
+poll has lost all its options. ClaimUsername and SubmitBallot set
+creator_voting_disabled when the poll keeps its creator from voting and
+the request comes from the creator's device. Poll management sets invalid_closes_at when closes_at is not
+after both the current time and opened_at, and duplicate_option when
+AddOption is given a label the poll already has. CreatePoll and
+DuplicatePoll set poll_create_too_soon when the client created another poll within the
+server's minimum interval, and CreatePoll sets idempotency_key_reused when
+an Idempotency-Key is reused with a different request body. GetResults sets
+results_embargoed for a closed poll whose reveal_at hasn't passed, and
+snapshot_missing when a closed poll's final snapshot can't be found.
 
 # Domain Types
 
 Internal data structures:
 
   - Poll: poll metadata and lifecycle state
-  - Option: voting option with label
+  - Option: voting option with label and optional description and metadata
   - Ballot: voter submission metadata
   - Score: individual option score (0-1)
   - OptionStats: BMJ statistics for an option
-  - ResultSnapshot: immutable result record
+  - ResultSnapshot: immutable result record, with the method, veto
+    threshold, and algorithm version it was computed with
+  - ResultsTie: option_ids sharing first place before the final tie-break
+  - ScoreScale: min, mid, and max of the signed axis, and the transform
+    from value01 onto it
 
 # Constants
 
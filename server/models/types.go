@@ -3,7 +3,13 @@
 
 package models
 
-import "time"
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
 
 // Poll status constants
 const (
@@ -17,22 +23,47 @@ const (
 	MethodBMJ = "bmj"
 )
 
+// Option result status constants
+const (
+	OptionStatusWinner = "winner"
+	OptionStatusVetoed = "vetoed"
+	OptionStatusRanked = "ranked"
+)
+
 // Request types
 
 type CreatePollRequest struct {
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	CreatorName string `json:"creator_name"`
+	Title            string     `json:"title"`
+	Description      string     `json:"description"`
+	CreatorName      string     `json:"creator_name"`
+	MinOpenSeconds   int        `json:"min_open_seconds,omitempty"`   // 0 = can close immediately
+	MinScoredOptions int        `json:"min_scored_options,omitempty"` // 0 = default of 1
+	LiveResults      bool       `json:"live_results,omitempty"`       // show provisional results while open
+	ClosesAt         *time.Time `json:"closes_at,omitempty"`          // must be in the future
+	CreatorContact   string     `json:"creator_contact,omitempty"`    // admin-only, e.g. an email or push token
+
+	ExcludeTestBallots bool     `json:"exclude_test_ballots,omitempty"` // leave the admin device's ballots out of the rankings
+	DefaultUnscored    *float64 `json:"default_unscored,omitempty"`     // 0-1 score assumed for options a ballot leaves unscored
+	AllowBallotUpdates *bool    `json:"allow_ballot_updates,omitempty"` // nil = true; false locks each ballot once submitted
+	MinUsernameLength  *int     `json:"min_username_length,omitempty"`  // nil = the server's; must lie within the server's bounds
+	MaxUsernameLength  *int     `json:"max_username_length,omitempty"`  // nil = the server's; must lie within the server's bounds
+	CreatorCanVote     *bool    `json:"creator_can_vote,omitempty"`     // nil = true; false refuses the admin device's claims and ballots
 }
 
 type AddOptionRequest struct {
-	Label string `json:"label"`
+	Label       string          `json:"label"`
+	Description string          `json:"description,omitempty"`
+	Metadata    json.RawMessage `json:"metadata,omitempty"` // client-defined JSON object, stored as-is
 }
 
 type ClaimUsernameRequest struct {
 	Username string `json:"username"`
 }
 
+type RenameUsernameRequest struct {
+	Username string `json:"username"`
+}
+
 // option_id -> value01 (0.0 to 1.0)
 type SubmitBallotRequest struct {
 	Scores map[string]float64 `json:"scores"`
@@ -49,13 +80,93 @@ type AddOptionResponse struct {
 	OptionID string `json:"option_id"`
 }
 
+type ImportOptionsResponse struct {
+	OptionIDs []string `json:"option_ids"` // in line order
+}
+
+type DeleteOptionResponse struct {
+	OptionID    string `json:"option_id"`
+	OptionCount int    `json:"option_count"` // options remaining
+}
+
+type SetVanitySlugRequest struct {
+	VanitySlug string `json:"vanity_slug"`
+}
+
+type SetVanitySlugResponse struct {
+	VanitySlug string `json:"vanity_slug"`
+	ShareSlug  string `json:"share_slug"`
+}
+
+// SetClosesAtRequest schedules when the poll closes; a null closes_at
+// clears the schedule
+type SetClosesAtRequest struct {
+	ClosesAt *time.Time `json:"closes_at"`
+}
+
+type SetClosesAtResponse struct {
+	ClosesAt *time.Time `json:"closes_at"`
+}
+
+// ClosePollRequest is the optional body of a close request. A reveal_at
+// keeps the results from the public until that time.
+type ClosePollRequest struct {
+	RevealAt *time.Time `json:"reveal_at,omitempty"`
+}
+
+// SetRevealAtRequest moves a closed poll's results embargo; a null
+// reveal_at lifts it
+type SetRevealAtRequest struct {
+	RevealAt *time.Time `json:"reveal_at"`
+}
+
+type SetRevealAtResponse struct {
+	RevealAt *time.Time `json:"reveal_at"`
+}
+
+// PollPausedResponse reports whether voting is paused after a pause or
+// resume request
+type PollPausedResponse struct {
+	Paused bool `json:"paused"`
+}
+
+// SetCloseWebhookRequest sets the URL notified when the poll closes; an
+// empty URL removes it
+type SetCloseWebhookRequest struct {
+	CloseWebhookURL string `json:"close_webhook_url"`
+}
+
+// SetCloseWebhookResponse returns the secret deliveries are signed with
+type SetCloseWebhookResponse struct {
+	CloseWebhookURL string `json:"close_webhook_url"`
+	WebhookSecret   string `json:"webhook_secret,omitempty"`
+}
+
+// CloseWebhookPayload is the body POSTed to a poll's close webhook. It
+// carries the sealed snapshot but never the per-voter breakdown.
+type CloseWebhookPayload struct {
+	Event    string         `json:"event"`
+	PollID   string         `json:"poll_id"`
+	ClosedAt time.Time      `json:"closed_at"`
+	Snapshot ResultSnapshot `json:"snapshot"`
+}
+
+// WebhookEventPollClosed is the Event of a CloseWebhookPayload
+const WebhookEventPollClosed = "poll.closed"
+
 type PublishPollResponse struct {
 	ShareSlug string `json:"share_slug"`
 	ShareURL  string `json:"share_url"`
 }
 
 type ClaimUsernameResponse struct {
-	VoterToken string `json:"voter_token"`
+	VoterToken   string `json:"voter_token"`
+	DeviceLinked *bool  `json:"device_linked,omitempty"` // only when X-Device-UUID was sent
+	Username     string `json:"username,omitempty"`      // only when the device had already claimed one; the request's name is ignored
+}
+
+type RenameUsernameResponse struct {
+	Username string `json:"username"`
 }
 
 type SubmitBallotResponse struct {
@@ -63,31 +174,209 @@ type SubmitBallotResponse struct {
 	Message  string `json:"message"`
 }
 
+// SyncBallotsRequest lists ballots queued offline, each for its own poll
+type SyncBallotsRequest struct {
+	Ballots []SyncBallotEntry `json:"ballots"`
+}
+
+// SyncBallotEntry is one ballot in a SyncBallotsRequest
+type SyncBallotEntry struct {
+	Slug       string          `json:"slug"`
+	VoterToken string          `json:"voter_token"`
+	Scores     json.RawMessage `json:"scores"` // as in SubmitBallotRequest
+}
+
+// SyncBallotResult reports what happened to one ballot in a sync. Status
+// is what SubmitBallot would have answered; error is its body on failure.
+type SyncBallotResult struct {
+	Slug     string         `json:"slug"`
+	Status   int            `json:"status"`
+	BallotID string         `json:"ballot_id,omitempty"` // only when accepted
+	Message  string         `json:"message,omitempty"`   // only when accepted
+	Error    *ErrorResponse `json:"error,omitempty"`     // only when refused
+}
+
+// SyncBallotsResponse holds one result per synced ballot, in request order
+type SyncBallotsResponse struct {
+	Results []SyncBallotResult `json:"results"`
+}
+
+// ValidateBallotResponse answers a SubmitBallot call made with
+// validate_only=true; invalid ballots get the usual error response instead
+type ValidateBallotResponse struct {
+	Valid bool `json:"valid"`
+}
+
 type ClosePollResponse struct {
-	ClosedAt time.Time      `json:"closed_at"`
-	Snapshot ResultSnapshot `json:"snapshot"`
+	ClosedAt    time.Time        `json:"closed_at"`
+	Snapshot    ResultSnapshot   `json:"snapshot"`
+	Breakdown   []VoterBreakdown `json:"breakdown,omitempty"` // only with include_breakdown=true and ballots cast
+	ComputeMS   int64            `json:"compute_ms"`          // milliseconds spent ranking the ballots
+	BallotCount int              `json:"ballot_count"`        // ballots sealed, test ballots included
+}
+
+// CloseBatchRequest lists the polls to close, each with its own admin key
+type CloseBatchRequest struct {
+	Polls []CloseBatchEntry `json:"polls"`
+}
+
+// CloseBatchEntry is one poll in a CloseBatchRequest
+type CloseBatchEntry struct {
+	ID       string `json:"id"`
+	AdminKey string `json:"admin_key"`
+}
+
+// Outcomes of one poll in a batch close
+const (
+	CloseOutcomeClosed         = "closed"
+	CloseOutcomeSkippedNotOpen = "skipped_not_open"
+	CloseOutcomeUnauthorized   = "unauthorized"
+	CloseOutcomeNotFound       = "not_found"
+	CloseOutcomeTooEarly       = "too_early" // still within min_open_seconds
+	CloseOutcomeFailed         = "failed"
+)
+
+// CloseBatchResult reports what happened to one poll in a batch close
+type CloseBatchResult struct {
+	ID         string     `json:"id"`
+	Outcome    string     `json:"outcome"`
+	ClosedAt   *time.Time `json:"closed_at,omitempty"`   // only when closed
+	SnapshotID string     `json:"snapshot_id,omitempty"` // only when closed
+	ClosableAt *time.Time `json:"closable_at,omitempty"` // only when too_early
+}
+
+// CloseBatchResponse holds one result per requested poll, in request order
+type CloseBatchResponse struct {
+	Results []CloseBatchResult `json:"results"`
+}
+
+// RecomputeResultsResponse returns the new final snapshot; the previous
+// one remains in the results history
+type RecomputeResultsResponse struct {
+	PreviousSnapshotID string         `json:"previous_snapshot_id,omitempty"`
+	Snapshot           ResultSnapshot `json:"snapshot"`
+}
+
+// VoterBreakdown is one voter's ballot, keyed by username rather than
+// voter token. It appears only in the close response and is never stored.
+type VoterBreakdown struct {
+	Username string             `json:"username"`
+	Scores   map[string]float64 `json:"scores"`
 }
 
 // Domain types
 
 type Poll struct {
-	ID              string     `json:"id"`
-	Title           string     `json:"title"`
-	Description     string     `json:"description"`
-	CreatorName     string     `json:"creator_name"`
-	Method          string     `json:"method"`
-	Status          string     `json:"status"`
-	ShareSlug       *string    `json:"share_slug,omitempty"`
-	ClosesAt        *time.Time `json:"closes_at,omitempty"`
-	ClosedAt        *time.Time `json:"closed_at,omitempty"`
-	FinalSnapshotID *string    `json:"final_snapshot_id,omitempty"`
-	CreatedAt       time.Time  `json:"created_at"`
+	ID               string     `json:"id"`
+	Title            string     `json:"title"`
+	Description      string     `json:"description"`
+	DescriptionSafe  *string    `json:"description_safe,omitempty"` // Description without HTML, escaped; only with cfg.SafeDescriptions, then always, even when empty
+	CreatorName      string     `json:"creator_name"`
+	Method           string     `json:"method"`
+	Status           string     `json:"status"`
+	ShareSlug        *string    `json:"share_slug,omitempty"`
+	VanitySlug       *string    `json:"vanity_slug,omitempty"`
+	ClosesAt         *time.Time `json:"closes_at,omitempty"`
+	ClosedAt         *time.Time `json:"closed_at,omitempty"`
+	FinalSnapshotID  *string    `json:"final_snapshot_id,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+	OpenedAt         *time.Time `json:"opened_at,omitempty"`
+	MinOpenSeconds   int        `json:"min_open_seconds"`
+	MinScoredOptions int        `json:"min_scored_options"`
+	LiveResults      bool       `json:"live_results"`
+	Paused           bool       `json:"paused"`                 // open, but not accepting voters or ballots
+	RevealAt         *time.Time `json:"reveal_at,omitempty"`    // closed, but results hidden from the public until then
+	OptionsHash      *string    `json:"options_hash,omitempty"` // SHA-256 of the option labels in display order, fixed at publish
+
+	ExcludeTestBallots bool     `json:"exclude_test_ballots"`          // rankings leave out ballots from the admin's device
+	DefaultUnscored    *float64 `json:"default_unscored,omitempty"`    // rankings fill in unscored options with this 0-1 score
+	AllowBallotUpdates bool     `json:"allow_ballot_updates"`          // false: a voter's first ballot is final
+	MinUsernameLength  *int     `json:"min_username_length,omitempty"` // omitted when the server's bound applies
+	MaxUsernameLength  *int     `json:"max_username_length,omitempty"` // omitted when the server's bound applies
+	CreatorCanVote     bool     `json:"creator_can_vote"`              // false: the admin device may not claim a username or vote
 }
 
 type Option struct {
-	ID     string `json:"id"`
-	PollID string `json:"poll_id"`
-	Label  string `json:"label"`
+	ID          string         `json:"id"`
+	PollID      string         `json:"poll_id"`
+	Label       string         `json:"label"`
+	Description string         `json:"description,omitempty"`
+	Position    int            `json:"position"`           // display order within the poll
+	Metadata    OptionMetadata `json:"metadata,omitempty"` // client-defined, e.g. a color or icon
+}
+
+// OptionMetadata is an option's client-defined JSON object, kept as the
+// raw JSON the client sent. It encodes as that JSON, and in MessagePack as
+// the equivalent map rather than as the JSON text in a binary blob.
+type OptionMetadata json.RawMessage
+
+// MarshalJSON returns the metadata as-is, or null when there is none
+func (m OptionMetadata) MarshalJSON() ([]byte, error) {
+	if len(m) == 0 {
+		return []byte("null"), nil
+	}
+	return m, nil
+}
+
+// UnmarshalJSON keeps a copy of data
+func (m *OptionMetadata) UnmarshalJSON(data []byte) error {
+	*m = append((*m)[:0], data...)
+	return nil
+}
+
+// EncodeMsgpack writes the metadata as a MessagePack map, with whole
+// numbers as integers
+func (m OptionMetadata) EncodeMsgpack(enc *msgpack.Encoder) error {
+	if len(m) == 0 {
+		return enc.EncodeNil()
+	}
+	dec := json.NewDecoder(bytes.NewReader(m))
+	dec.UseNumber()
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return err
+	}
+	return enc.Encode(msgpackNumbers(value))
+}
+
+// DecodeMsgpack reads a MessagePack value back into JSON
+func (m *OptionMetadata) DecodeMsgpack(dec *msgpack.Decoder) error {
+	value, err := dec.DecodeInterface()
+	if err != nil {
+		return err
+	}
+	if value == nil {
+		*m = nil
+		return nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	*m = data
+	return nil
+}
+
+// msgpackNumbers replaces each json.Number in value with an int64 when it
+// is a whole number that fits, and a float64 otherwise
+func msgpackNumbers(value any) any {
+	switch v := value.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]any:
+		for key, item := range v {
+			v[key] = msgpackNumbers(item)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = msgpackNumbers(item)
+		}
+	}
+	return value
 }
 
 type PollWithOptions struct {
@@ -95,6 +384,17 @@ type PollWithOptions struct {
 	Options []Option `json:"options"`
 }
 
+// OptionsResponse is one page of a poll's options in display order. Total
+// counts every option, so clients know when they have them all; limit is
+// omitted when the page runs to the end.
+type OptionsResponse struct {
+	PollID  string   `json:"poll_id"`
+	Total   int      `json:"total"`
+	Offset  int      `json:"offset"`
+	Limit   int      `json:"limit,omitempty"`
+	Options []Option `json:"options"`
+}
+
 type Ballot struct {
 	ID          string    `json:"id"`
 	PollID      string    `json:"poll_id"`
@@ -113,24 +413,46 @@ type Score struct {
 // BMJ Result Types
 
 type OptionStats struct {
-	OptionID string  `json:"option_id"`
-	Label    string  `json:"label"`
-	Median   float64 `json:"median"`
-	P10      float64 `json:"p10"`
-	P90      float64 `json:"p90"`
-	Mean     float64 `json:"mean"`
-	NegShare float64 `json:"neg_share"`
-	Veto     bool    `json:"veto"`
-	Rank     int     `json:"rank"` // 1-indexed ranking
+	OptionID    string  `json:"option_id"`
+	Label       string  `json:"label,omitempty"`       // omitted when GetResults excludes labels
+	Description string  `json:"description,omitempty"` // only when GetResults includes descriptions
+	Median      float64 `json:"median"`
+	P10         float64 `json:"p10"`
+	P90         float64 `json:"p90"`
+	Mean        float64 `json:"mean"`
+	NegShare    float64 `json:"neg_share"`
+	Veto        bool    `json:"veto"`
+	Rank        int     `json:"rank"`       // 1-indexed ranking
+	Status      string  `json:"status"`     // winner, vetoed, or ranked
+	VoteCount   int     `json:"vote_count"` // ballots that scored this option
+	LowSample   bool    `json:"low_sample"` // vote_count below the server's threshold; statistics are unreliable
 }
 
 type ResultSnapshot struct {
-	ID         string        `json:"id"`
-	PollID     string        `json:"poll_id"`
-	Method     string        `json:"method"`
-	ComputedAt time.Time     `json:"computed_at"`
-	Rankings   []OptionStats `json:"rankings"`
-	InputsHash string        `json:"inputs_hash"` // Hash of all ballot IDs for verification
+	ID               string        `json:"id"`
+	PollID           string        `json:"poll_id"`
+	Method           string        `json:"method"`
+	ComputedAt       time.Time     `json:"computed_at"`
+	Rankings         []OptionStats `json:"rankings"`
+	InputsHash       string        `json:"inputs_hash"`            // Hash of all ballot IDs for verification
+	OptionsHash      string        `json:"options_hash,omitempty"` // Hash of the ranked option labels; matches the poll's unless options changed
+	VetoThreshold    float64       `json:"veto_threshold"`         // Negative share that triggered a soft veto
+	AlgorithmVersion int           `json:"algorithm_version"`      // Ranking rules the results were computed with
+}
+
+// ResultsTie lists, in ranked order, the options sharing first place on
+// every BMJ statistic; only the final tie-break separated them
+type ResultsTie struct {
+	OptionIDs []string `json:"option_ids"`
+}
+
+// ScoreScale describes the signed axis result statistics use. Transform
+// names the formula mapping a ballot's value01 onto it.
+type ScoreScale struct {
+	Min       float64 `json:"min"`
+	Mid       float64 `json:"mid"`
+	Max       float64 `json:"max"`
+	Transform string  `json:"transform"`
 }
 
 // Device role constants
@@ -177,8 +499,55 @@ type DevicePollSummary struct {
 	LinkedAt    time.Time `json:"linked_at"`
 }
 
+// GetVoterPollsRequest lists voter tokens in the body of GET
+// /voters/my-polls, as an alternative to the X-Voter-Token header
+type GetVoterPollsRequest struct {
+	VoterTokens []string `json:"voter_tokens"`
+}
+
+// GetVoterPollsResponse lists the polls the given voter tokens joined,
+// most recently claimed first
+type GetVoterPollsResponse struct {
+	Polls []VoterPollSummary `json:"polls"`
+}
+
+// VoterPollSummary is one poll a voter token claimed a username in
+type VoterPollSummary struct {
+	PollID      string    `json:"poll_id"`
+	Title       string    `json:"title"`
+	Status      string    `json:"status"`
+	ShareSlug   *string   `json:"share_slug,omitempty"`
+	Username    string    `json:"username"`
+	HasVoted    bool      `json:"has_voted"`
+	BallotCount int       `json:"ballot_count"`
+	ClaimedAt   time.Time `json:"claimed_at"`
+}
+
+// BallotCountByTimeResponse counts a poll's ballots per time bucket
+type BallotCountByTimeResponse struct {
+	PollID      string             `json:"poll_id"`
+	Bucket      string             `json:"bucket"`       // bucket width as a Go duration, e.g. 1h0m0s
+	BallotCount int                `json:"ballot_count"` // sum of every bucket's count
+	Buckets     []BallotTimeBucket `json:"buckets"`      // oldest first; empty buckets omitted
+}
+
+// BallotTimeBucket is the number of ballots last submitted within
+// [start, start+bucket)
+type BallotTimeBucket struct {
+	Start time.Time `json:"start"`
+	Count int       `json:"count"`
+}
+
+// ActiveDevicesResponse counts devices seen within a window ending now
+type ActiveDevicesResponse struct {
+	Window        string    `json:"window"`
+	Since         time.Time `json:"since"`
+	ActiveDevices int       `json:"active_devices"`
+}
+
 type GetMyPollsResponse struct {
-	Polls []DevicePollSummary `json:"polls"`
+	Polls      []DevicePollSummary `json:"polls"`
+	NextCursor *string             `json:"next_cursor,omitempty"` // pass as ?cursor= for the next page; absent on the last
 }
 
 type PollPreviewResponse struct {
@@ -188,6 +557,137 @@ type PollPreviewResponse struct {
 	BallotCount int    `json:"ballot_count"`
 }
 
+// PollPublicStatusResponse is what anyone holding a poll ID may learn about
+// it: enough to see whether it has been published, and nothing an admin
+// or voter entered beyond the title
+type PollPublicStatusResponse struct {
+	Title       string `json:"title"`
+	Status      string `json:"status"`
+	OptionCount int    `json:"option_count"`
+}
+
+// PollAdminResponse is the admin view of a poll. While the poll is a draft,
+// FutureShareSlug and FutureShareURL preview the link publishing will
+// produce, so creators can pre-share it; they are omitted once published.
+// CreatorContact is never part of Poll, so public endpoints can't leak it.
+type PollAdminResponse struct {
+	PollWithOptions
+	FutureShareSlug string `json:"future_share_slug,omitempty"`
+	FutureShareURL  string `json:"future_share_url,omitempty"`
+	CreatorContact  string `json:"creator_contact,omitempty"`
+}
+
+type GetPreviewsRequest struct {
+	Slugs []string `json:"slugs"`
+}
+
+// PollPreviewEntry is one slug's result in a bulk preview lookup. Unknown
+// slugs carry NotFound instead of the preview fields.
+type PollPreviewEntry struct {
+	Slug string `json:"slug"`
+	*PollPreviewResponse
+	NotFound bool `json:"not_found,omitempty"`
+}
+
+type GetPreviewsResponse struct {
+	Previews []PollPreviewEntry `json:"previews"`
+}
+
+// AdminActionsResponse lists the admin actions recorded for a poll,
+// newest first
+type AdminActionsResponse struct {
+	PollID  string        `json:"poll_id"`
+	Actions []AdminAction `json:"actions"`
+}
+
+// StabilityResponse reports how often each option won when the poll's
+// ballots were resampled. Options are in their actual rank order.
+type StabilityResponse struct {
+	PollID      string            `json:"poll_id"`
+	Resamples   int               `json:"resamples"`    // 0 when there were no ballots to resample
+	BallotCount int               `json:"ballot_count"` // counted ballots each resample draws from
+	Options     []OptionStability `json:"options"`
+}
+
+// OptionStability is one option's share of bootstrap wins
+type OptionStability struct {
+	OptionID string  `json:"option_id"`
+	Label    string  `json:"label"`
+	Rank     int     `json:"rank"`      // rank on the actual ballots
+	WinShare float64 `json:"win_share"` // fraction of resamples the option ranked first
+}
+
+// RawScoresResponse lists the signed scores each option of a closed poll
+// was ranked on. Options are in rank order.
+type RawScoresResponse struct {
+	PollID  string            `json:"poll_id"`
+	Scale   ScoreScale        `json:"scale"`
+	Options []OptionRawScores `json:"options"`
+}
+
+// OptionRawScores is one option's signed scores, sorted ascending, with no
+// link to the ballots they came from. Values the poll's default_unscored
+// filled in are included, as in the rankings.
+type OptionRawScores struct {
+	OptionID string    `json:"option_id"`
+	Label    string    `json:"label"`
+	Rank     int       `json:"rank"`
+	Scores   []float64 `json:"scores"`
+}
+
+// AdminAction is one audited admin action. IPHash is the hashed IP of
+// the caller, empty for actions the server took itself, such as closing
+// an expired poll.
+type AdminAction struct {
+	ID        string    `json:"id"`
+	Action    string    `json:"action"`
+	IPHash    string    `json:"ip_hash,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Admin action constants
+const (
+	AdminActionPublish      = "publish"
+	AdminActionClose        = "close"
+	AdminActionDeleteOption = "delete_option"
+)
+
+// ResultsHistoryResponse lists every snapshot sealed for a poll, newest
+// first, so admins can compare closing rounds
+type ResultsHistoryResponse struct {
+	PollID    string            `json:"poll_id"`
+	Snapshots []SnapshotSummary `json:"snapshots"`
+}
+
+// SnapshotSummary is one sealed snapshot with a compact ranking. Final
+// marks the snapshot GetResults currently serves.
+type SnapshotSummary struct {
+	ID         string           `json:"id"`
+	ComputedAt time.Time        `json:"computed_at"`
+	Method     string           `json:"method"`
+	InputsHash string           `json:"inputs_hash"`
+	Final      bool             `json:"final"`
+	Rankings   []RankingSummary `json:"rankings"`
+}
+
+// RankingSummary is the part of OptionStats needed to compare outcomes
+type RankingSummary struct {
+	OptionID string  `json:"option_id"`
+	Label    string  `json:"label"`
+	Rank     int     `json:"rank"`
+	Median   float64 `json:"median"`
+	Status   string  `json:"status"`
+}
+
+// PollSummaryResponse bundles everything a voting screen needs in one call.
+// HasVoted is only present when the caller identified itself.
+type PollSummaryResponse struct {
+	PollWithOptions
+	BallotCount int   `json:"ballot_count"`
+	VoterCount  int   `json:"voter_count"`
+	HasVoted    *bool `json:"has_voted,omitempty"`
+}
+
 type GetMyBallotResponse struct {
 	Scores      map[string]float64 `json:"scores"`
 	SubmittedAt time.Time          `json:"submitted_at"`
@@ -197,6 +697,94 @@ type GetMyBallotResponse struct {
 // Error response
 
 type ErrorResponse struct {
-	Error   string `json:"error"`
-	Message string `json:"message,omitempty"`
+	Error   string       `json:"error"`
+	Message string       `json:"message,omitempty"`
+	Code    string       `json:"code,omitempty"`   // machine-readable reason, when one applies
+	Fields  []FieldError `json:"fields,omitempty"` // every invalid field, for validation errors
 }
+
+// Error codes distinguishing why a voting request was refused
+const (
+	ErrorCodePollNotFound  = "poll_not_found"
+	ErrorCodePollDraft     = "poll_draft"  // voting hasn't started
+	ErrorCodePollClosed    = "poll_closed" // voting has ended
+	ErrorCodePollPaused    = "poll_paused" // voting is halted until the admin resumes it
+	ErrorCodeTooFewScores  = "too_few_scores"
+	ErrorCodeBallotTooSoon = "ballot_too_soon"         // updated again before MinBallotInterval
+	ErrorCodeIPBallotLimit = "ip_ballot_limit"         // MaxBallotsPerIP ballots already cast from the caller's IP
+	ErrorCodePollFull      = "poll_full"               // the poll already holds MaxBallotsPerPoll ballots
+	ErrorCodeBallotLocked  = "ballot_locked"           // the poll doesn't allow ballot updates and the voter already voted
+	ErrorCodePollNoOptions = "poll_has_no_options"     // an open poll left without options; nothing can be scored
+	ErrorCodeCreatorVoting = "creator_voting_disabled" // the poll's admin device may not vote on it
+)
+
+// Error codes distinguishing why a poll management request was refused
+const (
+	ErrorCodeInvalidClosesAt   = "invalid_closes_at"    // closes_at not after now and opened_at
+	ErrorCodeDuplicateOption   = "duplicate_option"     // label matches an existing option
+	ErrorCodePollCreateTooSoon = "poll_create_too_soon" // the client created a poll within MinPollCreateInterval
+	// ErrorCodeIdempotencyKeyReused refuses an Idempotency-Key already used
+	// for a different CreatePollRequest
+	ErrorCodeIdempotencyKeyReused = "idempotency_key_reused"
+)
+
+// ErrorCodeResultsEmbargoed refuses public results for a closed poll whose
+// reveal_at hasn't passed
+const ErrorCodeResultsEmbargoed = "results_embargoed"
+
+// ErrorCodeSnapshotMissing reports a closed poll whose final snapshot can't
+// be found; recomputing the results replaces it
+const ErrorCodeSnapshotMissing = "snapshot_missing"
+
+// ResultsEmbargoedResponse is the error for embargoed results, with the
+// time they become public
+type ResultsEmbargoedResponse struct {
+	ErrorResponse
+	RevealAt time.Time `json:"reveal_at"`
+}
+
+// Error codes for requests that match no route
+const (
+	ErrorCodeNotFound         = "not_found"
+	ErrorCodeMethodNotAllowed = "method_not_allowed"
+)
+
+// ErrorCodeRateLimited answers a rate-limited route once the caller's IP has
+// used up its requests for the current window
+const ErrorCodeRateLimited = "rate_limited"
+
+// ErrorCodeDeviceTrackingDisabled answers device routes on servers that
+// keep no device records
+const ErrorCodeDeviceTrackingDisabled = "device_tracking_disabled"
+
+// Validation error codes
+const (
+	FieldCodeRequired   = "required"
+	FieldCodeOutOfRange = "out_of_range"
+	FieldCodeInvalid    = "invalid"
+	FieldCodeWrongType  = "wrong_type" // e.g. a score that isn't a number
+
+	FieldCodeDuplicateOptionKey = "duplicate_option_key" // an option scored twice in one ballot
+)
+
+// FieldError describes one invalid request field
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ReadinessResponse is the body of GET /readyz
+type ReadinessResponse struct {
+	Status                string `json:"status"`   // ready, unavailable, or schema_outdated
+	Database              bool   `json:"database"` // the database answered a ping
+	SchemaVersion         int    `json:"schema_version"`
+	ExpectedSchemaVersion int    `json:"expected_schema_version"`
+}
+
+// Readiness statuses
+const (
+	ReadinessReady          = "ready"
+	ReadinessUnavailable    = "unavailable"
+	ReadinessSchemaOutdated = "schema_outdated" // applied version is behind this build
+)
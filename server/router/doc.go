@@ -6,35 +6,106 @@ Package router defines HTTP routes for the Quickly Pick API.
 
 # Route Registration
 
-NewRouter creates a configured http.ServeMux with all endpoints:
+NewRouter creates an http.Handler serving all endpoints:
 
 	mux := router.NewRouter(db, cfg)
 
+With a read replica configured, NewRouterWithReplica sends the results,
+preview, and ballot count queries to it; everything else uses the primary:
+
+	mux := router.NewRouterWithReplica(db, readDB, cfg)
+
+When cfg.BasePath is set (e.g. "/api/v1"), every route below is registered
+under it: GET /api/v1/health, POST /api/v1/polls, and so on.
+
+A path that only matches a route once its trailing slash is removed, such
+as /polls/{slug}/, is answered according to cfg.TrailingSlash: a 308
+redirect to the route (the default), the route served directly
+(rewrite), or a 404 (strict). Only trailing slashes are removed, so
+/polls/{slug}/results/ still resolves to /polls/{slug}/results.
+
+Requests matching no route get the same JSON error body as handler
+errors, with code not_found (404) or method_not_allowed (405). The
+returned handler implements middleware.RouteChecker, so CORS preflights for
+unknown paths get that 404 too rather than a 200. It also implements
+middleware.MethodLister, reporting the methods its routes are registered
+for, so CORS allows exactly those.
+
+Every response the router writes, matched or not, carries the
+middleware.SecurityHeaders headers: X-Content-Type-Options: nosniff and
+a Content-Security-Policy that allows nothing. cfg.ServerHeader, when
+set, is sent as the Server header.
+
 # Endpoints
 
 Health:
 
 	GET /health
+	GET /readyz - Database reachable and schema up to date (503 otherwise)
+	GET /openapi.json - OpenAPI 3 description of every route
+
+/readyz reports status (ready, unavailable, or schema_outdated), database,
+schema_version as applied to the database, and expected_schema_version,
+the db.SchemaVersion this build creates.
+
+/openapi.json serves openapi.json, embedded at build time. It is
+maintained by hand, so add to it whenever a route or model changes; a
+router test fails if it documents an operation no route serves.
 
 Poll management (admin, requires X-Admin-Key):
 
-	POST /polls              - Create poll
+	POST /polls              - Create poll (Idempotency-Key makes retries return the same poll)
 	GET  /polls/{id}/admin   - Get poll details
+	GET  /polls/{id}/admin/actions - Audit log of publish, close, and option removal
+	GET  /polls/{id}/stability - Bootstrap estimate of how often each option wins (?resamples=, up to 1000)
+	GET  /polls/{id}/scores  - Each option's sorted signed scores (closed only, up to 100000)
 	POST /polls/{id}/options - Add option
-	POST /polls/{id}/publish - Open for voting
-	POST /polls/{id}/close   - Seal results
-
-Voting (public, uses share slug):
+	POST /polls/{id}/options:import - Add options from text/plain lines
+	DELETE /polls/{id}/options/{option_id} - Remove option (draft only)
+	POST /polls/{id}/publish - Open for voting (idempotent while open)
+	PUT  /polls/{id}/vanity-slug - Set a vanity slug (open only)
+	PUT  /polls/{id}/close-webhook - Set the URL notified on close (draft or open)
+	PATCH /polls/{id}/closes-at - Schedule or clear the close time (draft or open)
+	POST /polls/{id}/pause   - Halt voting without closing (open only)
+	POST /polls/{id}/resume  - Let voting continue (open only)
+	POST /polls/{id}/close   - Seal results (?include_breakdown=true for per-voter scores)
+	POST /polls:close-batch  - Close several polls, each with its own admin key
+	PUT  /polls/{id}/reveal-at - Embargo or release a closed poll's results
+	POST /polls/{id}/recompute - Re-rank a closed poll with the current BMJ parameters
+	GET  /polls/{id}/ballots.csv - Every ballot as CSV (closed only)
+	POST /polls/{id}/duplicate - Clone as a new draft
+
+Voting (public, uses share slug or vanity slug):
 
 	POST /polls/{slug}/claim-username - Claim voter identity
-	POST /polls/{slug}/ballots        - Submit/update ballot
+	PATCH /polls/{slug}/username      - Rename the voter (keeps token and ballot)
+	POST /polls/{slug}/ballots        - Submit/update ballot (?validate_only=true to check only)
+	POST /ballots:sync                - Submit up to 50 queued ballots, each with its own slug and token
+	GET  /voters/my-polls             - Polls joined with the given voter tokens (comma-separated)
 
 Results (public):
 
 	GET /polls/{slug}              - Poll info and options
-	GET /polls/{slug}/results      - Final results (closed only)
+	GET /polls/{slug}/options      - Options in display order (?limit=, ?offset=)
+	GET /polls/{slug}/results      - Final results (closed only unless live_results, ?include=labels,descriptions, ?precision=full, ?case=camel)
+	GET /polls/{slug}/results.txt  - Final ranking as plain text for chats (closed only)
 	GET /polls/{slug}/ballot-count - Vote count
+	GET /polls/{slug}/ballots/count-by-time - Ballots per time bucket (?bucket=1h)
 	GET /polls/{slug}/preview      - Compact preview data
+	GET /polls/{slug}/summary      - Poll, options, counts, and has_voted
+	POST /polls/previews           - Previews for up to 50 slugs at once
+
+Publication check (public, uses poll ID, 30 requests per minute per IP):
+
+	GET /polls/{id}/public-status - Title, status, and option count
+
+Collaborators given a poll ID before publishing can see whether it is open
+yet. Past the limit, requests get 429 with code rate_limited and a
+Retry-After header.
+
+Results history (admin, requires X-Admin-Key):
+
+	GET /polls/{slug}/results/history - Every sealed snapshot, newest first (?case=camel)
 
 Device management:
 
@@ -42,6 +113,13 @@ Device management:
 	GET  /devices/me       - Get device info
 	GET  /devices/my-polls - List device's polls
 
+Operations (requires X-Server-Key, disabled without a server admin key):
+
+	GET /admin/active-devices?window=24h - Devices seen within the window
+
+With cfg.DisableDevices set, the device and active-devices routes answer
+410 with code device_tracking_disabled.
+
 # Handler Initialization
 
 The router creates handler instances with dependency injection:
@@ -51,6 +129,7 @@ The router creates handler instances with dependency injection:
 	resultsHandler := handlers.NewResultsHandler(db, cfg)
 	deviceHandler := handlers.NewDeviceHandler(db, cfg)
 
-All handlers receive the database connection and configuration.
+All handlers receive the database connection and configuration. The voting
+and results handlers also share a ballot count cache.
 */
 package router
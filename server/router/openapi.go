@@ -0,0 +1,24 @@
+// Copyright (c) 2025 Daniel Kuo.
+// Source-available; no permission granted to use, copy, modify, or distribute. See LICENSE.
+
+package router
+
+import (
+	_ "embed"
+	"net/http"
+
+	"github.com/danielhkuo/quickly-pick/middleware"
+)
+
+// openAPISpec is the hand-maintained OpenAPI 3 description of every route.
+// Update openapi.json alongside any route or model change.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// serveOpenAPI handles GET /openapi.json
+func serveOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", middleware.JSONContentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(openAPISpec)
+}
@@ -4,56 +4,316 @@
 package router
 
 import (
+	"context"
 	"database/sql"
+	"log/slog"
 	"net/http"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/danielhkuo/quickly-pick/cliparse"
+	schema "github.com/danielhkuo/quickly-pick/db"
 	"github.com/danielhkuo/quickly-pick/handlers"
 	"github.com/danielhkuo/quickly-pick/middleware"
+	"github.com/danielhkuo/quickly-pick/models"
 )
 
-func NewRouter(db *sql.DB, cfg cliparse.Config) *http.ServeMux {
+// publicStatusRateLimit is how many public-status lookups one IP may make
+// per minute
+const publicStatusRateLimit = 30
+
+func NewRouter(db *sql.DB, cfg cliparse.Config) http.Handler {
+	return NewRouterWithReplica(db, nil, cfg)
+}
+
+// NewRouterWithReplica is NewRouter with results and previews reading from
+// readDB. A nil readDB reads from db.
+func NewRouterWithReplica(db, readDB *sql.DB, cfg cliparse.Config) http.Handler {
 	mux := http.NewServeMux()
 
+	// Every route is registered under cfg.BasePath. Its method is recorded
+	// for CORS preflights and HasRoute.
+	var methods []string
+	handle := func(pattern string, handler http.HandlerFunc) {
+		if method, _, found := strings.Cut(pattern, " "); found && !slices.Contains(methods, method) {
+			methods = append(methods, method)
+		}
+		mux.HandleFunc(withBasePath(cfg.BasePath, pattern), handler)
+	}
+
 	// Initialize handlers
+	// Voting invalidates the ballot counts that results serves
+	ballotCounts := handlers.NewBallotCountCache(handlers.DefaultBallotCountTTL)
+
 	pollHandler := handlers.NewPollHandler(db, cfg)
-	votingHandler := handlers.NewVotingHandler(db, cfg)
-	resultsHandler := handlers.NewResultsHandler(db, cfg)
+	votingHandler := handlers.NewVotingHandlerWithCache(db, cfg, ballotCounts)
+	resultsHandler := handlers.NewResultsHandlerWithReplica(db, readDB, cfg, ballotCounts)
 	deviceHandler := handlers.NewDeviceHandler(db, cfg)
 
 	// Health check
-	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
+	handle("GET /health", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("OK"))
 	})
+	handle("GET /readyz", readiness(db))
+	handle("GET /openapi.json", serveOpenAPI)
 
 	// Poll management (admin operations)
-	mux.HandleFunc("POST /polls", middleware.WithLogging(pollHandler.CreatePoll))
-	mux.HandleFunc("GET /polls/{id}/admin", middleware.WithLogging(pollHandler.GetPollAdmin))
-	mux.HandleFunc("POST /polls/{id}/options", middleware.WithLogging(pollHandler.AddOption))
-	mux.HandleFunc("POST /polls/{id}/publish", middleware.WithLogging(pollHandler.PublishPoll))
-	mux.HandleFunc("POST /polls/{id}/close", middleware.WithLogging(pollHandler.ClosePoll))
+	handle("POST /polls", middleware.WithLogging(pollHandler.CreatePoll))
+	handle("GET /polls/{id}/admin", middleware.WithLogging(pollHandler.GetPollAdmin))
+	handle("GET /polls/{id}/admin/actions", middleware.WithLogging(pollHandler.GetAdminActions))
+	handle("GET /polls/{id}/stability", middleware.WithLogging(pollHandler.GetStability))
+	handle("GET /polls/{id}/scores", middleware.WithLogging(pollHandler.GetRawScores))
+	handle("POST /polls/{id}/options", middleware.WithLogging(pollHandler.AddOption))
+	handle("POST /polls/{id}/options:import", middleware.WithLogging(pollHandler.ImportOptions))
+	handle("DELETE /polls/{id}/options/{option_id}", middleware.WithLogging(pollHandler.DeleteOption))
+	handle("POST /polls/{id}/publish", middleware.WithLogging(pollHandler.PublishPoll))
+	handle("PUT /polls/{id}/vanity-slug", middleware.WithLogging(pollHandler.SetVanitySlug))
+	handle("PUT /polls/{id}/close-webhook", middleware.WithLogging(pollHandler.SetCloseWebhook))
+	handle("PATCH /polls/{id}/closes-at", middleware.WithLogging(pollHandler.SetClosesAt))
+	handle("POST /polls/{id}/pause", middleware.WithLogging(pollHandler.PausePoll))
+	handle("POST /polls/{id}/resume", middleware.WithLogging(pollHandler.ResumePoll))
+	handle("POST /polls/{id}/close", middleware.WithLogging(pollHandler.ClosePoll))
+	handle("PUT /polls/{id}/reveal-at", middleware.WithLogging(pollHandler.SetRevealAt))
+	handle("POST /polls:close-batch", middleware.WithLogging(pollHandler.CloseBatch))
+	handle("POST /polls/{id}/recompute", middleware.WithLogging(pollHandler.RecomputeResults))
+	handle("GET /polls/{id}/ballots.csv", middleware.WithLogging(pollHandler.ExportBallotsCSV))
+	handle("POST /polls/{id}/duplicate", middleware.WithLogging(pollHandler.DuplicatePoll))
 
 	// Voting operations (public)
-	mux.HandleFunc("POST /polls/{slug}/claim-username", middleware.WithLogging(votingHandler.ClaimUsername))
-	mux.HandleFunc("POST /polls/{slug}/ballots", middleware.WithLogging(votingHandler.SubmitBallot))
-	mux.HandleFunc("GET /polls/{slug}/my-ballot", middleware.WithLogging(votingHandler.GetMyBallot))
+	handle("POST /polls/{slug}/claim-username", middleware.WithLogging(votingHandler.ClaimUsername))
+	handle("PATCH /polls/{slug}/username", middleware.WithLogging(votingHandler.RenameUsername))
+	handle("POST /polls/{slug}/ballots", middleware.WithLogging(votingHandler.SubmitBallot))
+	handle("GET /polls/{slug}/my-ballot", middleware.WithLogging(votingHandler.GetMyBallot))
+	handle("POST /ballots:sync", middleware.WithLogging(votingHandler.SyncBallots))
+	handle("GET /voters/my-polls", middleware.WithLogging(votingHandler.GetVoterPolls))
 
 	// Results retrieval (public, with sealed results)
-	mux.HandleFunc("GET /polls/{slug}", middleware.WithLogging(resultsHandler.GetPoll))
-	mux.HandleFunc("GET /polls/{slug}/results", middleware.WithLogging(resultsHandler.GetResults))
-	mux.HandleFunc("GET /polls/{slug}/ballot-count", middleware.WithLogging(resultsHandler.GetBallotCount))
-	mux.HandleFunc("GET /polls/{slug}/preview", middleware.WithLogging(resultsHandler.GetPreview))
+	handle("GET /polls/{slug}", middleware.WithLogging(resultsHandler.GetPoll))
+	handle("GET /polls/{slug}/options", middleware.WithLogging(resultsHandler.GetOptions))
+	handle("GET /polls/{slug}/results", middleware.WithLogging(resultsHandler.GetResults))
+	handle("GET /polls/{slug}/results.txt", middleware.WithLogging(resultsHandler.GetResultsText))
+	handle("GET /polls/{slug}/results/history", middleware.WithLogging(resultsHandler.GetResultsHistory))
+	handle("GET /polls/{slug}/ballot-count", middleware.WithLogging(resultsHandler.GetBallotCount))
+	handle("GET /polls/{slug}/ballots/count-by-time", middleware.WithLogging(resultsHandler.GetBallotCountByTime))
+	handle("GET /polls/{slug}/preview", middleware.WithLogging(resultsHandler.GetPreview))
+	handle("GET /polls/{slug}/summary", middleware.WithLogging(resultsHandler.GetSummary))
+	handle("POST /polls/previews", middleware.WithLogging(resultsHandler.GetPreviews))
+
+	// Lookup by poll ID needs no key, so it is rate limited per IP to keep
+	// ID guessing slow even though IDs are 128-bit
+	publicStatusLimiter := middleware.NewRateLimiter(publicStatusRateLimit, time.Minute, cfg.TrustProxyHeaders)
+	handle("GET /polls/{id}/public-status", middleware.WithLogging(publicStatusLimiter.Limit(resultsHandler.GetPublicStatus)))
+
+	// Device routes answer 410 when device tracking is disabled
+	devices := func(handler http.HandlerFunc) http.HandlerFunc {
+		if cfg.DisableDevices {
+			return handlers.DeviceTrackingDisabled
+		}
+		return handler
+	}
 
 	// Device management
-	mux.HandleFunc("POST /devices/register", middleware.WithLogging(deviceHandler.Register))
-	mux.HandleFunc("GET /devices/me", middleware.WithLogging(deviceHandler.GetMe))
-	mux.HandleFunc("GET /devices/my-polls", middleware.WithLogging(deviceHandler.GetMyPolls))
+	handle("POST /devices/register", middleware.WithLogging(devices(deviceHandler.Register)))
+	handle("GET /devices/me", middleware.WithLogging(devices(deviceHandler.GetMe)))
+	handle("GET /devices/my-polls", middleware.WithLogging(devices(deviceHandler.GetMyPolls)))
+
+	// Server-wide operations (requires X-Server-Key)
+	handle("GET /admin/active-devices", middleware.WithLogging(devices(deviceHandler.GetActiveDevices)))
 
-	// Root endpoint
-	mux.HandleFunc("GET /", func(w http.ResponseWriter, r *http.Request) {
+	// Root endpoint; {$} keeps it from catching every unknown GET
+	handle("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte("quickly-pick API v1"))
 	})
 
-	return mux
+	// Security headers go on every response, including unmatched ones
+	secure := middleware.SecurityHeaders(middleware.SecurityHeaderOptions{ServerName: cfg.ServerHeader})
+	wrap := secure
+	if cfg.LogHeaders {
+		wrap = func(next http.Handler) http.Handler {
+			return secure(middleware.LogHeaders(next))
+		}
+	}
+
+	slices.Sort(methods)
+	return withJSONErrors(mux, methods, cfg.TrailingSlash, wrap)
+}
+
+// withJSONErrors replaces the mux's plain-text 404 and 405 responses with
+// the JSON error format used by every handler. methods are those the mux's
+// routes are registered for; trailingSlash is how paths that only match a
+// route without their trailing slash are answered; wrap applies to every
+// request, matched or not. The result still reports the mux's routes to
+// the CORS middleware, which wrapping it from outside would hide.
+func withJSONErrors(mux *http.ServeMux, methods []string, trailingSlash string, wrap func(http.Handler) http.Handler) http.Handler {
+	h := &jsonErrorsHandler{mux: mux, methods: methods, trailingSlash: trailingSlash}
+	h.serve = wrap(http.HandlerFunc(h.dispatch))
+	return h
+}
+
+type jsonErrorsHandler struct {
+	mux           *http.ServeMux
+	methods       []string
+	trailingSlash string
+	serve         http.Handler
+}
+
+func (h *jsonErrorsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.serve.ServeHTTP(w, r)
+}
+
+func (h *jsonErrorsHandler) dispatch(w http.ResponseWriter, r *http.Request) {
+	// Matched routes and redirects report a pattern; only the mux's own
+	// not found and method not allowed handlers leave it empty
+	if _, pattern := h.mux.Handler(r); pattern != "" {
+		h.mux.ServeHTTP(w, r)
+		return
+	}
+
+	if canonical := h.withoutTrailingSlash(r); canonical != nil {
+		if h.trailingSlash == cliparse.TrailingSlashRewrite {
+			h.dispatch(w, canonical)
+			return
+		}
+		// 308 rather than 301 so clients repeat the method and body
+		w.Header().Set("Location", canonical.URL.RequestURI())
+		w.WriteHeader(http.StatusPermanentRedirect)
+		return
+	}
+
+	h.mux.ServeHTTP(&unmatchedWriter{ResponseWriter: w}, r)
+}
+
+// withoutTrailingSlash returns a copy of r with the trailing slashes
+// removed from its path, if that path is routed and trailing slashes
+// aren't strict. Only the end of the path changes, so /polls/{slug}/
+// resolves to /polls/{slug} and never to one of its subpaths.
+func (h *jsonErrorsHandler) withoutTrailingSlash(r *http.Request) *http.Request {
+	if h.trailingSlash == cliparse.TrailingSlashStrict {
+		return nil
+	}
+	path := strings.TrimRight(r.URL.Path, "/")
+	if path == "" || path == r.URL.Path {
+		return nil
+	}
+
+	canonical := r.Clone(r.Context())
+	canonical.URL.Path = path
+	canonical.URL.RawPath = strings.TrimRight(r.URL.RawPath, "/")
+	if !h.routed(canonical) {
+		return nil
+	}
+	return canonical
+}
+
+// RoutedMethods returns the methods any route is registered for, so the
+// CORS middleware allows exactly those
+func (h *jsonErrorsHandler) RoutedMethods() []string {
+	return h.methods
+}
+
+// HasRoute reports whether r's path is routed for any method, so the CORS
+// middleware can answer preflights for unknown paths with a 404. A path
+// only routed without its trailing slash counts unless trailing slashes
+// are strict.
+func (h *jsonErrorsHandler) HasRoute(r *http.Request) bool {
+	return h.routed(r) || h.withoutTrailingSlash(r) != nil
+}
+
+// routed reports whether r's path is routed for any method
+func (h *jsonErrorsHandler) routed(r *http.Request) bool {
+	for _, method := range h.methods {
+		probe := r.WithContext(r.Context())
+		probe.Method = method
+		if _, pattern := h.mux.Handler(probe); pattern != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// unmatchedWriter rewrites an error status into a JSON error response and
+// discards the plain-text body that follows
+type unmatchedWriter struct {
+	http.ResponseWriter
+	rewritten bool
+}
+
+func (w *unmatchedWriter) WriteHeader(statusCode int) {
+	switch statusCode {
+	case http.StatusNotFound:
+		w.rewrite(statusCode, models.ErrorCodeNotFound, "No route matches this path")
+	case http.StatusMethodNotAllowed:
+		// The mux has already set the Allow header
+		w.rewrite(statusCode, models.ErrorCodeMethodNotAllowed, "Method not allowed for this path")
+	default:
+		w.ResponseWriter.WriteHeader(statusCode)
+	}
+}
+
+func (w *unmatchedWriter) Write(b []byte) (int, error) {
+	if w.rewritten {
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *unmatchedWriter) rewrite(statusCode int, code, message string) {
+	w.rewritten = true
+	middleware.ErrorResponseWithCode(w.ResponseWriter, statusCode, code, message)
+}
+
+// withBasePath inserts basePath between a pattern's method and its path
+func withBasePath(basePath, pattern string) string {
+	if basePath == "" {
+		return pattern
+	}
+	method, path, found := strings.Cut(pattern, " ")
+	if !found {
+		return basePath + pattern
+	}
+	return method + " " + basePath + path
+}
+
+// readinessTimeout bounds the database round trips of a readiness check
+const readinessTimeout = 2 * time.Second
+
+// readiness reports whether the database is reachable and has at least the
+// schema version this build expects. Unlike /health, it answers 503 when
+// either check fails, so load balancers can hold traffic back.
+func readiness(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+		defer cancel()
+
+		resp := models.ReadinessResponse{
+			Status:                models.ReadinessUnavailable,
+			ExpectedSchemaVersion: schema.SchemaVersion,
+		}
+
+		if err := db.PingContext(ctx); err != nil {
+			slog.Warn("readiness check failed", "error", err)
+			middleware.JSONResponse(w, http.StatusServiceUnavailable, resp)
+			return
+		}
+		resp.Database = true
+
+		version, err := schema.AppliedSchemaVersion(ctx, db)
+		if err != nil {
+			slog.Warn("failed to read schema version", "error", err)
+			middleware.JSONResponse(w, http.StatusServiceUnavailable, resp)
+			return
+		}
+		resp.SchemaVersion = version
+
+		if version < schema.SchemaVersion {
+			resp.Status = models.ReadinessSchemaOutdated
+			middleware.JSONResponse(w, http.StatusServiceUnavailable, resp)
+			return
+		}
+
+		resp.Status = models.ReadinessReady
+		middleware.JSONResponse(w, http.StatusOK, resp)
+	}
 }
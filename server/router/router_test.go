@@ -4,10 +4,18 @@
 package router
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"regexp"
+	"slices"
+	"strings"
 	"testing"
 
+	"github.com/danielhkuo/quickly-pick/cliparse"
+	schema "github.com/danielhkuo/quickly-pick/db"
+	"github.com/danielhkuo/quickly-pick/middleware"
+	"github.com/danielhkuo/quickly-pick/models"
 	"github.com/danielhkuo/quickly-pick/testutil"
 )
 
@@ -75,17 +83,27 @@ func TestRouteExistence(t *testing.T) {
 		{"POST", "/polls"},
 		{"GET", "/polls/test-id/admin"},
 		{"POST", "/polls/test-id/options"},
+		{"POST", "/polls/test-id/options:import"},
+		{"DELETE", "/polls/test-id/options/test-option"},
 		{"POST", "/polls/test-id/publish"},
+		{"PUT", "/polls/test-id/vanity-slug"},
 		{"POST", "/polls/test-id/close"},
+		{"POST", "/polls/test-id/duplicate"},
 
 		// Voting routes (these use {slug} param)
 		{"POST", "/polls/test-slug/claim-username"},
 		{"POST", "/polls/test-slug/ballots"},
+		{"POST", "/ballots:sync"},
+		{"GET", "/polls/test-slug/summary"},
+		{"GET", "/polls/test-slug/options"},
+		{"GET", "/polls/test-slug/ballots/count-by-time"},
+		{"POST", "/polls/previews"},
 
 		// Device routes
 		{"POST", "/devices/register"},
 		{"GET", "/devices/me"},
 		{"GET", "/devices/my-polls"},
+		{"GET", "/admin/active-devices"},
 	}
 
 	for _, tc := range testCases {
@@ -197,3 +215,362 @@ func TestSpecificMethodRouting(t *testing.T) {
 		})
 	}
 }
+
+func TestBasePath(t *testing.T) {
+	cfg := testutil.GetTestConfig()
+	cfg.BasePath = "/api/v1"
+
+	// Health and routing checks never touch the database
+	mux := NewRouter(nil, cfg)
+
+	testCases := []struct {
+		path           string
+		expectedStatus int
+	}{
+		{"/api/v1/health", http.StatusOK},
+		{"/health", http.StatusNotFound},
+		{"/api/v1/", http.StatusOK},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tc.path, nil)
+			w := httptest.NewRecorder()
+
+			mux.ServeHTTP(w, req)
+
+			if w.Code != tc.expectedStatus {
+				t.Errorf("Expected %d for GET %s, got %d", tc.expectedStatus, tc.path, w.Code)
+			}
+		})
+	}
+}
+
+func TestTrailingSlash(t *testing.T) {
+	// Every request here is answered before a handler touches the database
+	testCases := []struct {
+		name             string
+		mode             string
+		method           string
+		path             string
+		expectedStatus   int
+		expectedLocation string
+	}{
+		{"redirects poll", cliparse.TrailingSlashRedirect, "GET", "/polls/xyz/", http.StatusPermanentRedirect, "/polls/xyz"},
+		{"redirects subpath", cliparse.TrailingSlashRedirect, "GET", "/polls/xyz/results/", http.StatusPermanentRedirect, "/polls/xyz/results"},
+		{"redirect keeps query", cliparse.TrailingSlashRedirect, "GET", "/polls/xyz/options/?limit=5", http.StatusPermanentRedirect, "/polls/xyz/options?limit=5"},
+		{"redirect keeps method", cliparse.TrailingSlashRedirect, "POST", "/polls/", http.StatusPermanentRedirect, "/polls"},
+		{"default redirects", "", "GET", "/health/", http.StatusPermanentRedirect, "/health"},
+		{"redirect unknown path", cliparse.TrailingSlashRedirect, "GET", "/no-such-route/", http.StatusNotFound, ""},
+		{"rewrite serves route", cliparse.TrailingSlashRewrite, "GET", "/health/", http.StatusOK, ""},
+		{"rewrite keeps query", cliparse.TrailingSlashRewrite, "GET", "/polls/xyz/options/?limit=0", http.StatusBadRequest, ""},
+		{"rewrite wrong method", cliparse.TrailingSlashRewrite, "DELETE", "/health/", http.StatusMethodNotAllowed, ""},
+		{"strict", cliparse.TrailingSlashStrict, "GET", "/polls/xyz/", http.StatusNotFound, ""},
+		{"root is unaffected", cliparse.TrailingSlashRedirect, "GET", "/", http.StatusOK, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := testutil.GetTestConfig()
+			cfg.TrailingSlash = tc.mode
+			mux := NewRouter(nil, cfg)
+
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, req)
+
+			if w.Code != tc.expectedStatus {
+				t.Fatalf("Expected %d for %s %s, got %d: %s", tc.expectedStatus, tc.method, tc.path, w.Code, w.Body.String())
+			}
+			if location := w.Header().Get("Location"); location != tc.expectedLocation {
+				t.Errorf("Expected Location %q, got %q", tc.expectedLocation, location)
+			}
+		})
+	}
+}
+
+func TestTrailingSlashResolvesLikeRoute(t *testing.T) {
+	cfg := testutil.GetTestConfig()
+	cfg.TrailingSlash = cliparse.TrailingSlashRewrite
+	h := NewRouter(nil, cfg).(*jsonErrorsHandler)
+
+	for _, path := range []string{"/polls/xyz", "/polls/xyz/results", "/polls/xyz/results/history"} {
+		_, want := h.mux.Handler(httptest.NewRequest("GET", path, nil))
+		canonical := h.withoutTrailingSlash(httptest.NewRequest("GET", path+"/", nil))
+		if canonical == nil {
+			t.Errorf("Expected %s/ to resolve", path)
+			continue
+		}
+		if _, got := h.mux.Handler(canonical); got != want {
+			t.Errorf("Expected %s/ to resolve to %q, got %q", path, want, got)
+		}
+	}
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	mux := NewRouter(nil, testutil.GetTestConfig())
+
+	req := httptest.NewRequest("GET", "/openapi.json", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json; charset=utf-8" {
+		t.Errorf("Expected Content-Type application/json; charset=utf-8, got %q", ct)
+	}
+
+	var doc struct {
+		OpenAPI string                                `json:"openapi"`
+		Paths   map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("Expected valid JSON, got %v", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		t.Errorf("Expected an OpenAPI 3 document, got version %q", doc.OpenAPI)
+	}
+	if _, ok := doc.Paths["/polls"]; !ok {
+		t.Error("Expected the document to describe /polls")
+	}
+
+	// Every documented operation must be a registered route
+	router := mux.(*jsonErrorsHandler)
+	params := regexp.MustCompile(`\{[^}]+\}`)
+	for path, operations := range doc.Paths {
+		for method := range operations {
+			req := httptest.NewRequest(strings.ToUpper(method), params.ReplaceAllString(path, "x"), nil)
+			if _, pattern := router.mux.Handler(req); pattern == "" {
+				t.Errorf("Documented %s %s matches no route", strings.ToUpper(method), path)
+			}
+		}
+	}
+}
+
+func TestPreflightUnknownRoute(t *testing.T) {
+	cfg := testutil.GetTestConfig()
+
+	// Preflights never reach a handler, so no database is needed
+	handler := middleware.CORS(NewRouter(nil, cfg))
+
+	preflight := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("OPTIONS", path, nil)
+		req.Header.Set("Origin", "https://app.example")
+		req.Header.Set("Access-Control-Request-Method", "POST")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	w := preflight("/nonexistent")
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("Expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+	if origin := w.Header().Get("Access-Control-Allow-Origin"); origin != "https://app.example" {
+		t.Errorf("Expected CORS headers on the 404, got Access-Control-Allow-Origin %q", origin)
+	}
+	var resp models.ErrorResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil || resp.Code != models.ErrorCodeNotFound {
+		t.Errorf("Expected a JSON not_found body, got %q", w.Body.String())
+	}
+
+	// Known paths still get a preflight 200, whatever methods they allow
+	for _, path := range []string{"/polls", "/polls/previews", "/polls/abc/results", "/health"} {
+		if w := preflight(path); w.Code != http.StatusOK {
+			t.Errorf("Expected status %d for a preflight of %s, got %d", http.StatusOK, path, w.Code)
+		}
+	}
+}
+
+func TestPreflightAllowMethods(t *testing.T) {
+	cfg := testutil.GetTestConfig()
+	handler := middleware.CORS(NewRouter(nil, cfg))
+
+	req := httptest.NewRequest("OPTIONS", "/polls/abc/closes-at", nil)
+	req.Header.Set("Origin", "https://app.example")
+	req.Header.Set("Access-Control-Request-Method", "PATCH")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	// PATCH /polls/{id}/closes-at is registered, so PATCH must be allowed
+	allowed := strings.Split(w.Header().Get("Access-Control-Allow-Methods"), ", ")
+	for _, method := range []string{"GET", "POST", "PATCH", "OPTIONS"} {
+		if !slices.Contains(allowed, method) {
+			t.Errorf("Expected %s in Access-Control-Allow-Methods, got %v", method, allowed)
+		}
+	}
+}
+
+func TestUnmatchedRoutesReturnJSON(t *testing.T) {
+	cfg := testutil.GetTestConfig()
+
+	// Unmatched requests never reach a handler, so no database is needed
+	mux := NewRouter(nil, cfg)
+
+	testCases := []struct {
+		name           string
+		method         string
+		path           string
+		expectedStatus int
+		expectedCode   string
+	}{
+		{"unknown path", "GET", "/no-such-route", http.StatusNotFound, models.ErrorCodeNotFound},
+		{"unknown nested path", "POST", "/polls/test-id/unknown", http.StatusNotFound, models.ErrorCodeNotFound},
+		{"wrong method", "DELETE", "/health", http.StatusMethodNotAllowed, models.ErrorCodeMethodNotAllowed},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+			w := httptest.NewRecorder()
+
+			mux.ServeHTTP(w, req)
+
+			if w.Code != tc.expectedStatus {
+				t.Fatalf("Expected %d for %s %s, got %d", tc.expectedStatus, tc.method, tc.path, w.Code)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "application/json; charset=utf-8" {
+				t.Errorf("Expected Content-Type application/json; charset=utf-8, got %q", ct)
+			}
+
+			var resp models.ErrorResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("Expected a JSON body, got %q: %v", w.Body.String(), err)
+			}
+			if resp.Code != tc.expectedCode {
+				t.Errorf("Expected code %q, got %q", tc.expectedCode, resp.Code)
+			}
+			if tc.expectedStatus == http.StatusMethodNotAllowed && w.Header().Get("Allow") == "" {
+				t.Error("Expected the Allow header to be preserved")
+			}
+		})
+	}
+}
+
+func TestDeviceRoutesDisabled(t *testing.T) {
+	cfg := testutil.GetTestConfig()
+	cfg.DisableDevices = true
+
+	// Disabled routes never reach the database
+	mux := NewRouter(nil, cfg)
+
+	routes := []struct {
+		method string
+		path   string
+	}{
+		{"POST", "/devices/register"},
+		{"GET", "/devices/me"},
+		{"GET", "/devices/my-polls"},
+		{"GET", "/admin/active-devices"},
+	}
+
+	for _, route := range routes {
+		t.Run(route.method+" "+route.path, func(t *testing.T) {
+			req := httptest.NewRequest(route.method, route.path, nil)
+			req.Header.Set("X-Device-UUID", "some-device")
+			w := httptest.NewRecorder()
+
+			mux.ServeHTTP(w, req)
+
+			if w.Code != http.StatusGone {
+				t.Fatalf("Expected %d, got %d", http.StatusGone, w.Code)
+			}
+			var resp models.ErrorResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if resp.Code != models.ErrorCodeDeviceTrackingDisabled {
+				t.Errorf("Expected code %q, got %q", models.ErrorCodeDeviceTrackingDisabled, resp.Code)
+			}
+		})
+	}
+}
+
+func TestReadyzReportsSchemaVersion(t *testing.T) {
+	conn := testutil.SetupTestDB(t)
+	defer conn.Close()
+
+	cfg := testutil.GetTestConfig()
+	mux := NewRouter(conn, cfg)
+
+	readyz := func() (int, models.ReadinessResponse) {
+		req := httptest.NewRequest("GET", "/readyz", nil)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		var resp models.ReadinessResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return w.Code, resp
+	}
+
+	// A database that predates version tracking is reported as outdated
+	if _, err := conn.Exec(`DROP TABLE IF EXISTS schema_migrations`); err != nil {
+		t.Fatalf("Failed to drop schema_migrations: %v", err)
+	}
+	code, resp := readyz()
+	if code != http.StatusServiceUnavailable || resp.Status != models.ReadinessSchemaOutdated {
+		t.Errorf("Expected 503 schema_outdated, got %d %q", code, resp.Status)
+	}
+	if !resp.Database || resp.SchemaVersion != 0 {
+		t.Errorf("Expected a reachable database at version 0, got %+v", resp)
+	}
+
+	if err := schema.CreateSchema(conn); err != nil {
+		t.Fatalf("Failed to create schema: %v", err)
+	}
+	var applied int
+	if err := conn.QueryRow(`SELECT MAX(version) FROM schema_migrations`).Scan(&applied); err != nil {
+		t.Fatalf("Failed to read migrations: %v", err)
+	}
+	if applied != schema.SchemaVersion {
+		t.Errorf("Expected every step to be recorded up to %d, got %d", schema.SchemaVersion, applied)
+	}
+
+	code, resp = readyz()
+	if code != http.StatusOK || resp.Status != models.ReadinessReady {
+		t.Fatalf("Expected 200 ready, got %d %q", code, resp.Status)
+	}
+	if resp.SchemaVersion != applied {
+		t.Errorf("Expected schema_version %d to match the applied migrations, got %d", applied, resp.SchemaVersion)
+	}
+	if resp.ExpectedSchemaVersion != schema.SchemaVersion {
+		t.Errorf("Expected expected_schema_version %d, got %d", schema.SchemaVersion, resp.ExpectedSchemaVersion)
+	}
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	cfg := testutil.GetTestConfig()
+
+	for _, path := range []string{"/openapi.json", "/no-such-route"} {
+		req := httptest.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		NewRouter(nil, cfg).ServeHTTP(w, req)
+
+		if ct := w.Header().Get("Content-Type"); ct != "application/json; charset=utf-8" {
+			t.Errorf("%s: Expected Content-Type application/json; charset=utf-8, got %q", path, ct)
+		}
+		if got := w.Header().Get("X-Content-Type-Options"); got != "nosniff" {
+			t.Errorf("%s: Expected X-Content-Type-Options nosniff, got %q", path, got)
+		}
+		if got := w.Header().Get("Content-Security-Policy"); got != middleware.DefaultContentSecurityPolicy {
+			t.Errorf("%s: Expected the default Content-Security-Policy, got %q", path, got)
+		}
+		if got := w.Header().Get("Server"); got != "" {
+			t.Errorf("%s: Expected no Server header by default, got %q", path, got)
+		}
+	}
+
+	cfg.ServerHeader = "quickly-pick"
+	req := httptest.NewRequest("GET", "/openapi.json", nil)
+	w := httptest.NewRecorder()
+	NewRouter(nil, cfg).ServeHTTP(w, req)
+	if got := w.Header().Get("Server"); got != "quickly-pick" {
+		t.Errorf("Expected Server header %q, got %q", "quickly-pick", got)
+	}
+}